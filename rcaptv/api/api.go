@@ -30,13 +30,19 @@ import (
 // TODO: split vods and clips into their files
 //
 
-var ErrDbLocalClips = errors.New("Unexpected error while retrieving local clips")
+var (
+	ErrDbLocalClips      = errors.New("Unexpected error while retrieving local clips")
+	ErrTwitchUnavailable = errors.New("Twitch API is unavailable right now, showing only tracked clips")
+)
 
 type APIOpts struct {
 	Storage database.Storage
 
 	// Max difference between start/end for clips.
 	ClipsMaxPeriodDiffHours int
+	// AlignedSchedule is true if the tracker schedule is aligned to the wall
+	// clock and persisted, so the next refresh of a channel can be computed
+	AlignedSchedule bool
 
 	ClientID, ClientSecret string
 	HelixAPIUrl            string
@@ -48,9 +54,11 @@ type API struct {
 	hx *helix.Helix
 
 	clipsMaxPeriodDiffHours int
+	alignedSchedule         bool
 
 	sv       *fiber.App
 	passport *auth.Passport
+	feeds    feedCache
 }
 
 type ResultsMode string
@@ -131,8 +139,79 @@ func (a *API) Vods(c *fiber.Ctx) error {
 	return c.Status(http.StatusOK).JSON(resp)
 }
 
+type ChannelsResponse struct {
+	Channels []*repo.Channel `json:"channels"`
+	// NextOffset is the offset of the next page. Nil if the page wasn't full,
+	// meaning there are no more channels.
+	NextOffset *int `json:"next_offset"`
+}
+
+// Channels lists the tracked channels
+// - `search` string Username prefix, case insensitive
+// - `sort` string One of username, tracked_since, vod_count, clip_count,
+// last_vod_at. Defaults to username
+// - `order` string asc or desc. Defaults to asc when sorting by username and
+// desc otherwise
+// - `first` int Page size, max 100
+// - `offset` int
+func (a *API) Channels(c *fiber.Ctx) error {
+	resp := NewResponse(&ChannelsResponse{
+		Channels: make([]*repo.Channel, 0),
+	})
+	resp.Mode = ModeLocal
+
+	sort := repo.ChannelSort(c.Query("sort", string(repo.SortUsername)))
+	if !repo.ValidChannelSort(sort) {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("Invalid sort '%s'", sort))
+	}
+	desc := sort != repo.SortUsername
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		resp.Errors = append(resp.Errors, "Invalid order, must be 'asc' or 'desc'")
+	}
+	first, err := strconv.Atoi(c.Query("first", "20"))
+	if err != nil || first <= 0 || first > repo.MaxChannelsPageSize {
+		resp.Errors = append(resp.Errors,
+			fmt.Sprintf("Invalid first, must be between 1 and %d", repo.MaxChannelsPageSize))
+	}
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		resp.Errors = append(resp.Errors, "Invalid offset")
+	}
+	if len(resp.Errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	channels, err := repo.Channels(a.db, &repo.ChannelsParams{
+		Search:  c.Query("search"),
+		Sort:    sort,
+		Desc:    desc,
+		First:   first,
+		Offset:  offset,
+		Context: c.Context(),
+	})
+	if err != nil {
+		resp.Errors = append(resp.Errors, "Unexpected error")
+		return c.Status(http.StatusInternalServerError).JSON(resp)
+	}
+	if len(channels) == first {
+		next := offset + first
+		resp.Data.NextOffset = &next
+	}
+	resp.Data.Channels = channels
+	return c.Status(http.StatusOK).JSON(resp)
+}
+
 type ClipsResponse struct {
 	Clips []*helix.Clip `json:"clips"`
+	// Complete is false if clips above the views threshold may be missing in
+	// the period, because it has too many clips to fetch them all
+	Complete bool `json:"complete"`
 }
 
 // Clips
@@ -155,8 +234,19 @@ type ClipsResponse struct {
 // solution when user is logged in we fetch clips from twitch api, we retrieve
 // clips from database, deduplicate and merge them. On the frontend, clips
 // should be filtered by vod_offset=null and the corresponding video_id.
+//
+// While the Twitch API circuit breaker is open, logged in users get local
+// clips too, with mode=local.
+//
+// complete is false if some clips above the views threshold may be missing:
+// in hybrid mode if the time window could not be split enough to fetch them
+// all or the request timed out, in local mode if the tracker is yet to retry
+// some range of the period.
 func (a *API) Clips(c *fiber.Ctx) error {
 	if auth.IsLoggedIn(c) {
+		if a.hx.BreakerState() == helix.BreakerOpen {
+			return a.localClips(c, ErrTwitchUnavailable)
+		}
 		return a.hybridClips(c)
 	}
 	return a.localClips(c)
@@ -177,9 +267,12 @@ func (a *API) hybridClips(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.UserContext(), 15*time.Second)
 	defer cancel()
 	g, ctx := errgroup.WithContext(ctx)
+	// partial is set if the timeout was reached before every clip was fetched
+	// from the twitch api
+	partial, complete := false, false
 	// clips from twitch api
 	g.Go(func() error {
-		clips, err := a.hx.DeepClips(&helix.DeepClipsParams{
+		result, err := a.hx.DeepClips(&helix.DeepClipsParams{
 			ClipsParams: &helix.ClipsParams{
 				BroadcasterID:            params.bid,
 				StartedAt:                params.started,
@@ -189,12 +282,20 @@ func (a *API) hybridClips(c *fiber.Ctx) error {
 				SkipDeduplication:        true,
 				Context:                  ctx,
 			},
-			MaxDeepLvl: cfg.ClipTrackingMaxDeepLevel,
+			MaxDeepLvl:  cfg.ClipTrackingMaxDeepLevel,
+			Concurrency: cfg.ClipTrackingConcurrency,
 		})
+		var partialErr *helix.PartialClipsError
+		if errors.As(err, &partialErr) && errors.Is(err, context.DeadlineExceeded) {
+			// respond with the clips fetched before the timeout
+			partial = true
+			err = nil
+		}
 		if err != nil {
 			return err
 		}
-		res <- clips
+		complete = result.Complete()
+		res <- result.Clips
 		return nil
 	})
 	// clips from db, previously tracked with our tracker
@@ -212,7 +313,11 @@ func (a *API) hybridClips(c *fiber.Ctx) error {
 		res <- localClips
 		return nil
 	})
-	if err := a.checkErr(c, g.Wait()); err != nil {
+	err := g.Wait()
+	if errors.Is(err, helix.ErrCircuitOpen) {
+		return a.localClips(c, ErrTwitchUnavailable)
+	}
+	if err := a.checkErr(c, err); err != nil {
 		if errors.Is(err, helix.ErrItemsEmpty) {
 			resp.Errors = append(resp.Errors,
 				fmt.Sprintf("No clips found for the provided streamer (bid:'%s'). Are clips enabled for this streamer?",
@@ -232,6 +337,10 @@ func (a *API) hybridClips(c *fiber.Ctx) error {
 		resp.Errors = append(resp.Errors, "Unexpected error")
 		return c.JSON(resp)
 	}
+	if partial {
+		resp.Errors = append(resp.Errors, "Timeout when fetching clips in hybrid mode. Some clips may be missing.")
+	}
+	resp.Data.Complete = complete
 	for i := 0; i < 2; i++ {
 		clips := <-res
 		resp.Data.Clips = append(resp.Data.Clips, clips...)
@@ -250,10 +359,15 @@ func (a *API) hybridClips(c *fiber.Ctx) error {
 	return c.Status(http.StatusOK).JSON(resp)
 }
 
-func (a *API) localClips(c *fiber.Ctx) error {
+// localClips responds with the clips tracked in the database. notes are
+// added to the response errors, e.g. why the hybrid mode was not used.
+func (a *API) localClips(c *fiber.Ctx, notes ...error) error {
 	resp := NewResponse(new(ClipsResponse))
 	resp.Data.Clips = make([]*helix.Clip, 0, 1)
 	resp.Mode = ModeLocal
+	for _, n := range notes {
+		resp.Errors = append(resp.Errors, n.Error())
+	}
 
 	params, errs := a.getClipParams(c)
 	if len(errs) > 0 {
@@ -280,6 +394,17 @@ func (a *API) localClips(c *fiber.Ctx) error {
 		return c.Status(http.StatusNotFound).JSON(resp)
 	}
 	resp.Data.Clips = localClips
+	incomplete, err := repo.IncompleteClipRanges(a.db, &repo.IncompleteClipRangesParams{
+		BroadcasterID: params.bid,
+		StartedAt:     params.started,
+		EndedAt:       params.ended,
+		Context:       c.Context(),
+	})
+	if err != nil {
+		l := log.With().Str("ctx", "api").Logger()
+		l.Err(err).Msgf("failed to retrieve incomplete clip ranges (bid:%s)", params.bid)
+	}
+	resp.Data.Complete = err == nil && len(incomplete) == 0
 	return c.Status(http.StatusOK).JSON(resp)
 }
 
@@ -403,8 +528,8 @@ func (a *API) newServer() *fiber.App {
 	l.Info().Msgf("apisv: setting up cors (domains: %s)", origins)
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     origins,
-		AllowMethods:     "GET, POST, OPTIONS",
-		AllowHeaders:     "Origin, Content-Type, Accept",
+		AllowMethods:     "GET, POST, DELETE, OPTIONS",
+		AllowHeaders:     "Origin, Content-Type, Accept, If-None-Match",
 		AllowCredentials: true,
 	}))
 
@@ -412,17 +537,33 @@ func (a *API) newServer() *fiber.App {
 	app.Get(cfg.HealthEndpoint, func(c *fiber.Ctx) error {
 		return c.Status(http.StatusOK).Send([]byte("ok"))
 	})
+	app.Get(cfg.FeedsEndpoint+"/:username/:feed", a.Feed)
 	v1 := app.Group(cfg.APIEndpoint)
 	v1.Get(cfg.APIVodsEndpoint, a.Vods)
+	v1.Get(cfg.APIChannelsEndpoint, a.Channels)
+	v1.Get(cfg.APIChannelsEndpoint+"/:bid"+cfg.APIStatsEndpoint, a.ChannelStats)
+	v1.Get(cfg.APIChannelsEndpoint+"/:bid"+cfg.APIScheduleEndpoint, a.ChannelSchedule)
 
 	hx := v1.Group(cfg.APIHelixEndpoint, a.passport.WithAuth)
 	hx.Get(cfg.APIValidateEndpoint, a.passport.ValidateSession)
 	hx.Get(cfg.APIClipsEndpoint, a.Clips)
+	hx.Get(cfg.APIExportEndpoint+"/:kind", a.Export)
+	hx.Get(cfg.APIWebhooksEndpoint, a.Webhooks)
+	hx.Post(cfg.APIWebhooksEndpoint, a.CreateWebhook)
+	hx.Delete(cfg.APIWebhooksEndpoint+"/:id", a.DeleteWebhook)
+	hx.Post(cfg.APIWebhooksEndpoint+"/:id/enable", a.EnableWebhook)
+	hx.Get(cfg.APIWebhooksEndpoint+"/:id/deliveries", a.WebhookDeliveries)
 
 	l.Info().Msgf("apisv health: %s", cfg.HealthEndpoint)
+	l.Info().Msgf("apisv feeds: %s", cfg.FeedsEndpoint+"/:username/:feed")
 	l.Info().Msgf("apisv vods: %s", cfg.APIEndpoint+cfg.APIVodsEndpoint)
+	l.Info().Msgf("apisv channels: %s", cfg.APIEndpoint+cfg.APIChannelsEndpoint)
+	l.Info().Msgf("apisv channel stats: %s", cfg.APIEndpoint+cfg.APIChannelsEndpoint+"/:bid"+cfg.APIStatsEndpoint)
+	l.Info().Msgf("apisv channel schedule: %s", cfg.APIEndpoint+cfg.APIChannelsEndpoint+"/:bid"+cfg.APIScheduleEndpoint)
 	l.Info().Msgf("apisv validate: %s", cfg.APIEndpoint+cfg.APIHelixEndpoint+cfg.APIValidateEndpoint)
 	l.Info().Msgf("apisv clips: %s", cfg.APIEndpoint+cfg.APIHelixEndpoint+cfg.APIClipsEndpoint)
+	l.Info().Msgf("apisv export: %s", cfg.APIEndpoint+cfg.APIHelixEndpoint+cfg.APIExportEndpoint+"/:kind")
+	l.Info().Msgf("apisv webhooks: %s", cfg.APIEndpoint+cfg.APIHelixEndpoint+cfg.APIWebhooksEndpoint)
 	a.sv = app
 	return app
 }
@@ -443,6 +584,7 @@ func New(auth *auth.Passport, opts APIOpts) *API {
 			APIUrl: opts.HelixAPIUrl,
 		}),
 		clipsMaxPeriodDiffHours: opts.ClipsMaxPeriodDiffHours,
+		alignedSchedule:         opts.AlignedSchedule,
 	}
 	return api
 }
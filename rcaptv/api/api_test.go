@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -9,20 +11,27 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/gofiber/fiber/v2"
 	"github.com/nsf/jsondiff"
 
+	"pedro.to/rcaptv/auth"
+	"pedro.to/rcaptv/gen/tracker/public/model"
 	"pedro.to/rcaptv/helix"
+	"pedro.to/rcaptv/repo"
+	"pedro.to/rcaptv/scheduler"
 	"pedro.to/rcaptv/test"
 )
 
 var (
 	db            *sql.DB
 	respClipsJson = []byte(`{"data":[{"id":"VainShakingYakinikuAllenHuhu-Qi-dMVyhPUGuJSwX","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:53:07Z","creator_id":"106212238","creator_name":"RagerBomb","title":"JAJAJAJAJAJA WELO ","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/9SO_A0IvnTFcoT1BriUsFw/46977536044-offset-24336-preview-480x272.jpg","duration":26,"view_count":2403,"vod_offset":24312},{"id":"LivelyIronicStaplePeanutButterJellyTime-s8wN4d_iRj_h7JT1","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:45:42Z","creator_id":"186415580","creator_name":"senor_simio","title":"SkainPutereando","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/FtC1-LPMDeHf4kY_4eyU9Q/AT-cm%7CFtC1-LPMDeHf4kY_4eyU9Q-preview-480x272.jpg","duration":13.5,"view_count":1555,"vod_offset":23837},{"id":"HelplessShyTermiteHassaanChop-27ZCgBgsnWMEoKea","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:24:38Z","creator_id":"554249414","creator_name":"0jota0","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/DIsr2tu2QoAYLJ1jBKCnmg/46977536044-offset-1026-preview-480x272.jpg","duration":28,"view_count":1140,"vod_offset":1002},{"id":"BoredTiredFrogSuperVinlin-F7t0wDliOTQgi2zs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:50:51Z","creator_id":"241257498","creator_name":"AL3xSG_","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/q6zAPRe7dMA5Yjk0IIts7A/46977536044-offset-24200-preview-480x272.jpg","duration":26,"view_count":428,"vod_offset":24176},{"id":"DiligentCrispyMonkeyStoneLightning-6QIyNhVPxXOGBjHX","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:24:56Z","creator_id":"181528808","creator_name":"DNeverland","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/3NIsDOkCMjYVT8VzhgYx1w/46977536044-offset-1044-preview-480x272.jpg","duration":28,"view_count":353,"vod_offset":1020},{"id":"FancyArbitraryLemurNomNom-Se5QiZSFesQpBPaE","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T16:29:27Z","creator_id":"164052966","creator_name":"muphasa_","title":"la cama","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/2xhcBUnuUImUhXrEKQT2hA/AT-cm%7C2xhcBUnuUImUhXrEKQT2hA-preview-480x272.jpg","duration":19.7,"view_count":249,"vod_offset":4872},{"id":"OpenPlumpDugongCmonBruh-ty2YkBc_j_o9vkyg","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:33:15Z","creator_id":"453377434","creator_name":"deny_box","title":"Uy","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/_o4M8LctmJAXP4_6Pb5MoA/AT-cm%7C_o4M8LctmJAXP4_6Pb5MoA-preview-480x272.jpg","duration":13.4,"view_count":197,"vod_offset":8711},{"id":"ImpartialTameRadishKeepo-96xOc8uwzM11DHDO","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:58:21Z","creator_id":"480623333","creator_name":"arenass95","title":"TITITITIN","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/hIuWMPesTnPLsMzNdQxwzg/46977536044-offset-3050-preview-480x272.jpg","duration":28,"view_count":161,"vod_offset":3026},{"id":"OnerousDignifiedSageBudBlast-IHCxunhU61blOeFB","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:29:46Z","creator_id":"89501893","creator_name":"Don_Samus","title":":(","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/GfMWQCBwsbp4VOtW5TnhQQ/AT-cm%7CGfMWQCBwsbp4VOtW5TnhQQ-preview-480x272.jpg","duration":38.5,"view_count":143,"vod_offset":22897},{"id":"LazyTrappedYogurtKappaWealth-lVKhImTlCx9p5c5r","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:06:23Z","creator_id":"174732498","creator_name":"Arcaniine","title":"El kenkro esquizo no existe...  Kenkro esquizo:","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/XQaubZVSoSJk5QsfaeXOaw/AT-cm%7CXQaubZVSoSJk5QsfaeXOaw-preview-480x272.jpg","duration":10.8,"view_count":134,"vod_offset":21227},{"id":"TrappedOutstandingWaterDatBoi-rocFCspnvIT2jDcD","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:19:07Z","creator_id":"145900332","creator_name":"Leonel0614","title":"Por el orto 2023","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/exRHwq6HBQrKZ7yE9ow3Aw/AT-cm%7CexRHwq6HBQrKZ7yE9ow3Aw-preview-480x272.jpg","duration":5,"view_count":133,"vod_offset":29482},{"id":"ThirstyAgitatedCheddarTF2John-mR6ZhI9FLB7XwRxY","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:38:01Z","creator_id":"183681572","creator_name":"ByStrago","title":"YAMETE KUDASAI","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/Vicc1JkemYSPRSwe18EcWA/AT-cm%7CVicc1JkemYSPRSwe18EcWA-preview-480x272.jpg","duration":6.5,"view_count":87,"vod_offset":30618},{"id":"SuspiciousDullAniseArsonNoSexy-A08gHf5hkwFyMNpL","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:21:13Z","creator_id":"97225836","creator_name":"Vsfernandez29","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/un29agNPIB3-YZMBcjDaSw/46977536044-offset-22416-preview-480x272.jpg","duration":30,"view_count":69,"vod_offset":22392},{"id":"SleepyRudeTomatoKappaRoss-tNSP7YtloCPY7Mnq","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T20:44:42Z","creator_id":"101376231","creator_name":"HahaVids","title":"Se pica","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/XlpHvLTUj-2LZaAj2OzJ6g/46977536044-offset-20232-preview-480x272.jpg","duration":26,"view_count":67,"vod_offset":20208},{"id":"WanderingExuberantWrenDatBoi-FpEPi_IgbhrVMR9X","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:30:19Z","creator_id":"145293169","creator_name":"sovietik_","title":"360 MI NIÑO","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/3l-2wBaRD4vyIG4a1TEfVw/AT-cm%7C3l-2wBaRD4vyIG4a1TEfVw-preview-480x272.jpg","duration":7.7,"view_count":51,"vod_offset":26543},{"id":"SucculentProudMarrowPanicVis-skGfMRbMxVUCu-xy","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:00:50Z","creator_id":"515013566","creator_name":"D4KN0","title":"asjkkajjkasas","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/HlwPULJBnzEWzMVVArvscg/AT-cm%7CHlwPULJBnzEWzMVVArvscg-preview-480x272.jpg","duration":32.3,"view_count":51,"vod_offset":21169},{"id":"CautiousViscousSrirachaKeyboardCat-QrarxC4wtpzR_Pgs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:34:05Z","creator_id":"167206569","creator_name":"itsLyrian","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/Nc_hhEK2q8QuMLac0fz4LA/46977536044-offset-23194-preview-480x272.jpg","duration":26,"view_count":41,"vod_offset":23170},{"id":"FaithfulSingleOryxVoteNay-2wx9JR1lRIfP8Urw","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:36:57Z","creator_id":"59179902","creator_name":"Apoken_","title":"y se la pela","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/jL3rr9sMfSUdZUSHz_FK8A/AT-cm%7CjL3rr9sMfSUdZUSHz_FK8A-preview-480x272.jpg","duration":21.4,"view_count":29,"vod_offset":1744},{"id":"LongPiliableSangBuddhaBar-QPmbozZCqqZOjSV5","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:35:18Z","creator_id":"599850528","creator_name":"afri6","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/sydlH0D5m6M2QqqmTo0leQ/46977536044-offset-8866-preview-480x272.jpg","duration":28,"view_count":29,"vod_offset":8842},{"id":"BoredPlacidPicklesBCouch-TK_odDyG7399f-Uq","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:39:19Z","creator_id":"150027413","creator_name":"manuubb","title":"knekro se caga encima","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/1i3YO0yv6HQ8hu9_fX_lKg/AT-cm%7C1i3YO0yv6HQ8hu9_fX_lKg-preview-480x272.jpg","duration":5.2,"view_count":19,"vod_offset":27083},{"id":"AcceptableOddShrimpCharlieBitMe-cbZvmbIDd5LSo5Sg","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:24:48Z","creator_id":"111370378","creator_name":"DTE99","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/kXTxHnG2b5myA3eS0qOUoA/46977536044-offset-8236-preview-480x272.jpg","duration":26,"view_count":17,"vod_offset":8212},{"id":"DifficultHorribleHyenaOneHand-qAvs5TyXzR-I5bgG","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:32:15Z","creator_id":"252090554","creator_name":"xLykkos","title":"u can stop me man","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/iDVt-6gL7OU8l80fiOW4Bg/AT-cm%7CiDVt-6gL7OU8l80fiOW4Bg-preview-480x272.jpg","duration":25.2,"view_count":13,"vod_offset":26646},{"id":"ObservantColdMarjoramNinjaGrumpy-iFhdsmRyzsIbHBI2","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:34:34Z","creator_id":"468966674","creator_name":"arestenshi","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/C-XwB4hYXbJkcs7CGxwSNg/46977536044-offset-8822-preview-480x272.jpg","duration":26,"view_count":13,"vod_offset":8798},{"id":"RockyEnergeticDonkeyCharlieBitMe-DtHuBP6jUOuQCxHs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T16:52:50Z","creator_id":"196594651","creator_name":"GelatinaLatina","title":"uy va dios mio!!!","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/87iUmA7R7gT-o1DrkAsR7A/AT-cm%7C87iUmA7R7gT-o1DrkAsR7A-preview-480x272.jpg","duration":10.2,"view_count":11,"vod_offset":6311},{"id":"WanderingLittleShrewBleedPurple-iRR1-d8hQw_mabtL","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T20:14:38Z","creator_id":"526433364","creator_name":"Lillos8","title":".","game_id":"21779","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/VQq69YkUdfnfIUMP5mm7Sw/AT-cm%7CVQq69YkUdfnfIUMP5mm7Sw-preview-480x272.jpg","duration":11.7,"view_count":11,"vod_offset":18418},{"id":"FriendlyCourageousBunnySoBayed-trbgdal5ZIN51oT0","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T18:02:47Z","creator_id":"98385514","creator_name":"Neceo","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/FuQIM7a24HWpeWSDedaE9A/46977536044-offset-10516-preview-480x272.jpg","duration":26,"view_count":11,"vod_offset":10492},{"id":"KitschyHonorableQuailPogChamp-faoCKtkhzejEGB8A","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:39:30Z","creator_id":"275210834","creator_name":"rumo9","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/12VUI32qAirZIc3oxBgfAA/46977536044-offset-23518-preview-480x272.jpg","duration":26,"view_count":9,"vod_offset":23494},{"id":"DirtyAntsyRatWutFace-J3Zl0vrAyNT9d8Is","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:03:37Z","creator_id":"115348532","creator_name":"petehockxd","title":"ella quiere p*nga","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/pyitvCd2ZdL6wMjU_XfCLA/46977536044-offset-28564-preview-480x272.jpg","duration":28,"view_count":9,"vod_offset":28540},{"id":"WealthyImpossiblePoultryNotATK-56DwZyDToGToLUzM","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:59:47Z","creator_id":"245790334","creator_name":"K0rVuX_x","title":"unai","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/GVuB-Oeg8Gcg06Bk6C1MzA/AT-cm%7CGVuB-Oeg8Gcg06Bk6C1MzA-preview-480x272.jpg","duration":15.5,"view_count":9,"vod_offset":28316},{"id":"PowerfulSmoothRutabagaPipeHype-ZY5pNzNbjazSzzno","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:51:15Z","creator_id":"221050357","creator_name":"kikefiga","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/TlV6o2WOaTczW42s0s856A/46977536044-offset-2624-preview-480x272.jpg","duration":28,"view_count":7,"vod_offset":2600},{"id":"RelentlessHeartlessRaccoonDancingBaby-G5DrpOnCUddr3_4V","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:39:13Z","creator_id":"93340335","creator_name":"elvisjak1","title":"Me representa","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/VneUKRuQ66TpwlHXa9_-FQ/AT-cm%7CVneUKRuQ66TpwlHXa9_-FQ-preview-480x272.jpg","duration":20.2,"view_count":7,"vod_offset":9076},{"id":"BrightPleasantPoxPRChase-cM0fsvSTeHcBkD_C","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:49:00Z","creator_id":"230022270","creator_name":"delnorte89","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/s1o0Z3DNH7L0k3h3AZA_wg/46977536044-offset-2488-preview-480x272.jpg","duration":28,"view_count":7,"vod_offset":2464}],"pagination":{"cursor":""}}`)
-	wantClipsJson = []byte(`{"data":{"clips":[{"id":"VainShakingYakinikuAllenHuhu-Qi-dMVyhPUGuJSwX","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:53:07Z","creator_id":"106212238","creator_name":"RagerBomb","title":"JAJAJAJAJAJA WELO ","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/9SO_A0IvnTFcoT1BriUsFw/46977536044-offset-24336-preview-480x272.jpg","duration":26,"view_count":2403,"vod_offset":24312},{"id":"LivelyIronicStaplePeanutButterJellyTime-s8wN4d_iRj_h7JT1","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:45:42Z","creator_id":"186415580","creator_name":"senor_simio","title":"SkainPutereando","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/FtC1-LPMDeHf4kY_4eyU9Q/AT-cm%7CFtC1-LPMDeHf4kY_4eyU9Q-preview-480x272.jpg","duration":13.5,"view_count":1555,"vod_offset":23837},{"id":"HelplessShyTermiteHassaanChop-27ZCgBgsnWMEoKea","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:24:38Z","creator_id":"554249414","creator_name":"0jota0","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/DIsr2tu2QoAYLJ1jBKCnmg/46977536044-offset-1026-preview-480x272.jpg","duration":28,"view_count":1140,"vod_offset":1002},{"id":"BoredTiredFrogSuperVinlin-F7t0wDliOTQgi2zs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:50:51Z","creator_id":"241257498","creator_name":"AL3xSG_","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/q6zAPRe7dMA5Yjk0IIts7A/46977536044-offset-24200-preview-480x272.jpg","duration":26,"view_count":428,"vod_offset":24176},{"id":"DiligentCrispyMonkeyStoneLightning-6QIyNhVPxXOGBjHX","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:24:56Z","creator_id":"181528808","creator_name":"DNeverland","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/3NIsDOkCMjYVT8VzhgYx1w/46977536044-offset-1044-preview-480x272.jpg","duration":28,"view_count":353,"vod_offset":1020},{"id":"FancyArbitraryLemurNomNom-Se5QiZSFesQpBPaE","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T16:29:27Z","creator_id":"164052966","creator_name":"muphasa_","title":"la cama","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/2xhcBUnuUImUhXrEKQT2hA/AT-cm%7C2xhcBUnuUImUhXrEKQT2hA-preview-480x272.jpg","duration":19.7,"view_count":249,"vod_offset":4872},{"id":"OpenPlumpDugongCmonBruh-ty2YkBc_j_o9vkyg","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:33:15Z","creator_id":"453377434","creator_name":"deny_box","title":"Uy","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/_o4M8LctmJAXP4_6Pb5MoA/AT-cm%7C_o4M8LctmJAXP4_6Pb5MoA-preview-480x272.jpg","duration":13.4,"view_count":197,"vod_offset":8711},{"id":"ImpartialTameRadishKeepo-96xOc8uwzM11DHDO","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:58:21Z","creator_id":"480623333","creator_name":"arenass95","title":"TITITITIN","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/hIuWMPesTnPLsMzNdQxwzg/46977536044-offset-3050-preview-480x272.jpg","duration":28,"view_count":161,"vod_offset":3026},{"id":"OnerousDignifiedSageBudBlast-IHCxunhU61blOeFB","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:29:46Z","creator_id":"89501893","creator_name":"Don_Samus","title":":(","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/GfMWQCBwsbp4VOtW5TnhQQ/AT-cm%7CGfMWQCBwsbp4VOtW5TnhQQ-preview-480x272.jpg","duration":38.5,"view_count":143,"vod_offset":22897},{"id":"LazyTrappedYogurtKappaWealth-lVKhImTlCx9p5c5r","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:06:23Z","creator_id":"174732498","creator_name":"Arcaniine","title":"El kenkro esquizo no existe...  Kenkro esquizo:","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/XQaubZVSoSJk5QsfaeXOaw/AT-cm%7CXQaubZVSoSJk5QsfaeXOaw-preview-480x272.jpg","duration":10.8,"view_count":134,"vod_offset":21227},{"id":"TrappedOutstandingWaterDatBoi-rocFCspnvIT2jDcD","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:19:07Z","creator_id":"145900332","creator_name":"Leonel0614","title":"Por el orto 2023","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/exRHwq6HBQrKZ7yE9ow3Aw/AT-cm%7CexRHwq6HBQrKZ7yE9ow3Aw-preview-480x272.jpg","duration":5,"view_count":133,"vod_offset":29482},{"id":"ThirstyAgitatedCheddarTF2John-mR6ZhI9FLB7XwRxY","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:38:01Z","creator_id":"183681572","creator_name":"ByStrago","title":"YAMETE KUDASAI","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/Vicc1JkemYSPRSwe18EcWA/AT-cm%7CVicc1JkemYSPRSwe18EcWA-preview-480x272.jpg","duration":6.5,"view_count":87,"vod_offset":30618},{"id":"SuspiciousDullAniseArsonNoSexy-A08gHf5hkwFyMNpL","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:21:13Z","creator_id":"97225836","creator_name":"Vsfernandez29","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/un29agNPIB3-YZMBcjDaSw/46977536044-offset-22416-preview-480x272.jpg","duration":30,"view_count":69,"vod_offset":22392},{"id":"SleepyRudeTomatoKappaRoss-tNSP7YtloCPY7Mnq","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T20:44:42Z","creator_id":"101376231","creator_name":"HahaVids","title":"Se pica","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/XlpHvLTUj-2LZaAj2OzJ6g/46977536044-offset-20232-preview-480x272.jpg","duration":26,"view_count":67,"vod_offset":20208},{"id":"WanderingExuberantWrenDatBoi-FpEPi_IgbhrVMR9X","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:30:19Z","creator_id":"145293169","creator_name":"sovietik_","title":"360 MI NIÑO","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/3l-2wBaRD4vyIG4a1TEfVw/AT-cm%7C3l-2wBaRD4vyIG4a1TEfVw-preview-480x272.jpg","duration":7.7,"view_count":51,"vod_offset":26543},{"id":"SucculentProudMarrowPanicVis-skGfMRbMxVUCu-xy","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:00:50Z","creator_id":"515013566","creator_name":"D4KN0","title":"asjkkajjkasas","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/HlwPULJBnzEWzMVVArvscg/AT-cm%7CHlwPULJBnzEWzMVVArvscg-preview-480x272.jpg","duration":32.3,"view_count":51,"vod_offset":21169},{"id":"CautiousViscousSrirachaKeyboardCat-QrarxC4wtpzR_Pgs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:34:05Z","creator_id":"167206569","creator_name":"itsLyrian","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/Nc_hhEK2q8QuMLac0fz4LA/46977536044-offset-23194-preview-480x272.jpg","duration":26,"view_count":41,"vod_offset":23170},{"id":"FaithfulSingleOryxVoteNay-2wx9JR1lRIfP8Urw","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:36:57Z","creator_id":"59179902","creator_name":"Apoken_","title":"y se la pela","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/jL3rr9sMfSUdZUSHz_FK8A/AT-cm%7CjL3rr9sMfSUdZUSHz_FK8A-preview-480x272.jpg","duration":21.4,"view_count":29,"vod_offset":1744},{"id":"LongPiliableSangBuddhaBar-QPmbozZCqqZOjSV5","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:35:18Z","creator_id":"599850528","creator_name":"afri6","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/sydlH0D5m6M2QqqmTo0leQ/46977536044-offset-8866-preview-480x272.jpg","duration":28,"view_count":29,"vod_offset":8842},{"id":"BoredPlacidPicklesBCouch-TK_odDyG7399f-Uq","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:39:19Z","creator_id":"150027413","creator_name":"manuubb","title":"knekro se caga encima","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/1i3YO0yv6HQ8hu9_fX_lKg/AT-cm%7C1i3YO0yv6HQ8hu9_fX_lKg-preview-480x272.jpg","duration":5.2,"view_count":19,"vod_offset":27083},{"id":"AcceptableOddShrimpCharlieBitMe-cbZvmbIDd5LSo5Sg","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:24:48Z","creator_id":"111370378","creator_name":"DTE99","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/kXTxHnG2b5myA3eS0qOUoA/46977536044-offset-8236-preview-480x272.jpg","duration":26,"view_count":17,"vod_offset":8212},{"id":"DifficultHorribleHyenaOneHand-qAvs5TyXzR-I5bgG","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:32:15Z","creator_id":"252090554","creator_name":"xLykkos","title":"u can stop me man","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/iDVt-6gL7OU8l80fiOW4Bg/AT-cm%7CiDVt-6gL7OU8l80fiOW4Bg-preview-480x272.jpg","duration":25.2,"view_count":13,"vod_offset":26646},{"id":"ObservantColdMarjoramNinjaGrumpy-iFhdsmRyzsIbHBI2","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:34:34Z","creator_id":"468966674","creator_name":"arestenshi","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/C-XwB4hYXbJkcs7CGxwSNg/46977536044-offset-8822-preview-480x272.jpg","duration":26,"view_count":13,"vod_offset":8798},{"id":"RockyEnergeticDonkeyCharlieBitMe-DtHuBP6jUOuQCxHs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T16:52:50Z","creator_id":"196594651","creator_name":"GelatinaLatina","title":"uy va dios mio!!!","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/87iUmA7R7gT-o1DrkAsR7A/AT-cm%7C87iUmA7R7gT-o1DrkAsR7A-preview-480x272.jpg","duration":10.2,"view_count":11,"vod_offset":6311},{"id":"WanderingLittleShrewBleedPurple-iRR1-d8hQw_mabtL","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T20:14:38Z","creator_id":"526433364","creator_name":"Lillos8","title":".","game_id":"21779","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/VQq69YkUdfnfIUMP5mm7Sw/AT-cm%7CVQq69YkUdfnfIUMP5mm7Sw-preview-480x272.jpg","duration":11.7,"view_count":11,"vod_offset":18418},{"id":"FriendlyCourageousBunnySoBayed-trbgdal5ZIN51oT0","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T18:02:47Z","creator_id":"98385514","creator_name":"Neceo","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/FuQIM7a24HWpeWSDedaE9A/46977536044-offset-10516-preview-480x272.jpg","duration":26,"view_count":11,"vod_offset":10492},{"id":"KitschyHonorableQuailPogChamp-faoCKtkhzejEGB8A","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:39:30Z","creator_id":"275210834","creator_name":"rumo9","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/12VUI32qAirZIc3oxBgfAA/46977536044-offset-23518-preview-480x272.jpg","duration":26,"view_count":9,"vod_offset":23494},{"id":"DirtyAntsyRatWutFace-J3Zl0vrAyNT9d8Is","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:03:37Z","creator_id":"115348532","creator_name":"petehockxd","title":"ella quiere p*nga","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/pyitvCd2ZdL6wMjU_XfCLA/46977536044-offset-28564-preview-480x272.jpg","duration":28,"view_count":9,"vod_offset":28540},{"id":"WealthyImpossiblePoultryNotATK-56DwZyDToGToLUzM","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:59:47Z","creator_id":"245790334","creator_name":"K0rVuX_x","title":"unai","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/GVuB-Oeg8Gcg06Bk6C1MzA/AT-cm%7CGVuB-Oeg8Gcg06Bk6C1MzA-preview-480x272.jpg","duration":15.5,"view_count":9,"vod_offset":28316},{"id":"PowerfulSmoothRutabagaPipeHype-ZY5pNzNbjazSzzno","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:51:15Z","creator_id":"221050357","creator_name":"kikefiga","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/TlV6o2WOaTczW42s0s856A/46977536044-offset-2624-preview-480x272.jpg","duration":28,"view_count":7,"vod_offset":2600},{"id":"RelentlessHeartlessRaccoonDancingBaby-G5DrpOnCUddr3_4V","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:39:13Z","creator_id":"93340335","creator_name":"elvisjak1","title":"Me representa","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/VneUKRuQ66TpwlHXa9_-FQ/AT-cm%7CVneUKRuQ66TpwlHXa9_-FQ-preview-480x272.jpg","duration":20.2,"view_count":7,"vod_offset":9076},{"id":"BrightPleasantPoxPRChase-cM0fsvSTeHcBkD_C","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:49:00Z","creator_id":"230022270","creator_name":"delnorte89","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/s1o0Z3DNH7L0k3h3AZA_wg/46977536044-offset-2488-preview-480x272.jpg","duration":28,"view_count":7,"vod_offset":2464}]},"errors":[],"mode":"hybrid"}`)
+	wantClipsJson = []byte(`{"data":{"clips":[{"id":"VainShakingYakinikuAllenHuhu-Qi-dMVyhPUGuJSwX","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:53:07Z","creator_id":"106212238","creator_name":"RagerBomb","title":"JAJAJAJAJAJA WELO ","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/9SO_A0IvnTFcoT1BriUsFw/46977536044-offset-24336-preview-480x272.jpg","duration":26,"view_count":2403,"vod_offset":24312},{"id":"LivelyIronicStaplePeanutButterJellyTime-s8wN4d_iRj_h7JT1","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:45:42Z","creator_id":"186415580","creator_name":"senor_simio","title":"SkainPutereando","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/FtC1-LPMDeHf4kY_4eyU9Q/AT-cm%7CFtC1-LPMDeHf4kY_4eyU9Q-preview-480x272.jpg","duration":13.5,"view_count":1555,"vod_offset":23837},{"id":"HelplessShyTermiteHassaanChop-27ZCgBgsnWMEoKea","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:24:38Z","creator_id":"554249414","creator_name":"0jota0","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/DIsr2tu2QoAYLJ1jBKCnmg/46977536044-offset-1026-preview-480x272.jpg","duration":28,"view_count":1140,"vod_offset":1002},{"id":"BoredTiredFrogSuperVinlin-F7t0wDliOTQgi2zs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:50:51Z","creator_id":"241257498","creator_name":"AL3xSG_","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/q6zAPRe7dMA5Yjk0IIts7A/46977536044-offset-24200-preview-480x272.jpg","duration":26,"view_count":428,"vod_offset":24176},{"id":"DiligentCrispyMonkeyStoneLightning-6QIyNhVPxXOGBjHX","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:24:56Z","creator_id":"181528808","creator_name":"DNeverland","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/3NIsDOkCMjYVT8VzhgYx1w/46977536044-offset-1044-preview-480x272.jpg","duration":28,"view_count":353,"vod_offset":1020},{"id":"FancyArbitraryLemurNomNom-Se5QiZSFesQpBPaE","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T16:29:27Z","creator_id":"164052966","creator_name":"muphasa_","title":"la cama","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/2xhcBUnuUImUhXrEKQT2hA/AT-cm%7C2xhcBUnuUImUhXrEKQT2hA-preview-480x272.jpg","duration":19.7,"view_count":249,"vod_offset":4872},{"id":"OpenPlumpDugongCmonBruh-ty2YkBc_j_o9vkyg","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:33:15Z","creator_id":"453377434","creator_name":"deny_box","title":"Uy","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/_o4M8LctmJAXP4_6Pb5MoA/AT-cm%7C_o4M8LctmJAXP4_6Pb5MoA-preview-480x272.jpg","duration":13.4,"view_count":197,"vod_offset":8711},{"id":"ImpartialTameRadishKeepo-96xOc8uwzM11DHDO","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:58:21Z","creator_id":"480623333","creator_name":"arenass95","title":"TITITITIN","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/hIuWMPesTnPLsMzNdQxwzg/46977536044-offset-3050-preview-480x272.jpg","duration":28,"view_count":161,"vod_offset":3026},{"id":"OnerousDignifiedSageBudBlast-IHCxunhU61blOeFB","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:29:46Z","creator_id":"89501893","creator_name":"Don_Samus","title":":(","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/GfMWQCBwsbp4VOtW5TnhQQ/AT-cm%7CGfMWQCBwsbp4VOtW5TnhQQ-preview-480x272.jpg","duration":38.5,"view_count":143,"vod_offset":22897},{"id":"LazyTrappedYogurtKappaWealth-lVKhImTlCx9p5c5r","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:06:23Z","creator_id":"174732498","creator_name":"Arcaniine","title":"El kenkro esquizo no existe...  Kenkro esquizo:","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/XQaubZVSoSJk5QsfaeXOaw/AT-cm%7CXQaubZVSoSJk5QsfaeXOaw-preview-480x272.jpg","duration":10.8,"view_count":134,"vod_offset":21227},{"id":"TrappedOutstandingWaterDatBoi-rocFCspnvIT2jDcD","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:19:07Z","creator_id":"145900332","creator_name":"Leonel0614","title":"Por el orto 2023","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/exRHwq6HBQrKZ7yE9ow3Aw/AT-cm%7CexRHwq6HBQrKZ7yE9ow3Aw-preview-480x272.jpg","duration":5,"view_count":133,"vod_offset":29482},{"id":"ThirstyAgitatedCheddarTF2John-mR6ZhI9FLB7XwRxY","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:38:01Z","creator_id":"183681572","creator_name":"ByStrago","title":"YAMETE KUDASAI","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/Vicc1JkemYSPRSwe18EcWA/AT-cm%7CVicc1JkemYSPRSwe18EcWA-preview-480x272.jpg","duration":6.5,"view_count":87,"vod_offset":30618},{"id":"SuspiciousDullAniseArsonNoSexy-A08gHf5hkwFyMNpL","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:21:13Z","creator_id":"97225836","creator_name":"Vsfernandez29","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/un29agNPIB3-YZMBcjDaSw/46977536044-offset-22416-preview-480x272.jpg","duration":30,"view_count":69,"vod_offset":22392},{"id":"SleepyRudeTomatoKappaRoss-tNSP7YtloCPY7Mnq","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T20:44:42Z","creator_id":"101376231","creator_name":"HahaVids","title":"Se pica","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/XlpHvLTUj-2LZaAj2OzJ6g/46977536044-offset-20232-preview-480x272.jpg","duration":26,"view_count":67,"vod_offset":20208},{"id":"WanderingExuberantWrenDatBoi-FpEPi_IgbhrVMR9X","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:30:19Z","creator_id":"145293169","creator_name":"sovietik_","title":"360 MI NIÑO","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/3l-2wBaRD4vyIG4a1TEfVw/AT-cm%7C3l-2wBaRD4vyIG4a1TEfVw-preview-480x272.jpg","duration":7.7,"view_count":51,"vod_offset":26543},{"id":"SucculentProudMarrowPanicVis-skGfMRbMxVUCu-xy","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:00:50Z","creator_id":"515013566","creator_name":"D4KN0","title":"asjkkajjkasas","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/HlwPULJBnzEWzMVVArvscg/AT-cm%7CHlwPULJBnzEWzMVVArvscg-preview-480x272.jpg","duration":32.3,"view_count":51,"vod_offset":21169},{"id":"CautiousViscousSrirachaKeyboardCat-QrarxC4wtpzR_Pgs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:34:05Z","creator_id":"167206569","creator_name":"itsLyrian","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/Nc_hhEK2q8QuMLac0fz4LA/46977536044-offset-23194-preview-480x272.jpg","duration":26,"view_count":41,"vod_offset":23170},{"id":"FaithfulSingleOryxVoteNay-2wx9JR1lRIfP8Urw","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:36:57Z","creator_id":"59179902","creator_name":"Apoken_","title":"y se la pela","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/jL3rr9sMfSUdZUSHz_FK8A/AT-cm%7CjL3rr9sMfSUdZUSHz_FK8A-preview-480x272.jpg","duration":21.4,"view_count":29,"vod_offset":1744},{"id":"LongPiliableSangBuddhaBar-QPmbozZCqqZOjSV5","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:35:18Z","creator_id":"599850528","creator_name":"afri6","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/sydlH0D5m6M2QqqmTo0leQ/46977536044-offset-8866-preview-480x272.jpg","duration":28,"view_count":29,"vod_offset":8842},{"id":"BoredPlacidPicklesBCouch-TK_odDyG7399f-Uq","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:39:19Z","creator_id":"150027413","creator_name":"manuubb","title":"knekro se caga encima","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/1i3YO0yv6HQ8hu9_fX_lKg/AT-cm%7C1i3YO0yv6HQ8hu9_fX_lKg-preview-480x272.jpg","duration":5.2,"view_count":19,"vod_offset":27083},{"id":"AcceptableOddShrimpCharlieBitMe-cbZvmbIDd5LSo5Sg","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:24:48Z","creator_id":"111370378","creator_name":"DTE99","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/kXTxHnG2b5myA3eS0qOUoA/46977536044-offset-8236-preview-480x272.jpg","duration":26,"view_count":17,"vod_offset":8212},{"id":"DifficultHorribleHyenaOneHand-qAvs5TyXzR-I5bgG","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:32:15Z","creator_id":"252090554","creator_name":"xLykkos","title":"u can stop me man","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/iDVt-6gL7OU8l80fiOW4Bg/AT-cm%7CiDVt-6gL7OU8l80fiOW4Bg-preview-480x272.jpg","duration":25.2,"view_count":13,"vod_offset":26646},{"id":"ObservantColdMarjoramNinjaGrumpy-iFhdsmRyzsIbHBI2","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:34:34Z","creator_id":"468966674","creator_name":"arestenshi","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/C-XwB4hYXbJkcs7CGxwSNg/46977536044-offset-8822-preview-480x272.jpg","duration":26,"view_count":13,"vod_offset":8798},{"id":"RockyEnergeticDonkeyCharlieBitMe-DtHuBP6jUOuQCxHs","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T16:52:50Z","creator_id":"196594651","creator_name":"GelatinaLatina","title":"uy va dios mio!!!","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/87iUmA7R7gT-o1DrkAsR7A/AT-cm%7C87iUmA7R7gT-o1DrkAsR7A-preview-480x272.jpg","duration":10.2,"view_count":11,"vod_offset":6311},{"id":"WanderingLittleShrewBleedPurple-iRR1-d8hQw_mabtL","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T20:14:38Z","creator_id":"526433364","creator_name":"Lillos8","title":".","game_id":"21779","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/VQq69YkUdfnfIUMP5mm7Sw/AT-cm%7CVQq69YkUdfnfIUMP5mm7Sw-preview-480x272.jpg","duration":11.7,"view_count":11,"vod_offset":18418},{"id":"FriendlyCourageousBunnySoBayed-trbgdal5ZIN51oT0","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T18:02:47Z","creator_id":"98385514","creator_name":"Neceo","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/FuQIM7a24HWpeWSDedaE9A/46977536044-offset-10516-preview-480x272.jpg","duration":26,"view_count":11,"vod_offset":10492},{"id":"KitschyHonorableQuailPogChamp-faoCKtkhzejEGB8A","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T21:39:30Z","creator_id":"275210834","creator_name":"rumo9","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/12VUI32qAirZIc3oxBgfAA/46977536044-offset-23518-preview-480x272.jpg","duration":26,"view_count":9,"vod_offset":23494},{"id":"DirtyAntsyRatWutFace-J3Zl0vrAyNT9d8Is","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T23:03:37Z","creator_id":"115348532","creator_name":"petehockxd","title":"ella quiere p*nga","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/pyitvCd2ZdL6wMjU_XfCLA/46977536044-offset-28564-preview-480x272.jpg","duration":28,"view_count":9,"vod_offset":28540},{"id":"WealthyImpossiblePoultryNotATK-56DwZyDToGToLUzM","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T22:59:47Z","creator_id":"245790334","creator_name":"K0rVuX_x","title":"unai","game_id":"491487","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/GVuB-Oeg8Gcg06Bk6C1MzA/AT-cm%7CGVuB-Oeg8Gcg06Bk6C1MzA-preview-480x272.jpg","duration":15.5,"view_count":9,"vod_offset":28316},{"id":"PowerfulSmoothRutabagaPipeHype-ZY5pNzNbjazSzzno","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:51:15Z","creator_id":"221050357","creator_name":"kikefiga","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/TlV6o2WOaTczW42s0s856A/46977536044-offset-2624-preview-480x272.jpg","duration":28,"view_count":7,"vod_offset":2600},{"id":"RelentlessHeartlessRaccoonDancingBaby-G5DrpOnCUddr3_4V","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T17:39:13Z","creator_id":"93340335","creator_name":"elvisjak1","title":"Me representa","game_id":"509658","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/VneUKRuQ66TpwlHXa9_-FQ/AT-cm%7CVneUKRuQ66TpwlHXa9_-FQ-preview-480x272.jpg","duration":20.2,"view_count":7,"vod_offset":9076},{"id":"BrightPleasantPoxPRChase-cM0fsvSTeHcBkD_C","broadcaster_id":"152633332","video_id":"1856303227","created_at":"2023-06-26T15:49:00Z","creator_id":"230022270","creator_name":"delnorte89","title":"ONLY CUAJADO| Empezamos la semana sin tilteos  | !eneba","game_id":"245018539","language":"es","thumbnail_url":"https://clips-media-assets2.twitch.tv/s1o0Z3DNH7L0k3h3AZA_wg/46977536044-offset-2488-preview-480x272.jpg","duration":28,"view_count":7,"vod_offset":2464}],"complete":true},"errors":[],"mode":"hybrid"}`)
 )
 
 func TestMain(m *testing.M) {
@@ -284,9 +293,116 @@ func TestClips(t *testing.T) {
 	}
 }
 
+// TestClipsReplay fetches the clips of a channel in hybrid mode from the
+// responses recorded in a cassette. Record it again with
+// HELIX_CASSETTE_MODE=record.
+func TestClipsReplay(t *testing.T) {
+	t.Parallel()
+	bid := test.FakeChannelID
+	hx, c, fake := test.ReplayHelix(t, "testdata/cassettes/clips.json")
+	api := &API{
+		db:                      db,
+		hx:                      hx,
+		clipsMaxPeriodDiffHours: 168,
+	}
+
+	app := fiber.New()
+	app.Get("/clips", api.hybridClips)
+
+	params := url.Values{}
+	params.Add("bid", bid)
+	params.Add("started_at", test.ReplayNow.AddDate(0, 0, -1).Format(time.RFC3339))
+	params.Add("ended_at", test.ReplayNow.Format(time.RFC3339))
+	resp, err := app.Test(httptest.NewRequest("GET", "/clips?"+params.Encode(), nil), 10*1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected http 200, got %d", resp.StatusCode)
+	}
+	var got APIResponse[ClipsResponse]
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if fake != nil {
+		// recording, the responses are checked when replaying them
+		return
+	}
+	if got.Mode != ModeHybrid || len(got.Errors) > 0 {
+		t.Fatalf("expected hybrid clips without errors, got %s %v", got.Mode, got.Errors)
+	}
+	test.CheckReplayedClips(t, c, bid, got.Data.Clips)
+}
+
+// TestVodsReplay stores the VODs of a channel recorded in a cassette, as the
+// tracker does, and requests them
+func TestVodsReplay(t *testing.T) {
+	t.Parallel()
+	bid := test.FakeChannelID
+	hx, c, fake := test.ReplayHelix(t, "testdata/cassettes/vods.json")
+	vods, err := hx.Vods(&helix.VODParams{
+		BroadcasterID: bid,
+		Period:        helix.Week,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake != nil {
+		// recording, the responses are checked when replaying them
+		return
+	}
+	recorded := test.Recorded[*helix.VOD](t, c, "/videos", "")[0]
+	if len(vods) != len(recorded) {
+		t.Fatalf("expected %d vods, got %d", len(recorded), len(vods))
+	}
+	// only two of them, the channels of the test database must have the most
+	// VODs for TestChannels
+	vods = vods[:2]
+	if _, err := db.Exec(`INSERT INTO tracked_channels (bc_id, bc_display_name, bc_username, bc_type)
+		VALUES ($1, 'Channel_2', 'channel_2', 'partner')`, bid); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpsertVods(db, vods); err != nil {
+		t.Fatal(err)
+	}
+
+	api := &API{
+		db: db,
+	}
+	app := fiber.New()
+	app.Get("/vods", api.Vods)
+
+	params := url.Values{}
+	params.Add("username", "Channel_2")
+	params.Add("extend", "1")
+	resp, err := app.Test(httptest.NewRequest("GET", "/vods?"+params.Encode(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected http 200, got %d", resp.StatusCode)
+	}
+	var got APIResponse[VodsResponse]
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Data.Vods) != len(vods) {
+		t.Fatalf("expected %d vods, got %d", len(vods), len(got.Data.Vods))
+	}
+	for i, v := range got.Data.Vods {
+		want := recorded[i]
+		if err := helix.ParseVODDurations([]*helix.VOD{want}); err != nil {
+			t.Fatal(err)
+		}
+		if v.VideoID != want.VideoID || v.Duration != want.Duration || !v.CreatedAt.Equal(want.CreatedAt) || v.Title != want.Title {
+			t.Fatalf("expected vod %d to be %+v, got %+v", i, want, v)
+		}
+	}
+}
+
 func TestClipsPeriodTooLarge(t *testing.T) {
 	t.Parallel()
-	wantJson := []byte(`{"data":{"clips":[]},"errors":["period between 'started_at' and 'ended_at' is too large"],"mode":"hybrid"}`)
+	wantJson := []byte(`{"data":{"clips":[],"complete":false},"errors":["period between 'started_at' and 'ended_at' is too large"],"mode":"hybrid"}`)
 	bid := "152633332"
 	start := "2023-06-18T00:46:30Z"
 	end := "2023-06-26T15:07:30Z"
@@ -346,7 +462,7 @@ func TestClipsPeriodTooLarge(t *testing.T) {
 
 func TestClipsEmpty(t *testing.T) {
 	t.Parallel()
-	wantJson := []byte(`{"data":{"clips":[]},"errors":["Missing bid","Missing started_at","Missing ended_at","Invalid 'started_at'","Invalid 'ended_at'"],"mode":"hybrid"}`)
+	wantJson := []byte(`{"data":{"clips":[],"complete":false},"errors":["Missing bid","Missing started_at","Missing ended_at","Invalid 'started_at'","Invalid 'ended_at'"],"mode":"hybrid"}`)
 
 	api := &API{
 		db: db,
@@ -382,7 +498,7 @@ func TestClipsEmpty(t *testing.T) {
 func TestClipsUnknownBID(t *testing.T) {
 	t.Parallel()
 	clipsJson := []byte(`{"data":[],"pagination":{}}`)
-	wantJson := []byte(`{"data":{"clips":[]},"errors":["No clips found for the provided streamer (bid:'1234'). Are clips enabled for this streamer?"],"mode":"hybrid"}`)
+	wantJson := []byte(`{"data":{"clips":[],"complete":false},"errors":["No clips found for the provided streamer (bid:'1234'). Are clips enabled for this streamer?"],"mode":"hybrid"}`)
 
 	bid := "1234"
 	start := "2023-06-18T00:46:30Z"
@@ -437,3 +553,383 @@ func TestClipsUnknownBID(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestClipsTwitchUnavailable(t *testing.T) {
+	t.Parallel()
+	var reqs int
+	sv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
+		reqs++
+		resp.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer sv.Close()
+	hx := helix.NewWithoutExchange(&helix.HelixOpts{
+		APIUrl:      sv.URL,
+		RetryPolicy: &helix.RetryPolicy{MaxAttempts: 3},
+		Breaker: &helix.BreakerOpts{
+			Window:            time.Minute,
+			MinRequests:       1,
+			FailureRate:       0.5,
+			OpenTimeout:       time.Minute,
+			HalfOpenSuccesses: 1,
+		},
+	}, sv.Client())
+	api := &API{
+		db:                      db,
+		hx:                      hx,
+		clipsMaxPeriodDiffHours: 168,
+	}
+
+	app := fiber.New()
+	app.Get("/clips", api.hybridClips)
+	params := url.Values{}
+	params.Add("bid", "152633332")
+	params.Add("started_at", "2023-06-26T15:07:30Z")
+	params.Add("ended_at", "2023-06-27T00:46:30Z")
+	req := httptest.NewRequest(
+		"GET",
+		fmt.Sprintf("/clips?%s", params.Encode()),
+		nil,
+	)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got APIResponse[*ClipsResponse]
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode != ModeLocal {
+		t.Fatalf("expected fallback to %s mode, got %s", ModeLocal, got.Mode)
+	}
+	if len(got.Errors) == 0 || got.Errors[0] != ErrTwitchUnavailable.Error() {
+		t.Fatalf("expected %q error, got %v", ErrTwitchUnavailable, got.Errors)
+	}
+	// the breaker opened after the first attempt
+	if reqs != 1 {
+		t.Fatalf("expected a single request to twitch, got %d", reqs)
+	}
+	if s := hx.BreakerState(); s != helix.BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", s)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	t.Parallel()
+	api := &API{
+		db: db,
+	}
+
+	app := fiber.New()
+	app.Get("/channels", api.Channels)
+
+	params := url.Values{}
+	params.Add("sort", "vod_count")
+	params.Add("first", "1")
+	req := httptest.NewRequest(
+		"GET",
+		fmt.Sprintf("/channels?%s", params.Encode()),
+		nil,
+	)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected http 200, got %d", resp.StatusCode)
+	}
+	var got APIResponse[ChannelsResponse]
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Data.Channels) != 1 {
+		t.Fatalf("expected exactly 1 channel, got %d", len(got.Data.Channels))
+	}
+	if got.Data.Channels[0].VodCount != 4 {
+		t.Fatalf("expected 4 vods, got %d", got.Data.Channels[0].VodCount)
+	}
+	if got.Data.NextOffset == nil || *got.Data.NextOffset != 1 {
+		t.Fatalf("expected next_offset 1, got %v", got.Data.NextOffset)
+	}
+}
+
+func TestChannelSchedule(t *testing.T) {
+	t.Parallel()
+	bid := "58753574"
+	cycleSize := 720
+	if err := repo.SaveScheduleState(db, nil, &model.ScheduleCursors{
+		ScheduleName: repo.TrackerSchedule,
+		CycleSize:    int32(cycleSize),
+		PickedAt:     time.Now().UTC(),
+	}, []*model.ScheduleKeys{{ScheduleName: repo.TrackerSchedule, Key: bid, Minute: 42}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	api := &API{
+		db:              db,
+		alignedSchedule: true,
+	}
+	app := fiber.New()
+	app.Get("/channels/:bid/schedule", api.ChannelSchedule)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/channels/"+bid+"/schedule", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected http 200, got %d", resp.StatusCode)
+	}
+	var got APIResponse[ChannelScheduleResponse]
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	next := got.Data.NextRefresh
+	if m := scheduler.AlignedMinute(next, time.Minute, uint(cycleSize)); m != 42 {
+		t.Fatalf("expected the next refresh at min:42 of the cycle, got %d (%s)", m, next)
+	}
+	if got.Data.InMinutes <= 0 || got.Data.InMinutes > cycleSize || got.Data.CycleMinutes != cycleSize {
+		t.Fatalf("unexpected schedule %+v", got.Data)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/channels/unknown/schedule", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected http 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestChannelsBadParams(t *testing.T) {
+	t.Parallel()
+	wantJson := []byte(`{"data":{"channels":[],"next_offset":null},"errors":["Invalid sort 'views'","Invalid order, must be 'asc' or 'desc'"],"mode":"local"}`)
+	api := &API{
+		db: db,
+	}
+
+	app := fiber.New()
+	app.Get("/channels", api.Channels)
+
+	params := url.Values{}
+	params.Add("sort", "views")
+	params.Add("order", "up")
+	req := httptest.NewRequest(
+		"GET",
+		fmt.Sprintf("/channels?%s", params.Encode()),
+		nil,
+	)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected http 400, got %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := jsondiff.DefaultConsoleOptions()
+	if res, diff := jsondiff.Compare(wantJson, body, &opts); res != jsondiff.FullMatch {
+		t.Fatal(diff)
+	}
+}
+
+func TestFeedVods(t *testing.T) {
+	t.Parallel()
+	api := &API{
+		db: db,
+	}
+
+	app := fiber.New()
+	app.Get("/feeds/:username/:feed", api.Feed)
+
+	req := httptest.NewRequest("GET", "/feeds/IlloJuan/vods.atom", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected http 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/atom+xml; charset=utf-8" {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<updated>2023-06-16T15:36:48Z</updated>",
+		"<id>https://www.twitch.tv/videos/1847800606</id>",
+		`<media:thumbnail url="https://static-cdn.jtvnw.net/cf_vods/dgeft87wbj63p/82d5aaf2650410948650_illojuan_46940301884_1686929802//thumb/thumb0-320x180.jpg"></media:thumbnail>`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected feed to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+	req = httptest.NewRequest("GET", "/feeds/illojuan/vods.atom", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected http 304, got %d", resp.StatusCode)
+	}
+}
+
+func TestFeedErrors(t *testing.T) {
+	t.Parallel()
+	api := &API{
+		db: db,
+	}
+
+	app := fiber.New()
+	app.Get("/feeds/:username/:feed", api.Feed)
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/feeds/unknown/vods.atom", http.StatusNotFound},
+		{"/feeds/illojuan/vods.rss", http.StatusNotFound},
+		{"/feeds/illojuan/clips.json?min_views=abc", http.StatusBadRequest},
+		{"/feeds/illojuan/clips.json?min_views=10", http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", tt.url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Fatalf("%s: expected http %d, got %d", tt.url, tt.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestFeedCache(t *testing.T) {
+	t.Parallel()
+	var fc feedCache
+	updated := time.Date(2023, 6, 16, 0, 0, 0, 0, time.UTC)
+	fc.set("a", &cachedFeed{channelUpdatedAt: updated, etag: `"a"`})
+	if _, ok := fc.get("a", updated); !ok {
+		t.Fatal("expected cached feed")
+	}
+	if _, ok := fc.get("a", updated.Add(time.Minute)); ok {
+		t.Fatal("expected stale feed after the channel was updated")
+	}
+	for i := 0; i < feedCacheSize+10; i++ {
+		fc.set(strconv.Itoa(i), &cachedFeed{})
+	}
+	if n := len(fc.feeds); n != feedCacheSize {
+		t.Fatalf("expected %d cached feeds, got %d", feedCacheSize, n)
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"abc"`); got != tt.want {
+			t.Fatalf("etagMatches(%q) = %t, want %t", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestExportRequiresLogin(t *testing.T) {
+	t.Parallel()
+	api := &API{
+		db: db,
+	}
+
+	app := fiber.New()
+	app.Get("/export/:kind", api.Export)
+
+	req := httptest.NewRequest("GET", "/export/clips?bid=58753574", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected http 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebhooksRequireLogin(t *testing.T) {
+	t.Parallel()
+	api := &API{
+		db: db,
+	}
+
+	app := fiber.New()
+	app.Get("/webhooks", api.Webhooks)
+	app.Post("/webhooks", api.CreateWebhook)
+	app.Delete("/webhooks/:id", api.DeleteWebhook)
+	app.Post("/webhooks/:id/enable", api.EnableWebhook)
+	app.Get("/webhooks/:id/deliveries", api.WebhookDeliveries)
+
+	for _, r := range []struct{ method, path string }{
+		{"GET", "/webhooks"},
+		{"POST", "/webhooks"},
+		{"DELETE", "/webhooks/1"},
+		{"POST", "/webhooks/1/enable"},
+		{"GET", "/webhooks/1/deliveries"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, nil)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("%s %s: expected http 401, got %d", r.method, r.path, resp.StatusCode)
+		}
+	}
+}
+
+func TestCreateWebhookForbiddenDestination(t *testing.T) {
+	t.Parallel()
+	api := &API{
+		db: db,
+	}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		ctx := context.WithValue(c.UserContext(), auth.CtxKeyUserID, int64(1))
+		c.SetUserContext(context.WithValue(ctx, auth.CtxKeyLoggedIn, true))
+		return c.Next()
+	})
+	app.Post("/webhooks", api.CreateWebhook)
+
+	for _, u := range []string{
+		"http://127.0.0.1:4000/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"https://10.0.0.2/hook",
+		"https://[::1]/hook",
+		"http://localhost/hook",
+	} {
+		req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"url":"`+u+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: expected http 400, got %d", u, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"pedro.to/rcaptv/auth"
+	"pedro.to/rcaptv/export"
+)
+
+// exportTimeout limits how long a single export can be streaming rows. Bigger
+// exports should use the export command instead.
+const exportTimeout = 10 * time.Minute
+
+// Export streams the tracked clips or VODs of a broadcaster. Requires a
+// logged in user.
+// - `kind` string clips or vods, path param
+// - `bid` string Broadcaster ID
+// - `started_at` string Optional start of the creation date range in RFC3339
+// - `ended_at` string Optional end of the creation date range in RFC3339
+// - `format` string csv, jsonl or parquet. Defaults to csv
+// - `columns` string Optional comma separated list of columns to export
+//
+// Errors in the parameters are reported as a JSON APIResponse. Once the
+// stream has started errors can only be logged and the response will be
+// truncated.
+func (a *API) Export(c *fiber.Ctx) error {
+	resp := NewResponse[any](nil)
+	resp.Mode = ModeLocal
+	if !auth.IsLoggedIn(c) {
+		resp.Errors = append(resp.Errors, "Login required")
+		return c.Status(http.StatusUnauthorized).JSON(resp)
+	}
+
+	p := &export.Params{
+		Kind:          export.Kind(c.Params("kind")),
+		Format:        export.Format(c.Query("format", string(export.FormatCSV))),
+		BroadcasterID: c.Query("bid"),
+	}
+	if cols := c.Query("columns"); cols != "" {
+		p.Columns = strings.Split(cols, ",")
+	}
+	var err error
+	if startedAt := c.Query("started_at"); startedAt != "" {
+		if p.StartedAt, err = time.Parse(time.RFC3339, startedAt); err != nil {
+			resp.Errors = append(resp.Errors, "Invalid 'started_at'")
+		}
+	}
+	if endedAt := c.Query("ended_at"); endedAt != "" {
+		if p.EndedAt, err = time.Parse(time.RFC3339, endedAt); err != nil {
+			resp.Errors = append(resp.Errors, "Invalid 'ended_at'")
+		}
+	}
+	if len(resp.Errors) == 0 {
+		if err := p.Validate(); err != nil {
+			resp.Errors = append(resp.Errors, err.Error())
+		}
+	}
+	if len(resp.Errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	c.Set(fiber.HeaderContentType, p.Format.ContentType())
+	c.Set(fiber.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="%s_%s.%s"`, p.BroadcasterID, p.Kind, p.Format))
+	c.Status(http.StatusOK)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		l := log.With().Str("ctx", "apiserver").Logger()
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		p.Context = ctx
+		n, err := export.Export(a.db, w, p)
+		if err != nil {
+			l.Err(err).Msgf("export interrupted after %d %s (bid:%s)", n, p.Kind, p.BroadcasterID)
+		}
+		if err := w.Flush(); err != nil {
+			l.Err(err).Msgf("failed to flush export (bid:%s)", p.BroadcasterID)
+		}
+	})
+	return nil
+}
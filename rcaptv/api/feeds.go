@@ -0,0 +1,262 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"pedro.to/rcaptv/feed"
+	"pedro.to/rcaptv/repo"
+)
+
+const (
+	// feedEntries is the number of VODs or clips in a feed
+	feedEntries = 50
+	// feedCacheSize is the max number of feeds kept in memory
+	feedCacheSize = 1024
+	// VOD thumbnails are templates with the size as placeholders
+	feedThumbnailWidth  = "320"
+	feedThumbnailHeight = "180"
+)
+
+var thumbnailSize = strings.NewReplacer(
+	"%{width}", feedThumbnailWidth,
+	"%{height}", feedThumbnailHeight,
+)
+
+type cachedFeed struct {
+	// channelUpdatedAt is the tracked_channels.last_updated_at the feed was
+	// generated with. The feed is stale once it changes
+	channelUpdatedAt time.Time
+	updated          time.Time
+	etag             string
+	body             []byte
+}
+
+// feedCache keeps generated feeds until the tracker stores new rows for the
+// channel. The zero value is ready to use.
+type feedCache struct {
+	mu    sync.Mutex
+	feeds map[string]*cachedFeed
+}
+
+// get returns the cached feed for key if it was generated for the current
+// channelUpdatedAt
+func (fc *feedCache) get(key string, channelUpdatedAt time.Time) (*cachedFeed, bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	f, ok := fc.feeds[key]
+	if !ok || !f.channelUpdatedAt.Equal(channelUpdatedAt) {
+		return nil, false
+	}
+	return f, true
+}
+
+func (fc *feedCache) set(key string, f *cachedFeed) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.feeds == nil {
+		fc.feeds = make(map[string]*cachedFeed, 64)
+	}
+	if _, ok := fc.feeds[key]; !ok && len(fc.feeds) >= feedCacheSize {
+		// evict any entry, stale ones are replaced on access anyway
+		for k := range fc.feeds {
+			delete(fc.feeds, k)
+			break
+		}
+	}
+	fc.feeds[key] = f
+}
+
+// Feed serves the feeds of a tracked channel
+// - `username` string Channel username, path param
+// - `feed` string One of vods.atom, vods.json, clips.atom, clips.json, path
+// param
+// - `min_views` int Only for clips feeds. Minimum views of the clips. Defaults
+// to 0
+//
+// Feeds are cached until the tracker writes new rows for the channel and
+// support conditional requests with If-None-Match.
+func (a *API) Feed(c *fiber.Ctx) error {
+	kind, format, ok := strings.Cut(c.Params("feed"), ".")
+	if !ok || (kind != "vods" && kind != "clips") || (format != "atom" && format != "json") {
+		return c.SendStatus(http.StatusNotFound)
+	}
+	minViews := 0
+	if kind == "clips" {
+		var err error
+		minViews, err = strconv.Atoi(c.Query("min_views", "0"))
+		if err != nil || minViews < 0 {
+			return c.Status(http.StatusBadRequest).SendString("Invalid min_views")
+		}
+	}
+
+	username := strings.ToLower(c.Params("username"))
+	ch, err := repo.ChannelByUsername(a.db, &repo.ChannelByUsernameParams{
+		Username: username,
+		Context:  c.Context(),
+	})
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return c.Status(http.StatusNotFound).
+				SendString(fmt.Sprintf("Username '%s' not found. The channel may not be tracked by us.", username))
+		}
+		return a.feedError(c, err)
+	}
+	var channelUpdatedAt time.Time
+	if ch.LastUpdatedAt != nil {
+		channelUpdatedAt = *ch.LastUpdatedAt
+	}
+
+	key := fmt.Sprintf("%s/%s.%s?min_views=%d", ch.BcID, kind, format, minViews)
+	cached, ok := a.feeds.get(key, channelUpdatedAt)
+	if !ok {
+		var f *feed.Feed
+		if kind == "vods" {
+			f, err = a.vodsFeed(c, ch.BcID)
+		} else {
+			f, err = a.clipsFeed(c, ch.BcID, minViews)
+		}
+		if err != nil {
+			return a.feedError(c, err)
+		}
+		f.Title = fmt.Sprintf("%s %s", ch.BcDisplayName, f.Title)
+		f.Link = "https://www.twitch.tv/" + ch.BcUsername
+		f.FeedURL = c.BaseURL() + c.Path()
+		if minViews > 0 {
+			f.FeedURL += fmt.Sprintf("?min_views=%d", minViews)
+		}
+		f.ID = f.FeedURL
+		f.Author = ch.BcDisplayName
+		if ch.PpURL != nil {
+			f.Icon = *ch.PpURL
+		}
+		// feeds without entries are as old as the channel
+		def := channelUpdatedAt
+		if def.IsZero() && ch.TrackedSince != nil {
+			def = *ch.TrackedSince
+		}
+		f.Updated = f.LastUpdated(def)
+
+		var body []byte
+		if format == "atom" {
+			body, err = f.Atom()
+		} else {
+			body, err = f.JSON()
+		}
+		if err != nil {
+			return a.feedError(c, err)
+		}
+		sum := sha256.Sum256(body)
+		cached = &cachedFeed{
+			channelUpdatedAt: channelUpdatedAt,
+			updated:          f.Updated,
+			etag:             `"` + hex.EncodeToString(sum[:16]) + `"`,
+			body:             body,
+		}
+		a.feeds.set(key, cached)
+	}
+
+	c.Set(fiber.HeaderETag, cached.etag)
+	if !cached.updated.IsZero() {
+		c.Set(fiber.HeaderLastModified, cached.updated.UTC().Format(http.TimeFormat))
+	}
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), cached.etag) {
+		return c.SendStatus(http.StatusNotModified)
+	}
+	if format == "atom" {
+		c.Set(fiber.HeaderContentType, feed.AtomContentType)
+	} else {
+		c.Set(fiber.HeaderContentType, feed.JSONContentType)
+	}
+	return c.Status(http.StatusOK).Send(cached.body)
+}
+
+func (a *API) vodsFeed(c *fiber.Ctx, bid string) (*feed.Feed, error) {
+	vods, err := repo.Vods(a.db, &repo.VodsParams{
+		BcID:    bid,
+		First:   feedEntries,
+		Context: c.Context(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	f := &feed.Feed{
+		Title:   "VODs",
+		Entries: make([]*feed.Entry, 0, len(vods)),
+	}
+	for _, v := range vods {
+		link := "https://www.twitch.tv/videos/" + v.VideoID
+		f.Entries = append(f.Entries, &feed.Entry{
+			ID:        link,
+			Title:     v.Title,
+			Link:      link,
+			Summary:   fmt.Sprintf("%s streamed, %d views", time.Duration(v.Duration)*time.Second, v.ViewCount),
+			Thumbnail: thumbnailSize.Replace(v.ThumbnailURL),
+			Published: v.CreatedAt,
+			Updated:   v.PublishedAt,
+		})
+	}
+	return f, nil
+}
+
+func (a *API) clipsFeed(c *fiber.Ctx, bid string, minViews int) (*feed.Feed, error) {
+	clips, err := repo.LatestClips(a.db, &repo.LatestClipsParams{
+		BroadcasterID: bid,
+		MinViews:      minViews,
+		First:         feedEntries,
+		Context:       c.Context(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	f := &feed.Feed{
+		Title:   "clips",
+		Entries: make([]*feed.Entry, 0, len(clips)),
+	}
+	if minViews > 0 {
+		f.Title = fmt.Sprintf("clips with at least %d views", minViews)
+	}
+	for _, cl := range clips {
+		link := "https://clips.twitch.tv/" + cl.ClipID
+		f.Entries = append(f.Entries, &feed.Entry{
+			ID:        link,
+			Title:     cl.Title,
+			Link:      link,
+			Summary:   fmt.Sprintf("Clipped by %s, %d views", cl.CreatorName, cl.ViewCount),
+			Thumbnail: cl.ThumbnailURL,
+			Published: cl.CreatedAt,
+			Updated:   cl.CreatedAt,
+		})
+	}
+	return f, nil
+}
+
+func (a *API) feedError(c *fiber.Ctx, err error) error {
+	log.Err(err).Str("ctx", "apiserver").Msgf("failed to generate feed (%s)", c.Path())
+	return c.Status(http.StatusInternalServerError).SendString("Unexpected error")
+}
+
+// etagMatches reports if the If-None-Match header matches etag. Weak
+// comparison is used as recommended for If-None-Match
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"pedro.to/rcaptv/repo"
+	"pedro.to/rcaptv/scheduler"
+)
+
+type ChannelScheduleResponse struct {
+	// NextRefresh is when the tracker refreshes the VODs and clips of the
+	// channel next
+	NextRefresh time.Time `json:"next_refresh"`
+	// InMinutes is the number of minutes until NextRefresh, rounded up
+	InMinutes    int `json:"in_minutes"`
+	CycleMinutes int `json:"cycle_minutes"`
+}
+
+// ChannelSchedule tells when a channel is tracked next
+// - `bid` string Broadcaster ID, path param
+//
+// It is only available if the tracker schedule is aligned to the wall clock
+// and persisted, see APIOpts.AlignedSchedule.
+func (a *API) ChannelSchedule(c *fiber.Ctx) error {
+	resp := NewResponse[*ChannelScheduleResponse](nil)
+	resp.Mode = ModeLocal
+
+	if !a.alignedSchedule {
+		resp.Errors = append(resp.Errors, "The tracking schedule is not available")
+		return c.Status(http.StatusNotImplemented).JSON(resp)
+	}
+
+	bid := c.Params("bid")
+	k, err := repo.ScheduledKeyMinute(a.db, &repo.ScheduledKeyParams{
+		Schedule: repo.TrackerSchedule,
+		Key:      bid,
+		Context:  c.Context(),
+	})
+	if err != nil {
+		log.Err(err).Str("ctx", "apiserver").Msgf("failed to get the schedule (bid:%s)", bid)
+		resp.Errors = append(resp.Errors, "Unexpected error")
+		return c.Status(http.StatusInternalServerError).JSON(resp)
+	}
+	if k == nil || k.CycleSize <= 0 {
+		resp.Errors = append(resp.Errors,
+			fmt.Sprintf("Channel '%s' not found. The channel may not be tracked by us.", bid))
+		return c.Status(http.StatusNotFound).JSON(resp)
+	}
+
+	now := time.Now()
+	next := scheduler.AlignedNextRun(scheduler.Minute(k.Minute), now, time.Minute, uint(k.CycleSize))
+	resp.Data = &ChannelScheduleResponse{
+		NextRefresh:  next.UTC(),
+		InMinutes:    int(math.Ceil(next.Sub(now).Minutes())),
+		CycleMinutes: int(k.CycleSize),
+	}
+	return c.Status(http.StatusOK).JSON(resp)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"pedro.to/rcaptv/stats"
+)
+
+// ChannelStats
+// - `bid` string Broadcaster ID, path param
+// - `period` string One of month, quarter, year, all. Defaults to quarter
+//
+// Stats are computed from the tracked VODs and clips and refreshed after every
+// tracking cycle.
+func (a *API) ChannelStats(c *fiber.Ctx) error {
+	resp := NewResponse[*stats.ChannelStats](nil)
+	resp.Mode = ModeLocal
+
+	bid := c.Params("bid")
+	period := stats.Period(c.Query("period", string(stats.DefaultPeriod)))
+	st, err := stats.Channel(a.db, &stats.ChannelParams{
+		BroadcasterID: bid,
+		Period:        period,
+		Context:       c.Context(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, stats.ErrInvalidPeriod):
+			resp.Errors = append(resp.Errors,
+				fmt.Sprintf("Invalid period '%s', must be one of month, quarter, year or all", period))
+			return c.Status(http.StatusBadRequest).JSON(resp)
+		case errors.Is(err, stats.ErrUnknownChannel):
+			resp.Errors = append(resp.Errors,
+				fmt.Sprintf("Channel '%s' not found. The channel may not be tracked by us.", bid))
+			return c.Status(http.StatusNotFound).JSON(resp)
+		}
+		log.Err(err).Str("ctx", "apiserver").Msgf("failed to compute stats (bid:%s)", bid)
+		resp.Errors = append(resp.Errors, "Unexpected error")
+		return c.Status(http.StatusInternalServerError).JSON(resp)
+	}
+	resp.Data = st
+	return c.Status(http.StatusOK).JSON(resp)
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+
+	"pedro.to/rcaptv/auth"
+	cfg "pedro.to/rcaptv/config"
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	"pedro.to/rcaptv/repo"
+	"pedro.to/rcaptv/webhook"
+)
+
+// maxWebhookEndpoints is the number of endpoints a user can register
+const maxWebhookEndpoints = 10
+
+type WebhookEndpoint struct {
+	ID            int32   `json:"id"`
+	URL           string  `json:"url"`
+	BroadcasterID *string `json:"bc_id"`
+	EventType     *string `json:"event_type"`
+	MinViews      int32   `json:"min_views"`
+	Enabled       bool    `json:"enabled"`
+	// ConsecutiveFailures is the number of failed deliveries in a row. The
+	// endpoint is disabled when it reaches the max
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	// Secret to verify the signature of the deliveries. Only returned on
+	// creation
+	Secret string `json:"secret,omitempty"`
+}
+
+func newWebhookEndpoint(ep *model.WebhookEndpoints) *WebhookEndpoint {
+	return &WebhookEndpoint{
+		ID:                  ep.EndpointID,
+		URL:                 ep.URL,
+		BroadcasterID:       ep.BcID,
+		EventType:           ep.EventType,
+		MinViews:            ep.MinViews,
+		Enabled:             ep.Enabled,
+		ConsecutiveFailures: ep.ConsecutiveFailures,
+		DisabledAt:          ep.DisabledAt,
+		CreatedAt:           ep.CreatedAt,
+	}
+}
+
+type WebhookDelivery struct {
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int32     `json:"attempt"`
+	Success    bool      `json:"success"`
+	StatusCode *int32    `json:"status_code"`
+	Error      *string   `json:"error"`
+	DurationMs int32     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type createWebhookRequest struct {
+	URL           string  `json:"url"`
+	BroadcasterID *string `json:"bc_id"`
+	EventType     *string `json:"event_type"`
+	MinViews      int32   `json:"min_views"`
+}
+
+// Webhooks lists the webhook endpoints of the logged in user
+func (a *API) Webhooks(c *fiber.Ctx) error {
+	resp := NewResponse[[]*WebhookEndpoint](nil)
+	resp.Mode = ModeLocal
+	if !auth.IsLoggedIn(c) {
+		resp.Errors = append(resp.Errors, "Login required")
+		return c.Status(http.StatusUnauthorized).JSON(resp)
+	}
+	eps, err := repo.WebhookEndpoints(a.db, &repo.WebhookEndpointsParams{
+		UserID:  auth.UserID(c),
+		Context: c.Context(),
+	})
+	if err != nil {
+		return a.webhookError(c, err)
+	}
+	resp.Data = make([]*WebhookEndpoint, 0, len(eps))
+	for _, ep := range eps {
+		resp.Data = append(resp.Data, newWebhookEndpoint(ep))
+	}
+	return c.Status(http.StatusOK).JSON(resp)
+}
+
+// CreateWebhook registers a webhook endpoint for the logged in user. The body
+// is a JSON object with:
+// - `url` string Where events are POSTed. Must be https in production and
+// resolve to a public address
+// - `bc_id` string Optional. Only events of this broadcaster
+// - `event_type` string Optional. One of vod.created, vod.updated or
+// clip.threshold_reached. Any event if empty
+// - `min_views` int Optional. clip.threshold_reached is sent when a clip
+// reaches this many views. Defaults to 0, every new clip
+//
+// The secret to verify deliveries is only returned in this response.
+func (a *API) CreateWebhook(c *fiber.Ctx) error {
+	resp := NewResponse[*WebhookEndpoint](nil)
+	resp.Mode = ModeLocal
+	if !auth.IsLoggedIn(c) {
+		resp.Errors = append(resp.Errors, "Login required")
+		return c.Status(http.StatusUnauthorized).JSON(resp)
+	}
+
+	var req createWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		resp.Errors = append(resp.Errors, "Invalid body")
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+	if u, err := url.Parse(req.URL); err != nil || u.Host == "" ||
+		(u.Scheme != "https" && (cfg.IsProd || u.Scheme != "http")) {
+		resp.Errors = append(resp.Errors, "Invalid 'url', must be an absolute https URL")
+	} else if err := webhook.CheckDestination(c.Context(), u); err != nil {
+		if !errors.Is(err, webhook.ErrForbiddenDestination) {
+			log.Debug().Err(err).Str("ctx", "apiserver").Msgf("failed to check webhook destination %s", u.Host)
+		}
+		resp.Errors = append(resp.Errors, "Invalid 'url', must be a public address")
+	}
+	if req.EventType != nil && *req.EventType == "" {
+		req.EventType = nil
+	}
+	if req.EventType != nil && !webhook.ValidEventType(webhook.EventType(*req.EventType)) {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("Invalid 'event_type', must be one of %s, %s or %s",
+			webhook.EventVodCreated, webhook.EventVodUpdated, webhook.EventClipThresholdReached))
+	}
+	if req.BroadcasterID != nil && *req.BroadcasterID == "" {
+		req.BroadcasterID = nil
+	}
+	if req.MinViews < 0 {
+		resp.Errors = append(resp.Errors, "Invalid 'min_views'")
+	}
+	if len(resp.Errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	uid := auth.UserID(c)
+	eps, err := repo.WebhookEndpoints(a.db, &repo.WebhookEndpointsParams{
+		UserID:  uid,
+		Context: c.Context(),
+	})
+	if err != nil {
+		return a.webhookError(c, err)
+	}
+	if len(eps) >= maxWebhookEndpoints {
+		resp.Errors = append(resp.Errors,
+			fmt.Sprintf("Webhook endpoints limit reached (%d)", maxWebhookEndpoints))
+		return c.Status(http.StatusConflict).JSON(resp)
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return a.webhookError(c, err)
+	}
+	ep, err := repo.CreateWebhookEndpoint(a.db, &model.WebhookEndpoints{
+		UserID:    int32(uid),
+		URL:       req.URL,
+		Secret:    secret,
+		BcID:      req.BroadcasterID,
+		EventType: req.EventType,
+		MinViews:  req.MinViews,
+	})
+	if err != nil {
+		if errors.Is(err, repo.ErrUnknownChannel) {
+			resp.Errors = append(resp.Errors,
+				fmt.Sprintf("Channel '%s' not found. The channel may not be tracked by us.", *req.BroadcasterID))
+			return c.Status(http.StatusBadRequest).JSON(resp)
+		}
+		return a.webhookError(c, err)
+	}
+	resp.Data = newWebhookEndpoint(ep)
+	resp.Data.Secret = ep.Secret
+	return c.Status(http.StatusCreated).JSON(resp)
+}
+
+// DeleteWebhook deletes a webhook endpoint of the logged in user
+// - `id` int Endpoint ID, path param
+func (a *API) DeleteWebhook(c *fiber.Ctx) error {
+	return a.updateWebhook(c, repo.DeleteWebhookEndpoint)
+}
+
+// EnableWebhook enables again a webhook endpoint of the logged in user that
+// was disabled after too many failed deliveries
+// - `id` int Endpoint ID, path param
+func (a *API) EnableWebhook(c *fiber.Ctx) error {
+	return a.updateWebhook(c, repo.EnableWebhookEndpoint)
+}
+
+func (a *API) updateWebhook(c *fiber.Ctx, update func(db *sql.DB, userID, endpointID int64) error) error {
+	resp := NewResponse[any](nil)
+	resp.Mode = ModeLocal
+	if !auth.IsLoggedIn(c) {
+		resp.Errors = append(resp.Errors, "Login required")
+		return c.Status(http.StatusUnauthorized).JSON(resp)
+	}
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		resp.Errors = append(resp.Errors, "Invalid webhook id")
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+	if err := update(a.db, auth.UserID(c), id); err != nil {
+		if errors.Is(err, repo.ErrNoRowsAffected) {
+			resp.Errors = append(resp.Errors, "Webhook not found")
+			return c.Status(http.StatusNotFound).JSON(resp)
+		}
+		return a.webhookError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(resp)
+}
+
+// WebhookDeliveries lists the last delivery attempts of a webhook endpoint of
+// the logged in user
+// - `id` int Endpoint ID, path param
+func (a *API) WebhookDeliveries(c *fiber.Ctx) error {
+	resp := NewResponse[[]*WebhookDelivery](nil)
+	resp.Mode = ModeLocal
+	if !auth.IsLoggedIn(c) {
+		resp.Errors = append(resp.Errors, "Login required")
+		return c.Status(http.StatusUnauthorized).JSON(resp)
+	}
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		resp.Errors = append(resp.Errors, "Invalid webhook id")
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+	eps, err := repo.WebhookEndpoints(a.db, &repo.WebhookEndpointsParams{
+		UserID:     auth.UserID(c),
+		EndpointID: id,
+		Context:    c.Context(),
+	})
+	if err != nil {
+		return a.webhookError(c, err)
+	}
+	if len(eps) == 0 {
+		resp.Errors = append(resp.Errors, "Webhook not found")
+		return c.Status(http.StatusNotFound).JSON(resp)
+	}
+	ds, err := repo.WebhookDeliveries(a.db, &repo.WebhookDeliveriesParams{
+		EndpointID: id,
+		Context:    c.Context(),
+	})
+	if err != nil {
+		return a.webhookError(c, err)
+	}
+	resp.Data = make([]*WebhookDelivery, 0, len(ds))
+	for _, d := range ds {
+		resp.Data = append(resp.Data, &WebhookDelivery{
+			EventID:    d.EventID,
+			EventType:  d.EventType,
+			Attempt:    d.Attempt,
+			Success:    d.Success,
+			StatusCode: d.StatusCode,
+			Error:      d.Error,
+			DurationMs: d.DurationMs,
+			CreatedAt:  d.CreatedAt,
+		})
+	}
+	return c.Status(http.StatusOK).JSON(resp)
+}
+
+func (a *API) webhookError(c *fiber.Ctx, err error) error {
+	log.Err(err).Str("ctx", "apiserver").Msgf("webhooks request failed (%s)", c.Path())
+	resp := NewResponse[any](nil)
+	resp.Mode = ModeLocal
+	resp.Errors = append(resp.Errors, "Unexpected error")
+	return c.Status(http.StatusInternalServerError).JSON(resp)
+}
@@ -54,7 +54,8 @@ func NewService(sto database.Storage) *Service {
 				ClientID:     "",
 				ClientSecret: "",
 			},
-			APIUrl: "",
+			APIUrl:           "",
+			ValidateEndpoint: cfg.TwitchValidateURL,
 		})),
 		TokenCollector: NewCollector(db, time.Duration(cfg.TokenCollectorIntervalHours)*time.Hour),
 	}
@@ -78,6 +78,12 @@ func (v *TokenValidator) context() context.Context {
 	return v.ctx.ctx
 }
 
+// Schedule returns the schedule of the users whose tokens are validated.
+// Useful to inspect it, see scheduler.DebugHandler
+func (v *TokenValidator) Schedule() *scheduler.BalancedSchedule {
+	return v.balancer
+}
+
 // Ready returns a channel that will be closed when ready. It can be used to
 // block before the validator is ready
 func (v *TokenValidator) Ready() <-chan struct{} {
@@ -95,9 +101,11 @@ func (v *TokenValidator) Run() error {
 		if err != nil {
 			v.l.Panic().Msg(err.Error())
 		}
+		ids := make([]string, 0, len(usrs))
 		for _, usr := range usrs {
-			v.AddUser(int64(usr.UserID))
+			ids = append(ids, strconv.FormatInt(int64(usr.UserID), 10))
 		}
+		v.balancer.AddMany(ids...)
 		v.l.Info().Msgf("validator: added:%d (balancer:%p)", len(usrs), v.balancer)
 	}
 
@@ -135,11 +143,15 @@ func (v *TokenValidator) cycle(m scheduler.RealTimeMinute) {
 		v.l.Debug().Msgf("validator: min:%s objs:%v", m.Min, m.Objects)
 	}
 	ctx := v.context()
-	for _, usrid := range m.Objects {
+	for i, usrid := range m.Objects {
+		// users are spread across the minute
+		if err := m.Wait(ctx, i); err != nil {
+			return
+		}
 		// NOTE: this path may become very slow if we have lots of concurrent
 		// active users that need validation. The roundtrip to twitch API is the
 		// slowest part here. If scheduler starts to warn about realTime minute
-		// discards we should make this faster. Maybe parallel queries? If that's
+		// merges we should make this faster. Maybe parallel queries? If that's
 		// not enough we could factorize validator service into multiple instances
 		// and distribute queries across instances
 		idstr := usrid // keep ref to str for parsing errors
@@ -213,14 +225,23 @@ var (
 )
 
 func NewTokenValidator(db *sql.DB, hx *helix.Helix) *TokenValidator {
+	opts := scheduler.BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(cfg.EstimatedActiveUsers),
+		BalanceStrategy:  scheduler.StrategyMurmur(uint32(cycleSize)),
+		Freq:             freq,
+		Salt:             cfg.BalancerSalt,
+		Aligned:          cfg.AlignedSchedules,
+		// tokens of a minute that starts while the previous one is still
+		// being validated are validated right after it
+		Overflow: scheduler.OverflowMerge,
+		Spread:   cfg.SpreadSchedules,
+	}
+	if cfg.PersistSchedules {
+		opts.Store = repo.NewScheduleStore(db, repo.ValidatorSchedule)
+	}
 	return &TokenValidator{
-		balancer: scheduler.New(scheduler.BalancedScheduleOpts{
-			CycleSize:        cycleSize,
-			EstimatedObjects: uint(cfg.EstimatedActiveUsers),
-			BalanceStrategy:  scheduler.StrategyMurmur(uint32(cycleSize)),
-			Freq:             freq,
-			Salt:             cfg.BalancerSalt,
-		}),
+		balancer:   scheduler.New(opts),
 		db:         db,
 		hx:         hx,
 		AfterCycle: func(m scheduler.RealTimeMinute) {},
@@ -45,6 +45,7 @@ func main() {
 	apisv := api.New(passport, api.APIOpts{
 		Storage:                 sto,
 		ClipsMaxPeriodDiffHours: cfg.ClipsMaxPeriodDiffHours,
+		AlignedSchedule:         cfg.AlignedSchedules && cfg.PersistSchedules,
 		ClientID:                cfg.HelixClientID,
 		ClientSecret:            cfg.HelixClientSecret,
 		HelixAPIUrl:             cfg.TwitchAPIUrl,
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -19,6 +20,7 @@ import (
 	cfg "pedro.to/rcaptv/config"
 	"pedro.to/rcaptv/database"
 	"pedro.to/rcaptv/database/postgres"
+	"pedro.to/rcaptv/scheduler"
 	"pedro.to/rcaptv/utils"
 )
 
@@ -101,8 +103,24 @@ func main() {
 		}
 	}()
 
+	var debugServer *http.Server
+	if cfg.AuthDebugAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(cfg.SchedulerDebugEndpoint, scheduler.DebugHandler(svc.TokenValidator.Schedule))
+		debugServer = &http.Server{Addr: cfg.AuthDebugAddr, Handler: mux}
+		go func() {
+			l.Info().Msgf("debug server listening on %s", cfg.AuthDebugAddr)
+			if err := debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				l.Err(err).Msg("debug server")
+			}
+		}()
+	}
+
 	sig := utils.WaitInterrupt()
 	l.Info().Msgf("termination signal received [%s]. Attempting to gracefully shutdown...", sig)
+	if debugServer != nil {
+		debugServer.Close()
+	}
 	l.Info().Msg("stopping auth service")
 	grcpServer.GracefulStop()
 	svc.Stop()
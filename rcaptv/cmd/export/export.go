@@ -0,0 +1,122 @@
+// Command export writes the tracked clips or VODs of a broadcaster to stdout
+// or a file in CSV, JSON Lines or Parquet.
+//
+//	export -kind clips -bid 58753574 -from 2023-06-01T00:00:00Z -format parquet -o clips.parquet
+//
+// Logs are written to stderr.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	cfg "pedro.to/rcaptv/config"
+	"pedro.to/rcaptv/database"
+	"pedro.to/rcaptv/database/postgres"
+	"pedro.to/rcaptv/export"
+	"pedro.to/rcaptv/utils"
+)
+
+func main() {
+	l := log.With().Str("ctx", "export").Logger()
+
+	var (
+		kind    = flag.String("kind", string(export.KindClips), "what to export: clips or vods")
+		bid     = flag.String("bid", "", "broadcaster id")
+		from    = flag.String("from", "", "optional start of the created_at range in RFC3339")
+		to      = flag.String("to", "", "optional end (exclusive) of the created_at range in RFC3339")
+		format  = flag.String("format", string(export.FormatCSV), "output format: csv, jsonl or parquet")
+		columns = flag.String("columns", "", "optional comma separated list of columns")
+		out     = flag.String("o", "", "output file. Defaults to stdout")
+		batch   = flag.Int("batch", export.DefaultBatchSize, "rows fetched from the database at once")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nclips columns: %s\nvods columns: %s\n",
+			strings.Join(export.Columns(export.KindClips), ","),
+			strings.Join(export.Columns(export.KindVods), ","),
+		)
+	}
+	flag.Parse()
+
+	p := &export.Params{
+		Kind:          export.Kind(*kind),
+		Format:        export.Format(*format),
+		BroadcasterID: *bid,
+		BatchSize:     *batch,
+	}
+	if *columns != "" {
+		p.Columns = strings.Split(*columns, ",")
+	}
+	var err error
+	if *from != "" {
+		if p.StartedAt, err = time.Parse(time.RFC3339, *from); err != nil {
+			l.Fatal().Err(err).Msg("invalid -from")
+		}
+	}
+	if *to != "" {
+		if p.EndedAt, err = time.Parse(time.RFC3339, *to); err != nil {
+			l.Fatal().Err(err).Msg("invalid -to")
+		}
+	}
+	if err := p.Validate(); err != nil {
+		flag.Usage()
+		l.Fatal().Err(err).Msg("invalid parameters")
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			l.Fatal().Err(err).Msg("")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	// exporting is read only, migrations are left to the services
+	cfg.SkipMigrations = true
+	sto := database.New(postgres.New(
+		&database.StorageOptions{
+			StorageHost:     cfg.PostgresHost,
+			StoragePort:     cfg.PostgresPort,
+			StorageUser:     cfg.RcaptvPostgresUser,
+			StoragePassword: cfg.RcaptvPostgresPassword,
+			StorageDbName:   cfg.PostgresDBName,
+
+			StorageMaxIdleConns:    1,
+			StorageMaxOpenConns:    1,
+			StorageConnMaxLifetime: time.Duration(cfg.PostgresConnMaxLifetimeMinutes) * time.Minute,
+			StorageConnTimeout:     time.Duration(cfg.PostgresConnTimeoutSeconds) * time.Second,
+		}))
+	defer sto.Conn().Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		sig := utils.WaitInterrupt()
+		l.Warn().Msgf("termination signal received [%s]. Cancelling export...", sig)
+		cancel()
+	}()
+	p.Context = ctx
+
+	start := time.Now()
+	n, err := export.Export(sto.Conn(), w, p)
+	if err != nil {
+		l.Error().Err(err).Msgf("export failed after %d %s", n, p.Kind)
+		os.Exit(1)
+	}
+	l.Info().Msgf("exported %d %s (bid:%s) in %s", n, p.Kind, p.BroadcasterID, time.Since(start))
+}
+
+func init() {
+	cfg.Setup()
+}
@@ -0,0 +1,60 @@
+// fakehelix serves a fake Twitch API with a seeded dataset, to run the whole
+// stack offline. Point the services to it with TWITCH_API_URL,
+// TWITCH_AUTH_URL, TWITCH_TOKEN_URL and TWITCH_VALIDATE_URL, see
+// docker-compose.offline.yml.
+//
+// The server is scripted with the endpoints under fakehelix.ControlPath, e.g.
+// to send stream.online webhooks to the EventSub subscriptions of a channel.
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	cfg "pedro.to/rcaptv/config"
+	"pedro.to/rcaptv/helix/fakehelix"
+	"pedro.to/rcaptv/utils"
+)
+
+func main() {
+	l := log.With().Str("ctx", "fakehelix").Logger()
+	port := cfg.Env("FAKEHELIX_PORT", "7777")
+	sv := fakehelix.New(fakehelix.Opts{
+		ClientID:     cfg.HelixClientID,
+		ClientSecret: cfg.HelixClientSecret,
+		Seed:         cfg.Env("FAKEHELIX_SEED", int64(1)),
+		Channels:     cfg.Env("FAKEHELIX_CHANNELS", 10),
+	})
+	ds := sv.Dataset()
+	l.Info().Msgf("serving %d users, %d videos and %d clips",
+		len(ds.Users), len(ds.Videos), len(ds.Clips),
+	)
+
+	httpsv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           sv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		l.Info().Msgf("fakehelix listening on :%s", port)
+		if err := httpsv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Panic().Err(err).Msg("")
+		}
+	}()
+	sig := utils.WaitInterrupt()
+	l.Info().Msgf("termination signal received [%s]. Attempting to gracefully shutdown...", sig)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpsv.Shutdown(ctx); err != nil {
+		l.Panic().Err(err).Msg("")
+	}
+	sv.WaitDeliveries()
+}
+
+func init() {
+	cfg.Setup()
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -10,8 +12,10 @@ import (
 	"pedro.to/rcaptv/database"
 	"pedro.to/rcaptv/database/postgres"
 	"pedro.to/rcaptv/helix"
+	"pedro.to/rcaptv/scheduler"
 	"pedro.to/rcaptv/tracker"
 	"pedro.to/rcaptv/utils"
+	"pedro.to/rcaptv/webhook"
 )
 
 func main() {
@@ -55,21 +59,46 @@ func main() {
 		},
 		APIUrl:           cfg.TwitchAPIUrl,
 		EventsubEndpoint: cfg.EventSubEndpoint,
+		TokenURL:         cfg.TwitchTokenURL,
 	})
 
+	l.Info().Msg("starting webhook dispatcher")
+	wh := webhook.NewDispatcher(&webhook.DispatcherOpts{DB: sto.Conn()})
+	wh.Start()
+
+	tr := tracker.New(&tracker.TrackerOpts{
+		Helix:    hx,
+		Context:  ctx,
+		Storage:  sto,
+		Webhooks: wh,
+	})
 	go func() {
 		l.Info().Msg("starting tracker service")
-		if err := tracker.New(&tracker.TrackerOpts{
-			Helix:   hx,
-			Context: ctx,
-			Storage: sto,
-		}).Run(); err != nil {
+		if err := tr.Run(); err != nil {
 			l.Panic().Err(err).Msg("tracker returned an error")
 		}
 	}()
+
+	var debugServer *http.Server
+	if cfg.TrackerDebugAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(cfg.SchedulerDebugEndpoint, scheduler.DebugHandler(tr.Schedule))
+		debugServer = &http.Server{Addr: cfg.TrackerDebugAddr, Handler: mux}
+		go func() {
+			l.Info().Msgf("debug server listening on %s", cfg.TrackerDebugAddr)
+			if err := debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				l.Err(err).Msg("debug server")
+			}
+		}()
+	}
 	sig := utils.WaitInterrupt()
 
 	l.Info().Msgf("termination signal received [%s]. Attempting gracefully shutdown...", sig)
+	if debugServer != nil {
+		debugServer.Close()
+	}
+	l.Info().Msg("stopping webhook dispatcher")
+	wh.Stop()
 	l.Info().Msg("closing database")
 	if err := sto.Stop(); err != nil {
 		l.Warn().Err(err).Msg("error closing database")
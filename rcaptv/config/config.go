@@ -17,7 +17,7 @@ import (
 
 const (
 	Version              = "0.2.0"
-	LastMigrationVersion = 1
+	LastMigrationVersion = 8
 )
 
 var loaded = false
@@ -59,8 +59,17 @@ var (
 	APIValidateEndpoint          string
 	APIVodsEndpoint              string
 	APIClipsEndpoint             string
+	APIChannelsEndpoint          string
+	APIStatsEndpoint             string
+	APIScheduleEndpoint          string
+	APIExportEndpoint            string
+	APIWebhooksEndpoint          string
+	FeedsEndpoint                string
 	CookieSecret                 string
 	TwitchAPIUrl                 string
+	TwitchAuthURL                string
+	TwitchTokenURL               string
+	TwitchValidateURL            string
 	WebserverPort                string
 	APIPort                      string
 	EventSubEndpoint             string
@@ -73,9 +82,19 @@ var (
 	TrackingCycleMinutes     int
 	ClipTrackingWindowHours  int
 	ClipTrackingMaxDeepLevel int
+	ClipTrackingConcurrency  int
+	// A broadcaster whose tracking failed is retried in TrackingRetryMinutes
+	// up to TrackingMaxRetries times in a row, at most
+	// TrackingRetriesPerMinute broadcasters every minute
+	TrackingRetryMinutes     int
+	TrackingMaxRetries       int
+	TrackingRetriesPerMinute int
 	ClipViewThreshold        int
 	ClipViewWindowSize       int
 
+	WebhookDeliveryMaxAttempts    int
+	WebhookMaxConsecutiveFailures int
+
 	EstimatedActiveUsers int
 
 	TrackIntervalMinutes        int
@@ -86,10 +105,38 @@ var (
 	WebserverIndexDir  string
 
 	BalancerSalt string
+	// PersistSchedules stores the state of the tracker and validator schedules
+	// so they resume at the same minute after a restart
+	PersistSchedules bool
+	// AlignedSchedules aligns the tracker and validator schedules to the wall
+	// clock, so the API can tell when a channel is refreshed next
+	AlignedSchedules bool
+	// SpreadSchedules spreads the channels and users of every minute of the
+	// tracker and validator schedules evenly inside it, to smooth the rate of
+	// requests to Twitch
+	SpreadSchedules bool
+	// DistributedTracking shards the tracking schedule across every tracker
+	// running with it. They must share the database, TRACKING_CYCLE_MINUTES
+	// and BALANCER_SALT
+	DistributedTracking bool
+	// WorkerID identifies the tracker in a distributed schedule. Defaults to
+	// the hostname
+	WorkerID string
+	// TrackingRebalanceCycles moves broadcasters between the minutes of the
+	// tracking schedule every TrackingRebalanceCycles cycles, by the number of
+	// requests needed to track them. 0 disables it. Ignored with
+	// DistributedTracking
+	TrackingRebalanceCycles int
 
 	RPCAuthHost string
 	RPCAuthPort string
 
+	// Internal addresses of the endpoint to inspect the tracker and the token
+	// validator schedules. Empty disables them
+	TrackerDebugAddr       string
+	AuthDebugAddr          string
+	SchedulerDebugEndpoint string
+
 	Debug bool
 )
 
@@ -106,8 +153,12 @@ func OAuthConfig() *oauth2.Config {
 		ClientID:     HelixClientID,
 		ClientSecret: HelixClientSecret,
 		Scopes:       Scopes,
-		Endpoint:     twitch.Endpoint,
-		RedirectURL:  redirect,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   TwitchAuthURL,
+			TokenURL:  TwitchTokenURL,
+			AuthStyle: twitch.Endpoint.AuthStyle,
+		},
+		RedirectURL: redirect,
 	}
 }
 
@@ -180,6 +231,14 @@ func Env[T SupportStringconv](key string, def T) T {
 	return def
 }
 
+func hostname() string {
+	h, err := os.Hostname()
+	if err != nil {
+		return "tracker"
+	}
+	return h
+}
+
 func LoadVars() {
 	l := l.With().
 		Str("ctx", "config").
@@ -228,10 +287,19 @@ func LoadVars() {
 	APIValidateEndpoint = Env("API_VALIDATE_ENDPOINT", "/validate")
 	APIVodsEndpoint = Env("API_VODS_ENDPOINT", "/vods")
 	APIClipsEndpoint = Env("API_CLIPS_ENDPOINT", "/clips")
+	APIChannelsEndpoint = Env("API_CHANNELS_ENDPOINT", "/channels")
+	APIStatsEndpoint = Env("API_STATS_ENDPOINT", "/stats")
+	APIScheduleEndpoint = Env("API_SCHEDULE_ENDPOINT", "/schedule")
+	APIExportEndpoint = Env("API_EXPORT_ENDPOINT", "/export")
+	APIWebhooksEndpoint = Env("API_WEBHOOKS_ENDPOINT", "/webhooks")
+	FeedsEndpoint = Env("FEEDS_ENDPOINT", "/feeds")
 	AuthEndpoint = Env("AUTH_ENDPOINT", "/auth")
 	AuthRedirectEndpoint = Env("AUTH_REDIRECT_ENDPOINT", "/auth/redirect")
 	CookieSecret = Env("COOKIE_SECRET", "unsafe_secret")
 	TwitchAPIUrl = Env("TWITCH_API_URL", "https://api.twitch.tv/helix")
+	TwitchAuthURL = Env("TWITCH_AUTH_URL", twitch.Endpoint.AuthURL)
+	TwitchTokenURL = Env("TWITCH_TOKEN_URL", twitch.Endpoint.TokenURL)
+	TwitchValidateURL = Env("TWITCH_VALIDATE_URL", "https://id.twitch.tv/oauth2/validate")
 	EventSubEndpoint = Env("EVENTSUB_ENDPOINT", "/eventsub")
 	APIRateLimitMaxConns = Env("API_RATE_LIMIT_MAX_CONNS", 20)
 	APIRateLimitExpSeconds = Env("API_RATE_LIMIT_EXP_SECONDS", 60)
@@ -242,9 +310,16 @@ func LoadVars() {
 	TrackingCycleMinutes = Env("TRACKING_CYCLE_MINUTES", 720)
 	ClipTrackingWindowHours = Env("CLIP_TRACKING_WINDOW_HOURS", 7*24)
 	ClipTrackingMaxDeepLevel = Env("CLIP_TRACKING_MAX_DEEP_LEVEL", 2)
+	ClipTrackingConcurrency = Env("CLIP_TRACKING_CONCURRENCY", 4)
+	TrackingRetryMinutes = Env("TRACKING_RETRY_MINUTES", 5)
+	TrackingMaxRetries = Env("TRACKING_MAX_RETRIES", 3)
+	TrackingRetriesPerMinute = Env("TRACKING_RETRIES_PER_MINUTE", 5)
 	ClipViewThreshold = Env("CLIP_VIEW_THRESHOLD", 10)
 	ClipViewWindowSize = Env("CLIP_VIEW_WINDOW_SIZE", 4)
 
+	WebhookDeliveryMaxAttempts = Env("WEBHOOK_DELIVERY_MAX_ATTEMPTS", 5)
+	WebhookMaxConsecutiveFailures = Env("WEBHOOK_MAX_CONSECUTIVE_FAILURES", 10)
+
 	EstimatedActiveUsers = Env("ESTIMATED_ACTIVE_USERS", 200)
 
 	TokenCollectorIntervalHours = Env("TOKEN_COLLECTOR_INTERVAL_HOURS", 72)
@@ -256,7 +331,17 @@ func LoadVars() {
 	RPCAuthHost = Env("RPC_AUTH_HOST", "localhost")
 	RPCAuthPort = Env("RPC_AUTH_PORT", "4001")
 
+	TrackerDebugAddr = Env("TRACKER_DEBUG_ADDR", "127.0.0.1:4002")
+	AuthDebugAddr = Env("AUTH_DEBUG_ADDR", "127.0.0.1:4003")
+	SchedulerDebugEndpoint = Env("SCHEDULER_DEBUG_ENDPOINT", "/debug/schedule")
+
 	BalancerSalt = Env("BALANCER_SALT", "fake_salt")
+	PersistSchedules = Env("PERSIST_SCHEDULES", false)
+	AlignedSchedules = Env("ALIGNED_SCHEDULES", false)
+	SpreadSchedules = Env("SPREAD_SCHEDULES", true)
+	DistributedTracking = Env("DISTRIBUTED_TRACKING", false)
+	WorkerID = Env("WORKER_ID", hostname())
+	TrackingRebalanceCycles = Env("TRACKING_REBALANCE_CYCLES", 1)
 
 	Debug = Env("DEBUG", false)
 	logger.SetLevel(Env("LOG_LEVEL", int8(zerolog.InfoLevel)))
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP MATERIALIZED VIEW IF EXISTS stats_weekly_clip_views;
+DROP MATERIALIZED VIEW IF EXISTS stats_weekly_games;
+DROP MATERIALIZED VIEW IF EXISTS stats_weekly_clippers;
+DROP MATERIALIZED VIEW IF EXISTS stats_weekly_clips;
+DROP MATERIALIZED VIEW IF EXISTS stats_weekly_vods;
+
+COMMIT;
@@ -0,0 +1,64 @@
+BEGIN;
+
+-- Weekly aggregations per broadcaster used by the stats package. They are
+-- refreshed concurrently by the tracker after every cycle, so each one needs a
+-- unique index.
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS stats_weekly_vods AS
+  SELECT
+    bc_id,
+    date_trunc('week', created_at) AS week,
+    count(*)::int AS vod_count,
+    sum(duration_seconds)::bigint AS stream_seconds,
+    sum(view_count)::bigint AS view_count
+  FROM vods
+  GROUP BY bc_id, week;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS stats_weekly_clips AS
+  SELECT
+    bc_id,
+    date_trunc('week', created_at) AS week,
+    count(*)::int AS clip_count,
+    sum(view_count)::bigint AS view_count
+  FROM clips
+  GROUP BY bc_id, week;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS stats_weekly_clippers AS
+  SELECT
+    bc_id,
+    date_trunc('week', created_at) AS week,
+    creator_id,
+    max(creator_name) AS creator_name,
+    count(*)::int AS clip_count,
+    sum(view_count)::bigint AS view_count
+  FROM clips
+  GROUP BY bc_id, week, creator_id;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS stats_weekly_games AS
+  SELECT
+    bc_id,
+    date_trunc('week', created_at) AS week,
+    coalesce(game_id, '') AS game_id,
+    count(*)::int AS clip_count,
+    sum(view_count)::bigint AS view_count
+  FROM clips
+  GROUP BY bc_id, week, coalesce(game_id, '');
+
+-- Clip views in logarithmic buckets: 0 = [0, 10), 1 = [10, 100), ...
+-- 5 = [100000, inf)
+CREATE MATERIALIZED VIEW IF NOT EXISTS stats_weekly_clip_views AS
+  SELECT
+    bc_id,
+    date_trunc('week', created_at) AS week,
+    least(floor(log(greatest(view_count, 1))), 5)::smallint AS bucket,
+    count(*)::int AS clip_count
+  FROM clips
+  GROUP BY bc_id, week, bucket;
+
+CREATE UNIQUE INDEX IF NOT EXISTS stats_weekly_vods_idx ON stats_weekly_vods USING btree (bc_id, week);
+CREATE UNIQUE INDEX IF NOT EXISTS stats_weekly_clips_idx ON stats_weekly_clips USING btree (bc_id, week);
+CREATE UNIQUE INDEX IF NOT EXISTS stats_weekly_clippers_idx ON stats_weekly_clippers USING btree (bc_id, week, creator_id);
+CREATE UNIQUE INDEX IF NOT EXISTS stats_weekly_games_idx ON stats_weekly_games USING btree (bc_id, week, game_id);
+CREATE UNIQUE INDEX IF NOT EXISTS stats_weekly_clip_views_idx ON stats_weekly_clip_views USING btree (bc_id, week, bucket);
+
+COMMIT;
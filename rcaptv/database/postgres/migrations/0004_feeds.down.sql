@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE tracked_channels DROP COLUMN IF EXISTS last_updated_at;
+
+COMMIT;
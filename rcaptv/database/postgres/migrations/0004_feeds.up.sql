@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Last time the tracker wrote VODs or clips of the channel. Used to invalidate
+-- cached feeds
+ALTER TABLE tracked_channels ADD COLUMN IF NOT EXISTS last_updated_at timestamp;
+
+COMMIT;
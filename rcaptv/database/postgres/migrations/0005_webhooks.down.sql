@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS endpoint_id_webhook_deliveries_idx;
+DROP INDEX IF EXISTS bc_id_webhook_endpoints_idx;
+DROP INDEX IF EXISTS user_id_webhook_endpoints_idx;
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
+
+COMMIT;
@@ -0,0 +1,38 @@
+BEGIN;
+
+-- Outgoing webhooks registered by users
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+  endpoint_id SERIAL PRIMARY KEY,
+  user_id integer NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  url text NOT NULL,
+  secret text NOT NULL,
+  -- filters, NULL matches any channel or event type
+  bc_id varchar REFERENCES tracked_channels(bc_id),
+  event_type varchar,
+  min_views int NOT NULL DEFAULT 0,
+  enabled boolean NOT NULL DEFAULT true,
+  -- failed deliveries in a row. The endpoint is disabled after too many
+  consecutive_failures int NOT NULL DEFAULT 0,
+  disabled_at timestamp,
+  created_at timestamp NOT NULL DEFAULT now()
+);
+
+-- One row per delivery attempt
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  delivery_id BIGSERIAL PRIMARY KEY,
+  endpoint_id integer NOT NULL REFERENCES webhook_endpoints(endpoint_id) ON DELETE CASCADE,
+  event_id varchar NOT NULL,
+  event_type varchar NOT NULL,
+  attempt int NOT NULL,
+  success boolean NOT NULL,
+  status_code int,
+  error text,
+  duration_ms int NOT NULL,
+  created_at timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS user_id_webhook_endpoints_idx ON webhook_endpoints USING btree (user_id);
+CREATE INDEX IF NOT EXISTS bc_id_webhook_endpoints_idx ON webhook_endpoints USING btree (bc_id);
+CREATE INDEX IF NOT EXISTS endpoint_id_webhook_deliveries_idx ON webhook_deliveries USING btree (endpoint_id, created_at DESC);
+
+COMMIT;
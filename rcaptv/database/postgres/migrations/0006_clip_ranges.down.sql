@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS bc_id_incomplete_clip_ranges_idx;
+
+DROP TABLE IF EXISTS incomplete_clip_ranges;
+
+COMMIT;
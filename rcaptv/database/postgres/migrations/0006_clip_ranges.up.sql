@@ -0,0 +1,19 @@
+BEGIN;
+
+-- Time ranges of the clips of a channel that reached the max deep level of
+-- the tracker before the views threshold. They are retried deeper later
+CREATE TABLE IF NOT EXISTS incomplete_clip_ranges (
+  range_id BIGSERIAL PRIMARY KEY,
+  bc_id varchar NOT NULL REFERENCES tracked_channels(bc_id) ON DELETE CASCADE,
+  started_at timestamp NOT NULL,
+  ended_at timestamp NOT NULL,
+  -- deep level reached when the range was found incomplete
+  depth int NOT NULL,
+  retries int NOT NULL DEFAULT 0,
+  created_at timestamp NOT NULL DEFAULT now(),
+  UNIQUE (bc_id, started_at, ended_at)
+);
+
+CREATE INDEX IF NOT EXISTS bc_id_incomplete_clip_ranges_idx ON incomplete_clip_ranges USING btree (bc_id, started_at);
+
+COMMIT;
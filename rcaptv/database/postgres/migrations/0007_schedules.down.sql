@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS schedule_keys;
+
+DROP TABLE IF EXISTS schedule_cursors;
+
+COMMIT;
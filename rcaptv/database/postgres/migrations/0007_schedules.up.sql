@@ -0,0 +1,20 @@
+BEGIN;
+
+-- Cursor of the schedules (tracker, token validator...), the last minute of
+-- the cycle picked and when, so they resume at the same minute after a restart
+CREATE TABLE IF NOT EXISTS schedule_cursors (
+  schedule_name varchar PRIMARY KEY,
+  minute int NOT NULL,
+  cycle_size int NOT NULL,
+  picked_at timestamp NOT NULL
+);
+
+-- Minute of the cycle assigned to every key of a schedule
+CREATE TABLE IF NOT EXISTS schedule_keys (
+  schedule_name varchar NOT NULL,
+  key varchar NOT NULL,
+  minute int NOT NULL,
+  PRIMARY KEY (schedule_name, key)
+);
+
+COMMIT;
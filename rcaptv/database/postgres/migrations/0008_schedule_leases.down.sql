@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS schedule_runs;
+
+DROP TABLE IF EXISTS schedule_workers;
+
+COMMIT;
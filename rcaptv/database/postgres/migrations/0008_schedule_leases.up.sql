@@ -0,0 +1,23 @@
+BEGIN;
+
+-- Workers of a distributed schedule. A worker is dead if its last heartbeat
+-- is older than the lease TTL
+CREATE TABLE IF NOT EXISTS schedule_workers (
+  schedule_name varchar NOT NULL,
+  worker_id varchar NOT NULL,
+  heartbeat_at timestamp NOT NULL DEFAULT now(),
+  PRIMARY KEY (schedule_name, worker_id)
+);
+
+-- Runs of the minutes of a distributed schedule. slot is the number of
+-- minutes since the Unix epoch. worker_id is NULL if the run was released
+CREATE TABLE IF NOT EXISTS schedule_runs (
+  schedule_name varchar NOT NULL,
+  slot bigint NOT NULL,
+  worker_id varchar,
+  finished boolean NOT NULL DEFAULT false,
+  claimed_at timestamp NOT NULL DEFAULT now(),
+  PRIMARY KEY (schedule_name, slot)
+);
+
+COMMIT;
@@ -0,0 +1,317 @@
+// Package export streams tracked VODs and clips of a broadcaster in CSV, JSON
+// Lines or Parquet.
+//
+// Rows are read through a server-side cursor and written as they are fetched,
+// so memory usage doesn't depend on the number of exported rows.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidKind     = errors.New("invalid kind, must be 'clips' or 'vods'")
+	ErrInvalidFormat   = errors.New("invalid format, must be 'csv', 'jsonl' or 'parquet'")
+	ErrInvalidColumn   = errors.New("invalid column")
+	ErrMissingBcID     = errors.New("missing broadcaster id")
+	ErrInvalidPeriod   = errors.New("ended_at must be after started_at")
+	ErrDuplicateColumn = errors.New("duplicate column")
+)
+
+type Kind string
+
+const (
+	KindClips Kind = "clips"
+	KindVods  Kind = "vods"
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"
+	FormatParquet Format = "parquet"
+)
+
+// ContentType of the format, to be used in HTTP responses
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv"
+	}
+}
+
+type colType int
+
+const (
+	colString colType = iota
+	colInt
+	colFloat
+	colTime
+)
+
+type column struct {
+	name     string
+	typ      colType
+	nullable bool
+}
+
+// columns by kind in the default export order
+var columns = map[Kind][]column{
+	KindClips: {
+		{"clip_id", colString, false},
+		{"bc_id", colString, false},
+		{"video_id", colString, true},
+		{"created_at", colTime, false},
+		{"creator_id", colString, false},
+		{"creator_name", colString, false},
+		{"title", colString, false},
+		{"game_id", colString, true},
+		{"lang", colString, false},
+		{"thumbnail_url", colString, false},
+		{"duration_seconds", colFloat, false},
+		{"view_count", colInt, false},
+		{"vod_offset", colInt, true},
+	},
+	KindVods: {
+		{"video_id", colString, false},
+		{"stream_id", colString, false},
+		{"bc_id", colString, false},
+		{"created_at", colTime, false},
+		{"published_at", colTime, false},
+		{"duration_seconds", colInt, false},
+		{"lang", colString, false},
+		{"thumbnail_url", colString, false},
+		{"title", colString, false},
+		{"view_count", colInt, false},
+	},
+}
+
+// Columns returns the columns that can be exported for kind k
+func Columns(k Kind) []string {
+	cols := columns[k]
+	r := make([]string, 0, len(cols))
+	for _, c := range cols {
+		r = append(r, c.name)
+	}
+	return r
+}
+
+// DefaultBatchSize is the number of rows fetched from the cursor at once
+const DefaultBatchSize = 1000
+
+type Params struct {
+	Kind          Kind
+	Format        Format
+	BroadcasterID string
+	// StartedAt and EndedAt filter rows by created_at in [StartedAt, EndedAt).
+	// A zero value means no bound.
+	StartedAt time.Time
+	EndedAt   time.Time
+	// Columns to export, in order. All columns if empty. See Columns
+	Columns []string
+	// BatchSize is the number of rows fetched from the cursor at once. Defaults
+	// to DefaultBatchSize
+	BatchSize int
+
+	Context context.Context
+}
+
+// Validate checks p and fills in the defaults. It's called by Export, but it
+// can be called beforehand to report errors before starting to write.
+func (p *Params) Validate() error {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	if p.BatchSize <= 0 {
+		p.BatchSize = DefaultBatchSize
+	}
+	if p.Format == "" {
+		p.Format = FormatCSV
+	}
+	switch p.Format {
+	case FormatCSV, FormatJSONL, FormatParquet:
+	default:
+		return ErrInvalidFormat
+	}
+	if p.BroadcasterID == "" {
+		return ErrMissingBcID
+	}
+	if !p.StartedAt.IsZero() && !p.EndedAt.IsZero() && !p.EndedAt.After(p.StartedAt) {
+		return ErrInvalidPeriod
+	}
+	_, err := p.selected()
+	return err
+}
+
+// selected returns the columns selected by p
+func (p *Params) selected() ([]column, error) {
+	cols, ok := columns[p.Kind]
+	if !ok {
+		return nil, ErrInvalidKind
+	}
+	if len(p.Columns) == 0 {
+		return cols, nil
+	}
+	r := make([]column, 0, len(p.Columns))
+	seen := make(map[string]bool, len(p.Columns))
+	for _, name := range p.Columns {
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateColumn, name)
+		}
+		seen[name] = true
+		found := false
+		for _, c := range cols {
+			if c.name == name {
+				r = append(r, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidColumn, name)
+		}
+	}
+	return r, nil
+}
+
+// Export writes the rows selected by p to w in p.Format, returning the number
+// of rows written.
+//
+// Rows are sorted by created_at and read in batches of p.BatchSize from a
+// server-side cursor inside a read-only transaction.
+func Export(db *sql.DB, w io.Writer, p *Params) (int64, error) {
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+	cols, _ := p.selected()
+
+	tx, err := db.BeginTx(p.Context, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	// the transaction is never committed, closing it closes the cursor too
+	defer tx.Rollback()
+
+	query, args := p.query(cols)
+	if _, err := tx.ExecContext(p.Context, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return 0, fmt.Errorf("declare cursor: %w", err)
+	}
+
+	rw, err := newRowWriter(p.Format, w, cols)
+	if err != nil {
+		return 0, err
+	}
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_cursor", p.BatchSize)
+	row := make([]any, len(cols))
+	var n int64
+	for {
+		rows, err := tx.QueryContext(p.Context, fetch)
+		if err != nil {
+			return n, fmt.Errorf("fetch: %w", err)
+		}
+		fetched := 0
+		for rows.Next() {
+			if err := scanRow(rows, cols, row); err != nil {
+				rows.Close()
+				return n, err
+			}
+			if err := rw.WriteRow(row); err != nil {
+				rows.Close()
+				return n, err
+			}
+			fetched++
+			n++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return n, fmt.Errorf("fetch: %w", err)
+		}
+		if fetched < p.BatchSize {
+			break
+		}
+	}
+	return n, rw.Close()
+}
+
+// query builds the cursor query. Column names come from the columns table so
+// they are safe to be interpolated.
+func (p *Params) query(cols []column) (string, []any) {
+	names := make([]string, 0, len(cols))
+	for _, c := range cols {
+		names = append(names, c.name)
+	}
+	var pk string
+	if p.Kind == KindClips {
+		pk = "clip_id"
+	} else {
+		pk = "video_id"
+	}
+
+	args := []any{p.BroadcasterID}
+	where := []string{"bc_id = $1"}
+	if !p.StartedAt.IsZero() {
+		args = append(args, p.StartedAt.UTC())
+		where = append(where, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !p.EndedAt.IsZero() {
+		args = append(args, p.EndedAt.UTC())
+		where = append(where, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY created_at ASC, %s ASC",
+		strings.Join(names, ", "), p.Kind, strings.Join(where, " AND "), pk,
+	), args
+}
+
+// scanRow scans the current row into row. Values are nil, string, int64,
+// float64 or time.Time depending on the column type.
+func scanRow(rows *sql.Rows, cols []column, row []any) error {
+	dest := make([]any, len(cols))
+	for i, c := range cols {
+		switch c.typ {
+		case colString:
+			dest[i] = new(sql.NullString)
+		case colInt:
+			dest[i] = new(sql.NullInt64)
+		case colFloat:
+			dest[i] = new(sql.NullFloat64)
+		case colTime:
+			dest[i] = new(sql.NullTime)
+		}
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	for i, d := range dest {
+		row[i] = nil
+		switch v := d.(type) {
+		case *sql.NullString:
+			if v.Valid {
+				row[i] = v.String
+			}
+		case *sql.NullInt64:
+			if v.Valid {
+				row[i] = v.Int64
+			}
+		case *sql.NullFloat64:
+			if v.Valid {
+				row[i] = v.Float64
+			}
+		case *sql.NullTime:
+			if v.Valid {
+				row[i] = v.Time.UTC()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bytes"
+	"database/sql"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/test"
+)
+
+var db *sql.DB
+
+func TestMain(m *testing.M) {
+	conn, pool, res := test.SetupPostgres()
+	db = conn
+
+	// Run tests
+	code := m.Run()
+
+	if err := test.CancelPostgres(pool, res); err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(code)
+}
+
+func TestExportVods(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	n, err := Export(db, &buf, &Params{
+		Kind:          KindVods,
+		Format:        FormatCSV,
+		BroadcasterID: "58753574",
+		StartedAt:     time.Date(2023, 6, 14, 8, 0, 0, 0, time.UTC),
+		Columns:       []string{"video_id", "created_at", "duration_seconds"},
+		// force several fetches from the cursor
+		BatchSize: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `video_id,created_at,duration_seconds
+1846472757,2023-06-14T23:21:38Z,4000
+1849313047,2023-06-18T08:10:51Z,26420
+1849520474,2023-06-18T15:31:56Z,1870
+`
+	if n != 3 {
+		t.Fatalf("expected 3 rows, got %d", n)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected csv, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExportEmpty(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	n, err := Export(db, &buf, &Params{
+		Kind:          KindClips,
+		Format:        FormatJSONL,
+		BroadcasterID: "1234",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || buf.Len() != 0 {
+		t.Fatalf("expected no rows, got %d (%q)", n, buf.String())
+	}
+}
+
+func TestExportInvalidParams(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		p   *Params
+		err string
+	}{
+		{&Params{Kind: KindVods}, ErrMissingBcID.Error()},
+		{&Params{Kind: KindVods, BroadcasterID: "1", Format: "xml"}, ErrInvalidFormat.Error()},
+		{&Params{Kind: KindVods, BroadcasterID: "1", Columns: []string{"clip_id"}}, ErrInvalidColumn.Error()},
+		{&Params{
+			Kind:          KindVods,
+			BroadcasterID: "1",
+			StartedAt:     time.Date(2023, 6, 14, 0, 0, 0, 0, time.UTC),
+			EndedAt:       time.Date(2023, 6, 13, 0, 0, 0, 0, time.UTC),
+		}, ErrInvalidPeriod.Error()},
+	}
+	for _, tt := range tests {
+		_, err := Export(db, &bytes.Buffer{}, tt.p)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Fatalf("expected error %q, got %v", tt.err, err)
+		}
+	}
+}
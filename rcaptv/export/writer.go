@@ -0,0 +1,187 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+)
+
+// rowWriter writes rows of values as returned by scanRow
+type rowWriter interface {
+	WriteRow(row []any) error
+	// Close flushes any buffered data. It doesn't close the underlying writer
+	Close() error
+}
+
+func newRowWriter(f Format, w io.Writer, cols []column) (rowWriter, error) {
+	switch f {
+	case FormatJSONL:
+		return newJSONLWriter(w, cols), nil
+	case FormatParquet:
+		return newParquetWriter(w, cols)
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	}
+	return nil, ErrInvalidFormat
+}
+
+type csvWriter struct {
+	w   *csv.Writer
+	rec []string
+}
+
+// newCSVWriter writes the header right away
+func newCSVWriter(w io.Writer, cols []column) (*csvWriter, error) {
+	cw := &csvWriter{
+		w:   csv.NewWriter(w),
+		rec: make([]string, len(cols)),
+	}
+	for i, c := range cols {
+		cw.rec[i] = c.name
+	}
+	if err := cw.w.Write(cw.rec); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// WriteRow writes null values as empty fields and times in RFC3339
+func (cw *csvWriter) WriteRow(row []any) error {
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			cw.rec[i] = ""
+		case string:
+			cw.rec[i] = v
+		case int64:
+			cw.rec[i] = strconv.FormatInt(v, 10)
+		case float64:
+			cw.rec[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case time.Time:
+			cw.rec[i] = v.Format(time.RFC3339)
+		}
+	}
+	return cw.w.Write(cw.rec)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonlWriter struct {
+	w *bufio.Writer
+	// keys are the already encoded column names
+	keys [][]byte
+}
+
+func newJSONLWriter(w io.Writer, cols []column) *jsonlWriter {
+	jw := &jsonlWriter{
+		w:    bufio.NewWriter(w),
+		keys: make([][]byte, len(cols)),
+	}
+	for i, c := range cols {
+		jw.keys[i], _ = json.Marshal(c.name)
+	}
+	return jw
+}
+
+// WriteRow writes a JSON object per line with the keys in column order
+func (jw *jsonlWriter) WriteRow(row []any) error {
+	jw.w.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			jw.w.WriteByte(',')
+		}
+		jw.w.Write(jw.keys[i])
+		jw.w.WriteByte(':')
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		jw.w.Write(b)
+	}
+	jw.w.WriteByte('}')
+	return jw.w.WriteByte('\n')
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.w.Flush()
+}
+
+// parquetRowGroupSize is the approximate size in bytes of the data buffered
+// by the parquet writer before flushing it as a row group
+const parquetRowGroupSize = 16 * 1024 * 1024
+
+type parquetWriter struct {
+	w    *goparquet.FileWriter
+	cols []column
+}
+
+func newParquetWriter(w io.Writer, cols []column) (*parquetWriter, error) {
+	var sb strings.Builder
+	sb.WriteString("message export {\n")
+	for _, c := range cols {
+		rep := "required"
+		if c.nullable {
+			rep = "optional"
+		}
+		var typ string
+		switch c.typ {
+		case colString:
+			typ = "binary %s (STRING)"
+		case colInt:
+			typ = "int64 %s"
+		case colFloat:
+			typ = "double %s"
+		case colTime:
+			typ = "int64 %s (TIMESTAMP(MILLIS, true))"
+		}
+		fmt.Fprintf(&sb, "  %s "+typ+";\n", rep, c.name)
+	}
+	sb.WriteString("}\n")
+	sd, err := parquetschema.ParseSchemaDefinition(sb.String())
+	if err != nil {
+		return nil, err
+	}
+	return &parquetWriter{
+		w: goparquet.NewFileWriter(w,
+			goparquet.WithSchemaDefinition(sd),
+			goparquet.WithCompressionCodec(parquet.CompressionCodec_SNAPPY),
+			goparquet.WithMaxRowGroupSize(parquetRowGroupSize),
+		),
+		cols: cols,
+	}, nil
+}
+
+// WriteRow leaves null values out of the record, which is how optional
+// fields are encoded
+func (pw *parquetWriter) WriteRow(row []any) error {
+	rec := make(map[string]any, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case string:
+			rec[pw.cols[i].name] = []byte(v)
+		case time.Time:
+			rec[pw.cols[i].name] = v.UnixMilli()
+		default:
+			rec[pw.cols[i].name] = v
+		}
+	}
+	return pw.w.AddData(rec)
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.w.Close()
+}
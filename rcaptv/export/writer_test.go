@@ -0,0 +1,128 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	goparquet "github.com/fraugster/parquet-go"
+)
+
+var testRows = [][]any{
+	{"clip1", nil, time.Date(2023, 6, 18, 10, 0, 0, 0, time.UTC), 10.5, int64(100)},
+	{"clip,2", "game1", time.Date(2023, 6, 18, 11, 0, 0, 0, time.UTC), float64(30), int64(2000)},
+}
+
+var testCols = []column{
+	{"clip_id", colString, false},
+	{"game_id", colString, true},
+	{"created_at", colTime, false},
+	{"duration_seconds", colFloat, false},
+	{"view_count", colInt, false},
+}
+
+func writeRows(t *testing.T, f Format) []byte {
+	var buf bytes.Buffer
+	rw, err := newRowWriter(f, &buf, testCols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range testRows {
+		if err := rw.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	t.Parallel()
+	want := `clip_id,game_id,created_at,duration_seconds,view_count
+clip1,,2023-06-18T10:00:00Z,10.5,100
+"clip,2",game1,2023-06-18T11:00:00Z,30,2000
+`
+	if got := string(writeRows(t, FormatCSV)); got != want {
+		t.Fatalf("unexpected csv, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	t.Parallel()
+	want := `{"clip_id":"clip1","game_id":null,"created_at":"2023-06-18T10:00:00Z","duration_seconds":10.5,"view_count":100}
+{"clip_id":"clip,2","game_id":"game1","created_at":"2023-06-18T11:00:00Z","duration_seconds":30,"view_count":2000}
+`
+	if got := string(writeRows(t, FormatJSONL)); got != want {
+		t.Fatalf("unexpected jsonl, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	t.Parallel()
+	b := writeRows(t, FormatParquet)
+	r, err := goparquet.NewFileReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := r.NumRows(); n != int64(len(testRows)) {
+		t.Fatalf("expected %d rows, got %d", len(testRows), n)
+	}
+	first, err := r.NextRow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := first["game_id"]; ok {
+		t.Fatalf("expected null game_id, got %v", first["game_id"])
+	}
+	if string(first["clip_id"].([]byte)) != "clip1" || first["view_count"].(int64) != 100 {
+		t.Fatalf("unexpected first row %v", first)
+	}
+	second, err := r.NextRow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(second["game_id"].([]byte)) != "game1" || second["duration_seconds"].(float64) != 30 {
+		t.Fatalf("unexpected second row %v", second)
+	}
+	if ms := second["created_at"].(int64); ms != testRows[1][2].(time.Time).UnixMilli() {
+		t.Fatalf("unexpected created_at %d", ms)
+	}
+}
+
+func TestSelectedColumns(t *testing.T) {
+	t.Parallel()
+	p := &Params{
+		Kind:    KindClips,
+		Columns: []string{"view_count", "clip_id"},
+	}
+	cols, err := p.selected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 2 || cols[0].name != "view_count" || cols[1].name != "clip_id" {
+		t.Fatalf("unexpected columns %v", cols)
+	}
+	query, args := (&Params{
+		Kind:          KindClips,
+		BroadcasterID: "1234",
+		StartedAt:     time.Date(2023, 6, 18, 0, 0, 0, 0, time.UTC),
+	}).query(cols)
+	want := "SELECT view_count, clip_id FROM clips WHERE bc_id = $1 AND created_at >= $2 ORDER BY created_at ASC, clip_id ASC"
+	if query != want || len(args) != 2 {
+		t.Fatalf("unexpected query %q (args:%v)", query, args)
+	}
+
+	for _, c := range [][]string{{"password"}, {"clip_id", "clip_id"}} {
+		p.Columns = c
+		if _, err := p.selected(); err == nil || !strings.Contains(err.Error(), "column") {
+			t.Fatalf("expected column error for %v, got %v", c, err)
+		}
+	}
+	p.Kind = "users"
+	if _, err := p.selected(); err != ErrInvalidKind {
+		t.Fatalf("expected ErrInvalidKind, got %v", err)
+	}
+}
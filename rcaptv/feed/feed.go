@@ -0,0 +1,191 @@
+// Package feed renders Atom and JSON Feed documents.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+type Feed struct {
+	// ID must be a permanent and unique URI for the feed
+	ID    string
+	Title string
+	// Link is the website the feed belongs to
+	Link string
+	// FeedURL is the URL the feed is served from
+	FeedURL string
+	Icon    string
+	Author  string
+	// Updated is the last time the feed changed in a significant way. Use
+	// LastUpdated to get the most recent entry update
+	Updated time.Time
+	Entries []*Entry
+}
+
+type Entry struct {
+	// ID must be a permanent and unique URI for the entry
+	ID        string
+	Title     string
+	Link      string
+	Summary   string
+	Thumbnail string
+	Published time.Time
+	Updated   time.Time
+}
+
+// LastUpdated returns the most recent Updated time of the entries, or def if
+// there are none.
+func (f *Feed) LastUpdated(def time.Time) time.Time {
+	last := def
+	for i, e := range f.Entries {
+		if i == 0 || e.Updated.After(last) {
+			last = e.Updated
+		}
+	}
+	return last
+}
+
+const (
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	JSONContentType = "application/feed+json; charset=utf-8"
+)
+
+type atomFeed struct {
+	XMLName    xml.Name     `xml:"feed"`
+	XMLNS      string       `xml:"xmlns,attr"`
+	XMLNSMedia string       `xml:"xmlns:media,attr"`
+	ID         string       `xml:"id"`
+	Title      string       `xml:"title"`
+	Updated    string       `xml:"updated"`
+	Links      []atomLink   `xml:"link"`
+	Author     *atomAuthor  `xml:"author,omitempty"`
+	Icon       string       `xml:"icon,omitempty"`
+	Entries    []*atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string          `xml:"id"`
+	Title     string          `xml:"title"`
+	Links     []atomLink      `xml:"link"`
+	Published string          `xml:"published,omitempty"`
+	Updated   string          `xml:"updated"`
+	Summary   string          `xml:"summary,omitempty"`
+	Thumbnail *mediaThumbnail `xml:"media:thumbnail,omitempty"`
+}
+
+// mediaThumbnail is the Media RSS thumbnail element, understood by most
+// readers to show a preview of the entry
+type mediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Atom renders f as an Atom 1.0 document
+func (f *Feed) Atom() ([]byte, error) {
+	af := &atomFeed{
+		XMLNS:      "http://www.w3.org/2005/Atom",
+		XMLNSMedia: "http://search.yahoo.com/mrss/",
+		ID:         f.ID,
+		Title:      f.Title,
+		Updated:    atomTime(f.Updated),
+		Links: []atomLink{
+			{Rel: "self", Href: f.FeedURL},
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+		},
+		Icon:    f.Icon,
+		Entries: make([]*atomEntry, 0, len(f.Entries)),
+	}
+	if f.Author != "" {
+		af.Author = &atomAuthor{Name: f.Author, URI: f.Link}
+	}
+	for _, e := range f.Entries {
+		ae := &atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Links:     []atomLink{{Rel: "alternate", Type: "text/html", Href: e.Link}},
+			Published: atomTime(e.Published),
+			Updated:   atomTime(e.Updated),
+			Summary:   e.Summary,
+		}
+		if e.Thumbnail != "" {
+			ae.Links = append(ae.Links, atomLink{Rel: "enclosure", Type: "image/jpeg", Href: e.Thumbnail})
+			ae.Thumbnail = &mediaThumbnail{URL: e.Thumbnail}
+		}
+		af.Entries = append(af.Entries, ae)
+	}
+	b, err := xml.MarshalIndent(af, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+type jsonFeed struct {
+	Version     string        `json:"version"`
+	Title       string        `json:"title"`
+	HomePageURL string        `json:"home_page_url,omitempty"`
+	FeedURL     string        `json:"feed_url,omitempty"`
+	Icon        string        `json:"icon,omitempty"`
+	Authors     []*jsonAuthor `json:"authors,omitempty"`
+	Items       []*jsonItem   `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type jsonItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url,omitempty"`
+	Title         string `json:"title,omitempty"`
+	ContentText   string `json:"content_text"`
+	Image         string `json:"image,omitempty"`
+	DatePublished string `json:"date_published,omitempty"`
+	DateModified  string `json:"date_modified,omitempty"`
+}
+
+// JSON renders f as a JSON Feed 1.1 document
+func (f *Feed) JSON() ([]byte, error) {
+	jf := &jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.FeedURL,
+		Icon:        f.Icon,
+		Items:       make([]*jsonItem, 0, len(f.Entries)),
+	}
+	if f.Author != "" {
+		jf.Authors = []*jsonAuthor{{Name: f.Author, URL: f.Link}}
+	}
+	for _, e := range f.Entries {
+		jf.Items = append(jf.Items, &jsonItem{
+			ID:            e.ID,
+			URL:           e.Link,
+			Title:         e.Title,
+			ContentText:   e.Summary,
+			Image:         e.Thumbnail,
+			DatePublished: atomTime(e.Published),
+			DateModified:  atomTime(e.Updated),
+		})
+	}
+	return json.MarshalIndent(jf, "", "  ")
+}
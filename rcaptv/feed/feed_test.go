@@ -0,0 +1,109 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	return &Feed{
+		ID:      "https://example.com/feeds/illojuan/vods.atom",
+		Title:   "IlloJuan VODs",
+		Link:    "https://www.twitch.tv/illojuan",
+		FeedURL: "https://example.com/feeds/illojuan/vods.atom",
+		Icon:    "https://example.com/pp.png",
+		Author:  "IlloJuan",
+		Updated: time.Date(2023, 6, 16, 15, 36, 48, 0, time.UTC),
+		Entries: []*Entry{
+			{
+				ID:        "https://www.twitch.tv/videos/1847800606",
+				Title:     "Bellum #6 <&>",
+				Link:      "https://www.twitch.tv/videos/1847800606",
+				Summary:   "6h52m50s streamed",
+				Thumbnail: "https://example.com/thumb-320x180.jpg",
+				Published: time.Date(2023, 6, 16, 15, 36, 48, 0, time.UTC),
+				Updated:   time.Date(2023, 6, 16, 15, 36, 48, 0, time.UTC),
+			},
+		},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	t.Parallel()
+	b, err := testFeed().Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">`,
+		`<updated>2023-06-16T15:36:48Z</updated>`,
+		`<link rel="self" href="https://example.com/feeds/illojuan/vods.atom"></link>`,
+		`<title>Bellum #6 &lt;&amp;&gt;</title>`,
+		`<link rel="enclosure" type="image/jpeg" href="https://example.com/thumb-320x180.jpg"></link>`,
+		`<media:thumbnail url="https://example.com/thumb-320x180.jpg"></media:thumbnail>`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("expected atom feed to contain %q, got:\n%s", want, s)
+		}
+	}
+	// must be well formed
+	var v struct {
+		Entries []struct {
+			ID string `xml:"id"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Entries) != 1 || v.Entries[0].ID != "https://www.twitch.tv/videos/1847800606" {
+		t.Fatalf("unexpected entries %+v", v.Entries)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+	b, err := testFeed().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got jsonFeed
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "https://jsonfeed.org/version/1.1" {
+		t.Fatalf("unexpected version %s", got.Version)
+	}
+	if len(got.Items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(got.Items))
+	}
+	item := got.Items[0]
+	if item.Image != "https://example.com/thumb-320x180.jpg" || item.DateModified != "2023-06-16T15:36:48Z" {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	if len(got.Authors) != 1 || got.Authors[0].Name != "IlloJuan" {
+		t.Fatalf("unexpected authors %+v", got.Authors)
+	}
+}
+
+func TestLastUpdated(t *testing.T) {
+	t.Parallel()
+	def := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := &Feed{}
+	if got := f.LastUpdated(def); !got.Equal(def) {
+		t.Fatalf("expected default %s, got %s", def, got)
+	}
+	newest := time.Date(2023, 6, 18, 0, 0, 0, 0, time.UTC)
+	f.Entries = []*Entry{
+		{Updated: time.Date(2023, 6, 16, 0, 0, 0, 0, time.UTC)},
+		{Updated: newest},
+		{Updated: time.Date(2023, 6, 17, 0, 0, 0, 0, time.UTC)},
+	}
+	if got := f.LastUpdated(def); !got.Equal(newest) {
+		t.Fatalf("expected %s, got %s", newest, got)
+	}
+}
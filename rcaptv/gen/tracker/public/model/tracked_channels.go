@@ -23,4 +23,5 @@ type TrackedChannels struct {
 	EnabledStatus      *bool
 	LastModifiedStatus *time.Time
 	PriorityLvl        *int16
+	LastUpdatedAt      *time.Time
 }
@@ -11,9 +11,16 @@ package table
 // this method only once at the beginning of the program.
 func UseSchema(schema string) {
 	Clips = Clips.FromSchema(schema)
+	IncompleteClipRanges = IncompleteClipRanges.FromSchema(schema)
+	ScheduleCursors = ScheduleCursors.FromSchema(schema)
+	ScheduleKeys = ScheduleKeys.FromSchema(schema)
+	ScheduleRuns = ScheduleRuns.FromSchema(schema)
+	ScheduleWorkers = ScheduleWorkers.FromSchema(schema)
 	SchemaMigrations = SchemaMigrations.FromSchema(schema)
 	TokenPairs = TokenPairs.FromSchema(schema)
 	TrackedChannels = TrackedChannels.FromSchema(schema)
 	Users = Users.FromSchema(schema)
 	Vods = Vods.FromSchema(schema)
+	WebhookDeliveries = WebhookDeliveries.FromSchema(schema)
+	WebhookEndpoints = WebhookEndpoints.FromSchema(schema)
 }
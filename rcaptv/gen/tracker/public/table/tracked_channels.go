@@ -28,6 +28,7 @@ type trackedChannelsTable struct {
 	EnabledStatus      postgres.ColumnBool
 	LastModifiedStatus postgres.ColumnTimestamp
 	PriorityLvl        postgres.ColumnInteger
+	LastUpdatedAt      postgres.ColumnTimestamp
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -79,8 +80,9 @@ func newTrackedChannelsTableImpl(schemaName, tableName, alias string) trackedCha
 		EnabledStatusColumn      = postgres.BoolColumn("enabled_status")
 		LastModifiedStatusColumn = postgres.TimestampColumn("last_modified_status")
 		PriorityLvlColumn        = postgres.IntegerColumn("priority_lvl")
-		allColumns               = postgres.ColumnList{BcIDColumn, BcDisplayNameColumn, BcUsernameColumn, BcTypeColumn, PpURLColumn, OfflinePpURLColumn, TrackedSinceColumn, SeenInactiveCountColumn, EnabledStatusColumn, LastModifiedStatusColumn, PriorityLvlColumn}
-		mutableColumns           = postgres.ColumnList{BcDisplayNameColumn, BcUsernameColumn, BcTypeColumn, PpURLColumn, OfflinePpURLColumn, TrackedSinceColumn, SeenInactiveCountColumn, EnabledStatusColumn, LastModifiedStatusColumn, PriorityLvlColumn}
+		LastUpdatedAtColumn      = postgres.TimestampColumn("last_updated_at")
+		allColumns               = postgres.ColumnList{BcIDColumn, BcDisplayNameColumn, BcUsernameColumn, BcTypeColumn, PpURLColumn, OfflinePpURLColumn, TrackedSinceColumn, SeenInactiveCountColumn, EnabledStatusColumn, LastModifiedStatusColumn, PriorityLvlColumn, LastUpdatedAtColumn}
+		mutableColumns           = postgres.ColumnList{BcDisplayNameColumn, BcUsernameColumn, BcTypeColumn, PpURLColumn, OfflinePpURLColumn, TrackedSinceColumn, SeenInactiveCountColumn, EnabledStatusColumn, LastModifiedStatusColumn, PriorityLvlColumn, LastUpdatedAtColumn}
 	)
 
 	return trackedChannelsTable{
@@ -98,6 +100,7 @@ func newTrackedChannelsTableImpl(schemaName, tableName, alias string) trackedCha
 		EnabledStatus:      EnabledStatusColumn,
 		LastModifiedStatus: LastModifiedStatusColumn,
 		PriorityLvl:        PriorityLvlColumn,
+		LastUpdatedAt:      LastUpdatedAtColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
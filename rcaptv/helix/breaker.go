@@ -0,0 +1,255 @@
+package helix
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var ErrCircuitOpen = errors.New("twitch api circuit breaker open")
+
+// BreakerState is the state of the Twitch API circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every request with ErrCircuitOpen without sending it
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe request through at a time. The
+	// breaker closes after enough successful probes and opens again on the
+	// first failed one
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breakerBuckets is the number of buckets the failure rate window is split
+// into. Outcomes older than the window expire one bucket at a time.
+const breakerBuckets = 10
+
+type BreakerOpts struct {
+	// Window is the rolling period in which the failure rate is computed
+	Window time.Duration
+	// MinRequests is the number of requests in the window needed before the
+	// breaker can open
+	MinRequests int
+	// FailureRate from 0 to 1 of the requests in the window that failed with a
+	// 5xx response or a timeout that opens the breaker. A rate over 1 never
+	// opens it
+	FailureRate float64
+	// OpenTimeout is how long the breaker stays open before letting probe
+	// requests through
+	OpenTimeout time.Duration
+	// HalfOpenSuccesses is the number of consecutive successful probes needed
+	// to close the breaker again
+	HalfOpenSuccesses int
+	// OnStateChange is called after every state transition. Optional
+	OnStateChange func(from, to BreakerState)
+}
+
+// DefaultBreakerOpts is used when HelixOpts.Breaker is nil
+var DefaultBreakerOpts = &BreakerOpts{
+	Window:            time.Minute,
+	MinRequests:       10,
+	FailureRate:       0.5,
+	OpenTimeout:       30 * time.Second,
+	HalfOpenSuccesses: 3,
+}
+
+type breakerOutcome int
+
+const (
+	// outcomeIgnored is for requests that say nothing about the health of the
+	// API, e.g. the ones cancelled by the caller
+	outcomeIgnored breakerOutcome = iota
+	outcomeSuccess
+	outcomeFailure
+)
+
+type breakerBucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+// breaker is a circuit breaker driven by the failure rate of the requests in
+// a rolling window. The zero value is a closed breaker ready to use.
+type breaker struct {
+	mu       sync.Mutex
+	state    BreakerState
+	openedAt time.Time
+	// probing is true while the probe request of the half-open state is in
+	// flight
+	probing   bool
+	successes int
+	buckets   [breakerBuckets]breakerBucket
+}
+
+// allow checks if a request can be sent. If it is the probe of the half-open
+// state, probe is true and done must be called with its outcome to let the
+// next one through.
+func (b *breaker) allow(now time.Time, o *BreakerOpts) (probe bool, err error) {
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case BreakerClosed:
+		b.mu.Unlock()
+		return false, nil
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < o.OpenTimeout {
+			b.mu.Unlock()
+			return false, ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.successes = 0
+	}
+	if b.probing {
+		b.mu.Unlock()
+		return false, ErrCircuitOpen
+	}
+	b.probing = true
+	to := b.state
+	b.mu.Unlock()
+	if from != to {
+		b.changed(o, from, to)
+	}
+	return true, nil
+}
+
+// done records the outcome of a request allowed by allow
+func (b *breaker) done(now time.Time, o *BreakerOpts, probe bool, outcome breakerOutcome) {
+	b.mu.Lock()
+	from := b.state
+	if probe {
+		if b.state == BreakerHalfOpen {
+			b.probing = false
+			switch outcome {
+			case outcomeFailure:
+				b.open(now)
+			case outcomeSuccess:
+				b.successes++
+				if b.successes >= o.HalfOpenSuccesses {
+					b.close()
+				}
+			}
+		}
+	} else if b.state == BreakerClosed && outcome != outcomeIgnored {
+		b.record(now, o, outcome == outcomeFailure)
+		total, failures := b.counts(now, o)
+		if outcome == outcomeFailure && total >= o.MinRequests &&
+			float64(failures) >= o.FailureRate*float64(total) {
+			b.open(now)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	if from != to {
+		b.changed(o, from, to)
+	}
+}
+
+// open must be called with the lock held
+func (b *breaker) open(now time.Time) {
+	b.state = BreakerOpen
+	b.openedAt = now
+	b.probing = false
+}
+
+// close must be called with the lock held
+func (b *breaker) close() {
+	b.state = BreakerClosed
+	b.buckets = [breakerBuckets]breakerBucket{}
+}
+
+// record must be called with the lock held
+func (b *breaker) record(now time.Time, o *BreakerOpts, failed bool) {
+	width := o.Window / breakerBuckets
+	if width <= 0 {
+		width = 1
+	}
+	start := now.Truncate(width)
+	bk := &b.buckets[(start.UnixNano()/int64(width))%breakerBuckets]
+	if !bk.start.Equal(start) {
+		*bk = breakerBucket{start: start}
+	}
+	bk.total++
+	if failed {
+		bk.failures++
+	}
+}
+
+// counts must be called with the lock held
+func (b *breaker) counts(now time.Time, o *BreakerOpts) (total, failures int) {
+	from := now.Add(-o.Window)
+	for _, bk := range b.buckets {
+		if bk.start.After(from) {
+			total += bk.total
+			failures += bk.failures
+		}
+	}
+	return total, failures
+}
+
+func (b *breaker) current(now time.Time, o *BreakerOpts) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= o.OpenTimeout {
+		// the next request will be a probe
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+func (b *breaker) changed(o *BreakerOpts, from, to BreakerState) {
+	log.Warn().Str("ctx", "helix").Msgf("twitch api circuit breaker %s -> %s", from, to)
+	if o.OnStateChange != nil {
+		o.OnStateChange(from, to)
+	}
+}
+
+// outcomeOf classifies the result of a request for the breaker. Only 5xx
+// responses and timeouts are failures.
+func outcomeOf(ctx context.Context, statusCode int, err error) breakerOutcome {
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return outcomeIgnored
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return outcomeFailure
+		}
+		return outcomeIgnored
+	}
+	if statusCode >= 500 {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// BreakerState returns the state of the Twitch API circuit breaker. Callers
+// with a fallback can use it to skip the API while it is open.
+func (hx *Helix) BreakerState() BreakerState {
+	return hx.br.current(time.Now(), hx.breakerOpts())
+}
+
+func (hx *Helix) breakerOpts() *BreakerOpts {
+	if hx.opts.Breaker != nil {
+		return hx.opts.Breaker
+	}
+	return DefaultBreakerOpts
+}
@@ -0,0 +1,220 @@
+package helix
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerStates(t *testing.T) {
+	t.Parallel()
+	o := &BreakerOpts{
+		Window:            time.Minute,
+		MinRequests:       4,
+		FailureRate:       0.5,
+		OpenTimeout:       30 * time.Second,
+		HalfOpenSuccesses: 2,
+	}
+	var changes []BreakerState
+	o.OnStateChange = func(from, to BreakerState) {
+		changes = append(changes, to)
+	}
+	now := time.Date(2023, 6, 18, 10, 0, 0, 0, time.UTC)
+	var b breaker
+	request := func(outcome breakerOutcome) error {
+		probe, err := b.allow(now, o)
+		if err != nil {
+			return err
+		}
+		b.done(now, o, probe, outcome)
+		return nil
+	}
+
+	// not enough requests to open it
+	for i := 0; i < 3; i++ {
+		request(outcomeFailure)
+	}
+	if s := b.current(now, o); s != BreakerClosed {
+		t.Fatalf("expected closed breaker under MinRequests, got %s", s)
+	}
+	// ignored outcomes are not counted
+	request(outcomeIgnored)
+	if s := b.current(now, o); s != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", s)
+	}
+	request(outcomeFailure)
+	if s := b.current(now, o); s != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", s)
+	}
+	if err := request(outcomeSuccess); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// a failed probe opens it again
+	now = now.Add(o.OpenTimeout)
+	if s := b.current(now, o); s != BreakerHalfOpen {
+		t.Fatalf("expected half-open breaker after the timeout, got %s", s)
+	}
+	request(outcomeFailure)
+	if s := b.current(now, o); s != BreakerOpen {
+		t.Fatalf("expected open breaker after a failed probe, got %s", s)
+	}
+
+	// one probe at a time
+	now = now.Add(o.OpenTimeout)
+	probe, err := b.allow(now, o)
+	if err != nil || !probe {
+		t.Fatalf("expected a probe, got %v %v", probe, err)
+	}
+	if _, err := b.allow(now, o); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen while probing, got %v", err)
+	}
+	b.done(now, o, probe, outcomeSuccess)
+	if s := b.current(now, o); s != BreakerHalfOpen {
+		t.Fatalf("expected half-open breaker after 1 probe, got %s", s)
+	}
+	request(outcomeSuccess)
+	if s := b.current(now, o); s != BreakerClosed {
+		t.Fatalf("expected closed breaker after 2 probes, got %s", s)
+	}
+
+	// failures expire with the window
+	for i := 0; i < 3; i++ {
+		request(outcomeFailure)
+	}
+	now = now.Add(o.Window)
+	request(outcomeFailure)
+	if s := b.current(now, o); s != BreakerClosed {
+		t.Fatalf("expected closed breaker after the failures expired, got %s", s)
+	}
+
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("expected state changes %v, got %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("expected state changes %v, got %v", want, changes)
+		}
+	}
+}
+
+func TestBreakerOutcome(t *testing.T) {
+	t.Parallel()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout := &timeoutErr{}
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+		err    error
+		want   breakerOutcome
+	}{
+		{"ok", context.Background(), http.StatusOK, nil, outcomeSuccess},
+		{"client error", context.Background(), http.StatusBadRequest, nil, outcomeSuccess},
+		{"rate limited", context.Background(), http.StatusTooManyRequests, nil, outcomeSuccess},
+		{"server error", context.Background(), http.StatusInternalServerError, nil, outcomeFailure},
+		{"unavailable", context.Background(), http.StatusServiceUnavailable, nil, outcomeFailure},
+		{"timeout", context.Background(), 0, timeout, outcomeFailure},
+		{"other error", context.Background(), 0, errors.New("connection reset"), outcomeIgnored},
+		{"cancelled", cancelled, 0, timeout, outcomeIgnored},
+	}
+	for _, tt := range tests {
+		if got := outcomeOf(tt.ctx, tt.status, tt.err); got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+type timeoutErr struct{}
+
+func (*timeoutErr) Error() string   { return "i/o timeout" }
+func (*timeoutErr) Timeout() bool   { return true }
+func (*timeoutErr) Temporary() bool { return true }
+
+func TestBreakerFailsFast(t *testing.T) {
+	t.Parallel()
+	var (
+		reqs    atomic.Int32
+		healthy atomic.Bool
+	)
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer sv.Close()
+	var (
+		mu      sync.Mutex
+		changes []BreakerState
+	)
+	hx := &Helix{
+		opts: &HelixOpts{
+			APIUrl:      sv.URL,
+			RetryPolicy: fastRetries,
+			Breaker: &BreakerOpts{
+				Window:            time.Minute,
+				MinRequests:       4,
+				FailureRate:       0.5,
+				OpenTimeout:       100 * time.Millisecond,
+				HalfOpenSuccesses: 1,
+				OnStateChange: func(from, to BreakerState) {
+					mu.Lock()
+					defer mu.Unlock()
+					changes = append(changes, to)
+				},
+			},
+		},
+		defaultClient: sv.Client(),
+	}
+
+	// 2 requests of 3 attempts, the 4th attempt opens the breaker and the 5th
+	// fails without being sent
+	for i := 0; i < 2; i++ {
+		if err := doFakeRequest(hx, context.Background(), sv.URL); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	if got := reqs.Load(); got != 4 {
+		t.Fatalf("expected 4 requests sent, got %d", got)
+	}
+	if s := hx.BreakerState(); s != BreakerOpen {
+		t.Fatalf("expected open breaker, got %s", s)
+	}
+	start := time.Now()
+	if err := doFakeRequest(hx, context.Background(), sv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("expected request to fail fast, took %s", elapsed)
+	}
+	if got := reqs.Load(); got != 4 {
+		t.Fatalf("expected no requests sent while open, got %d", got-4)
+	}
+
+	healthy.Store(true)
+	time.Sleep(100 * time.Millisecond)
+	if s := hx.BreakerState(); s != BreakerHalfOpen {
+		t.Fatalf("expected half-open breaker, got %s", s)
+	}
+	if err := doFakeRequest(hx, context.Background(), sv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if s := hx.BreakerState(); s != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", s)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) != 3 || changes[0] != BreakerOpen || changes[1] != BreakerHalfOpen || changes[2] != BreakerClosed {
+		t.Fatalf("unexpected state changes %v", changes)
+	}
+}
@@ -0,0 +1,366 @@
+// Package cassette records the requests of a helix client to fixture files
+// and replays them, so tests can run full flows offline against real Twitch
+// responses.
+//
+// Record a cassette running the tests with HELIX_CASSETTE_MODE=record, against
+// Twitch with real credentials or against a fakehelix server, see
+// test.ReplayHelix. Secrets and tokens are scrubbed before saving it.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	cfg "pedro.to/rcaptv/config"
+	"pedro.to/rcaptv/helix"
+)
+
+// Version of the fixture format. Cassettes of other versions must be
+// recorded again.
+const Version = 1
+
+// Redacted replaces the scrubbed values
+const Redacted = "REDACTED"
+
+var (
+	ErrVersion       = errors.New("unsupported cassette version")
+	ErrNoInteraction = errors.New("no recorded interaction for request")
+	ErrReplayMode    = errors.New("cassette is in replay mode")
+)
+
+type Mode string
+
+const (
+	ModeReplay Mode = "replay"
+	ModeRecord Mode = "record"
+)
+
+var (
+	// ScrubbedParams are the query parameters and JSON body keys whose values
+	// are replaced by Redacted
+	ScrubbedParams = []string{
+		"access_token", "refresh_token", "client_secret", "client_id", "code", "token",
+	}
+	// ScrubbedHeaders are the response headers that are never saved
+	ScrubbedHeaders = []string{"Set-Cookie", "Authorization", "Client-Id"}
+)
+
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Query is normalized, see NormalizeQuery
+	Query string `json:"query,omitempty"`
+}
+
+type Response struct {
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	// Body is set if the body is JSON, BodyText otherwise
+	Body     json.RawMessage `json:"body,omitempty"`
+	BodyText string          `json:"body_text,omitempty"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is a list of recorded interactions. Requests are matched by method,
+// path and normalized query, pagination cursors included. Interactions with
+// the same request are replayed in the order they were recorded, and the last
+// one is repeated once they are all used.
+type Cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+
+	path string
+	mode Mode
+	// ignored are the query parameters left out of the matching
+	ignored []string
+
+	mu sync.Mutex
+	// index of the interactions by request key
+	index map[string][]int
+	// served is the number of times each request key was replayed
+	served map[string]int
+}
+
+// Open a cassette. In replay mode it is loaded from path, in record mode it
+// starts empty and Save writes it to path.
+//
+// ignoredParams are left out of the matching, e.g. the started_at of requests
+// relative to the current time.
+func Open(path string, mode Mode, ignoredParams ...string) (*Cassette, error) {
+	c := &Cassette{
+		Version: Version,
+		path:    path,
+		mode:    mode,
+		ignored: ignoredParams,
+		served:  make(map[string]int),
+	}
+	switch mode {
+	case ModeRecord:
+		return c, nil
+	case ModeReplay:
+	default:
+		return nil, fmt.Errorf("invalid cassette mode %q", mode)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	if c.Version != Version {
+		return nil, fmt.Errorf("%w: %d (current: %d)", ErrVersion, c.Version, Version)
+	}
+	c.index = make(map[string][]int, len(c.Interactions))
+	for i, it := range c.Interactions {
+		k := c.key(&it.Request)
+		c.index[k] = append(c.index[k], i)
+	}
+	return c, nil
+}
+
+func (c *Cassette) Mode() Mode {
+	return c.mode
+}
+
+// Middleware returns the helix middleware of the cassette. In replay mode
+// requests never reach the network.
+func (c *Cassette) Middleware() helix.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return helix.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if c.mode == ModeReplay {
+				return c.replay(req)
+			}
+			return c.record(next, req)
+		})
+	}
+}
+
+func (c *Cassette) replay(req *http.Request) (*http.Response, error) {
+	r := newRequest(req)
+	k := c.key(&r)
+	c.mu.Lock()
+	idx, ok := c.index[k]
+	if !ok {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrNoInteraction, k)
+	}
+	n := c.served[k]
+	c.served[k]++
+	c.mu.Unlock()
+	if n >= len(idx) {
+		n = len(idx) - 1
+	}
+	it := c.Interactions[idx[n]]
+
+	body := []byte(it.Response.BodyText)
+	if it.Response.Body != nil {
+		// bodies are indented in the file
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, it.Response.Body); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+	}
+	h := make(http.Header, len(it.Response.Headers))
+	for k, v := range it.Response.Headers {
+		h[k] = append([]string(nil), v...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.Response.StatusCode, http.StatusText(it.Response.StatusCode)),
+		StatusCode:    it.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (c *Cassette) record(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	it := &Interaction{
+		Request: newRequest(req),
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    make(map[string][]string, len(resp.Header)),
+		},
+	}
+	for k, v := range resp.Header {
+		if !contains(ScrubbedHeaders, k) {
+			it.Response.Headers[k] = append([]string(nil), v...)
+		}
+	}
+	if scrubbed, err := scrubJSON(body); err == nil {
+		it.Response.Body = scrubbed
+	} else {
+		it.Response.BodyText = string(body)
+	}
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, it)
+	c.mu.Unlock()
+	return resp, nil
+}
+
+// Save writes the recorded interactions to the cassette path
+func (c *Cassette) Save() error {
+	if c.mode != ModeRecord {
+		return ErrReplayMode
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	c.mu.Lock()
+	err := enc.Encode(c)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, buf.Bytes(), 0o644)
+}
+
+// ForTest opens the cassette at path with OpenForTest and returns its
+// middleware
+func ForTest(t testing.TB, path string, ignoredParams ...string) helix.Middleware {
+	t.Helper()
+	return OpenForTest(t, path, ignoredParams...).Middleware()
+}
+
+// OpenForTest opens the cassette at path in the mode of the
+// HELIX_CASSETTE_MODE env var, replay by default. Recorded cassettes are saved
+// when the test ends.
+func OpenForTest(t testing.TB, path string, ignoredParams ...string) *Cassette {
+	t.Helper()
+	mode := Mode(cfg.Env("HELIX_CASSETTE_MODE", string(ModeReplay)))
+	c, err := Open(path, mode, ignoredParams...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.mode == ModeRecord {
+		t.Cleanup(func() {
+			if err := c.Save(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	return c
+}
+
+// key of the request used for matching
+func (c *Cassette) key(r *Request) string {
+	q := r.Query
+	if len(c.ignored) > 0 {
+		if values, err := url.ParseQuery(q); err == nil {
+			for _, p := range c.ignored {
+				values.Del(p)
+			}
+			q = values.Encode()
+		}
+	}
+	return r.Method + " " + r.Path + "?" + q
+}
+
+func newRequest(req *http.Request) Request {
+	return Request{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  NormalizeQuery(req.URL.RawQuery),
+	}
+}
+
+// NormalizeQuery sorts the parameters and their values and scrubs the
+// ScrubbedParams, so the same request always has the same query
+func NormalizeQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	for k, v := range values {
+		if contains(ScrubbedParams, k) {
+			values[k] = []string{Redacted}
+			continue
+		}
+		sort.Strings(v)
+	}
+	return values.Encode()
+}
+
+// scrubJSON replaces the values of the ScrubbedParams keys in a JSON body. It
+// fails if the body is not JSON.
+func scrubJSON(body []byte) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, errors.New("empty body")
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	// keep numbers as they are, e.g. big ids
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(scrubValue(v)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+func scrubValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, vv := range t {
+			if contains(ScrubbedParams, k) {
+				t[k] = Redacted
+				continue
+			}
+			t[k] = scrubValue(vv)
+		}
+	case []any:
+		for i, vv := range t {
+			t[i] = scrubValue(vv)
+		}
+	}
+	return v
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package cassette
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pedro.to/rcaptv/helix"
+)
+
+const (
+	page1 = `{"data":[{"id":"a","broadcaster_id":"58753574","view_count":120,"thumbnail_url":"https://clips-media-assets2.twitch.tv/a-preview-480x272.jpg?x=1&y=2"}],"pagination":{"cursor":"cursor_1"}}`
+	page2 = `{"data":[{"id":"b","broadcaster_id":"58753574","view_count":90}],"pagination":{}}`
+)
+
+func fetchClips(t *testing.T, hx *helix.Helix, apiURL string) []helix.Clip {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, apiURL+"/clips?first=1&broadcaster_id=58753574", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clips, err := helix.DoWithPagination[helix.Clip](hx, req, func(helix.Clip, []helix.Clip) bool {
+		return false
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clips
+}
+
+func TestRecordReplay(t *testing.T) {
+	t.Parallel()
+	var reqs int
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs++
+		w.Header().Set("Set-Cookie", "session=secret_session")
+		switch r.URL.Query().Get("after") {
+		case "":
+			w.Write([]byte(page1))
+		case "cursor_1":
+			w.Write([]byte(page2))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer sv.Close()
+	path := filepath.Join(t.TempDir(), "clips.json")
+
+	rec, err := Open(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hx := helix.NewWithoutExchange(&helix.HelixOpts{
+		APIUrl:      sv.URL,
+		Middlewares: []helix.Middleware{rec.Middleware()},
+	}, sv.Client())
+	recorded := fetchClips(t, hx, sv.URL)
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if reqs != 2 {
+		t.Fatalf("expected 2 requests recorded, got %d", reqs)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret_session") {
+		t.Fatalf("expected cookies to be scrubbed, got %s", b)
+	}
+	if !strings.Contains(string(b), "a-preview-480x272.jpg?x=1&y=2") {
+		t.Fatalf("expected bodies to be saved as they are, got %s", b)
+	}
+
+	// replayed without a server, the host doesn't matter
+	rep, err := Open(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hx = helix.NewWithoutExchange(&helix.HelixOpts{
+		APIUrl:      "http://twitch.invalid",
+		RetryPolicy: &helix.RetryPolicy{MaxAttempts: 1},
+		Middlewares: []helix.Middleware{rep.Middleware()},
+	})
+	for i := 0; i < 2; i++ {
+		replayed := fetchClips(t, hx, "http://twitch.invalid")
+		if len(replayed) != 2 || len(recorded) != 2 {
+			t.Fatalf("expected 2 clips, got %d recorded and %d replayed", len(recorded), len(replayed))
+		}
+		for i := range recorded {
+			if recorded[i] != replayed[i] {
+				t.Fatalf("expected %+v, got %+v", recorded[i], replayed[i])
+			}
+		}
+	}
+	if reqs != 2 {
+		t.Fatalf("expected replay to not reach the server, got %d requests", reqs)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://twitch.invalid/clips?broadcaster_id=90075649", nil)
+	if _, err := hx.Do(req); !errors.Is(err, ErrNoInteraction) {
+		t.Fatalf("expected ErrNoInteraction, got %v", err)
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	t.Parallel()
+	c := &Cassette{Version: Version}
+	for _, body := range []string{`{"n":1}`, `{"n":2}`} {
+		c.Interactions = append(c.Interactions, &Interaction{
+			Request:  Request{Method: http.MethodGet, Path: "/streams", Query: "user_id=1"},
+			Response: Response{StatusCode: http.StatusOK, Body: []byte(body)},
+		})
+	}
+	path := filepath.Join(t.TempDir(), "streams.json")
+	c.mode = ModeRecord
+	c.path = path
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := Open(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := rep.Middleware()(nil)
+	for _, want := range []string{`{"n":1}`, `{"n":2}`, `{"n":2}`} {
+		req, _ := http.NewRequest(http.MethodGet, "http://twitch.invalid/streams?user_id=1", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+	}
+}
+
+func TestOpenVersion(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "old.json")
+	if err := os.WriteFile(path, []byte(`{"version":0,"interactions":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, ModeReplay); !errors.Is(err, ErrVersion) {
+		t.Fatalf("expected ErrVersion, got %v", err)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		a, b string
+	}{
+		{"first=100&broadcaster_id=1&after=xyz", "after=xyz&broadcaster_id=1&first=100"},
+		{"id=2&id=1", "id=1&id=2"},
+		{"client_secret=abc&grant_type=client_credentials", "client_secret=def&grant_type=client_credentials"},
+	}
+	for _, tt := range tests {
+		if a, b := NormalizeQuery(tt.a), NormalizeQuery(tt.b); a != b {
+			t.Errorf("expected %q and %q to match, got %q and %q", tt.a, tt.b, a, b)
+		}
+	}
+	if q := NormalizeQuery("access_token=secret"); strings.Contains(q, "secret") {
+		t.Fatalf("expected token to be scrubbed, got %q", q)
+	}
+}
+
+func TestScrubJSON(t *testing.T) {
+	t.Parallel()
+	got, err := scrubJSON([]byte(`{"access_token":"secret","expires_in":5011271,"data":[{"refresh_token":"secret2","id":"12345678901234567890"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"access_token":"REDACTED","data":[{"id":"12345678901234567890","refresh_token":"REDACTED"}],"expires_in":5011271}`
+	if string(got) != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	if _, err := scrubJSON([]byte("not json")); err == nil {
+		t.Fatal("expected error for non JSON body")
+	}
+}
+
+func TestReplayIgnoredParams(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "clips.json")
+	body := `{"version":1,"interactions":[{"request":{"method":"GET","path":"/clips","query":"after=abc&broadcaster_id=1&started_at=2023-06-20T00%3A00%3A00Z"},"response":{"status_code":200,"body":{"data":[]}}}]}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Open(path, ModeReplay, "started_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := c.Middleware()(nil)
+	for _, q := range []string{
+		"broadcaster_id=1&after=abc&started_at=2026-01-01T00%3A00%3A00Z",
+		"broadcaster_id=1&after=abc",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://twitch.invalid/clips?"+q, nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	// cursors are never ignored
+	req, _ := http.NewRequest(http.MethodGet, "http://twitch.invalid/clips?broadcaster_id=1&after=def", nil)
+	if _, err := rt.RoundTrip(req); !errors.Is(err, ErrNoInteraction) {
+		t.Fatalf("expected ErrNoInteraction, got %v", err)
+	}
+}
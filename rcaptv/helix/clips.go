@@ -2,25 +2,26 @@ package helix
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
-	"net/http"
-	"net/url"
-	"strconv"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
 
 	"pedro.to/rcaptv/bufop"
 )
 
 type ClipsParams struct {
-	BroadcasterID string
-	GameID        string
-	StartedAt     time.Time
-	EndedAt       time.Time
-	First         int
-	After         string
+	BroadcasterID string    `query:"broadcaster_id"`
+	GameID        string    `query:"game_id"`
+	StartedAt     time.Time `query:"started_at"`
+	EndedAt       time.Time `query:"ended_at"`
+	First         int       `query:"first"`
+	After         string    `query:"after"`
 	// Views threshold to stop fetching for more clips. The threshold is the
 	// minimum average views of the clips in the window to be returned. When the
 	// average in the rolling window is less than the threshold when checking a
@@ -60,41 +61,9 @@ type ClipResponse struct {
 }
 
 func (hx *Helix) Clips(p *ClipsParams) (*ClipResponse, error) {
-	params := url.Values{}
-
-	if p.BroadcasterID != "" {
-		params.Add("broadcaster_id", p.BroadcasterID)
-	}
-	if p.GameID != "" {
-		params.Add("game_id", p.GameID)
-	}
-	if !p.StartedAt.IsZero() {
-		params.Add("started_at", p.StartedAt.Format(time.RFC3339))
-	}
-	if !p.EndedAt.IsZero() {
-		params.Add("ended_at", p.EndedAt.Format(time.RFC3339))
-	}
-	if p.After != "" {
-		params.Add("after", p.After)
-	}
 	if p.First == 0 {
 		p.First = 100
 	}
-	params.Add("first", strconv.Itoa(p.First))
-
-	if p.Context == nil {
-		p.Context = context.Background()
-	}
-	req, err := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s/clips?%s", hx.APIUrl(), params.Encode()),
-		nil,
-	)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(p.Context)
-
 	if p.ViewsThresholdWindowSize == 0 {
 		p.ViewsThresholdWindowSize = 4
 	}
@@ -112,7 +81,7 @@ func (hx *Helix) Clips(p *ClipsParams) (*ClipResponse, error) {
 	bop := bufop.New(p.ViewsThresholdWindowSize)
 	t := float32(p.StopViewsThreshold)
 	stopped := false
-	clips, err := DoWithPagination[*Clip](hx, req, func(item *Clip, all []*Clip) bool {
+	clips, err := Paginate(p.Context, hx, "/clips", p, func(item *Clip, all []*Clip) bool {
 		bop.PutInt(item.ViewCount)
 		if bop.Avg() < t {
 			stopped = true
@@ -129,8 +98,99 @@ func (hx *Helix) Clips(p *ClipsParams) (*ClipResponse, error) {
 type DeepClipsParams struct {
 	*ClipsParams
 	MaxDeepLvl int
+	// Concurrency is the max number of sub-ranges fetched at the same time.
+	// It is bounded by the remaining rate limit budget when DeepClips starts.
+	// Defaults to 1, fetching the sub-ranges sequentially
+	Concurrency int
+	// Split decides the sub-ranges of an incomplete range. Defaults to
+	// BisectSplit
+	Split SplitStrategy
+}
+
+// ClipsRange is a time window of the clips requested by DeepClips
+type ClipsRange struct {
+	From time.Time
+	To   time.Time
+}
 
-	windowHours float64
+// RangeStatus is the completeness of a sub-range of a DeepClips
+type RangeStatus string
+
+const (
+	// RangeComplete sub-ranges reached the views threshold, so every clip
+	// above it was fetched
+	RangeComplete RangeStatus = "complete"
+	// RangeIncomplete sub-ranges reached MaxDeepLvl before the views
+	// threshold. Clips above the threshold may be missing
+	RangeIncomplete RangeStatus = "incomplete"
+	// RangePending sub-ranges were not fetched because the context was
+	// cancelled
+	RangePending RangeStatus = "pending"
+)
+
+// RangeResult is a sub-range that was not split further by DeepClips
+type RangeResult struct {
+	ClipsRange
+	Status RangeStatus
+	// Depth is the deep level of the sub-range, 1 for the whole window
+	Depth int
+	// Clips is the number of clips fetched for the sub-range
+	Clips int
+}
+
+// DeepClipsResult are the clips of a DeepClips and how complete they are
+type DeepClipsResult struct {
+	Clips []*Clip
+	// Ranges are the sub-ranges that were not split further, sorted by From.
+	// Together they cover the whole window
+	Ranges []RangeResult
+	// Requests is the number of requests made, including pagination and
+	// retries
+	Requests int
+	// Depth is the deepest level reached, 1 if the window wasn't split
+	Depth int
+}
+
+// Complete is true if every sub-range is complete
+func (r *DeepClipsResult) Complete() bool {
+	for _, rr := range r.Ranges {
+		if rr.Status != RangeComplete {
+			return false
+		}
+	}
+	return true
+}
+
+// Incomplete returns the sub-ranges that are not complete, to be retried
+// later with a deeper MaxDeepLvl
+func (r *DeepClipsResult) Incomplete() []RangeResult {
+	var ranges []RangeResult
+	for _, rr := range r.Ranges {
+		if rr.Status != RangeComplete {
+			ranges = append(ranges, rr)
+		}
+	}
+	return ranges
+}
+
+// PartialClipsError is returned by DeepClips along with the clips fetched so
+// far when the context is cancelled before every sub-range is fetched
+type PartialClipsError struct {
+	// Pending are the sub-ranges that were not fetched, sorted by From. Their
+	// clips are missing from the result
+	Pending []ClipsRange
+	// Fetched is the number of sub-ranges that were fetched
+	Fetched int
+	Err     error
+}
+
+func (e *PartialClipsError) Error() string {
+	return fmt.Sprintf("incomplete clips, %d sub-ranges fetched and %d pending: %s",
+		e.Fetched, len(e.Pending), e.Err.Error())
+}
+
+func (e *PartialClipsError) Unwrap() error {
+	return e.Err
 }
 
 // DeepClips is similar to Clips but it will try to fetch all the clips if the
@@ -148,9 +208,9 @@ type DeepClipsParams struct {
 // the specified threshold and period range, just like Clips() would do. Then
 // if the stop function was never invoked, that is: we went through all the
 // clips and the min. threshold was never met, we consider the request
-// incomplete and we divide the period (or time window) in half and try again
-// and we go on recursively until every response is considered complete or the
-// MaxDeepLvl is reached. The result is the sum of all the completed responses
+// incomplete and we divide the period (or time window) in half, or as decided
+// by p.Split, and try again and we go on recursively until every response is
+// considered complete or the MaxDeepLvl is reached. The result is the sum of all the completed responses
 // corresponding to every period part. The result periods should be exclusive
 // but because the dynamic nature of the API and the pagination, some clips may
 // be duplicated between Clips() results even if we deduplicate within Clips().
@@ -158,72 +218,203 @@ type DeepClipsParams struct {
 //
 // For example, if the window is initially set to 168 hours (7 days), and the
 // response is marked as incomplete, we would perform another 2 requests for
-// the ranges 0-84 and 84-168 hours in the corresponding time window
-func (hx *Helix) DeepClips(p *DeepClipsParams) ([]*Clip, error) {
-	p.windowHours = p.EndedAt.Sub(p.StartedAt).Hours()
+// the ranges 0-84 and 84-168 hours in the corresponding time window.
+//
+// Sibling sub-ranges are fetched in parallel up to p.Concurrency requests.
+// The clips are merged in the order of their sub-ranges, so the result is the
+// same as fetching them sequentially. If the context is cancelled, the clips
+// fetched so far are returned with a *PartialClipsError.
+//
+// The result reports the completeness of every sub-range, so callers can tell
+// complete results from the ones that reached MaxDeepLvl, see
+// DeepClipsResult.Incomplete. The result is nil only for errors other than
+// *PartialClipsError.
+func (hx *Helix) DeepClips(p *DeepClipsParams) (*DeepClipsResult, error) {
 	skipDedup := p.SkipDeduplication
 	// skip dedup anyway for every hx.Clips, since we'll perform our own
 	// deduplication afterwards.
 	p.SkipDeduplication = true
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	if skipDedup {
+	f := &deepFetcher{
+		hx:    hx,
+		p:     p,
+		ctx:   ctx,
+		sem:   make(chan struct{}, hx.deepClipsConcurrency(p.Concurrency)),
+		split: p.Split,
+	}
+	if f.split == nil {
+		f.split = BisectSplit{}
+	}
+	clips, err := f.fetch(ContextWithRequestCounter(ctx, &f.requests), 1, p.StartedAt, p.EndedAt)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(f.ranges, func(i, j int) bool {
+		return f.ranges[i].From.Before(f.ranges[j].From)
+	})
+	res := &DeepClipsResult{
+		Clips:    clips,
+		Ranges:   f.ranges,
+		Requests: int(f.requests.Load()),
+		Depth:    f.depth,
+	}
+	if !skipDedup {
 		// if p.SkipDeduplication=true was explicity passed down, skip our
 		// deduplication too
-		return hx.deepFetchClips(*p, 1, p.StartedAt, p.EndedAt)
+		res.Clips = Deduplicate(clips, func(c *Clip) string {
+			return c.ClipID
+		})
+	}
+
+	var pending []ClipsRange
+	for _, rr := range f.ranges {
+		if rr.Status == RangePending {
+			pending = append(pending, rr.ClipsRange)
+		}
+	}
+	if len(pending) > 0 {
+		return res, &PartialClipsError{
+			Pending: pending,
+			Fetched: f.fetched,
+			Err:     ctx.Err(),
+		}
 	}
-	clips, err := hx.deepFetchClips(*p, 1, p.StartedAt, p.EndedAt)
-	return Deduplicate(clips, func(c *Clip) string {
-		return c.ClipID
-	}), err
+	return res, nil
+}
+
+// deepClipsConcurrency bounds the requested concurrency by the rate limit
+// budget, so the requests of a DeepClips don't wait for the bucket to refill
+// while they hold a slot
+func (hx *Helix) deepClipsConcurrency(n int) int {
+	if n < 1 {
+		n = 1
+	}
+	b := hx.RateLimitBudget()
+	if !b.Known {
+		return n
+	}
+	if avail := b.Remaining - hx.opts.RateLimitReserve; avail < n {
+		n = avail
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// deepFetcher walks the sub-ranges of a DeepClips. sem bounds the number of
+// requests in flight and not the number of goroutines, so parent ranges
+// waiting for their children don't hold a slot.
+type deepFetcher struct {
+	hx *Helix
+	p  *DeepClipsParams
+	// ctx is the context of the caller. Sub-ranges that fail because it is done
+	// are reported as pending instead of failing the whole DeepClips
+	ctx   context.Context
+	sem   chan struct{}
+	split SplitStrategy
+
+	requests atomic.Int64
+
+	mu sync.Mutex
+	// ranges are the leaves of the split
+	ranges  []RangeResult
+	fetched int
+	depth   int
 }
 
-func (hx *Helix) deepFetchClips(p DeepClipsParams, lvl int, from time.Time, to time.Time) ([]*Clip, error) {
+func (f *deepFetcher) leaf(r RangeResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ranges = append(f.ranges, r)
+}
+
+func (f *deepFetcher) fetch(ctx context.Context, lvl int, from time.Time, to time.Time) ([]*Clip, error) {
 	l := log.With().Str("ctx", "helix").Logger()
-	clipsResp, err := hx.Clips(&ClipsParams{
-		BroadcasterID:            p.BroadcasterID,
-		StopViewsThreshold:       p.StopViewsThreshold,
-		ViewsThresholdWindowSize: p.ViewsThresholdWindowSize,
-		Context:                  p.Context,
-		SkipDeduplication:        p.SkipDeduplication,
-		StartedAt:                from,
-		EndedAt:                  to,
-	})
+	clipsResp, err := f.clips(ctx, from, to)
+	if lvl > 1 && errors.Is(err, ErrItemsEmpty) {
+		// only an empty window is an error, not an empty sub-range of it
+		clipsResp, err = &ClipResponse{IsComplete: true}, nil
+	}
 	if err != nil {
+		if f.ctx.Err() != nil {
+			f.leaf(RangeResult{ClipsRange{from, to}, RangePending, lvl, 0})
+			return nil, nil
+		}
 		return nil, err
 	}
+	f.mu.Lock()
+	f.fetched++
+	if lvl > f.depth {
+		f.depth = lvl
+	}
+	f.mu.Unlock()
 	if clipsResp.IsComplete {
+		f.leaf(RangeResult{ClipsRange{from, to}, RangeComplete, lvl, len(clipsResp.Clips)})
 		return clipsResp.Clips, nil
 	}
 	// If next level is too deep, we stop here and return the current results
-	if lvl+1 > p.MaxDeepLvl {
-		l.Warn().Msgf("incomplete clip results after clip_tracking_max_deep_level=%d "+
+	if lvl+1 > f.p.MaxDeepLvl {
+		l.Debug().Msgf("incomplete clip results after max_deep_level=%d "+
 			"reached for period from=%s to=%s (bid:%s) ",
-			p.MaxDeepLvl, from.Format(time.RFC3339), to.Format(time.RFC3339), p.BroadcasterID)
+			f.p.MaxDeepLvl, from.Format(time.RFC3339), to.Format(time.RFC3339), f.p.BroadcasterID)
+		f.leaf(RangeResult{ClipsRange{from, to}, RangeIncomplete, lvl, len(clipsResp.Clips)})
 		return clipsResp.Clips, nil
 	}
 
-	nReqs := math.Pow(2, float64(lvl))
-	partHours := float64(p.windowHours) / nReqs
-	all := make([]*Clip, 0, 100*2)
+	ranges := f.split.Split(ClipsRange{from, to}, clipsResp.Clips)
+	if len(ranges) < 2 {
+		ranges = BisectSplit{}.Split(ClipsRange{from, to}, clipsResp.Clips)
+	}
 	l.Debug().Msgf("(bid:%s) incomplete clip results for period from=%s to=%s. "+
-		"Deepening (lvl:%d/%d, part_hours:%f, n_reqs:%f)",
-		p.BroadcasterID, from.Format(time.RFC3339), to.Format(time.RFC3339), lvl,
-		p.MaxDeepLvl, partHours, nReqs,
+		"Deepening (lvl:%d/%d, parts:%d)",
+		f.p.BroadcasterID, from.Format(time.RFC3339), to.Format(time.RFC3339), lvl,
+		f.p.MaxDeepLvl, len(ranges),
 	)
-	// left and right in the binary tree
-	for i := 0; i < 2; i++ {
-		to := from.Add(time.Duration(partHours) * time.Hour)
-		clips, err := hx.deepFetchClips(
-			p,
-			lvl+1,
-			from,
-			to,
-		)
-		if err != nil {
-			return nil, err
-		}
+	// every sub-range has its own slot so the merge doesn't depend on which
+	// one finishes first
+	parts := make([][]*Clip, len(ranges))
+	g, gctx := errgroup.WithContext(ctx)
+	for i := range ranges {
+		i := i
+		g.Go(func() error {
+			clips, err := f.fetch(gctx, lvl+1, ranges[i].From, ranges[i].To)
+			parts[i] = clips
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, clips := range parts {
+		n += len(clips)
+	}
+	all := make([]*Clip, 0, n)
+	for _, clips := range parts {
 		all = append(all, clips...)
-		from = to
 	}
 	return all, nil
 }
+
+func (f *deepFetcher) clips(ctx context.Context, from time.Time, to time.Time) (*ClipResponse, error) {
+	select {
+	case f.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-f.sem }()
+	return f.hx.Clips(&ClipsParams{
+		BroadcasterID:            f.p.BroadcasterID,
+		StopViewsThreshold:       f.p.StopViewsThreshold,
+		ViewsThresholdWindowSize: f.p.ViewsThresholdWindowSize,
+		Context:                  ctx,
+		SkipDeduplication:        f.p.SkipDeduplication,
+		StartedAt:                from,
+		EndedAt:                  to,
+	})
+}
@@ -1,10 +1,13 @@
 package helix
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -245,7 +248,7 @@ func TestDeepFetchClips(t *testing.T) {
 		APIUrl: sv.URL,
 	}, sv.Client())
 
-	clips, err := hx.DeepClips(&DeepClipsParams{
+	res, err := hx.DeepClips(&DeepClipsParams{
 		ClipsParams: &ClipsParams{
 			BroadcasterID:            "58753574",
 			StartedAt:                startA,
@@ -259,8 +262,13 @@ func TestDeepFetchClips(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if reqs != 5 {
-		t.Fatalf("unexpected number of requests, got:%d, want:5", reqs)
+	if reqs != 5 || res.Requests != 5 {
+		t.Fatalf("unexpected number of requests, got:%d (reported:%d), want:5", reqs, res.Requests)
+	}
+	// D, E and C
+	if !res.Complete() || res.Depth != 3 || len(res.Ranges) != 3 ||
+		!res.Ranges[0].To.Equal(endD) || res.Ranges[2].Depth != 2 {
+		t.Fatalf("unexpected completeness report: %+v", res)
 	}
 
 	want := []Clip{
@@ -273,7 +281,7 @@ func TestDeepFetchClips(t *testing.T) {
 		// From C
 		{ClipID: "TsundereOddSrirachaDendiFace-MifT_BPTFolNTpGN"},
 	}
-	for i, clip := range clips {
+	for i, clip := range res.Clips {
 		if got := clip.ClipID; got != want[i].ClipID {
 			t.Fatalf("unexpected clip, got:%s want:%s", got, want[i].ClipID)
 		}
@@ -355,7 +363,7 @@ func TestDeepFetchClipsEmptyClips(t *testing.T) {
 	hx := NewWithoutExchange(&HelixOpts{
 		APIUrl: sv.URL,
 	}, sv.Client())
-	clips, err := hx.DeepClips(&DeepClipsParams{
+	res, err := hx.DeepClips(&DeepClipsParams{
 		ClipsParams: &ClipsParams{
 			BroadcasterID:            "58753574",
 			StartedAt:                startA,
@@ -377,7 +385,234 @@ func TestDeepFetchClipsEmptyClips(t *testing.T) {
 		t.Fatalf("unexpected number of requests, got:%d, want:1", reqs)
 	}
 
-	if len(clips) != 0 {
-		t.Fatalf("expected 0 clips")
+	if res != nil {
+		t.Fatalf("expected no result")
+	}
+}
+
+// rangeClipsHandler responds with a single clip identified by the requested
+// range, with enough views to never reach the threshold, so DeepClips goes down
+// to the max deep level
+func rangeClipsHandler(t *testing.T, block func(start, end time.Time) bool) http.HandlerFunc {
+	return func(resp http.ResponseWriter, r *http.Request) {
+		s := r.URL.Query().Get("started_at")
+		e := r.URL.Query().Get("ended_at")
+		start, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Error(err)
+		}
+		end, err := time.Parse(time.RFC3339, e)
+		if err != nil {
+			t.Error(err)
+		}
+		if block != nil && block(start, end) {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprintf(resp, `{"data":[{"id":"%s/%s","view_count":100}],"pagination":{}}`, s, e)
+	}
+}
+
+func TestDeepClipsConcurrency(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2023, 6, 4, 0, 0, 0, 0, time.UTC)
+	end := start.Add(192 * time.Hour)
+
+	var mu sync.Mutex
+	inFlight, maxInFlight, reqs := 0, 0, 0
+	handler := rangeClipsHandler(t, nil)
+	sv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs++
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		handler(resp, r)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer sv.Close()
+	hx := NewWithoutExchange(&HelixOpts{APIUrl: sv.URL}, sv.Client())
+
+	var want []*Clip
+	for _, concurrency := range []int{1, 4} {
+		mu.Lock()
+		reqs, maxInFlight = 0, 0
+		mu.Unlock()
+		res, err := hx.DeepClips(&DeepClipsParams{
+			ClipsParams: &ClipsParams{
+				BroadcasterID:            "58753574",
+				StartedAt:                start,
+				EndedAt:                  end,
+				StopViewsThreshold:       8,
+				ViewsThresholdWindowSize: 1,
+			},
+			MaxDeepLvl:  4,
+			Concurrency: concurrency,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		if reqs != 15 {
+			t.Fatalf("concurrency %d: expected 15 requests, got %d", concurrency, reqs)
+		}
+		if maxInFlight > concurrency || (concurrency > 1 && maxInFlight == 1) {
+			t.Fatalf("concurrency %d: got %d requests in flight", concurrency, maxInFlight)
+		}
+		mu.Unlock()
+		if res.Requests != 15 || res.Depth != 4 || len(res.Incomplete()) != 8 {
+			t.Fatalf("concurrency %d: unexpected completeness report: %+v", concurrency, res)
+		}
+		clips := res.Clips
+		if want == nil {
+			want = clips
+			continue
+		}
+		// the merge must not depend on the order the requests finish
+		if len(clips) != len(want) {
+			t.Fatalf("concurrency %d: expected %d clips, got %d", concurrency, len(want), len(clips))
+		}
+		for i := range clips {
+			if clips[i].ClipID != want[i].ClipID {
+				t.Fatalf("concurrency %d: unexpected clip %d, got:%s want:%s",
+					concurrency, i, clips[i].ClipID, want[i].ClipID)
+			}
+		}
+	}
+}
+
+func TestDeepClipsPartial(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2023, 6, 4, 0, 0, 0, 0, time.UTC)
+	end := start.Add(168 * time.Hour)
+	mid := start.Add(84 * time.Hour)
+
+	// the right half never responds
+	sv := httptest.NewServer(rangeClipsHandler(t, func(s, e time.Time) bool {
+		return s.Equal(mid)
+	}))
+	defer sv.Close()
+	hx := NewWithoutExchange(&HelixOpts{APIUrl: sv.URL}, sv.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	res, err := hx.DeepClips(&DeepClipsParams{
+		ClipsParams: &ClipsParams{
+			BroadcasterID:            "58753574",
+			StartedAt:                start,
+			EndedAt:                  end,
+			StopViewsThreshold:       8,
+			ViewsThresholdWindowSize: 1,
+			Context:                  ctx,
+		},
+		MaxDeepLvl:  2,
+		Concurrency: 2,
+	})
+	var partial *PartialClipsError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected a partial result, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+	if partial.Fetched != 2 || len(partial.Pending) != 1 ||
+		!partial.Pending[0].From.Equal(mid) || !partial.Pending[0].To.Equal(end) {
+		t.Fatalf("unexpected completeness report: %+v", partial)
+	}
+	if len(res.Ranges) != 2 || res.Ranges[0].Status != RangeIncomplete || res.Ranges[1].Status != RangePending {
+		t.Fatalf("unexpected ranges: %+v", res.Ranges)
+	}
+	// the clips of the root range are replaced by the ones of its halves
+	clips := res.Clips
+	if len(clips) != 1 || clips[0].ClipID != start.Format(time.RFC3339)+"/"+mid.Format(time.RFC3339) {
+		t.Fatalf("expected the clip of the left half, got %+v", clips)
+	}
+}
+
+func TestDeepClipsEmptySubRange(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2023, 6, 4, 0, 0, 0, 0, time.UTC)
+	end := start.Add(168 * time.Hour)
+	mid := start.Add(84 * time.Hour)
+
+	handler := rangeClipsHandler(t, nil)
+	sv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("started_at") == mid.Format(time.RFC3339) {
+			resp.Write([]byte(`{"data":[],"pagination":{}}`))
+			return
+		}
+		handler(resp, r)
+	}))
+	defer sv.Close()
+	hx := NewWithoutExchange(&HelixOpts{APIUrl: sv.URL}, sv.Client())
+
+	// an empty sub-range is not an error, only an empty window is
+	res, err := hx.DeepClips(&DeepClipsParams{
+		ClipsParams: &ClipsParams{
+			BroadcasterID:            "58753574",
+			StartedAt:                start,
+			EndedAt:                  end,
+			StopViewsThreshold:       8,
+			ViewsThresholdWindowSize: 1,
+		},
+		MaxDeepLvl: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Clips) != 1 {
+		t.Fatalf("expected the clip of the left half, got %+v", res.Clips)
+	}
+	if len(res.Ranges) != 2 || res.Ranges[1].Status != RangeComplete || res.Ranges[1].Clips != 0 {
+		t.Fatalf("expected the empty half to be complete, got %+v", res.Ranges)
+	}
+}
+
+func TestDeepClipsEmptyHalf(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2023, 6, 4, 0, 0, 0, 0, time.UTC)
+	end := start.Add(168 * time.Hour)
+	mid := start.Add(84 * time.Hour)
+
+	// there are no clips in the right half
+	handler := rangeClipsHandler(t, nil)
+	sv := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
+		s, err := time.Parse(time.RFC3339, r.URL.Query().Get("started_at"))
+		if err != nil {
+			t.Error(err)
+		}
+		if s.Equal(mid) {
+			resp.Write([]byte(`{"data":[],"pagination":{}}`))
+			return
+		}
+		handler(resp, r)
+	}))
+	defer sv.Close()
+	hx := NewWithoutExchange(&HelixOpts{APIUrl: sv.URL}, sv.Client())
+
+	res, err := hx.DeepClips(&DeepClipsParams{
+		ClipsParams: &ClipsParams{
+			BroadcasterID:            "58753574",
+			StartedAt:                start,
+			EndedAt:                  end,
+			StopViewsThreshold:       8,
+			ViewsThresholdWindowSize: 1,
+		},
+		MaxDeepLvl: 2,
+	})
+	if err != nil {
+		t.Fatalf("expected an empty half not to fail DeepClips, got %v", err)
+	}
+	if len(res.Clips) != 1 || res.Clips[0].ClipID != start.Format(time.RFC3339)+"/"+mid.Format(time.RFC3339) {
+		t.Fatalf("expected the clip of the left half, got %+v", res.Clips)
+	}
+	// only the left half is incomplete
+	if inc := res.Incomplete(); len(inc) != 1 || !inc[0].To.Equal(mid) {
+		t.Fatalf("unexpected completeness report: %+v", res)
 	}
 }
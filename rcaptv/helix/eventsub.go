@@ -39,8 +39,9 @@ func (hx *Helix) CreateEventsubSubscription(sub *Subscription) error {
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return errors.New("Expected 200 response, got " + fmt.Sprint(resp.StatusCode))
+	// Twitch responds 202 Accepted, the callback is verified afterwards
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return errors.New("Expected 202 response, got " + fmt.Sprint(resp.StatusCode))
 	}
 	return nil
 }
@@ -0,0 +1,85 @@
+package fakehelix
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// ControlPath is the prefix of the endpoints to script the server while it
+// runs, e.g. with curl:
+//
+//	POST /fake/streams/online?broadcaster_id=58753574
+//	POST /fake/streams/offline?broadcaster_id=58753574
+//	POST /fake/revoke?id=sub-1&status=authorization_revoked
+//	POST /fake/fail?path=/helix/clips&status=503&n=3
+//	POST /fake/token?user_id=58753574
+const ControlPath = "/fake"
+
+func (s *Server) handleControl() {
+	s.mux.HandleFunc(ControlPath+"/streams/online", s.controlStream(s.StreamOnline))
+	s.mux.HandleFunc(ControlPath+"/streams/offline", s.controlStream(s.StreamOffline))
+	s.mux.HandleFunc(ControlPath+"/revoke", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		q := r.URL.Query()
+		status := q.Get("status")
+		if status == "" {
+			status = StatusAuthRevoked
+		}
+		if err := s.Revoke(r.Context(), q.Get("id"), status); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	s.mux.HandleFunc(ControlPath+"/fail", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		q := r.URL.Query()
+		status, err := strconv.Atoi(q.Get("status"))
+		if err != nil || status < 400 || status > 599 {
+			writeError(w, http.StatusBadRequest, "Invalid status")
+			return
+		}
+		n, err := strconv.Atoi(q.Get("n"))
+		if err != nil {
+			n = 1
+		}
+		s.Fail(q.Get("path"), status, n)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	s.mux.HandleFunc(ControlPath+"/token", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		writeJSON(w, http.StatusOK, tokenResponse{
+			AccessToken: s.IssueToken(r.URL.Query().Get("user_id")),
+			ExpiresIn:   int(TokenExpiration.Seconds()),
+			TokenType:   "bearer",
+		})
+	})
+}
+
+func (s *Server) controlStream(emit func(ctx context.Context, bid string) (int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		sent, err := emit(r.Context(), r.URL.Query().Get("broadcaster_id"))
+		switch {
+		case errors.Is(err, ErrDelivery):
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Notified int `json:"notified"`
+		}{sent})
+	}
+}
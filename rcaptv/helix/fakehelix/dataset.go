@@ -0,0 +1,215 @@
+package fakehelix
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"pedro.to/rcaptv/helix"
+)
+
+// Dataset served by the fake server
+type Dataset struct {
+	Users   []*helix.User
+	Videos  []*helix.VOD
+	Clips   []*helix.Clip
+	Streams []*helix.Stream
+}
+
+// seedChannels are always the first channels of a seeded dataset. They are
+// the channels tracked in the test database.
+var seedChannels = []struct {
+	id, login, name string
+}{
+	{"58753574", "zeling", "Zeling"},
+	{"90075649", "illojuan", "IlloJuan"},
+}
+
+const (
+	seedVideosPerChannel = 10
+	seedMaxClipsPerVideo = 300
+)
+
+// Seed generates a dataset of n channels with VODs from the days before now
+// and clips of them. The same seed always generates the same dataset.
+func Seed(seed int64, n int, now time.Time) *Dataset {
+	r := rand.New(rand.NewSource(seed))
+	now = now.UTC().Truncate(time.Second)
+	ds := &Dataset{}
+	for i := 0; i < n; i++ {
+		id, login, name := fmt.Sprint(100000000+i), fmt.Sprintf("channel_%d", i), fmt.Sprintf("Channel_%d", i)
+		if i < len(seedChannels) {
+			id, login, name = seedChannels[i].id, seedChannels[i].login, seedChannels[i].name
+		}
+		createdAt := helix.RFC3339Timestamp(now.AddDate(-5, 0, -r.Intn(365)))
+		ds.Users = append(ds.Users, &helix.User{
+			Id:              id,
+			Login:           login,
+			DisplayName:     name,
+			BroadcasterType: "partner",
+			Description:     fmt.Sprintf("%s streams", name),
+			ProfileImageURL: fmt.Sprintf("https://static-cdn.jtvnw.net/jtv_user_pictures/%s-profile_image-300x300.png", login),
+			OfflineImageURL: fmt.Sprintf("https://static-cdn.jtvnw.net/jtv_user_pictures/%s-channel_offline_image-1920x1080.png", login),
+			ViewCount:       r.Intn(10000000),
+			CreatedAt:       createdAt,
+		})
+
+		for v := 0; v < seedVideosPerChannel; v++ {
+			start := now.AddDate(0, 0, -v-1).Add(time.Duration(r.Intn(12)) * time.Hour)
+			duration := time.Duration(1+r.Intn(8))*time.Hour + time.Duration(r.Intn(60))*time.Minute
+			streamID := fmt.Sprint(40000000000 + r.Int63n(10000000000))
+			vod := &helix.VOD{
+				VideoID:        fmt.Sprint(1800000000 + i*seedVideosPerChannel + v),
+				BroadcasterID:  id,
+				StreamID:       streamID,
+				CreatedAt:      start,
+				PublishedAt:    start,
+				DurationString: helixDuration(duration),
+				Lang:           "es",
+				Title:          fmt.Sprintf("%s stream #%d", name, seedVideosPerChannel-v),
+				ThumbnailURL:   fmt.Sprintf("https://static-cdn.jtvnw.net/cf_vods/%s_%s//thumb/thumb0-%%{width}x%%{height}.jpg", login, streamID),
+				ViewCount:      r.Intn(1000000),
+			}
+			ds.Videos = append(ds.Videos, vod)
+
+			for c := r.Intn(seedMaxClipsPerVideo); c > 0; c-- {
+				offset := r.Intn(int(duration.Seconds()))
+				// most clips have a few views and a few of them many
+				views := int(10 * r.ExpFloat64() * r.ExpFloat64() * r.ExpFloat64() * 100)
+				ds.Clips = append(ds.Clips, &helix.Clip{
+					ClipID:           fmt.Sprintf("%sClip%d-%d", name, len(ds.Clips), r.Intn(1000000)),
+					BroadcasterID:    id,
+					VideoID:          vod.VideoID,
+					CreatedAt:        start.Add(time.Duration(offset+30) * time.Second).Format(time.RFC3339),
+					CreatorID:        fmt.Sprint(r.Intn(900000000)),
+					CreatorName:      fmt.Sprintf("viewer_%d", r.Intn(100000)),
+					Title:            vod.Title,
+					GameID:           "509658",
+					Lang:             "es",
+					ThumbnailURL:     fmt.Sprintf("https://clips-media-assets2.twitch.tv/%s-offset-%d-preview-480x272.jpg", streamID, offset),
+					DurationSeconds:  float32(5 + r.Intn(55)),
+					ViewCount:        views,
+					VODOffsetSeconds: intPtr(offset),
+				})
+			}
+		}
+
+		if r.Intn(4) == 0 {
+			ds.Streams = append(ds.Streams, &helix.Stream{
+				ID:           fmt.Sprint(40000000000 + r.Int63n(10000000000)),
+				UserID:       id,
+				UserLogin:    login,
+				UserName:     name,
+				GameID:       "509658",
+				GameName:     "Just Chatting",
+				Type:         helix.StreamLive,
+				Title:        fmt.Sprintf("%s live", name),
+				ViewerCount:  r.Intn(50000),
+				StartedAt:    now.Add(-time.Duration(r.Intn(300)) * time.Minute),
+				Lang:         "es",
+				ThumbnailURL: fmt.Sprintf("https://static-cdn.jtvnw.net/previews-ttv/live_user_%s-{width}x{height}.jpg", login),
+			})
+		}
+	}
+	return ds
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// helixDuration formats d as Twitch does, e.g. 3h2m1s
+func helixDuration(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%dm%ds", h, m, s)
+	case m > 0:
+		return fmt.Sprintf("%dm%ds", m, s)
+	}
+	return fmt.Sprintf("%ds", s)
+}
+
+// AddUsers adds users to the dataset, replacing the ones with the same ID
+func (s *Server) AddUsers(users ...*helix.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range users {
+		s.ds.Users = replaceOrAppend(s.ds.Users, u, func(o *helix.User) bool { return o.Id == u.Id })
+	}
+}
+
+// AddVideos adds VODs to the dataset, replacing the ones with the same ID
+func (s *Server) AddVideos(vods ...*helix.VOD) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range vods {
+		s.ds.Videos = replaceOrAppend(s.ds.Videos, v, func(o *helix.VOD) bool { return o.VideoID == v.VideoID })
+	}
+	sortVideos(s.ds.Videos)
+}
+
+// AddClips adds clips to the dataset, replacing the ones with the same ID
+func (s *Server) AddClips(clips ...*helix.Clip) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range clips {
+		s.ds.Clips = replaceOrAppend(s.ds.Clips, c, func(o *helix.Clip) bool { return o.ClipID == c.ClipID })
+	}
+	sortClips(s.ds.Clips)
+}
+
+// SetClipViews updates the views of a clip. Returns false if there is no clip
+// with that ID.
+func (s *Server) SetClipViews(clipID string, views int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.ds.Clips {
+		if c.ClipID == clipID {
+			c.ViewCount = views
+			sortClips(s.ds.Clips)
+			return true
+		}
+	}
+	return false
+}
+
+// Dataset returns a copy of the current dataset
+func (s *Server) Dataset() *Dataset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &Dataset{
+		Users:   append([]*helix.User(nil), s.ds.Users...),
+		Videos:  append([]*helix.VOD(nil), s.ds.Videos...),
+		Clips:   append([]*helix.Clip(nil), s.ds.Clips...),
+		Streams: append([]*helix.Stream(nil), s.ds.Streams...),
+	}
+}
+
+func replaceOrAppend[T any](s []T, v T, same func(T) bool) []T {
+	for i := range s {
+		if same(s[i]) {
+			s[i] = v
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+// sortVideos by creation time, most recent first, as Twitch does
+func sortVideos(vods []*helix.VOD) {
+	sort.SliceStable(vods, func(i, j int) bool {
+		return vods[i].CreatedAt.After(vods[j].CreatedAt)
+	})
+}
+
+// sortClips by views, as Twitch does
+func sortClips(clips []*helix.Clip) {
+	sort.SliceStable(clips, func(i, j int) bool {
+		return clips[i].ViewCount > clips[j].ViewCount
+	})
+}
@@ -0,0 +1,115 @@
+package fakehelix
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/helix"
+)
+
+const (
+	benchBroadcasterID = "58753574"
+	benchClips         = 8000
+	benchThreshold     = 10
+)
+
+// skewedDataset generates benchClips clips of a single broadcaster created
+// inside the lives, with heavy tailed views
+func skewedDataset(lives []helix.ClipsRange) *Dataset {
+	r := rand.New(rand.NewSource(1))
+	ds := &Dataset{
+		Users: []*helix.User{{Id: benchBroadcasterID, Login: "zeling", DisplayName: "Zeling"}},
+	}
+	for i := 0; i < benchClips; i++ {
+		live := lives[r.Intn(len(lives))]
+		offset := time.Duration(r.Int63n(int64(live.To.Sub(live.From))))
+		ds.Clips = append(ds.Clips, &helix.Clip{
+			ClipID:        fmt.Sprintf("clip_%d", i),
+			BroadcasterID: benchBroadcasterID,
+			CreatedAt:     live.From.Add(offset).Format(time.RFC3339),
+			ViewCount:     int(2000 * math.Pow(r.Float64(), 4)),
+		})
+	}
+	return ds
+}
+
+// BenchmarkDeepClipsSplit compares the split strategies of DeepClips with
+// clips concentrated in a few live hours of the week. Besides the time, it
+// reports the requests made and the clips above the views threshold that
+// were missed after reaching MaxDeepLvl.
+func BenchmarkDeepClipsSplit(b *testing.B) {
+	end := now.Truncate(time.Hour)
+	start := end.Add(-168 * time.Hour)
+	livesAt := func(n int, d time.Duration) []helix.ClipsRange {
+		lives := make([]helix.ClipsRange, 0, n)
+		for i := 0; i < n; i++ {
+			from := start.Add(time.Duration(i*168/n)*time.Hour + 18*time.Hour)
+			lives = append(lives, helix.ClipsRange{From: from, To: from.Add(d)})
+		}
+		return lives
+	}
+	distributions := []struct {
+		name  string
+		lives []helix.ClipsRange
+	}{
+		{"uniform", []helix.ClipsRange{{From: start, To: end}}},
+		{"daily lives", livesAt(7, 4*time.Hour)},
+		{"two lives", livesAt(2, 3*time.Hour)},
+		{"single live", livesAt(1, 2*time.Hour)},
+	}
+	for _, dist := range distributions {
+		ds := skewedDataset(dist.lives)
+		above := make(map[string]bool, len(ds.Clips))
+		for _, c := range ds.Clips {
+			if c.ViewCount >= benchThreshold {
+				above[c.ClipID] = true
+			}
+		}
+		strategies := []struct {
+			name  string
+			split helix.SplitStrategy
+		}{
+			{"bisect", helix.BisectSplit{}},
+			{"density", helix.DensitySplit{}},
+			{"density 4 parts", helix.DensitySplit{Parts: 4}},
+			{"density with lives", helix.DensitySplit{Lives: dist.lives}},
+		}
+		for _, st := range strategies {
+			b.Run(dist.name+"/"+st.name, func(b *testing.B) {
+				_, _, hx := setup(b, Opts{Dataset: ds, RateLimit: math.MaxInt32})
+				var res *helix.DeepClipsResult
+				var err error
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					res, err = hx.DeepClips(&helix.DeepClipsParams{
+						ClipsParams: &helix.ClipsParams{
+							BroadcasterID:            benchBroadcasterID,
+							StartedAt:                start,
+							EndedAt:                  end,
+							StopViewsThreshold:       benchThreshold,
+							ViewsThresholdWindowSize: 4,
+						},
+						MaxDeepLvl:  3,
+						Concurrency: 4,
+						Split:       st.split,
+					})
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+				missed := len(above)
+				for _, c := range res.Clips {
+					if above[c.ClipID] {
+						missed--
+					}
+				}
+				b.ReportMetric(float64(res.Requests), "reqs/op")
+				b.ReportMetric(float64(missed), "missed")
+			})
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package fakehelix
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"pedro.to/rcaptv/helix"
+)
+
+const (
+	// Twitch EventSub subscription statuses
+	StatusEnabled            = "enabled"
+	StatusPending            = "webhook_callback_verification_pending"
+	StatusVerificationFailed = "webhook_callback_verification_failed"
+	StatusUserRemoved        = "user_removed"
+	StatusAuthRevoked        = "authorization_revoked"
+
+	// maxSubscriptionsCost is the max total cost of the subscriptions of an app
+	maxSubscriptionsCost = 10000
+)
+
+var (
+	ErrNoSubscription = errors.New("no subscription with that ID")
+	ErrDelivery       = errors.New("webhook delivery failed")
+)
+
+type subscriptionsResponse struct {
+	Data         []*helix.Subscription `json:"data"`
+	Total        int                   `json:"total"`
+	TotalCost    int                   `json:"total_cost"`
+	MaxTotalCost int                   `json:"max_total_cost"`
+	Pagination   struct{}              `json:"pagination"`
+}
+
+func (s *Server) handleSubscriptions(w http.ResponseWriter, r *http.Request, _ *token) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		status, typ, userID := q.Get("status"), q.Get("type"), q.Get("user_id")
+		s.mu.RLock()
+		resp := s.subscriptionsResponse(func(sub *helix.Subscription) bool {
+			return (status == "" || sub.Status == status) &&
+				(typ == "" || sub.Type == typ) &&
+				(userID == "" || sub.Condition.BroadcasterUserID == userID)
+		})
+		s.mu.RUnlock()
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodPost:
+		s.createSubscription(w, r)
+	case http.MethodDelete:
+		if !s.deleteSubscription(r.URL.Query().Get("id")) {
+			writeError(w, http.StatusNotFound, "Subscription not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// subscriptionsResponse lists the subscriptions matching filter. s.mu must
+// be held.
+func (s *Server) subscriptionsResponse(filter func(sub *helix.Subscription) bool) *subscriptionsResponse {
+	resp := &subscriptionsResponse{
+		Data:         []*helix.Subscription{},
+		MaxTotalCost: maxSubscriptionsCost,
+	}
+	for _, sub := range s.subs {
+		resp.TotalCost += sub.Cost
+		resp.Total++
+		if filter(sub) {
+			resp.Data = append(resp.Data, publicSubscription(sub))
+		}
+	}
+	return resp
+}
+
+func (s *Server) createSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type      string           `json:"type"`
+		Version   string           `json:"version"`
+		Condition *helix.Condition `json:"condition"`
+		Transport *helix.Transport `json:"transport"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid body")
+		return
+	}
+	switch {
+	case req.Type != helix.SubStreamOnline && req.Type != helix.SubStreamOffline:
+		writeError(w, http.StatusBadRequest, "Unsupported subscription type "+req.Type)
+		return
+	case req.Version != "1":
+		writeError(w, http.StatusBadRequest, "Unsupported version "+req.Version)
+		return
+	case req.Condition == nil || req.Condition.BroadcasterUserID == "":
+		writeError(w, http.StatusBadRequest, "Missing broadcaster_user_id condition")
+		return
+	case req.Transport == nil || req.Transport.Method != "webhook":
+		writeError(w, http.StatusBadRequest, "Unsupported transport")
+		return
+	case len(req.Transport.Secret) < 10 || len(req.Transport.Secret) > 100:
+		writeError(w, http.StatusBadRequest, "The secret must be between 10 and 100 characters")
+		return
+	}
+	// unlike Twitch, plain http callbacks are allowed for local development
+	if u, err := url.Parse(req.Transport.Callback); err != nil || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "Invalid callback")
+		return
+	}
+
+	id := s.nextID("sub-")
+	s.mu.Lock()
+	if s.user(req.Condition.BroadcasterUserID) == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, "Invalid broadcaster_user_id")
+		return
+	}
+	for _, sub := range s.subs {
+		if sub.Type == req.Type && sub.Condition.BroadcasterUserID == req.Condition.BroadcasterUserID &&
+			sub.Transport.Callback == req.Transport.Callback {
+			s.mu.Unlock()
+			writeError(w, http.StatusConflict, "subscription already exists")
+			return
+		}
+	}
+	sub := &helix.Subscription{
+		ID:        id,
+		Status:    StatusPending,
+		Type:      req.Type,
+		Version:   req.Version,
+		Cost:      1,
+		Condition: &helix.Condition{BroadcasterUserID: req.Condition.BroadcasterUserID},
+		Transport: &helix.Transport{Method: req.Transport.Method, Callback: req.Transport.Callback},
+		CreatedAt: s.opts.Now().UTC(),
+	}
+	s.subs = append(s.subs, sub)
+	s.secrets[sub.ID] = req.Transport.Secret
+	resp := s.subscriptionsResponse(func(o *helix.Subscription) bool { return o == sub })
+	verification := publicSubscription(sub)
+	s.mu.Unlock()
+
+	// like Twitch, the callback is verified after responding
+	s.deliveries.Add(1)
+	go func() {
+		defer s.deliveries.Done()
+		s.verify(context.Background(), verification, req.Transport.Secret)
+	}()
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+// verify sends the challenge to the callback of the subscription and enables
+// it if the callback responds with it
+func (s *Server) verify(ctx context.Context, sub *helix.Subscription, secret string) {
+	challenge := randomToken()
+	body, err := json.Marshal(helix.WebhookVerificationPayload{
+		Challenge:    challenge,
+		Subscription: sub,
+	})
+	if err != nil {
+		log.Err(err).Str("ctx", "fakehelix").Msg("couldn't encode verification")
+		return
+	}
+	status := StatusVerificationFailed
+	resp, err := s.deliver(ctx, sub, secret, helix.WebhookEventVerification, body)
+	if err == nil && string(resp) == challenge {
+		status = StatusEnabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.subs {
+		if o.ID == sub.ID {
+			o.Status = status
+		}
+	}
+}
+
+// deliver sends a signed webhook message of the subscription and returns
+// the response body
+func (s *Server) deliver(ctx context.Context, sub *helix.Subscription, secret, msgType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Transport.Callback, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	id := s.nextID("msg-")
+	ts := s.opts.Now().UTC().Format(time.RFC3339Nano)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(helix.WebhookHeaderID, id)
+	req.Header.Set(helix.WebhookHeaderTimestamp, ts)
+	req.Header.Set(helix.WebhookHeaderSignature, Sign(secret, id, ts, body))
+	req.Header.Set(helix.WebhookHeaderType, msgType)
+	req.Header.Set("Twitch-Eventsub-Subscription-Type", sub.Type)
+	req.Header.Set("Twitch-Eventsub-Subscription-Version", sub.Version)
+
+	resp, err := s.opts.WebhookClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDelivery, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, helix.HttpMaxClientResponseReadLimitBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDelivery, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%w: %s responded %d", ErrDelivery, sub.Transport.Callback, resp.StatusCode)
+	}
+	return b, nil
+}
+
+// Sign returns the Twitch signature of a webhook message
+func Sign(secret, id, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+	return string(helix.WebhookEventHMACPrefix) + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify sends a notification with the event to the enabled subscriptions of
+// type subType for the broadcaster. Returns the number of subscriptions
+// notified.
+func (s *Server) Notify(ctx context.Context, subType, bid string, event any) (int, error) {
+	type target struct {
+		sub    *helix.Subscription
+		secret string
+	}
+	var targets []target
+	s.mu.RLock()
+	for _, sub := range s.subs {
+		if sub.Status == StatusEnabled && sub.Type == subType && sub.Condition.BroadcasterUserID == bid {
+			targets = append(targets, target{publicSubscription(sub), s.secrets[sub.ID]})
+		}
+	}
+	s.mu.RUnlock()
+
+	var errs []error
+	sent := 0
+	for _, t := range targets {
+		body, err := json.Marshal(struct {
+			Subscription *helix.Subscription `json:"subscription"`
+			Event        any                 `json:"event"`
+		}{t.sub, event})
+		if err != nil {
+			return sent, err
+		}
+		if _, err := s.deliver(ctx, t.sub, t.secret, helix.WebhookEventNotification, body); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// StreamOnline sets a live stream for the channel and notifies its
+// stream.online subscriptions
+func (s *Server) StreamOnline(ctx context.Context, bid string) (int, error) {
+	s.mu.RLock()
+	u := s.user(bid)
+	s.mu.RUnlock()
+	if u == nil {
+		return 0, fmt.Errorf("unknown broadcaster %s", bid)
+	}
+	st := &helix.Stream{
+		ID:        s.nextID("stream-"),
+		UserID:    u.Id,
+		UserLogin: u.Login,
+		UserName:  u.DisplayName,
+		Type:      helix.StreamLive,
+		Title:     u.DisplayName + " live",
+		StartedAt: s.opts.Now().UTC(),
+		Lang:      "es",
+	}
+	s.SetStream(st)
+	return s.Notify(ctx, helix.SubStreamOnline, bid, &helix.EventStreamOnline{
+		Event: &helix.Event{ID: st.ID, Type: st.Type, StartedAt: st.StartedAt},
+		Broadcaster: &helix.Broadcaster{
+			ID: u.Id, Login: u.Login, Username: u.DisplayName,
+		},
+	})
+}
+
+// StreamOffline ends the live stream of the channel and notifies its
+// stream.offline subscriptions
+func (s *Server) StreamOffline(ctx context.Context, bid string) (int, error) {
+	s.mu.RLock()
+	u := s.user(bid)
+	s.mu.RUnlock()
+	if u == nil {
+		return 0, fmt.Errorf("unknown broadcaster %s", bid)
+	}
+	s.EndStream(bid)
+	return s.Notify(ctx, helix.SubStreamOffline, bid, &helix.EventStreamOffline{
+		Broadcaster: &helix.Broadcaster{
+			ID: u.Id, Login: u.Login, Username: u.DisplayName,
+		},
+	})
+}
+
+// Revoke removes a subscription and sends the revocation message with the
+// given status, e.g. StatusAuthRevoked
+func (s *Server) Revoke(ctx context.Context, subID, status string) error {
+	s.mu.Lock()
+	var sub *helix.Subscription
+	for i, o := range s.subs {
+		if o.ID == subID {
+			sub = o
+			s.subs = append(s.subs[:i], s.subs[i+1:]...)
+			break
+		}
+	}
+	if sub == nil {
+		s.mu.Unlock()
+		return ErrNoSubscription
+	}
+	sub.Status = status
+	secret := s.secrets[subID]
+	delete(s.secrets, subID)
+	revoked := publicSubscription(sub)
+	s.mu.Unlock()
+
+	body, err := json.Marshal(helix.WebhookRevokePayload{Subscription: revoked})
+	if err != nil {
+		return err
+	}
+	_, err = s.deliver(ctx, revoked, secret, helix.WebhookEventRevocation, body)
+	return err
+}
+
+// Subscriptions returns a copy of the EventSub subscriptions
+func (s *Server) Subscriptions() []*helix.Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subs := make([]*helix.Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, publicSubscription(sub))
+	}
+	return subs
+}
+
+// WaitDeliveries waits for the pending callback verifications
+func (s *Server) WaitDeliveries() {
+	s.deliveries.Wait()
+}
+
+func (s *Server) deleteSubscription(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sub := range s.subs {
+		if sub.ID == id {
+			s.subs = append(s.subs[:i], s.subs[i+1:]...)
+			delete(s.secrets, id)
+			return true
+		}
+	}
+	return false
+}
+
+// user returns the user with the given ID or nil. s.mu must be held.
+func (s *Server) user(id string) *helix.User {
+	for _, u := range s.ds.Users {
+		if u.Id == id {
+			return u
+		}
+	}
+	return nil
+}
+
+// publicSubscription returns a copy of sub as returned by the API
+func publicSubscription(sub *helix.Subscription) *helix.Subscription {
+	c := *sub
+	cond := *sub.Condition
+	c.Condition = &cond
+	c.Transport = &helix.Transport{Method: sub.Transport.Method, Callback: sub.Transport.Callback}
+	return &c
+}
@@ -0,0 +1,447 @@
+// Package fakehelix is a fake of the Twitch Helix API, its OAuth endpoints
+// and EventSub webhooks, serving a seeded dataset that can be changed from
+// tests.
+//
+// It is used by tests with httptest and by cmd/fakehelix to run the whole
+// stack offline. Helix endpoints are served under /helix and the OAuth ones
+// under /oauth2, like api.twitch.tv and id.twitch.tv do.
+package fakehelix
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"pedro.to/rcaptv/helix"
+)
+
+const (
+	HelixPath = "/helix"
+	OAuthPath = "/oauth2"
+
+	// maxPageSize is the max value of the first parameter
+	maxPageSize = 100
+	// maxClipResults is the max number of clips Twitch returns for a query,
+	// following the pagination
+	maxClipResults = 1000
+	// defaultRateLimit is the number of points of the rate limit buckets
+	defaultRateLimit = 800
+)
+
+type Opts struct {
+	// Credentials of the only registered app
+	ClientID, ClientSecret string
+	// Seed of the generated dataset
+	Seed int64
+	// Channels is the number of channels of the generated dataset, at least
+	// the ones of the test database. Ignored if Dataset is set
+	Channels int
+	// Dataset served instead of a generated one
+	Dataset *Dataset
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+	// WebhookClient delivers the EventSub webhooks. Defaults to
+	// http.DefaultClient
+	WebhookClient *http.Client
+	// RateLimit is the number of points of the rate limit bucket of every
+	// token, refilled every minute. Defaults to 800 like Twitch
+	RateLimit int
+}
+
+// Server is a fake Twitch API. It is safe for concurrent use.
+type Server struct {
+	opts Opts
+	mux  *http.ServeMux
+
+	mu sync.RWMutex
+	ds *Dataset
+	// tokens issued by access token
+	tokens map[string]*token
+	// refreshTokens are the access tokens by refresh token
+	refreshTokens map[string]string
+	// codes are the authorization codes not exchanged yet
+	codes map[string]authCode
+	subs  []*helix.Subscription
+	// secrets of the subscriptions by ID, not returned by the API
+	secrets map[string]string
+	// failures are the scripted failures by path
+	failures map[string][]int
+	seq      int
+
+	// deliveries are the pending callback verifications
+	deliveries sync.WaitGroup
+}
+
+// New creates a fake server with a generated dataset, unless opts.Dataset is
+// set
+func New(opts Opts) *Server {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.WebhookClient == nil {
+		opts.WebhookClient = http.DefaultClient
+	}
+	if opts.RateLimit == 0 {
+		opts.RateLimit = defaultRateLimit
+	}
+	if opts.Channels < len(seedChannels) {
+		opts.Channels = len(seedChannels)
+	}
+	ds := opts.Dataset
+	if ds == nil {
+		ds = Seed(opts.Seed, opts.Channels, opts.Now())
+	}
+	sortVideos(ds.Videos)
+	sortClips(ds.Clips)
+	s := &Server{
+		opts:          opts,
+		mux:           http.NewServeMux(),
+		ds:            ds,
+		tokens:        make(map[string]*token),
+		refreshTokens: make(map[string]string),
+		codes:         make(map[string]authCode),
+		secrets:       make(map[string]string),
+		failures:      make(map[string][]int),
+	}
+	s.mux.HandleFunc(HelixPath+"/users", s.authenticated(s.handleUsers))
+	s.mux.HandleFunc(HelixPath+"/videos", s.authenticated(s.handleVideos))
+	s.mux.HandleFunc(HelixPath+"/clips", s.authenticated(s.handleClips))
+	s.mux.HandleFunc(HelixPath+"/streams", s.authenticated(s.handleStreams))
+	s.mux.HandleFunc(HelixPath+"/eventsub/subscriptions", s.authenticated(s.handleSubscriptions))
+	s.mux.HandleFunc(OAuthPath+"/token", s.handleToken)
+	s.mux.HandleFunc(OAuthPath+"/validate", s.handleValidate)
+	s.mux.HandleFunc(OAuthPath+"/authorize", s.handleAuthorize)
+	s.handleControl()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Debug().Str("ctx", "fakehelix").Msgf("%s %s", r.Method, r.URL.RequestURI())
+	if status, ok := s.scriptedFailure(r.URL.Path); ok {
+		writeError(w, status, "scripted failure")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Fail makes the next n requests to path fail with the given status, after
+// the failures already scripted for that path
+func (s *Server) Fail(path string, status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures[path] = append(s.failures[path], status)
+	}
+}
+
+func (s *Server) scriptedFailure(path string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.failures[path]
+	if len(f) == 0 {
+		return 0, false
+	}
+	s.failures[path] = f[1:]
+	return f[0], true
+}
+
+// nextID returns a new unique ID
+func (s *Server) nextID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return fmt.Sprintf("%s%d", prefix, s.seq)
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request, tk *token) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	ids, logins := q["id"], q["login"]
+	if len(ids)+len(logins) == 0 {
+		// the user of the token
+		if tk.userID == "" {
+			writeError(w, http.StatusBadRequest, "Must provide an ID, Login or OAuth Token")
+			return
+		}
+		ids = []string{tk.userID}
+	}
+	if len(ids)+len(logins) > maxPageSize {
+		writeError(w, http.StatusBadRequest, "The combined number of ids and logins must not exceed 100")
+		return
+	}
+	s.mu.RLock()
+	users := make([]*helix.User, 0, len(ids)+len(logins))
+	for _, u := range s.ds.Users {
+		if contains(ids, u.Id) || contains(logins, u.Login) {
+			users = append(users, u)
+		}
+	}
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, helix.PaginationManyObj[*helix.User]{Data: users})
+}
+
+func (s *Server) handleVideos(w http.ResponseWriter, r *http.Request, _ *token) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	ids, userID, gameID := q["id"], q.Get("user_id"), q.Get("game_id")
+	if len(ids) == 0 && userID == "" && gameID == "" {
+		writeError(w, http.StatusBadRequest, "Must provide one of id, user_id or game_id")
+		return
+	}
+	first, offset, ok := pageParams(w, q.Get("first"), q.Get("after"))
+	if !ok {
+		return
+	}
+	var since time.Time
+	switch p := q.Get("period"); p {
+	case "", helix.All.String():
+	case helix.Day.String():
+		since = s.opts.Now().AddDate(0, 0, -1)
+	case helix.Week.String():
+		since = s.opts.Now().AddDate(0, 0, -7)
+	case helix.Month.String():
+		since = s.opts.Now().AddDate(0, -1, 0)
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid period "+p)
+		return
+	}
+	lang := q.Get("language")
+
+	s.mu.RLock()
+	vods := make([]*helix.VOD, 0, maxPageSize)
+	for _, v := range s.ds.Videos {
+		switch {
+		case len(ids) > 0 && !contains(ids, v.VideoID),
+			userID != "" && v.BroadcasterID != userID,
+			lang != "" && v.Lang != lang,
+			v.CreatedAt.Before(since):
+			continue
+		}
+		vods = append(vods, v)
+	}
+	s.mu.RUnlock()
+	writePage(w, vods, first, offset, len(vods))
+}
+
+func (s *Server) handleClips(w http.ResponseWriter, r *http.Request, _ *token) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	ids, bid, gameID := q["id"], q.Get("broadcaster_id"), q.Get("game_id")
+	if len(ids) == 0 && bid == "" && gameID == "" {
+		writeError(w, http.StatusBadRequest, "Must provide one of id, broadcaster_id or game_id")
+		return
+	}
+	first, offset, ok := pageParams(w, q.Get("first"), q.Get("after"))
+	if !ok {
+		return
+	}
+	var startedAt, endedAt time.Time
+	if v := q.Get("started_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid started_at")
+			return
+		}
+		startedAt = t
+		// Twitch defaults to a week after started_at
+		endedAt = t.AddDate(0, 0, 7)
+	}
+	if v := q.Get("ended_at"); v != "" {
+		if startedAt.IsZero() {
+			writeError(w, http.StatusBadRequest, "ended_at requires started_at")
+			return
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid ended_at")
+			return
+		}
+		endedAt = t
+	}
+
+	s.mu.RLock()
+	clips := make([]*helix.Clip, 0, maxPageSize)
+	for _, c := range s.ds.Clips {
+		switch {
+		case len(ids) > 0 && !contains(ids, c.ClipID),
+			bid != "" && c.BroadcasterID != bid,
+			gameID != "" && c.GameID != gameID:
+			continue
+		}
+		if !startedAt.IsZero() {
+			createdAt, err := time.Parse(time.RFC3339, c.CreatedAt)
+			if err != nil || createdAt.Before(startedAt) || createdAt.After(endedAt) {
+				continue
+			}
+		}
+		clips = append(clips, c)
+	}
+	s.mu.RUnlock()
+	// like Twitch, the pagination ends after the first 1000 clips
+	writePage(w, clips, first, offset, maxClipResults)
+}
+
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request, _ *token) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	userIDs, logins := q["user_id"], q["user_login"]
+	first, offset, ok := pageParams(w, q.Get("first"), q.Get("after"))
+	if !ok {
+		return
+	}
+	s.mu.RLock()
+	streams := make([]*helix.Stream, 0, len(s.ds.Streams))
+	for _, st := range s.ds.Streams {
+		if len(userIDs)+len(logins) > 0 && !contains(userIDs, st.UserID) && !contains(logins, st.UserLogin) {
+			continue
+		}
+		streams = append(streams, st)
+	}
+	s.mu.RUnlock()
+	writePage(w, streams, first, offset, len(streams))
+}
+
+// SetStream sets the live stream of a channel, replacing the current one.
+// Use StreamOnline to also notify the EventSub subscriptions.
+func (s *Server) SetStream(st *helix.Stream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ds.Streams = replaceOrAppend(s.ds.Streams, st, func(o *helix.Stream) bool { return o.UserID == st.UserID })
+}
+
+// EndStream removes the live stream of a channel. Returns false if the channel
+// was not live.
+func (s *Server) EndStream(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, st := range s.ds.Streams {
+		if st.UserID == userID {
+			s.ds.Streams = append(s.ds.Streams[:i], s.ds.Streams[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// cursor is the pagination cursor, encoded as base64 JSON like Twitch does
+type cursor struct {
+	Offset int `json:"o"`
+}
+
+func encodeCursor(offset int) string {
+	b, _ := json.Marshal(cursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return 0, err
+	}
+	if c.Offset < 0 {
+		return 0, fmt.Errorf("invalid cursor offset %d", c.Offset)
+	}
+	return c.Offset, nil
+}
+
+// pageParams parses the first and after parameters. It writes the error
+// response if they are not valid.
+func pageParams(w http.ResponseWriter, first, after string) (int, int, bool) {
+	n := 20
+	if first != "" {
+		var err error
+		n, err = strconv.Atoi(first)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeError(w, http.StatusBadRequest, "Invalid value for first, must be between 1 and 100")
+			return 0, 0, false
+		}
+	}
+	offset := 0
+	if after != "" {
+		var err error
+		if offset, err = decodeCursor(after); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid cursor")
+			return 0, 0, false
+		}
+	}
+	return n, offset, true
+}
+
+// writePage writes first items of all from offset, with the cursor of the
+// next page if there are more items before limit
+func writePage[T any](w http.ResponseWriter, all []T, first, offset, limit int) {
+	if limit > len(all) {
+		limit = len(all)
+	}
+	resp := struct {
+		Data       []T `json:"data"`
+		Pagination struct {
+			Cursor string `json:"cursor,omitempty"`
+		} `json:"pagination"`
+	}{Data: []T{}}
+	if offset < limit {
+		end := offset + first
+		if end > limit {
+			end = limit
+		}
+		resp.Data = all[offset:end]
+		if end < limit {
+			resp.Pagination.Cursor = encodeCursor(end)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Err(err).Str("ctx", "fakehelix").Msg("couldn't encode response")
+	}
+}
+
+// writeError writes an error with the format of the Twitch API
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, struct {
+		Error   string `json:"error"`
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	}{http.StatusText(status), status, msg})
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	if contains(methods, r.Method) {
+		return true
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	return false
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
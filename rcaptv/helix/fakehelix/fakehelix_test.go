@@ -0,0 +1,457 @@
+package fakehelix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+
+	"pedro.to/rcaptv/helix"
+)
+
+const (
+	clientID     = "fake_client_id"
+	clientSecret = "fake_client_secret"
+)
+
+var now = time.Date(2023, 6, 20, 12, 0, 0, 0, time.UTC)
+
+// setup starts a fake server and returns a helix client authenticated with an
+// app token through the client credentials flow
+func setup(t testing.TB, opts Opts) (*Server, *httptest.Server, *helix.Helix) {
+	t.Helper()
+	opts.ClientID, opts.ClientSecret = clientID, clientSecret
+	if opts.Now == nil {
+		opts.Now = func() time.Time { return now }
+	}
+	// idle connections would delay the shutdown of the webhook servers
+	opts.WebhookClient = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	fake := New(opts)
+	sv := httptest.NewServer(fake)
+	t.Cleanup(sv.Close)
+	hx := helix.New(&helix.HelixOpts{
+		Creds:            helix.ClientCreds{ClientID: clientID, ClientSecret: clientSecret},
+		APIUrl:           sv.URL + HelixPath,
+		EventsubEndpoint: "/eventsub",
+		TokenURL:         sv.URL + OAuthPath + "/token",
+		ValidateEndpoint: sv.URL + OAuthPath + "/validate",
+		RetryPolicy: &helix.RetryPolicy{
+			MaxAttempts:       2,
+			BaseBackoff:       time.Millisecond,
+			RetryableStatuses: helix.DefaultRetryPolicy.RetryableStatuses,
+		},
+	})
+	return fake, sv, hx
+}
+
+func TestSeed(t *testing.T) {
+	t.Parallel()
+	a, b := Seed(1, 5, now), Seed(1, 5, now)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("expected the same seed to generate the same dataset")
+	}
+	if len(a.Users) != 5 || a.Users[0].Login != "zeling" || a.Users[1].Login != "illojuan" {
+		t.Fatalf("unexpected users %+v", a.Users)
+	}
+	if len(a.Videos) != 5*seedVideosPerChannel || len(a.Clips) == 0 {
+		t.Fatalf("expected videos and clips, got %d and %d", len(a.Videos), len(a.Clips))
+	}
+	if c := Seed(2, 5, now); reflect.DeepEqual(a.Clips, c.Clips) {
+		t.Fatal("expected different seeds to generate different clips")
+	}
+}
+
+func TestClips(t *testing.T) {
+	t.Parallel()
+	fake, _, hx := setup(t, Opts{Seed: 1})
+	bid := "58753574"
+	from, to := now.AddDate(0, 0, -4), now.AddDate(0, 0, -2)
+	var want []*helix.Clip
+	for _, c := range fake.Dataset().Clips {
+		createdAt, _ := time.Parse(time.RFC3339, c.CreatedAt)
+		if c.BroadcasterID == bid && !createdAt.Before(from) && !createdAt.After(to) {
+			want = append(want, c)
+		}
+	}
+	if len(want) <= 100 {
+		t.Fatalf("expected more than a page of clips in the dataset, got %d", len(want))
+	}
+
+	resp, err := hx.Clips(&helix.ClipsParams{
+		BroadcasterID:      bid,
+		StartedAt:          from,
+		EndedAt:            to,
+		StopViewsThreshold: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Clips) != len(want) {
+		t.Fatalf("expected %d clips, got %d", len(want), len(resp.Clips))
+	}
+	for i, c := range resp.Clips {
+		if c.ClipID != want[i].ClipID {
+			t.Fatalf("clip %d: expected %s, got %s", i, want[i].ClipID, c.ClipID)
+		}
+		if i > 0 && c.ViewCount > resp.Clips[i-1].ViewCount {
+			t.Fatalf("expected clips sorted by views, got %d after %d", c.ViewCount, resp.Clips[i-1].ViewCount)
+		}
+	}
+}
+
+func TestClipsResultsLimit(t *testing.T) {
+	t.Parallel()
+	ds := Seed(1, 2, now)
+	ds.Clips = nil
+	for i := 0; i < maxClipResults+200; i++ {
+		ds.Clips = append(ds.Clips, &helix.Clip{
+			ClipID:        fmt.Sprint("clip", i),
+			BroadcasterID: "58753574",
+			CreatedAt:     now.Add(-time.Duration(i) * time.Minute).Format(time.RFC3339),
+			ViewCount:     10000 - i,
+		})
+	}
+	_, _, hx := setup(t, Opts{Dataset: ds})
+	resp, err := hx.Clips(&helix.ClipsParams{BroadcasterID: "58753574"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Clips) != maxClipResults || resp.IsComplete {
+		t.Fatalf("expected %d clips and an incomplete response, got %d (complete: %v)",
+			maxClipResults, len(resp.Clips), resp.IsComplete,
+		)
+	}
+}
+
+func TestVideos(t *testing.T) {
+	t.Parallel()
+	_, _, hx := setup(t, Opts{Seed: 1})
+	vods, err := hx.Vods(&helix.VODParams{BroadcasterID: "90075649", First: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vods) != seedVideosPerChannel {
+		t.Fatalf("expected %d vods, got %d", seedVideosPerChannel, len(vods))
+	}
+	for i, v := range vods {
+		if v.BroadcasterID != "90075649" || v.Duration == 0 {
+			t.Fatalf("unexpected vod %+v", v)
+		}
+		if i > 0 && v.CreatedAt.After(vods[i-1].CreatedAt) {
+			t.Fatal("expected the most recent vods first")
+		}
+	}
+
+	recent, err := hx.Vods(&helix.VODParams{BroadcasterID: "90075649", OnlyMostRecent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].VideoID != vods[0].VideoID {
+		t.Fatalf("expected the most recent vod, got %+v", recent)
+	}
+
+	week, err := hx.Vods(&helix.VODParams{BroadcasterID: "90075649", Period: helix.Week})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(week) == 0 || len(week) >= len(vods) {
+		t.Fatalf("expected only the vods of the last week, got %d of %d", len(week), len(vods))
+	}
+}
+
+func TestUsers(t *testing.T) {
+	t.Parallel()
+	fake, sv, hx := setup(t, Opts{Seed: 1})
+	resp, err := hx.User(&helix.UserParams{Login: "zeling"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Id != "58753574" {
+		t.Fatalf("unexpected users %+v", resp.Data)
+	}
+
+	// without a token
+	req, _ := http.NewRequest(http.MethodGet, sv.URL+HelixPath+"/users?login=zeling", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", res.StatusCode)
+	}
+
+	// revoked tokens are rejected
+	tk := fake.IssueToken("58753574")
+	fake.RevokeToken(tk)
+	req.Header.Set("Authorization", "Bearer "+tk)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", res.StatusCode)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	t.Parallel()
+	fake, _, hx := setup(t, Opts{Dataset: Seed(1, 2, now)})
+	fake.EndStream("58753574")
+	fake.EndStream("90075649")
+	if _, err := hx.Streams(&helix.StreamsParams{UserIDs: []string{"58753574"}}); !errors.Is(err, helix.ErrItemsEmpty) {
+		t.Fatalf("expected ErrItemsEmpty, got %v", err)
+	}
+	fake.SetStream(&helix.Stream{ID: "1", UserID: "58753574", UserLogin: "zeling", Type: helix.StreamLive})
+	fake.SetStream(&helix.Stream{ID: "2", UserID: "90075649", UserLogin: "illojuan", Type: helix.StreamLive})
+	streams, err := hx.Streams(&helix.StreamsParams{UserLogins: []string{"zeling", "illojuan"}, First: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 {
+		t.Fatalf("expected 2 streams, got %+v", streams)
+	}
+}
+
+func TestFail(t *testing.T) {
+	t.Parallel()
+	fake, _, hx := setup(t, Opts{Seed: 1})
+	fake.Fail(HelixPath+"/users", http.StatusServiceUnavailable, 1)
+	if _, err := hx.User(&helix.UserParams{Login: "zeling"}); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if stats := hx.RequestStats(); stats.Retries != 1 {
+		t.Fatalf("expected 1 retry, got %+v", stats)
+	}
+	fake.Fail(HelixPath+"/users", http.StatusBadRequest, 1)
+	if _, err := hx.User(&helix.UserParams{Login: "zeling"}); !errors.Is(err, helix.ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestOAuthFlow(t *testing.T) {
+	t.Parallel()
+	_, sv, _ := setup(t, Opts{Seed: 1})
+	// like the auth service, validates tokens without an app token
+	hx := helix.NewWithoutExchange(&helix.HelixOpts{ValidateEndpoint: sv.URL + OAuthPath + "/validate"})
+	conf := &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"user:read:email"},
+		RedirectURL:  "http://localhost/auth/redirect",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   sv.URL + OAuthPath + "/authorize",
+			TokenURL:  sv.URL + OAuthPath + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+	c := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := c.Get(conf.AuthCodeURL("state_1") + "&login=illojuan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	loc, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || res.StatusCode != http.StatusFound {
+		t.Fatalf("expected a redirect, got %d %q", res.StatusCode, res.Header.Get("Location"))
+	}
+	if loc.Query().Get("state") != "state_1" {
+		t.Fatalf("expected the state in the redirect, got %q", loc)
+	}
+
+	tk, err := conf.Exchange(context.Background(), loc.Query().Get("code"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hx.ValidToken(helix.ValidTokenParams{AccessToken: tk.AccessToken}) {
+		t.Fatal("expected the exchanged token to be valid")
+	}
+	if _, err := conf.Exchange(context.Background(), loc.Query().Get("code")); err == nil {
+		t.Fatal("expected codes to be exchanged only once")
+	}
+
+	// the user of the token
+	uhx := helix.NewWithUserTokens(&helix.HelixOpts{
+		Creds:  helix.ClientCreds{ClientID: clientID, ClientSecret: clientSecret},
+		APIUrl: sv.URL + HelixPath,
+	})
+	ctx := helix.ContextWithTokenSource(context.Background(), tk, helix.NotifyReuseTokenSourceOpts{
+		OAuthConfig: conf,
+	})
+	resp, err := uhx.User(&helix.UserParams{Context: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Login != "illojuan" {
+		t.Fatalf("expected the user of the token, got %+v", resp.Data)
+	}
+
+	// refreshed tokens replace the old ones
+	refreshed, err := conf.TokenSource(context.Background(), &oauth2.Token{RefreshToken: tk.RefreshToken}).Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hx.ValidToken(helix.ValidTokenParams{AccessToken: tk.AccessToken}) {
+		t.Fatal("expected the refreshed token to be invalid")
+	}
+	if !hx.ValidToken(helix.ValidTokenParams{AccessToken: refreshed.AccessToken}) {
+		t.Fatal("expected the new token to be valid")
+	}
+}
+
+// webhookServer serves the webhook handler of hx and returns its callback URL
+func webhookServer(t *testing.T, hx *helix.Helix, secret string) string {
+	t.Helper()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Post("/webhook", hx.WebhookHandler([]byte(secret)))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + ln.Addr().String() + "/webhook"
+}
+
+func TestEventSub(t *testing.T) {
+	t.Parallel()
+	fake, _, hx := setup(t, Opts{Seed: 1, Now: time.Now})
+	online := make(chan *helix.EventStreamOnline, 1)
+	offline := make(chan *helix.EventStreamOffline, 1)
+	revoked := make(chan *helix.WebhookRevokePayload, 1)
+	hx.HandleStreamOnline(func(evt *helix.EventStreamOnline) { online <- evt })
+	hx.HandleStreamOffline(func(evt *helix.EventStreamOffline) { offline <- evt })
+	hx.HandleRevocation(func(evt *helix.WebhookRevokePayload) { revoked <- evt })
+
+	secret := "webhook_secret"
+	callback := webhookServer(t, hx, secret)
+	subscribe := func(typ, secret string) {
+		t.Helper()
+		if err := hx.CreateEventsubSubscription(&helix.Subscription{
+			Type:      typ,
+			Version:   "1",
+			Condition: &helix.Condition{BroadcasterUserID: "58753574"},
+			Transport: &helix.Transport{Method: "webhook", Callback: callback, Secret: secret},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subscribe(helix.SubStreamOnline, secret)
+	subscribe(helix.SubStreamOffline, secret)
+	fake.WaitDeliveries()
+	for _, sub := range fake.Subscriptions() {
+		if sub.Status != StatusEnabled {
+			t.Fatalf("expected the subscription to be verified, got %+v", sub)
+		}
+	}
+
+	if n, err := fake.StreamOnline(context.Background(), "58753574"); err != nil || n != 1 {
+		t.Fatalf("expected 1 notification, got %d: %v", n, err)
+	}
+	select {
+	case evt := <-online:
+		if evt.Broadcaster.ID != "58753574" || evt.Type != helix.StreamLive {
+			t.Fatalf("unexpected event %+v", evt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream.online not received")
+	}
+	if n, err := fake.StreamOffline(context.Background(), "58753574"); err != nil || n != 1 {
+		t.Fatalf("expected 1 notification, got %d: %v", n, err)
+	}
+	select {
+	case evt := <-offline:
+		if evt.Broadcaster.Login != "zeling" {
+			t.Fatalf("unexpected event %+v", evt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream.offline not received")
+	}
+
+	sub := fake.Subscriptions()[0]
+	if err := fake.Revoke(context.Background(), sub.ID, StatusAuthRevoked); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case evt := <-revoked:
+		if evt.Subscription.ID != sub.ID || evt.Subscription.Status != StatusAuthRevoked {
+			t.Fatalf("unexpected revocation %+v", evt.Subscription)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("revocation not received")
+	}
+	if subs := fake.Subscriptions(); len(subs) != 1 {
+		t.Fatalf("expected the revoked subscription to be removed, got %d", len(subs))
+	}
+}
+
+func TestEventSubVerificationFailed(t *testing.T) {
+	t.Parallel()
+	fake, _, hx := setup(t, Opts{Seed: 1, Now: time.Now})
+	// the handler rejects the signature of a different secret
+	callback := webhookServer(t, hx, "another_secret")
+	if err := hx.CreateEventsubSubscription(&helix.Subscription{
+		Type:      helix.SubStreamOnline,
+		Version:   "1",
+		Condition: &helix.Condition{BroadcasterUserID: "90075649"},
+		Transport: &helix.Transport{Method: "webhook", Callback: callback, Secret: "webhook_secret"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fake.WaitDeliveries()
+	subs := fake.Subscriptions()
+	if len(subs) != 1 || subs[0].Status != StatusVerificationFailed {
+		t.Fatalf("expected a failed verification, got %+v", subs)
+	}
+	if n, err := fake.StreamOnline(context.Background(), "90075649"); err != nil || n != 0 {
+		t.Fatalf("expected no notifications, got %d: %v", n, err)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	t.Parallel()
+	items := []int{1, 2, 3, 4, 5}
+	tests := []struct {
+		first, offset, limit int
+		want                 []int
+		more                 bool
+	}{
+		{2, 0, 5, []int{1, 2}, true},
+		{2, 4, 5, []int{5}, false},
+		{10, 0, 3, []int{1, 2, 3}, false},
+		{2, 6, 5, []int{}, false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		writePage(w, items, tt.first, tt.offset, tt.limit)
+		var got helix.PaginationManyObj[int]
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Data, tt.want) {
+			t.Errorf("%+v: expected %v, got %v", tt, tt.want, got.Data)
+		}
+		if more := got.Pagination != nil && got.Pagination.Cursor != ""; more != tt.more {
+			t.Errorf("%+v: expected more pages %v, got %v", tt, tt.more, more)
+			continue
+		}
+		if tt.more {
+			if offset, err := decodeCursor(got.Pagination.Cursor); err != nil || offset != tt.offset+tt.first {
+				t.Errorf("%+v: unexpected cursor offset %d: %v", tt, offset, err)
+			}
+		}
+	}
+}
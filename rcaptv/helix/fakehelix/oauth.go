@@ -0,0 +1,298 @@
+package fakehelix
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"pedro.to/rcaptv/helix"
+)
+
+// TokenExpiration of the issued access tokens
+const TokenExpiration = 4 * time.Hour
+
+type token struct {
+	clientID string
+	// userID is empty for app tokens
+	userID    string
+	scopes    []string
+	expiresAt time.Time
+	// remaining points of the rate limit bucket and when it was last refilled
+	remaining int
+	refilled  time.Time
+}
+
+// IssueToken returns a new access token of the app for the user, or an app
+// token if userID is empty. Useful to authenticate requests without going
+// through the OAuth flow.
+func (s *Server) IssueToken(userID string, scopes ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	access, _ := s.issue(userID, scopes)
+	return access
+}
+
+// issue returns a new access token and its refresh token. s.mu must be held.
+func (s *Server) issue(userID string, scopes []string) (string, string) {
+	access, refresh := randomToken(), randomToken()
+	s.tokens[access] = &token{
+		clientID:  s.opts.ClientID,
+		userID:    userID,
+		scopes:    scopes,
+		expiresAt: s.opts.Now().Add(TokenExpiration),
+		remaining: s.opts.RateLimit,
+		refilled:  s.opts.Now(),
+	}
+	s.refreshTokens[refresh] = access
+	return access, refresh
+}
+
+// RevokeToken makes an access token invalid
+func (s *Server) RevokeToken(access string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, access)
+}
+
+// lookup returns the token if it is valid. s.mu must be held.
+func (s *Server) lookup(access string) (*token, bool) {
+	tk, ok := s.tokens[access]
+	if !ok || !s.opts.Now().Before(tk.expiresAt) {
+		return nil, false
+	}
+	return tk, true
+}
+
+// authenticated checks the bearer token of helix requests and sets the rate
+// limit headers of its bucket
+func (s *Server) authenticated(h func(w http.ResponseWriter, r *http.Request, tk *token)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		access, ok := cutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "OAuth token is missing")
+			return
+		}
+		s.mu.Lock()
+		tk, ok := s.lookup(access)
+		if !ok {
+			s.mu.Unlock()
+			writeError(w, http.StatusUnauthorized, "Invalid OAuth token")
+			return
+		}
+		if cid := r.Header.Get("Client-Id"); cid != "" && cid != tk.clientID {
+			s.mu.Unlock()
+			writeError(w, http.StatusUnauthorized, "Client ID and OAuth token do not match")
+			return
+		}
+		// the bucket is refilled every minute
+		now := s.opts.Now()
+		if now.Sub(tk.refilled) >= time.Minute {
+			tk.remaining, tk.refilled = s.opts.RateLimit, now
+		}
+		limited := tk.remaining == 0
+		if !limited {
+			tk.remaining--
+		}
+		h2 := w.Header()
+		h2.Set(helix.HeaderRatelimitLimit, strconv.Itoa(s.opts.RateLimit))
+		h2.Set(helix.HeaderRatelimitRemaining, strconv.Itoa(tk.remaining))
+		h2.Set(helix.HeaderRatelimitReset, strconv.FormatInt(tk.refilled.Add(time.Minute).Unix(), 10))
+		c := *tk
+		s.mu.Unlock()
+		if limited {
+			writeError(w, http.StatusTooManyRequests, "Too Many Requests")
+			return
+		}
+		h(w, r, &c)
+	}
+}
+
+// authCode is an authorization code not exchanged yet
+type authCode struct {
+	userID string
+	scopes []string
+}
+
+type tokenResponse struct {
+	AccessToken  string   `json:"access_token"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	ExpiresIn    int      `json:"expires_in"`
+	Scope        []string `json:"scope,omitempty"`
+	TokenType    string   `json:"token_type"`
+}
+
+// handleToken issues tokens for the client_credentials, authorization_code
+// and refresh_token grants
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid form")
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.opts.ClientID || secret != s.opts.ClientSecret {
+		writeError(w, http.StatusForbidden, "invalid client secret")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		userID string
+		scopes []string
+	)
+	switch grant := r.PostForm.Get("grant_type"); grant {
+	case "client_credentials":
+		access, _ := s.issue("", nil)
+		writeJSON(w, http.StatusOK, tokenResponse{
+			AccessToken: access,
+			ExpiresIn:   int(TokenExpiration.Seconds()),
+			TokenType:   "bearer",
+		})
+		return
+	case "authorization_code":
+		code := r.PostForm.Get("code")
+		ac, ok := s.codes[code]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Invalid authorization code")
+			return
+		}
+		delete(s.codes, code)
+		userID, scopes = ac.userID, ac.scopes
+	case "refresh_token":
+		refresh := r.PostForm.Get("refresh_token")
+		old, ok := s.refreshTokens[refresh]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Invalid refresh token")
+			return
+		}
+		delete(s.refreshTokens, refresh)
+		if tk, ok := s.tokens[old]; ok {
+			userID, scopes = tk.userID, tk.scopes
+			delete(s.tokens, old)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid grant type "+grant)
+		return
+	}
+	access, refresh := s.issue(userID, scopes)
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(TokenExpiration.Seconds()),
+		Scope:        scopes,
+		TokenType:    "bearer",
+	})
+}
+
+// handleValidate validates the tokens sent with the OAuth authorization
+// scheme
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	auth := r.Header.Get("Authorization")
+	access, ok := cutPrefix(auth, "OAuth ")
+	if !ok {
+		access, ok = cutPrefix(auth, "Bearer ")
+	}
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing authorization token")
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tk, ok := s.lookup(access)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	resp := helix.ValidateTokenResponse{
+		ClientID:     tk.clientID,
+		Scopes:       tk.scopes,
+		TwitchUserID: tk.userID,
+		ExpiresIn:    int(tk.expiresAt.Sub(s.opts.Now()).Seconds()),
+	}
+	if resp.Scopes == nil {
+		resp.Scopes = []string{}
+	}
+	for _, u := range s.ds.Users {
+		if u.Id == tk.userID {
+			resp.Login = u.Login
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAuthorize authorizes the app right away, as the user of the login
+// parameter or the first user of the dataset, and redirects back with the
+// code
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("client_id") != s.opts.ClientID {
+		writeError(w, http.StatusBadRequest, "invalid client")
+		return
+	}
+	if q.Get("response_type") != "code" {
+		writeError(w, http.StatusBadRequest, "unsupported response_type")
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Host == "" {
+		writeError(w, http.StatusBadRequest, "invalid redirect_uri")
+		return
+	}
+
+	s.mu.Lock()
+	var userID string
+	for _, u := range s.ds.Users {
+		if login := q.Get("login"); login == "" || login == u.Login {
+			userID = u.Id
+			break
+		}
+	}
+	if userID == "" {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, "unknown login")
+		return
+	}
+	code := randomToken()
+	s.codes[code] = authCode{userID: userID, scopes: strings.Fields(q.Get("scope"))}
+	s.mu.Unlock()
+
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("scope", q.Get("scope"))
+	if state := q.Get("state"); state != "" {
+		rq.Set("state", state)
+	}
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func randomToken() string {
+	b := make([]byte, 15)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
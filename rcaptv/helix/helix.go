@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -16,8 +17,6 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 	"golang.org/x/oauth2/twitch"
-
-	"pedro.to/rcaptv/utils"
 )
 
 const (
@@ -55,13 +54,7 @@ func ContextWithCustomQueryOpts(ctx context.Context, opts *CustomQueryOpts) cont
 
 const EstimatedSubscriptionJSONSize = 350
 
-// Note: thinking about moving this to opts? Maybe it's time for a custom http
-// client
-const (
-	HttpMaxAttempts                     = 3
-	HttpRetryDelay                      = time.Second * 5
-	HttpMaxClientResponseReadLimitBytes = 1 * MB
-)
+const HttpMaxClientResponseReadLimitBytes = 1 * MB
 
 var (
 	ErrTooManyRequestAttempts = errors.New("no attempts left for performing requests")
@@ -130,6 +123,8 @@ type HelixOpts struct {
 	APIUrl           string
 	EventsubEndpoint string
 	ValidateEndpoint string
+	// TokenURL of the client credentials exchange. Defaults to the Twitch one
+	TokenURL string
 
 	// Event handlers
 	HandleStreamOnline  func(evt *EventStreamOnline)
@@ -137,6 +132,22 @@ type HelixOpts struct {
 
 	// Webhook handlers
 	HandleRevocation func(evt *WebhookRevokePayload)
+
+	// RateLimitMaxWait is the max time a request waits for the rate limit
+	// bucket to refill before failing with ErrRateLimited. Defaults to
+	// DefaultRateLimitMaxWait. Use a negative value to never wait.
+	RateLimitMaxWait time.Duration
+	// RateLimitReserve is the number of points of each bucket that are never
+	// used, leaving room for other clients sharing the same credentials
+	RateLimitReserve int
+
+	// RetryPolicy of the requests. Defaults to DefaultRetryPolicy
+	RetryPolicy *RetryPolicy
+	// Breaker options of the circuit breaker. Defaults to DefaultBreakerOpts
+	Breaker *BreakerOpts
+
+	// Middlewares wrap the transport of the http client, see Middleware
+	Middlewares []Middleware
 }
 
 // Helix client for Twitch Helix API
@@ -148,6 +159,11 @@ type Helix struct {
 	opts             *HelixOpts
 	defaultClient    *http.Client
 	defaultQueryOpts *CustomQueryOpts
+	// rl keeps the rate limit buckets shared by all the requests
+	rl       rateLimiter
+	counters requestCounters
+	// br stops sending requests while the Twitch API is failing
+	br breaker
 
 	useUserTokens bool
 }
@@ -192,16 +208,23 @@ func (hx *Helix) EventsubEndpoint() string {
 	return hx.opts.EventsubEndpoint
 }
 
-// Do handles some errors for resiliency and retries if possible. If
-// Ratelimit-reset header is present during TooManyRequests errors it will
-// retry after the reset time
+// Do handles some errors for resiliency and retries if possible, following
+// HelixOpts.RetryPolicy or DefaultRetryPolicy. If Ratelimit-reset header is
+// present during TooManyRequests errors it will retry after the reset time.
+// Waits between attempts end early if the request context is done.
 //
-// HttpMaxAttempts controls the number of attempts to retry.
-// HttpRetryDelay controls the delay before a retry for 5XX errors.
+// Requests go through a rate limit governor that tracks the Twitch bucket of
+// the app token or the user token from the response headers. If the bucket is
+// empty the request waits until there are points again, or fails with
+// ErrRateLimited if that would take more than HelixOpts.RateLimitMaxWait.
+//
+// A circuit breaker opens when too many requests fail with 5xx responses or
+// timeouts. While it is open requests fail right away with ErrCircuitOpen,
+// see BreakerState.
 //
 // Do not use the body of the response as it will already be processed.
 func (hx *Helix) Do(req *http.Request) (*HttpResponse, error) {
-	return hx.doAtMost(req, HttpMaxAttempts)
+	return hx.do(req)
 }
 
 type PaginationManyObj[T any] struct {
@@ -283,15 +306,57 @@ PaginationLoop:
 	return all, nil
 }
 
-func (hx *Helix) doAtMost(req *http.Request, attemptsLeft int) (*HttpResponse, error) {
-	lctx := log.With().
-		Str("ctx", "helix")
-
-	if attemptsLeft <= 0 {
-		return nil, ErrTooManyRequestAttempts
+func (hx *Helix) do(req *http.Request) (*HttpResponse, error) {
+	policy := hx.retryPolicy()
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			hx.counters.retries.Add(1)
+			// the body was consumed by the previous attempt
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+		resp, wait, err := hx.doAttempt(req, attempt, policy)
+		if err == nil {
+			return resp, nil
+		}
+		if wait < 0 {
+			return nil, err
+		}
+		lastErr = err
+		if attempt == maxAttempts {
+			break
+		}
+		log.Warn().Str("ctx", "helix").Msgf("%s %s failed: %s. Retry: %s (attempts:%d/%d)",
+			req.Method, req.URL.Path, err.Error(), wait, attempt, maxAttempts,
+		)
+		if err := sleepCtx(req.Context(), wait); err != nil {
+			return nil, err
+		}
 	}
+	return nil, fmt.Errorf("%w: %w", ErrTooManyRequestAttempts, lastErr)
+}
+
+// doAttempt performs a single attempt of the request. If it fails, wait is the
+// time to wait before retrying it, or negative if it can't be retried.
+func (hx *Helix) doAttempt(req *http.Request, attempt int, policy *RetryPolicy) (r *HttpResponse, wait time.Duration, err error) {
+	l := log.With().
+		Str("ctx", "helix").
+		Int("attempts", attempt).
+		Str("endpoint", req.URL.Path).
+		Logger()
 
 	ctx := req.Context()
+	noRetry := time.Duration(-1)
 
 	qopts := hx.defaultQueryOpts
 	if o, ok := ctx.Value(CtxHelixCustomQuery).(*CustomQueryOpts); ok && o != nil {
@@ -302,89 +367,123 @@ func (hx *Helix) doAtMost(req *http.Request, attemptsLeft int) (*HttpResponse, e
 		req.Header.Set("Client-Id", hx.ClientID())
 	}
 
-	attempts := utils.Abs(attemptsLeft-HttpMaxAttempts) + 1
-	l := lctx.
-		Int("attempts", attempts).
-		Str("endpoint", req.URL.Path).
-		Logger()
-
 	c := hx.defaultClient
+	// bucketKey identifies the rate limit bucket, empty for the app token
+	var bucketKey string
 	if hx.useUserTokens {
 		ts, ok := ctx.Value(CtxHelixTokenSource).(oauth2.TokenSource)
 		if !ok {
 			l.Err(ErrInvalidContext).Msg("invalid context")
-			return nil, ErrInvalidContext
+			return nil, noRetry, ErrInvalidContext
+		}
+		tk, err := ts.Token()
+		if err != nil {
+			return nil, noRetry, err
 		}
+		bucketKey = tk.AccessToken
 		// we only will use this client within the current request
-		c = oauth2.NewClient(req.Context(), ts)
+		uc := *hx.defaultClient
+		uc.Transport = &oauth2.Transport{Source: ts, Base: hx.defaultClient.Transport}
+		c = &uc
 	}
+	bo := hx.breakerOpts()
+	probe, err := hx.br.allow(time.Now(), bo)
+	if err != nil {
+		l.Warn().Msgf("%s-> %s %s (attempts:%d) <- ('%s')",
+			req.Method, req.URL.Path, req.URL.RawQuery, attempt, err.Error(),
+		)
+		return nil, noRetry, err
+	}
+	outcome := outcomeIgnored
+	defer func() {
+		hx.br.done(time.Now(), bo, probe, outcome)
+	}()
+
+	if err := hx.rl.wait(ctx, bucketKey, hx.opts.RateLimitReserve, hx.rateLimitMaxWait()); err != nil {
+		l.Warn().Msgf("%s-> %s %s (attempts:%d) <- ('%s')",
+			req.Method, req.URL.Path, req.URL.RawQuery, attempt, err.Error(),
+		)
+		return nil, noRetry, err
+	}
+
+	hx.counters.attempt(ctx)
+	defer func() {
+		if err != nil {
+			hx.counters.failures.Add(1)
+		}
+	}()
 	resp, err := c.Do(req)
 	if err != nil {
+		outcome = outcomeOf(ctx, 0, err)
 		l.Info().Msgf("%s-> %s %s (attempts:%d) <- ('%s')",
-			req.Method, req.URL.Path, req.URL.RawQuery, attempts, err.Error(),
+			req.Method, req.URL.Path, req.URL.RawQuery, attempt, err.Error(),
 		)
-		return nil, err
+		if policy.retryableError(err) {
+			return nil, policy.backoff(attempt), err
+		}
+		return nil, noRetry, err
 	}
 	l.Info().Msgf("%s-> %s %s (attempts:%d) <- %d",
-		req.Method, req.URL.Path, req.URL.RawQuery, attempts, resp.StatusCode,
+		req.Method, req.URL.Path, req.URL.RawQuery, attempt, resp.StatusCode,
 	)
+	outcome = outcomeOf(ctx, resp.StatusCode, nil)
 	defer resp.Body.Close()
-	r := io.LimitReader(resp.Body, HttpMaxClientResponseReadLimitBytes)
-	body, err := ioutil.ReadAll(r)
+	lr := io.LimitReader(resp.Body, HttpMaxClientResponseReadLimitBytes)
+	body, err := ioutil.ReadAll(lr)
 	if err != nil {
-		return nil, ErrBodyResponseTooBig
+		return nil, noRetry, ErrBodyResponseTooBig
 	}
 
 	respondedAt, err := parseRespDate(resp.Header.Get("Date"))
 	if err != nil {
-		return nil, err
+		return nil, noRetry, err
 	}
+	hx.rl.observe(bucketKey, resp.Header, respondedAt)
 
 	switch resp.StatusCode {
-	case http.StatusOK:
-	case http.StatusCreated:
-	case http.StatusNoContent:
-	case http.StatusAccepted:
-		break
-	case http.StatusTooManyRequests:
-		d, err := untilRatelimitReset(
-			resp.Header.Get("Ratelimit-Reset"),
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusAccepted:
+		return &HttpResponse{
+			Body:       body,
+			StatusCode: resp.StatusCode,
+		}, 0, nil
+	}
+
+	if policy.retryableStatus(resp.StatusCode) {
+		err := fmt.Errorf("%w: %d", ErrUnexpectedStatusCode, resp.StatusCode)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return nil, policy.backoff(attempt), err
+		}
+		d, rerr := untilRatelimitReset(
+			resp.Header.Get(HeaderRatelimitReset),
 			respondedAt,
 		)
-		if err != nil {
-			return nil, err
+		if rerr != nil {
+			return nil, policy.backoff(attempt), err
 		}
 		l.Warn().Msgf("ratelimit reached for %s: %s %s. Retry: %s (attempts:%d)",
-			req.Method, req.URL.Path, req.URL.RawQuery, d, attempts,
-		)
-		time.Sleep(d + time.Second)
-		attemptsLeft--
-		return hx.doAtMost(req, attemptsLeft)
-	case http.StatusInternalServerError:
-	case http.StatusBadGateway:
-	case http.StatusServiceUnavailable:
-	case http.StatusGatewayTimeout:
-		l.Warn().Msgf("got HTTP %d from provider for %s: %s %s. Retry %s (attempts:%d)",
-			resp.StatusCode, req.Method, req.URL.Path, req.URL.RawQuery,
-			HttpRetryDelay, attempts,
+			req.Method, req.URL.Path, req.URL.RawQuery, d, attempt,
 		)
-		// Retry some 5XX twitch responses for resiliency
-		time.Sleep(HttpRetryDelay)
-		attemptsLeft--
-		return hx.doAtMost(req, attemptsLeft)
+		return nil, d + time.Second, err
+	}
+	switch resp.StatusCode {
 	case http.StatusUnauthorized:
-		return nil, ErrUnauthorized
+		return nil, noRetry, ErrUnauthorized
 	case http.StatusBadRequest:
-		return nil, ErrBadRequest
+		return nil, noRetry, ErrBadRequest
 	case http.StatusNotFound:
-		return nil, ErrNotFound
-	default:
-		return nil, ErrUnexpectedStatusCode
+		return nil, noRetry, ErrNotFound
+	}
+	return nil, noRetry, fmt.Errorf("%w: %d", ErrUnexpectedStatusCode, resp.StatusCode)
+}
+
+func (hx *Helix) rateLimitMaxWait() time.Duration {
+	switch {
+	case hx.opts.RateLimitMaxWait < 0:
+		return 0
+	case hx.opts.RateLimitMaxWait == 0:
+		return DefaultRateLimitMaxWait
 	}
-	return &HttpResponse{
-		Body:       body,
-		StatusCode: resp.StatusCode,
-	}, nil
+	return hx.opts.RateLimitMaxWait
 }
 
 func (hx *Helix) HandleStreamOnline(cb func(evt *EventStreamOnline)) {
@@ -427,9 +526,20 @@ func (hx *Helix) Exchange() {
 	o2 := &clientcredentials.Config{
 		ClientID:     hx.ClientID(),
 		ClientSecret: hx.ClientSecret(),
-		TokenURL:     twitch.Endpoint.TokenURL,
+		TokenURL:     hx.opts.TokenURL,
 	}
-	hx.defaultClient = o2.Client(hx.ctx)
+	hx.setTokenSource(o2.TokenSource(hx.ctx))
+}
+
+// setTokenSource makes the default client authenticate with the tokens of ts.
+// The token is injected before the middlewares of the client.
+func (hx *Helix) setTokenSource(ts oauth2.TokenSource) {
+	c := *hx.defaultClient
+	c.Transport = &oauth2.Transport{
+		Source: ts,
+		Base:   hx.defaultClient.Transport,
+	}
+	hx.defaultClient = &c
 }
 
 // NewWithoutExchange instantiates a new Helix client but without exchanging
@@ -438,18 +548,25 @@ func (hx *Helix) Exchange() {
 //
 // Use New() if your helix client will use authenticated endpoints with app
 // tokens and NewWithUserTokens() if your will use user tokens instead.
+//
+// The http client c is used in every mode, with the token source and
+// HelixOpts.Middlewares wrapping its transport.
 func NewWithoutExchange(opts *HelixOpts, c ...*http.Client) *Helix {
 	hx := &Helix{
-		opts:          opts,
-		defaultClient: http.DefaultClient,
+		opts: opts,
 		defaultQueryOpts: &CustomQueryOpts{
 			UseClientID: true,
 		},
 		ctx: context.Background(),
 	}
+	dc := *http.DefaultClient
 	if len(c) == 1 {
-		hx.defaultClient = c[0]
+		dc = *c[0]
+	}
+	if len(opts.Middlewares) > 0 {
+		dc.Transport = Chain(dc.Transport, opts.Middlewares...)
 	}
+	hx.defaultClient = &dc
 	if hx.opts.HandleStreamOnline == nil {
 		hx.opts.HandleStreamOnline = func(evt *EventStreamOnline) {}
 	}
@@ -462,6 +579,9 @@ func NewWithoutExchange(opts *HelixOpts, c ...*http.Client) *Helix {
 	if hx.opts.ValidateEndpoint == "" {
 		hx.opts.ValidateEndpoint = TwitchValidateEndpoint
 	}
+	if hx.opts.TokenURL == "" {
+		hx.opts.TokenURL = twitch.Endpoint.TokenURL
+	}
 	return hx
 }
 
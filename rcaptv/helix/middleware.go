@@ -0,0 +1,169 @@
+package helix
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+)
+
+// Middleware wraps the transport of the helix client. It sees every request
+// after the Authorization header is set, in both app token and user token
+// modes. The request must not be modified, clone it instead.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to use functions as http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps rt with the middlewares. The first middleware is the outermost
+// one, so it is the first to see the request and the last to see the
+// response.
+func Chain(rt http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		rt = mws[i](rt)
+	}
+	return rt
+}
+
+// HeaderMiddleware sets the given headers on every request, replacing the
+// existing values
+func HeaderMiddleware(h http.Header) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for k, v := range h {
+				req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// redactedHeaders are replaced in the dumps of DumpMiddleware
+var redactedHeaders = []string{"Authorization", "Client-Id"}
+
+// DumpMiddleware writes every request and response to w, with the
+// Authorization and Client-Id headers redacted. Bodies are included if body is
+// true. Meant for debugging only.
+func DumpMiddleware(w io.Writer, body bool) Middleware {
+	var mu sync.Mutex
+	write := func(b []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(b)
+		w.Write([]byte("\n"))
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			dreq := req.Clone(req.Context())
+			for _, h := range redactedHeaders {
+				if dreq.Header.Get(h) != "" {
+					dreq.Header.Set(h, "REDACTED")
+				}
+			}
+			// the body of the clone is the same reader, dump a copy
+			dumpBody := false
+			if body && req.GetBody != nil {
+				if b, err := req.GetBody(); err == nil {
+					dreq.Body = b
+					dumpBody = true
+				}
+			}
+			if b, err := httputil.DumpRequestOut(dreq, dumpBody); err == nil {
+				write(b)
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				write([]byte(fmt.Sprintf("%s %s: %s\n", req.Method, req.URL, err)))
+				return nil, err
+			}
+			if b, err := httputil.DumpResponse(resp, body); err == nil {
+				write(b)
+			}
+			return resp, nil
+		})
+	}
+}
+
+// EndpointMetrics are the metrics of the requests to an endpoint
+type EndpointMetrics struct {
+	Requests int64
+	// Errors is the number of requests that failed without a response
+	Errors        int64
+	StatusCodes   map[int]int64
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+}
+
+// TransportMetrics collects the metrics of MetricsMiddleware. The zero value
+// is ready to use.
+type TransportMetrics struct {
+	mu        sync.Mutex
+	endpoints map[string]*EndpointMetrics
+}
+
+func (m *TransportMetrics) record(endpoint string, status int, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.endpoints == nil {
+		m.endpoints = make(map[string]*EndpointMetrics, 8)
+	}
+	em, ok := m.endpoints[endpoint]
+	if !ok {
+		em = &EndpointMetrics{StatusCodes: make(map[int]int64, 4)}
+		m.endpoints[endpoint] = em
+	}
+	em.Requests++
+	if err != nil {
+		em.Errors++
+	} else {
+		em.StatusCodes[status]++
+	}
+	em.TotalDuration += d
+	if d > em.MaxDuration {
+		em.MaxDuration = d
+	}
+}
+
+// Snapshot returns a copy of the metrics by endpoint, with keys like
+// "GET /helix/clips"
+func (m *TransportMetrics) Snapshot() map[string]EndpointMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := make(map[string]EndpointMetrics, len(m.endpoints))
+	for k, em := range m.endpoints {
+		c := *em
+		c.StatusCodes = make(map[int]int64, len(em.StatusCodes))
+		for code, n := range em.StatusCodes {
+			c.StatusCodes[code] = n
+		}
+		s[k] = c
+	}
+	return s
+}
+
+// MetricsMiddleware records the count, status codes and latency of the
+// requests by endpoint in m. Every attempt of a retried request is recorded.
+func MetricsMiddleware(m *TransportMetrics) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			m.record(req.Method+" "+req.URL.Path, status, time.Since(start), err)
+			return resp, err
+		})
+	}
+}
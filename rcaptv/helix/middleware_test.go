@@ -0,0 +1,135 @@
+package helix
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestChainOrder(t *testing.T) {
+	t.Parallel()
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+">")
+				resp, err := next.RoundTrip(req)
+				order = append(order, "<"+name)
+				return resp, err
+			})
+		}
+	}
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "base")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	if _, err := Chain(base, mw("a"), mw("b")).RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(order, " "); got != "a> b> base <b <a" {
+		t.Fatalf("unexpected order %q", got)
+	}
+}
+
+// userTokenCtx returns a context with a static user token source
+func userTokenCtx(token string) context.Context {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	return context.WithValue(context.Background(), CtxHelixTokenSource, ts)
+}
+
+func TestMiddlewaresUserTokens(t *testing.T) {
+	t.Parallel()
+	var got http.Header
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer sv.Close()
+
+	var metrics TransportMetrics
+	var dump bytes.Buffer
+	opts := &HelixOpts{
+		APIUrl: sv.URL,
+		Middlewares: []Middleware{
+			MetricsMiddleware(&metrics),
+			HeaderMiddleware(http.Header{"X-Request-Source": {"tracker"}}),
+			DumpMiddleware(&dump, true),
+		},
+	}
+	// the client passed to NewWithoutExchange is used with user tokens too
+	var viaClient bool
+	c := sv.Client()
+	base := c.Transport
+	c.Transport = RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		viaClient = true
+		return base.RoundTrip(req)
+	})
+	hx := NewWithoutExchange(opts, c)
+	hx.useUserTokens = true
+
+	for i := 0; i < 2; i++ {
+		if err := doFakeRequest(hx, userTokenCtx("user_token"), sv.URL+"/clips"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !viaClient {
+		t.Fatal("expected the request to be sent by the given client")
+	}
+	if v := got.Get("Authorization"); v != "Bearer user_token" {
+		t.Fatalf("expected user token authorization, got %q", v)
+	}
+	if v := got.Get("X-Request-Source"); v != "tracker" {
+		t.Fatalf("expected injected header, got %q", v)
+	}
+	m, ok := metrics.Snapshot()["GET /clips"]
+	if !ok || m.Requests != 2 || m.StatusCodes[http.StatusOK] != 2 || m.Errors != 0 {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+	d := dump.String()
+	if strings.Contains(d, "user_token") {
+		t.Fatalf("expected the token to be redacted, got %q", d)
+	}
+	if !strings.Contains(d, "GET /clips") || !strings.Contains(d, `{"data":[]}`) {
+		t.Fatalf("expected request and response in the dump, got %q", d)
+	}
+}
+
+func TestMiddlewaresAppToken(t *testing.T) {
+	t.Parallel()
+	var got http.Header
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer sv.Close()
+
+	var metrics TransportMetrics
+	hx := NewWithoutExchange(&HelixOpts{
+		APIUrl: sv.URL,
+		Middlewares: []Middleware{
+			MetricsMiddleware(&metrics),
+			HeaderMiddleware(http.Header{"X-Request-Source": {"api"}}),
+		},
+	}, sv.Client())
+	// what Exchange does, with a static token instead of the twitch endpoint
+	hx.setTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "app_token"}))
+
+	if err := doFakeRequest(hx, context.Background(), sv.URL+"/videos"); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Get("Authorization"); v != "Bearer app_token" {
+		t.Fatalf("expected app token authorization, got %q", v)
+	}
+	if v := got.Get("X-Request-Source"); v != "api" {
+		t.Fatalf("expected injected header, got %q", v)
+	}
+	if m := metrics.Snapshot()["GET /videos"]; m.Requests != 1 {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+}
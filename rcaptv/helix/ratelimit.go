@@ -0,0 +1,219 @@
+package helix
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Twitch rate limit headers
+// See https://dev.twitch.tv/docs/api/guide/#twitch-rate-limits
+const (
+	HeaderRatelimitLimit     = "Ratelimit-Limit"
+	HeaderRatelimitRemaining = "Ratelimit-Remaining"
+	HeaderRatelimitReset     = "Ratelimit-Reset"
+)
+
+const (
+	// DefaultRateLimitMaxWait is the max time a request waits for budget when
+	// HelixOpts.RateLimitMaxWait is not set
+	DefaultRateLimitMaxWait = time.Minute
+	// maxUserBuckets is the number of user token buckets kept before pruning
+	// the ones already refilled
+	maxUserBuckets = 1024
+)
+
+var ErrRateLimited = errors.New("rate limit budget exhausted")
+
+// RateLimitBudget is the estimated state of a Twitch rate limit bucket
+type RateLimitBudget struct {
+	// Known is false until a response with rate limit headers is received. Every
+	// other field is zero in that case
+	Known bool
+	// Limit is the size of the bucket
+	Limit int
+	// Remaining is the estimated number of points left right now, taking into
+	// account the points used since the last response and the ones refilled
+	Remaining int
+	// ResetAt is when the bucket will be full again if no more requests are
+	// made
+	ResetAt time.Time
+}
+
+// bucket is a token bucket that mirrors the Twitch one from the rate limit
+// headers of the responses. Between responses it assumes that points are
+// refilled at a constant rate, so the bucket is full at the reset time.
+type bucket struct {
+	known  bool
+	limit  float64
+	tokens float64
+	// at is when tokens was last computed
+	at      time.Time
+	resetAt time.Time
+	// rate of refill in points per second
+	rate float64
+}
+
+func (b *bucket) refill(now time.Time) {
+	if !b.known || !now.After(b.at) {
+		return
+	}
+	if !now.Before(b.resetAt) {
+		b.tokens = b.limit
+	} else {
+		b.tokens = math.Min(b.limit, b.tokens+b.rate*now.Sub(b.at).Seconds())
+	}
+	b.at = now
+}
+
+// take a point from the bucket if there are more than reserve left. Otherwise
+// returns how long until there will be.
+func (b *bucket) take(now time.Time, reserve int) time.Duration {
+	if !b.known {
+		return 0
+	}
+	b.refill(now)
+	need := 1 + float64(reserve)
+	if b.tokens >= need {
+		b.tokens--
+		return 0
+	}
+	if b.rate <= 0 {
+		return b.resetAt.Sub(now)
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// observe updates the bucket with the rate limit headers of a response.
+// respondedAt is the Date of the response, used to compute the reset time in
+// local time as the clocks may not be in sync.
+func (b *bucket) observe(h http.Header, now, respondedAt time.Time) {
+	limit, err := strconv.Atoi(h.Get(HeaderRatelimitLimit))
+	if err != nil || limit <= 0 {
+		return
+	}
+	remaining, err := strconv.Atoi(h.Get(HeaderRatelimitRemaining))
+	if err != nil {
+		return
+	}
+	d, err := untilRatelimitReset(h.Get(HeaderRatelimitReset), respondedAt)
+	if err != nil {
+		return
+	}
+	b.known = true
+	b.limit = float64(limit)
+	b.tokens = math.Max(0, math.Min(float64(remaining), b.limit))
+	b.at = now
+	b.resetAt = now.Add(d)
+	b.rate = 0
+	if d > 0 {
+		b.rate = (b.limit - b.tokens) / d.Seconds()
+	} else {
+		b.tokens = b.limit
+	}
+}
+
+func (b *bucket) budget(now time.Time) RateLimitBudget {
+	if !b.known {
+		return RateLimitBudget{}
+	}
+	b.refill(now)
+	return RateLimitBudget{
+		Known:     true,
+		Limit:     int(b.limit),
+		Remaining: int(b.tokens),
+		ResetAt:   b.resetAt,
+	}
+}
+
+// rateLimiter keeps the bucket of the app token and one per user token. The
+// zero value is ready to use.
+type rateLimiter struct {
+	mu    sync.Mutex
+	app   bucket
+	users map[string]*bucket
+}
+
+// bucket returns the bucket of the given user token or the app bucket if
+// userToken is empty. Must be called with the lock held.
+func (rl *rateLimiter) bucket(userToken string, now time.Time) *bucket {
+	if userToken == "" {
+		return &rl.app
+	}
+	if rl.users == nil {
+		rl.users = make(map[string]*bucket, 64)
+	}
+	b, ok := rl.users[userToken]
+	if !ok {
+		if len(rl.users) >= maxUserBuckets {
+			for k, ub := range rl.users {
+				if !now.Before(ub.resetAt) {
+					delete(rl.users, k)
+				}
+			}
+		}
+		b = &bucket{}
+		rl.users[userToken] = b
+	}
+	return b
+}
+
+// wait takes a point from the bucket of userToken, waiting for it if needed.
+// Fails with ErrRateLimited without waiting if it would take longer than
+// maxWait or than the context deadline.
+func (rl *rateLimiter) wait(ctx context.Context, userToken string, reserve int, maxWait time.Duration) error {
+	for {
+		now := time.Now()
+		rl.mu.Lock()
+		d := rl.bucket(userToken, now).take(now, reserve)
+		rl.mu.Unlock()
+		if d <= 0 {
+			return nil
+		}
+		if d > maxWait {
+			return ErrRateLimited
+		}
+		if deadline, ok := ctx.Deadline(); ok && now.Add(d).After(deadline) {
+			return ErrRateLimited
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+			// other requests may have taken the refilled points, try again
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (rl *rateLimiter) observe(userToken string, h http.Header, respondedAt time.Time) {
+	now := time.Now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.bucket(userToken, now).observe(h, now, respondedAt)
+}
+
+func (rl *rateLimiter) budget(userToken string) RateLimitBudget {
+	now := time.Now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if userToken != "" {
+		b, ok := rl.users[userToken]
+		if !ok {
+			return RateLimitBudget{}
+		}
+		return b.budget(now)
+	}
+	return rl.app.budget(now)
+}
+
+// RateLimitBudget returns the estimated budget of the app token rate limit
+// bucket. Callers making many requests can use it to adjust their pace.
+func (hx *Helix) RateLimitBudget() RateLimitBudget {
+	return hx.rl.budget("")
+}
@@ -0,0 +1,225 @@
+package helix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeLimitServer enforces a Twitch like token bucket per Authorization
+// header, refilling `limit` points every `refill`
+type fakeLimitServer struct {
+	*httptest.Server
+
+	limit  float64
+	refill time.Duration
+
+	mu       sync.Mutex
+	buckets  map[string]*fakeBucket
+	requests int
+	limited  int
+}
+
+type fakeBucket struct {
+	tokens float64
+	at     time.Time
+}
+
+func newFakeLimitServer(limit int, refill time.Duration) *fakeLimitServer {
+	fs := &fakeLimitServer{
+		limit:   float64(limit),
+		refill:  refill,
+		buckets: make(map[string]*fakeBucket),
+	}
+	fs.Server = httptest.NewServer(http.HandlerFunc(fs.handle))
+	return fs
+}
+
+func (fs *fakeLimitServer) handle(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.requests++
+
+	now := time.Now()
+	rate := fs.limit / fs.refill.Seconds()
+	key := r.Header.Get("Authorization")
+	b, ok := fs.buckets[key]
+	if !ok {
+		b = &fakeBucket{tokens: fs.limit, at: now}
+		fs.buckets[key] = b
+	}
+	b.tokens = math.Min(fs.limit, b.tokens+rate*now.Sub(b.at).Seconds())
+	b.at = now
+
+	status := http.StatusOK
+	if b.tokens >= 1 {
+		b.tokens--
+	} else {
+		fs.limited++
+		status = http.StatusTooManyRequests
+	}
+	resetAt := now.Add(time.Duration((fs.limit - b.tokens) / rate * float64(time.Second)))
+	w.Header().Set(HeaderRatelimitLimit, fmt.Sprint(int(fs.limit)))
+	w.Header().Set(HeaderRatelimitRemaining, fmt.Sprint(int(b.tokens)))
+	// rounded up, as the reset is in seconds
+	w.Header().Set(HeaderRatelimitReset, fmt.Sprint(resetAt.Add(time.Second-1).Unix()))
+	w.WriteHeader(status)
+	w.Write([]byte(`{"data":[]}`))
+}
+
+func (fs *fakeLimitServer) stats() (requests, limited int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.requests, fs.limited
+}
+
+func doFakeRequest(hx *Helix, ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = hx.Do(req)
+	return err
+}
+
+func TestRateLimitGovernorAvoidsLimit(t *testing.T) {
+	t.Parallel()
+	sv := newFakeLimitServer(10, time.Second)
+	defer sv.Close()
+	hx := &Helix{
+		opts:          &HelixOpts{APIUrl: sv.URL},
+		defaultClient: sv.Client(),
+	}
+
+	if b := hx.RateLimitBudget(); b.Known {
+		t.Fatalf("expected unknown budget before any request, got %+v", b)
+	}
+	start := time.Now()
+	for i := 0; i < 25; i++ {
+		if err := doFakeRequest(hx, context.Background(), sv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requests, limited := sv.stats()
+	if limited != 0 {
+		t.Fatalf("expected no rate limited requests, got %d of %d", limited, requests)
+	}
+	// 10 points available at once and 15 more refilled at 10/s at most
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected requests to be delayed by the governor, took %s", elapsed)
+	}
+	b := hx.RateLimitBudget()
+	if !b.Known || b.Limit != 10 || b.Remaining < 0 || b.Remaining > 10 || b.ResetAt.Before(start) {
+		t.Fatalf("unexpected budget %+v", b)
+	}
+}
+
+func TestRateLimitGovernorRejects(t *testing.T) {
+	t.Parallel()
+	// it would take a minute to get a point back
+	sv := newFakeLimitServer(2, 2*time.Minute)
+	defer sv.Close()
+	hx := &Helix{
+		opts: &HelixOpts{
+			APIUrl:           sv.URL,
+			RateLimitMaxWait: 100 * time.Millisecond,
+		},
+		defaultClient: sv.Client(),
+	}
+	for i := 0; i < 2; i++ {
+		if err := doFakeRequest(hx, context.Background(), sv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	if err := doFakeRequest(hx, context.Background(), sv.URL); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("expected the request to be rejected right away, took %s", elapsed)
+	}
+	if requests, _ := sv.stats(); requests != 2 {
+		t.Fatalf("expected rejected request to not reach the server, got %d requests", requests)
+	}
+
+	// rejected too if the context expires before there is budget, even if
+	// the max wait is longer
+	hx.opts.RateLimitMaxWait = 5 * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := doFakeRequest(hx, ctx, sv.URL); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestRateLimitGovernorUserBuckets(t *testing.T) {
+	t.Parallel()
+	sv := newFakeLimitServer(2, 2*time.Minute)
+	defer sv.Close()
+	hx := NewWithUserTokens(&HelixOpts{
+		APIUrl:           sv.URL,
+		RateLimitMaxWait: -1,
+	})
+	userCtx := func(token string) context.Context {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		return context.WithValue(context.Background(), CtxHelixTokenSource, ts)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := doFakeRequest(hx, userCtx("user1"), sv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := doFakeRequest(hx, userCtx("user1"), sv.URL); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited for user1, got %v", err)
+	}
+	// other users have their own bucket
+	if err := doFakeRequest(hx, userCtx("user2"), sv.URL); err != nil {
+		t.Fatalf("expected user2 to have budget, got %v", err)
+	}
+	if b := hx.RateLimitBudget(); b.Known {
+		t.Fatalf("expected app budget to be unknown, got %+v", b)
+	}
+	if _, limited := sv.stats(); limited != 0 {
+		t.Fatalf("expected no rate limited requests, got %d", limited)
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2023, 6, 18, 10, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set(HeaderRatelimitLimit, "800")
+	h.Set(HeaderRatelimitRemaining, "0")
+	h.Set(HeaderRatelimitReset, fmt.Sprint(now.Add(time.Minute).Unix()))
+
+	var b bucket
+	if d := b.take(now, 0); d != 0 {
+		t.Fatalf("expected unknown bucket to not wait, got %s", d)
+	}
+	b.observe(h, now, now)
+	if d := b.take(now, 0); d <= 0 || d > time.Second {
+		t.Fatalf("expected to wait for a point to be refilled, got %s", d)
+	}
+	half := now.Add(30 * time.Second)
+	if got := b.budget(half).Remaining; got != 400 {
+		t.Fatalf("expected half the bucket to be refilled, got %d", got)
+	}
+	if d := b.take(half, 399); d != 0 {
+		t.Fatalf("expected a point over the reserve, got %s", d)
+	}
+	if d := b.take(half, 399); d <= 0 {
+		t.Fatal("expected to wait once the reserve is reached")
+	}
+	if got := b.budget(now.Add(2 * time.Minute)).Remaining; got != 800 {
+		t.Fatalf("expected the bucket to be full after the reset, got %d", got)
+	}
+}
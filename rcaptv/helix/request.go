@@ -0,0 +1,196 @@
+package helix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryTag is the struct tag of the param struct fields encoded as query
+// parameters, e.g.
+//
+//	type GamesParams struct {
+//		IDs   []string `query:"id"`
+//		Names []string `query:"name"`
+//	}
+//
+// Fields without the tag are not encoded and zero values are left out.
+// Supported types are strings, integers, bools, time.Time (RFC3339), slices
+// of those (one parameter per element) and fmt.Stringer types like
+// VideoPeriod.
+const QueryTag = "query"
+
+var ErrUnsupportedParam = errors.New("unsupported query parameter type")
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// RequestError is returned by the requests built with Get and Paginate, with
+// the endpoint context of the error
+type RequestError struct {
+	Method   string
+	Endpoint string
+	Err      error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.Endpoint, e.Err.Error())
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// EncodeParams encodes the fields of the params struct with the QueryTag as
+// query parameters. params can be nil, a struct or a pointer to a struct. The
+// fields of embedded structs are encoded too.
+func EncodeParams(params any) (url.Values, error) {
+	values := url.Values{}
+	if params == nil {
+		return values, nil
+	}
+	if err := encodeStruct(values, reflect.ValueOf(params)); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func encodeStruct(values url.Values, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %s is not a struct", ErrUnsupportedParam, v.Type())
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get(QueryTag), ",")
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			if err := encodeStruct(values, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		if fv.Kind() == reflect.Slice {
+			for j := 0; j < fv.Len(); j++ {
+				if err := addParam(values, name, fv.Index(j)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := addParam(values, name, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addParam(values url.Values, name string, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.IsZero() {
+		return nil
+	}
+	switch {
+	case v.Type() == timeType:
+		values.Add(name, v.Interface().(time.Time).Format(time.RFC3339))
+		return nil
+	case v.Type().Implements(stringerType):
+		values.Add(name, v.Interface().(fmt.Stringer).String())
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		values.Add(name, v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		values.Add(name, strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		values.Add(name, strconv.FormatUint(v.Uint(), 10))
+	case reflect.Bool:
+		values.Add(name, strconv.FormatBool(v.Bool()))
+	default:
+		return fmt.Errorf("%w: %s (%s)", ErrUnsupportedParam, name, v.Type())
+	}
+	return nil
+}
+
+// NewRequest builds a GET request to the endpoint of the API, e.g. "/clips",
+// with the params encoded by EncodeParams
+func (hx *Helix) NewRequest(ctx context.Context, endpoint string, params any) (*http.Request, error) {
+	return newRequest(ctx, http.MethodGet, hx.APIUrl()+endpoint, params)
+}
+
+func newRequest(ctx context.Context, method, rawURL string, params any) (*http.Request, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	values, err := EncodeParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) > 0 {
+		rawURL += "?" + values.Encode()
+	}
+	return http.NewRequestWithContext(ctx, method, rawURL, nil)
+}
+
+// Get requests a single page of the endpoint and returns its data
+func Get[T any](ctx context.Context, hx *Helix, endpoint string, params any) ([]T, error) {
+	req, err := hx.NewRequest(ctx, endpoint, params)
+	if err != nil {
+		return nil, &RequestError{http.MethodGet, endpoint, err}
+	}
+	resp, err := hx.Do(req)
+	if err != nil {
+		return nil, &RequestError{http.MethodGet, endpoint, err}
+	}
+	var parsed PaginationManyObj[T]
+	if err := json.Unmarshal(resp.Body, &parsed); err != nil {
+		return nil, &RequestError{http.MethodGet, endpoint, err}
+	}
+	return parsed.Data, nil
+}
+
+// Paginate requests the endpoint following the pagination, see
+// DoWithPagination for stopFunc and deduplicateKeyFn. A nil stopFunc reads
+// every page.
+func Paginate[T any](
+	ctx context.Context, hx *Helix, endpoint string, params any,
+	stopFunc func(item T, all []T) bool,
+	deduplicateKeyFn func(i T) string,
+) ([]T, error) {
+	req, err := hx.NewRequest(ctx, endpoint, params)
+	if err != nil {
+		return nil, &RequestError{http.MethodGet, endpoint, err}
+	}
+	if stopFunc == nil {
+		stopFunc = func(T, []T) bool { return false }
+	}
+	all, err := DoWithPagination(hx, req, stopFunc, deduplicateKeyFn)
+	if err != nil {
+		return nil, &RequestError{http.MethodGet, endpoint, err}
+	}
+	return all, nil
+}
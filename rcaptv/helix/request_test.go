@@ -0,0 +1,208 @@
+package helix
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestEncodeParams(t *testing.T) {
+	t.Parallel()
+	startedAt := time.Date(2023, 6, 20, 10, 0, 0, 0, time.UTC)
+	first := 5
+	type nested struct {
+		ID string `query:"id"`
+	}
+	tests := []struct {
+		name   string
+		params any
+		want   string
+		err    error
+	}{
+		{"nil", nil, "", nil},
+		{"nil pointer", (*ClipsParams)(nil), "", nil},
+		{"zero values left out", &ClipsParams{BroadcasterID: "58753574"}, "broadcaster_id=58753574", nil},
+		{
+			"times and ints",
+			ClipsParams{BroadcasterID: "58753574", StartedAt: startedAt, First: 100, StopViewsThreshold: 8},
+			"broadcaster_id=58753574&first=100&started_at=2023-06-20T10%3A00%3A00Z",
+			nil,
+		},
+		{"stringers", &VODParams{BroadcasterID: "58753574", Period: Week}, "period=week&user_id=58753574", nil},
+		{"slices", &StreamsParams{UserIDs: []string{"1", "2"}, First: 2}, "first=2&user_id=1&user_id=2", nil},
+		{
+			"pointers, bools and untagged fields",
+			struct {
+				First    *int   `query:"first"`
+				Last     *int   `query:"last"`
+				Live     bool   `query:"live"`
+				Skipped  string `query:"-"`
+				Untagged string
+			}{First: &first, Live: true, Skipped: "a", Untagged: "b"},
+			"first=5&live=true",
+			nil,
+		},
+		{
+			"embedded structs",
+			struct {
+				*nested
+				Type string `query:"type"`
+			}{&nested{"1"}, "archive"},
+			"id=1&type=archive",
+			nil,
+		},
+		{"not a struct", "id=1", "", ErrUnsupportedParam},
+		{
+			"unsupported field",
+			struct {
+				M map[string]string `query:"m"`
+			}{map[string]string{"a": "b"}},
+			"",
+			ErrUnsupportedParam,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := EncodeParams(tt.params)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if err == nil && got.Encode() != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got.Encode())
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+	var query url.Values
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		switch r.URL.Path {
+		case "/users":
+			w.Write([]byte(`{"data":[{"id":"58753574","login":"zeling"}]}`))
+		case "/empty":
+			w.Write([]byte(`{"data":[]}`))
+		case "/invalid":
+			w.Write([]byte(`{"data":`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer sv.Close()
+	hx := &Helix{
+		opts:          &HelixOpts{APIUrl: sv.URL},
+		defaultClient: sv.Client(),
+	}
+
+	tests := []struct {
+		endpoint string
+		params   any
+		want     int
+		err      error
+	}{
+		{"/users", &UserParams{Login: "zeling"}, 1, nil},
+		{"/empty", nil, 0, nil},
+		{"/missing", nil, 0, ErrNotFound},
+		{"/users", 1, 0, ErrUnsupportedParam},
+	}
+	for _, tt := range tests {
+		users, err := Get[User](context.Background(), hx, tt.endpoint, tt.params)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: expected error %v, got %v", tt.endpoint, tt.err, err)
+		}
+		if len(users) != tt.want {
+			t.Fatalf("%s: expected %d users, got %d", tt.endpoint, tt.want, len(users))
+		}
+		if err == nil {
+			continue
+		}
+		var reqErr *RequestError
+		if !errors.As(err, &reqErr) || reqErr.Endpoint != tt.endpoint || reqErr.Method != http.MethodGet {
+			t.Fatalf("%s: expected the endpoint in the error, got %v", tt.endpoint, err)
+		}
+	}
+	if query.Get("login") != "" {
+		t.Fatalf("expected the last request without params, got %v", query)
+	}
+
+	if _, err := Get[User](context.Background(), hx, "/invalid", nil); err == nil {
+		t.Fatal("expected an error decoding an invalid body")
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	t.Parallel()
+	pages := map[string]string{
+		"":   `{"data":[{"id":"a"},{"id":"b"}],"pagination":{"cursor":"c1"}}`,
+		"c1": `{"data":[{"id":"b"},{"id":"c"}],"pagination":{"cursor":"c2"}}`,
+		"c2": `{"data":[{"id":"d"}],"pagination":{}}`,
+	}
+	var reqs int
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs++
+		if r.URL.Query().Get("broadcaster_id") != "58753574" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(pages[r.URL.Query().Get("after")]))
+	}))
+	defer sv.Close()
+	hx := &Helix{
+		opts:          &HelixOpts{APIUrl: sv.URL},
+		defaultClient: sv.Client(),
+	}
+
+	id := func(c *Clip) string { return c.ClipID }
+	tests := []struct {
+		name   string
+		stop   func(*Clip, []*Clip) bool
+		dedup  func(*Clip) string
+		params *ClipsParams
+		want   int
+		reqs   int
+		err    error
+	}{
+		{"every page", nil, nil, &ClipsParams{BroadcasterID: "58753574"}, 5, 3, nil},
+		{"deduplicated", nil, id, &ClipsParams{BroadcasterID: "58753574"}, 4, 3, nil},
+		{
+			"stopped",
+			func(c *Clip, _ []*Clip) bool { return c.ClipID == "c" },
+			id,
+			&ClipsParams{BroadcasterID: "58753574"},
+			3,
+			2,
+			nil,
+		},
+		{"bad request", nil, nil, &ClipsParams{}, 0, 1, ErrBadRequest},
+	}
+	for _, tt := range tests {
+		reqs = 0
+		clips, err := Paginate(context.Background(), hx, "/clips", tt.params, tt.stop, tt.dedup)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: expected error %v, got %v", tt.name, tt.err, err)
+		}
+		if len(clips) != tt.want || reqs != tt.reqs {
+			t.Fatalf("%s: expected %d clips in %d requests, got %d in %d", tt.name, tt.want, tt.reqs, len(clips), reqs)
+		}
+	}
+}
+
+func TestVodsInvalidURL(t *testing.T) {
+	t.Parallel()
+	hx := &Helix{
+		opts:          &HelixOpts{APIUrl: "http://[::1"},
+		defaultClient: http.DefaultClient,
+	}
+	// the request is not built, it must fail instead of panicking
+	if _, err := hx.Vods(&VODParams{BroadcasterID: "58753574"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,159 @@
+package helix
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy controls how Do retries failed requests
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first one
+	MaxAttempts int
+	// BaseBackoff is the wait before the first retry, doubled on every retry up
+	// to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Jitter is the fraction of the backoff that is randomized, from 0 to 1. A
+	// jitter of 0.5 waits between half and the whole backoff
+	Jitter float64
+	// RetryableStatuses are the response status codes that are retried. 429
+	// responses wait until the rate limit reset instead of the backoff
+	RetryableStatuses []int
+	// RetryableError reports whether a request that failed without a response
+	// (e.g. a network error) is retried. Defaults to DefaultRetryableError
+	RetryableError func(err error) bool
+}
+
+// DefaultRetryPolicy is used when HelixOpts.RetryPolicy is nil
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	BaseBackoff: time.Second,
+	MaxBackoff:  30 * time.Second,
+	Jitter:      0.5,
+	RetryableStatuses: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// DefaultRetryableError retries any error except the ones caused by the
+// request context or the rate limit governor, which would fail again
+func DefaultRetryableError(err error) bool {
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrRateLimited) &&
+		!errors.Is(err, ErrInvalidContext)
+}
+
+func (p *RetryPolicy) retryableStatus(code int) bool {
+	for _, c := range p.RetryableStatuses {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryableError(err error) bool {
+	if p.RetryableError != nil {
+		return p.RetryableError(err)
+	}
+	return DefaultRetryableError(err)
+}
+
+// backoff returns the wait before the given retry, starting at 1
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := p.BaseBackoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d -= time.Duration(rand.Float64() * j * float64(d))
+	}
+	return d
+}
+
+// sleepCtx sleeps for d or until ctx is done, in which case it returns the
+// context error
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RequestStats are counters of the requests made by a Helix client
+type RequestStats struct {
+	// Attempts is the number of requests sent, including retries
+	Attempts int64
+	// Retries is the number of attempts that were retries of a failed one
+	Retries int64
+	// Failures is the number of attempts that failed, with or without a
+	// response
+	Failures int64
+}
+
+// requestCounters is the concurrent safe version of RequestStats. The zero
+// value is ready to use.
+type requestCounters struct {
+	attempts atomic.Int64
+	retries  atomic.Int64
+	failures atomic.Int64
+}
+
+const CtxHelixRequestCounter CtxHelixKey = "helix_request_counter"
+
+// ContextWithRequestCounter returns a context that counts in n the requests
+// sent with it, including retries. Useful to know the requests of a single
+// operation when the client is shared.
+func ContextWithRequestCounter(ctx context.Context, n *atomic.Int64) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, CtxHelixRequestCounter, n)
+}
+
+// attempt counts a request sent with ctx, see ContextWithRequestCounter
+func (c *requestCounters) attempt(ctx context.Context) {
+	c.attempts.Add(1)
+	if n, ok := ctx.Value(CtxHelixRequestCounter).(*atomic.Int64); ok && n != nil {
+		n.Add(1)
+	}
+}
+
+// RequestStats returns the counters of the requests made by the client
+func (hx *Helix) RequestStats() RequestStats {
+	return RequestStats{
+		Attempts: hx.counters.attempts.Load(),
+		Retries:  hx.counters.retries.Load(),
+		Failures: hx.counters.failures.Load(),
+	}
+}
+
+func (hx *Helix) retryPolicy() *RetryPolicy {
+	if hx.opts.RetryPolicy != nil {
+		return hx.opts.RetryPolicy
+	}
+	return DefaultRetryPolicy
+}
@@ -0,0 +1,194 @@
+package helix
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetries = &RetryPolicy{
+	MaxAttempts:       3,
+	BaseBackoff:       time.Millisecond,
+	MaxBackoff:        5 * time.Millisecond,
+	RetryableStatuses: DefaultRetryPolicy.RetryableStatuses,
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	t.Parallel()
+	for _, status := range []int{
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	} {
+		var reqs atomic.Int32
+		sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if reqs.Add(1) < 3 {
+				w.WriteHeader(status)
+				return
+			}
+			w.Write([]byte(`{"data":[]}`))
+		}))
+		hx := &Helix{
+			opts:          &HelixOpts{APIUrl: sv.URL, RetryPolicy: fastRetries},
+			defaultClient: sv.Client(),
+		}
+		req, _ := http.NewRequest(http.MethodGet, sv.URL, nil)
+		resp, err := hx.Do(req)
+		sv.Close()
+		if err != nil {
+			t.Fatalf("%d: %v", status, err)
+		}
+		if string(resp.Body) != `{"data":[]}` {
+			t.Fatalf("%d: expected the body of the successful attempt, got %q", status, resp.Body)
+		}
+		if got := hx.RequestStats(); got != (RequestStats{Attempts: 3, Retries: 2, Failures: 2}) {
+			t.Fatalf("%d: unexpected stats %+v", status, got)
+		}
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	t.Parallel()
+	var reqs atomic.Int32
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer sv.Close()
+	hx := &Helix{
+		opts:          &HelixOpts{APIUrl: sv.URL, RetryPolicy: fastRetries},
+		defaultClient: sv.Client(),
+	}
+	req, _ := http.NewRequest(http.MethodGet, sv.URL, nil)
+	_, err := hx.Do(req)
+	if !errors.Is(err, ErrTooManyRequestAttempts) {
+		t.Fatalf("expected ErrTooManyRequestAttempts, got %v", err)
+	}
+	if got := reqs.Load(); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	t.Parallel()
+	var reqs atomic.Int32
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer sv.Close()
+	hx := &Helix{
+		opts:          &HelixOpts{APIUrl: sv.URL, RetryPolicy: fastRetries},
+		defaultClient: sv.Client(),
+	}
+	req, _ := http.NewRequest(http.MethodGet, sv.URL, nil)
+	if _, err := hx.Do(req); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", err)
+	}
+	if got := reqs.Load(); got != 1 {
+		t.Fatalf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestRetryNetworkErrors(t *testing.T) {
+	t.Parallel()
+	var reqs atomic.Int32
+	var body []byte
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reqs.Add(1) == 1 {
+			// drop the connection without responding
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer sv.Close()
+	hx := &Helix{
+		opts:          &HelixOpts{APIUrl: sv.URL, RetryPolicy: fastRetries},
+		defaultClient: sv.Client(),
+	}
+	req, _ := http.NewRequest(http.MethodPost, sv.URL, bytes.NewReader([]byte(`{"type":"stream.online"}`)))
+	if _, err := hx.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if got := reqs.Load(); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+	if string(body) != `{"type":"stream.online"}` {
+		t.Fatalf("expected the body to be sent again, got %q", body)
+	}
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	t.Parallel()
+	var reqs atomic.Int32
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer sv.Close()
+	hx := &Helix{
+		opts: &HelixOpts{
+			APIUrl: sv.URL,
+			RetryPolicy: &RetryPolicy{
+				MaxAttempts:       3,
+				BaseBackoff:       time.Minute,
+				RetryableStatuses: []int{http.StatusServiceUnavailable},
+			},
+		},
+		defaultClient: sv.Client(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, sv.URL, nil)
+	start := time.Now()
+	if _, err := hx.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the backoff to be interrupted, took %s", elapsed)
+	}
+	if got := reqs.Load(); got != 1 {
+		t.Fatalf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+	p := &RetryPolicy{
+		BaseBackoff: time.Second,
+		MaxBackoff:  10 * time.Second,
+	}
+	for retry, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		20: 10 * time.Second,
+	} {
+		if got := p.backoff(retry); got != want {
+			t.Fatalf("retry %d: expected %s, got %s", retry, want, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(3); got < 2*time.Second || got > 4*time.Second {
+			t.Fatalf("expected backoff with jitter in [2s, 4s], got %s", got)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package helix
+
+import (
+	"sort"
+	"time"
+)
+
+// SplitStrategy decides how DeepClips splits the time window of an incomplete
+// clips response.
+type SplitStrategy interface {
+	// Split returns the sub-ranges of r to request next. clips are the ones
+	// of the incomplete response of r, sorted by views. The sub-ranges must be
+	// sorted and cover r without gaps. Returning less than 2 sub-ranges falls
+	// back to BisectSplit.
+	Split(r ClipsRange, clips []*Clip) []ClipsRange
+}
+
+// BisectSplit splits the window in two equal halves. It is the default
+// SplitStrategy of DeepClips.
+type BisectSplit struct{}
+
+func (BisectSplit) Split(r ClipsRange, _ []*Clip) []ClipsRange {
+	mid := r.From.Add(r.To.Sub(r.From) / 2)
+	return []ClipsRange{{r.From, mid}, {mid, r.To}}
+}
+
+// DensitySplit splits the window where the clips are, instead of in equal
+// halves. Clips are concentrated around the live hours of the broadcaster so
+// bisecting wastes requests on ranges without clips and still reaches
+// MaxDeepLvl on the dense ones.
+//
+// The split points are the quantiles of the created_at of the clips in the
+// incomplete response, so every sub-range is expected to have a similar number
+// of clips. If the response has too few clips, the quantiles of the known live
+// time in Lives are used instead and it falls back to bisecting without them.
+type DensitySplit struct {
+	// Parts is the number of sub-ranges of every split. Defaults to 2
+	Parts int
+	// MinWidth is the min duration of a sub-range. Defaults to a minute
+	MinWidth time.Duration
+	// Lives are the known live intervals of the broadcaster, e.g. from its
+	// VODs with VODRanges. Optional
+	Lives []ClipsRange
+}
+
+const (
+	defaultDensityMinWidth = time.Minute
+	// minDensityClipsPerPart is the number of clips of the response per
+	// sub-range needed to use their distribution
+	minDensityClipsPerPart = 4
+)
+
+func (s DensitySplit) Split(r ClipsRange, clips []*Clip) []ClipsRange {
+	parts := s.Parts
+	if parts < 2 {
+		parts = 2
+	}
+	minWidth := s.MinWidth
+	if minWidth <= 0 {
+		minWidth = defaultDensityMinWidth
+	}
+
+	var points []time.Time
+	if created := clipTimes(r, clips); len(created) >= parts*minDensityClipsPerPart {
+		points = sampleQuantiles(created, parts)
+	} else if lives := clampRanges(r, s.Lives); len(lives) > 0 {
+		points = liveQuantiles(lives, parts)
+	}
+	ranges := make([]ClipsRange, 0, parts)
+	from := r.From
+	for _, p := range points {
+		// the API only takes seconds
+		p = p.Truncate(time.Second)
+		if p.Sub(from) < minWidth || r.To.Sub(p) < minWidth {
+			continue
+		}
+		ranges = append(ranges, ClipsRange{from, p})
+		from = p
+	}
+	if len(ranges) == 0 {
+		return BisectSplit{}.Split(r, clips)
+	}
+	return append(ranges, ClipsRange{from, r.To})
+}
+
+// VODRanges returns the live intervals of the VODs, to be used as
+// DensitySplit.Lives
+func VODRanges(vods []*VOD) []ClipsRange {
+	ranges := make([]ClipsRange, 0, len(vods))
+	for _, v := range vods {
+		d, err := v.DurationSeconds()
+		if err != nil || d <= 0 {
+			continue
+		}
+		ranges = append(ranges, ClipsRange{
+			From: v.CreatedAt,
+			To:   v.CreatedAt.Add(time.Duration(d) * time.Second),
+		})
+	}
+	return ranges
+}
+
+// clipTimes returns the sorted creation times of the clips inside r
+func clipTimes(r ClipsRange, clips []*Clip) []time.Time {
+	times := make([]time.Time, 0, len(clips))
+	for _, c := range clips {
+		t, err := time.Parse(time.RFC3339, c.CreatedAt)
+		if err != nil || t.Before(r.From) || !t.Before(r.To) {
+			continue
+		}
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+	return times
+}
+
+// sampleQuantiles returns the parts-1 split points of the sorted times, each
+// one in the middle of the gap between the samples around the quantile
+func sampleQuantiles(times []time.Time, parts int) []time.Time {
+	points := make([]time.Time, 0, parts-1)
+	for k := 1; k < parts; k++ {
+		i := k * len(times) / parts
+		points = append(points, times[i-1].Add(times[i].Sub(times[i-1])/2))
+	}
+	return points
+}
+
+// clampRanges returns the sorted ranges intersecting r, clamped to it
+func clampRanges(r ClipsRange, ranges []ClipsRange) []ClipsRange {
+	clamped := make([]ClipsRange, 0, len(ranges))
+	for _, lr := range ranges {
+		if lr.From.Before(r.From) {
+			lr.From = r.From
+		}
+		if lr.To.After(r.To) {
+			lr.To = r.To
+		}
+		if lr.To.After(lr.From) {
+			clamped = append(clamped, lr)
+		}
+	}
+	sort.Slice(clamped, func(i, j int) bool {
+		return clamped[i].From.Before(clamped[j].From)
+	})
+	return clamped
+}
+
+// liveQuantiles returns the parts-1 split points that divide the live time
+// of the sorted ranges in equal parts
+func liveQuantiles(lives []ClipsRange, parts int) []time.Time {
+	var total time.Duration
+	for _, lr := range lives {
+		total += lr.To.Sub(lr.From)
+	}
+	points := make([]time.Time, 0, parts-1)
+	k := 1
+	var acc time.Duration
+	for _, lr := range lives {
+		d := lr.To.Sub(lr.From)
+		for k < parts && acc+d >= total*time.Duration(k)/time.Duration(parts) {
+			points = append(points, lr.From.Add(total*time.Duration(k)/time.Duration(parts)-acc))
+			k++
+		}
+		acc += d
+	}
+	return points
+}
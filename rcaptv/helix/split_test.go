@@ -0,0 +1,96 @@
+package helix
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2023, 6, 4, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	clipsAt := func(ds ...time.Duration) []*Clip {
+		clips := make([]*Clip, 0, len(ds))
+		for _, d := range ds {
+			clips = append(clips, &Clip{CreatedAt: at(d).Format(time.RFC3339)})
+		}
+		return clips
+	}
+	week := ClipsRange{start, at(168 * time.Hour)}
+	dense := []time.Duration{10 * time.Hour, 10 * time.Hour, 10 * time.Hour, 10 * time.Hour}
+	for i := 0; i < 12; i++ {
+		dense = append(dense, 100*time.Hour+time.Duration(i)*5*time.Minute)
+	}
+
+	tests := []struct {
+		name     string
+		strategy SplitStrategy
+		clips    []*Clip
+		want     []time.Time
+	}{
+		{"bisect", BisectSplit{}, clipsAt(dense...), []time.Time{at(84 * time.Hour)}},
+		{"density", DensitySplit{}, clipsAt(dense...), []time.Time{at(100*time.Hour + 17*time.Minute + 30*time.Second)}},
+		{
+			"density in 4 parts",
+			DensitySplit{Parts: 4},
+			clipsAt(dense...),
+			[]time.Time{
+				at(55 * time.Hour),
+				at(100*time.Hour + 17*time.Minute + 30*time.Second),
+				at(100*time.Hour + 37*time.Minute + 30*time.Second),
+			},
+		},
+		{
+			"density with few clips uses the lives",
+			DensitySplit{Lives: []ClipsRange{
+				{at(-2 * time.Hour), at(2 * time.Hour)},
+				{at(20 * time.Hour), at(24 * time.Hour)},
+				{at(200 * time.Hour), at(204 * time.Hour)},
+			}},
+			clipsAt(time.Hour),
+			[]time.Time{at(21 * time.Hour)},
+		},
+		{"density without clips nor lives bisects", DensitySplit{}, nil, []time.Time{at(84 * time.Hour)}},
+		{
+			"density below min width bisects",
+			DensitySplit{MinWidth: time.Hour},
+			clipsAt(time.Second, time.Second, time.Second, time.Second, time.Minute, time.Minute, time.Minute, time.Minute),
+			[]time.Time{at(84 * time.Hour)},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ranges := tt.strategy.Split(week, tt.clips)
+			if len(ranges) != len(tt.want)+1 {
+				t.Fatalf("expected %d ranges, got %+v", len(tt.want)+1, ranges)
+			}
+			// the ranges must cover the window without gaps
+			if !ranges[0].From.Equal(week.From) || !ranges[len(ranges)-1].To.Equal(week.To) {
+				t.Fatalf("ranges don't cover the window: %+v", ranges)
+			}
+			for i, p := range tt.want {
+				if !ranges[i].To.Equal(p) || !ranges[i+1].From.Equal(p) {
+					t.Fatalf("expected split point %d at %s, got %+v", i, p, ranges)
+				}
+			}
+		})
+	}
+}
+
+func TestVODRanges(t *testing.T) {
+	t.Parallel()
+	created := time.Date(2023, 6, 4, 18, 0, 0, 0, time.UTC)
+	ranges := VODRanges([]*VOD{
+		{VideoID: "1", CreatedAt: created, DurationString: "3h30m"},
+		{VideoID: "2", CreatedAt: created, DurationString: "invalid"},
+		{VideoID: "3", CreatedAt: created, Duration: 60},
+	})
+	if len(ranges) != 2 {
+		t.Fatalf("expected 2 ranges, got %+v", ranges)
+	}
+	if !ranges[0].To.Equal(created.Add(210*time.Minute)) || !ranges[1].To.Equal(created.Add(time.Minute)) {
+		t.Fatalf("unexpected ranges %+v", ranges)
+	}
+}
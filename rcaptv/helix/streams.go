@@ -0,0 +1,45 @@
+package helix
+
+import (
+	"context"
+	"time"
+)
+
+type StreamsParams struct {
+	UserIDs    []string `query:"user_id"`
+	UserLogins []string `query:"user_login"`
+	GameIDs    []string `query:"game_id"`
+	Lang       []string `query:"language"`
+	// Type is "live" or "all"
+	Type  string `query:"type"`
+	First int    `query:"first"`
+	After string `query:"after"`
+
+	Context context.Context
+}
+
+type Stream struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	UserLogin    string    `json:"user_login"`
+	UserName     string    `json:"user_name"`
+	GameID       string    `json:"game_id"`
+	GameName     string    `json:"game_name"`
+	Type         string    `json:"type"`
+	Title        string    `json:"title"`
+	ViewerCount  int       `json:"viewer_count"`
+	StartedAt    time.Time `json:"started_at"`
+	Lang         string    `json:"language"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+// Streams returns the live streams matching the params, following the
+// pagination. Returns ErrItemsEmpty if there are none.
+func (hx *Helix) Streams(p *StreamsParams) ([]*Stream, error) {
+	if p.First == 0 {
+		p.First = 100
+	}
+	return Paginate(p.Context, hx, "/streams", p, nil, func(s *Stream) string {
+		return s.ID
+	})
+}
@@ -2,15 +2,11 @@ package helix
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"net/url"
 )
 
 type UserParams struct {
-	UserID string
-	Login  string
+	UserID string `query:"id"`
+	Login  string `query:"login"`
 
 	Context context.Context
 }
@@ -34,34 +30,9 @@ type UserResponse struct {
 }
 
 func (hx *Helix) User(p *UserParams) (*UserResponse, error) {
-	params := url.Values{}
-
-	if p.UserID != "" {
-		params.Add("id", p.UserID)
-	}
-	if p.Login != "" {
-		params.Add("login", p.Login)
-	}
-	if p.Context == nil {
-		p.Context = context.Background()
-	}
-	req, err := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s/users?%s", hx.APIUrl(), params.Encode()),
-		nil,
-	)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(p.Context)
-
-	resp, err := hx.Do(req)
+	users, err := Get[User](p.Context, hx, "/users", p)
 	if err != nil {
 		return nil, err
 	}
-	var usr *UserResponse
-	if err := json.Unmarshal(resp.Body, &usr); err != nil {
-		return nil, err
-	}
-	return usr, nil
+	return &UserResponse{Data: users}, nil
 }
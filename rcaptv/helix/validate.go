@@ -24,17 +24,13 @@ type ValidTokenParams struct {
 // ValidToken checks whether the provided access token is valid with the
 // Twitch API
 func (hx *Helix) ValidToken(p ValidTokenParams) bool {
-	if p.Context == nil {
-		p.Context = context.Background()
-	}
-	req, err := http.NewRequest("GET", hx.ValidateEndpoint(), nil)
-	if err != nil {
-		return false
-	}
 	ctx := ContextWithCustomQueryOpts(p.Context, &CustomQueryOpts{
 		UseClientID: false,
 	})
-	req = req.WithContext(ctx)
+	req, err := newRequest(ctx, http.MethodGet, hx.ValidateEndpoint(), nil)
+	if err != nil {
+		return false
+	}
 	req.Header.Set("Authorization", fmt.Sprintf("OAuth %s", p.AccessToken))
 
 	resp, err := hx.Do(req)
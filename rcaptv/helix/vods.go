@@ -2,10 +2,6 @@ package helix
 
 import (
 	"context"
-	"fmt"
-	"net/http"
-	"net/url"
-	"strconv"
 	"time"
 )
 
@@ -24,12 +20,12 @@ func (p VideoPeriod) String() string {
 }
 
 type VODParams struct {
-	BroadcasterID  string
-	GameID         string
-	Lang           string
-	Period         VideoPeriod
-	After          string
-	First          int
+	BroadcasterID  string      `query:"user_id"`
+	GameID         string      `query:"game_id"`
+	Lang           string      `query:"language"`
+	Period         VideoPeriod `query:"period"`
+	After          string      `query:"after"`
+	First          int         `query:"first"`
 	StopAtVODID    string
 	OnlyMostRecent bool
 
@@ -79,23 +75,6 @@ func ParseVODDurations(vods []*VOD) error {
 // If p.StopAtVODID is empty, it will fetch and return only the most recent
 // VOD.
 func (hx *Helix) Vods(p *VODParams) ([]*VOD, error) {
-	params := url.Values{}
-
-	if p.BroadcasterID != "" {
-		params.Add("user_id", p.BroadcasterID)
-	}
-	if p.GameID != "" {
-		params.Add("game_id", p.GameID)
-	}
-	if p.Lang != "" {
-		params.Add("language", p.Lang)
-	}
-	if p.Period != 0 {
-		params.Add("period", p.Period.String())
-	}
-	if p.After != "" {
-		params.Add("after", p.After)
-	}
 	if p.First == 0 {
 		p.First = 100
 	}
@@ -109,21 +88,6 @@ func (hx *Helix) Vods(p *VODParams) ([]*VOD, error) {
 			return true
 		}
 	}
-	params.Add("first", strconv.Itoa(p.First))
-	params.Add("type", "archive")
-
-	if p.Context == nil {
-		p.Context = context.Background()
-	}
-	req, err := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s/videos?%s", hx.APIUrl(), params.Encode()),
-		nil,
-	)
-	req = req.WithContext(p.Context)
-	if err != nil {
-		return nil, err
-	}
 	var dedupFn func(v *VOD) string
 	if !p.SkipDeduplication {
 		dedupFn = func(v *VOD) string {
@@ -131,7 +95,12 @@ func (hx *Helix) Vods(p *VODParams) ([]*VOD, error) {
 		}
 	}
 
-	vods, err := DoWithPagination[*VOD](hx, req, pagFunc, dedupFn)
+	// only VODs, not highlights or uploads
+	params := struct {
+		*VODParams
+		Type string `query:"type"`
+	}{p, "archive"}
+	vods, err := Paginate(p.Context, hx, "/videos", params, pagFunc, dedupFn)
 	if err != nil {
 		return nil, err
 	}
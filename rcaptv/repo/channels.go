@@ -1,9 +1,14 @@
 package repo
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"strings"
+	"time"
 
 	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
 
 	"pedro.to/rcaptv/gen/tracker/public/model"
 	tbl "pedro.to/rcaptv/gen/tracker/public/table"
@@ -19,4 +24,200 @@ func Tracked(db *sql.DB) (r []*model.TrackedChannels, err error) {
 		return nil, err
 	}
 	return r, nil
-}
\ No newline at end of file
+}
+
+type ChannelByUsernameParams struct {
+	// Username of the channel, case insensitive
+	Username string
+
+	Context context.Context
+}
+
+// ChannelByUsername returns the tracked channel with the given username.
+// Returns ErrNotFound if the channel is not tracked.
+func ChannelByUsername(db *sql.DB, p *ChannelByUsernameParams) (*model.TrackedChannels, error) {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	stmt := SELECT(
+		tbl.TrackedChannels.AllColumns,
+	).FROM(tbl.TrackedChannels).
+		WHERE(tbl.TrackedChannels.BcUsername.EQ(String(strings.ToLower(p.Username))))
+
+	var r model.TrackedChannels
+	if err := stmt.QueryContext(p.Context, db, &r); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+// SetChannelUpdated sets last_updated_at of a tracked channel to now. Called
+// after storing new VODs or clips of the channel.
+func SetChannelUpdated(db *sql.DB, bid string) error {
+	stmt := tbl.TrackedChannels.UPDATE(tbl.TrackedChannels.LastUpdatedAt).
+		SET(TimestampExp(NOW())).
+		WHERE(tbl.TrackedChannels.BcID.EQ(String(bid)))
+	res, err := stmt.Exec(db)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	return ErrNoRowsAffected
+}
+
+type ChannelSort string
+
+const (
+	SortUsername     ChannelSort = "username"
+	SortTrackedSince ChannelSort = "tracked_since"
+	SortVodCount     ChannelSort = "vod_count"
+	SortClipCount    ChannelSort = "clip_count"
+	SortLastVod      ChannelSort = "last_vod_at"
+)
+
+// ValidChannelSort reports whether s is a sort supported by Channels
+func ValidChannelSort(s ChannelSort) bool {
+	switch s {
+	case SortUsername, SortTrackedSince, SortVodCount, SortClipCount, SortLastVod:
+		return true
+	}
+	return false
+}
+
+const MaxChannelsPageSize = 100
+
+type ChannelsParams struct {
+	// Search filters channels whose username starts with Search. Search is case
+	// insensitive.
+	Search string
+	Sort   ChannelSort
+	Desc   bool
+	// First is the page size. Capped to MaxChannelsPageSize
+	First  int
+	Offset int
+
+	Context context.Context
+}
+
+// Channel is a tracked channel with some stats about the VODs and clips we
+// store for it.
+type Channel struct {
+	BroadcasterID     string     `json:"bc_id" alias:"tracked_channels.bc_id"`
+	Username          string     `json:"username" alias:"tracked_channels.bc_username"`
+	DisplayName       string     `json:"display_name" alias:"tracked_channels.bc_display_name"`
+	BcType            string     `json:"bc_type" alias:"tracked_channels.bc_type"`
+	ProfilePictureURL *string    `json:"profile_picture_url" alias:"tracked_channels.pp_url"`
+	TrackedSince      *time.Time `json:"tracked_since" alias:"tracked_channels.tracked_since"`
+	VodCount          int        `json:"vod_count" alias:"channel.vod_count"`
+	ClipCount         int        `json:"clip_count" alias:"channel.clip_count"`
+	LastVodAt         *time.Time `json:"last_vod_at" alias:"channel.last_vod_at"`
+}
+
+// Channels returns the directory of tracked channels with their VOD count,
+// clip count and last VOD date.
+//
+// Results are sorted by p.Sort (username by default) and ties are broken by
+// broadcaster id so pagination with p.Offset is stable.
+func Channels(db *sql.DB, p *ChannelsParams) ([]*Channel, error) {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	if p.First <= 0 || p.First > MaxChannelsPageSize {
+		p.First = MaxChannelsPageSize
+	}
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	if p.Sort == "" {
+		p.Sort = SortUsername
+	}
+
+	vodStats := SELECT(
+		tbl.Vods.BcID,
+		COUNT(tbl.Vods.VideoID).AS("vod_count"),
+		MAX(tbl.Vods.CreatedAt).AS("last_vod_at"),
+	).FROM(tbl.Vods).
+		GROUP_BY(tbl.Vods.BcID).
+		AsTable("vod_stats")
+	clipStats := SELECT(
+		tbl.Clips.BcID,
+		COUNT(tbl.Clips.ClipID).AS("clip_count"),
+	).FROM(tbl.Clips).
+		GROUP_BY(tbl.Clips.BcID).
+		AsTable("clip_stats")
+
+	var (
+		vodBcID   = tbl.Vods.BcID.From(vodStats)
+		vodCount  = IntegerColumn("vod_count").From(vodStats)
+		lastVodAt = TimestampColumn("last_vod_at").From(vodStats)
+		clipBcID  = tbl.Clips.BcID.From(clipStats)
+		clipCount = IntegerColumn("clip_count").From(clipStats)
+	)
+
+	stmt := SELECT(
+		tbl.TrackedChannels.BcID,
+		tbl.TrackedChannels.BcUsername,
+		tbl.TrackedChannels.BcDisplayName,
+		tbl.TrackedChannels.BcType,
+		tbl.TrackedChannels.PpURL,
+		tbl.TrackedChannels.TrackedSince,
+		COALESCE(vodCount, Int(0)).AS("channel.vod_count"),
+		COALESCE(clipCount, Int(0)).AS("channel.clip_count"),
+		lastVodAt.AS("channel.last_vod_at"),
+	).FROM(
+		tbl.TrackedChannels.
+			LEFT_JOIN(vodStats, vodBcID.EQ(tbl.TrackedChannels.BcID)).
+			LEFT_JOIN(clipStats, clipBcID.EQ(tbl.TrackedChannels.BcID)),
+	)
+
+	if p.Search != "" {
+		stmt = stmt.WHERE(
+			tbl.TrackedChannels.BcUsername.LIKE(String(escapeLike(strings.ToLower(p.Search)) + "%")),
+		)
+	}
+
+	var sortBy Expression
+	switch p.Sort {
+	case SortTrackedSince:
+		sortBy = tbl.TrackedChannels.TrackedSince
+	case SortVodCount:
+		sortBy = COALESCE(vodCount, Int(0))
+	case SortClipCount:
+		sortBy = COALESCE(clipCount, Int(0))
+	case SortLastVod:
+		sortBy = lastVodAt
+	default:
+		sortBy = tbl.TrackedChannels.BcUsername
+	}
+	order := sortBy.ASC()
+	if p.Desc {
+		order = sortBy.DESC()
+	}
+	// channels without a value for the sorted column (e.g. no VODs yet) are
+	// always listed last
+	stmt = stmt.ORDER_BY(sortBy.IS_NULL().ASC(), order, tbl.TrackedChannels.BcID.ASC()).
+		LIMIT(int64(p.First)).
+		OFFSET(int64(p.Offset))
+
+	r := make([]*Channel, 0, p.First)
+	if err := stmt.QueryContext(p.Context, db, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it can be used as a literal
+// prefix
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
@@ -1,7 +1,9 @@
 package repo
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"pedro.to/rcaptv/gen/tracker/public/model"
 )
@@ -31,3 +33,99 @@ func TestTrackedChannels(t *testing.T) {
 		}
 	}
 }
+
+func TestChannels(t *testing.T) {
+	rows, err := Channels(db, &ChannelsParams{
+		Search: "ILLO",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected exactly 1 channel, got %d", len(rows))
+	}
+	got := rows[0]
+	if got.BroadcasterID != "90075649" || got.DisplayName != "IlloJuan" {
+		t.Fatalf("unexpected channel %s (%s)", got.BroadcasterID, got.DisplayName)
+	}
+	if got.VodCount != 4 {
+		t.Fatalf("expected 4 vods, got %d", got.VodCount)
+	}
+	if got.LastVodAt == nil || got.LastVodAt.Format(time.RFC3339) != "2023-06-16T15:36:48Z" {
+		t.Fatalf("unexpected last vod date %v", got.LastVodAt)
+	}
+
+	// wildcards are matched literally
+	rows, err = Channels(db, &ChannelsParams{
+		Search: "%",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("expected no channels, got %d", len(rows))
+	}
+}
+
+func TestChannelsSortAndPaginate(t *testing.T) {
+	want := []string{"zeling", "illojuan"}
+	for i, w := range want {
+		rows, err := Channels(db, &ChannelsParams{
+			Sort:   SortUsername,
+			Desc:   true,
+			First:  1,
+			Offset: i,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("expected exactly 1 channel, got %d", len(rows))
+		}
+		if rows[0].Username != w {
+			t.Fatalf("unexpected channel at offset %d, got %s, want %s", i, rows[0].Username, w)
+		}
+	}
+
+	rows, err := Channels(db, &ChannelsParams{
+		Sort:   SortLastVod,
+		Desc:   true,
+		Offset: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("expected empty page, got %d channels", len(rows))
+	}
+}
+
+func TestChannelByUsername(t *testing.T) {
+	ch, err := ChannelByUsername(db, &ChannelByUsernameParams{Username: "ZeLiNg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.BcID != "58753574" {
+		t.Fatalf("unexpected channel %s", ch.BcID)
+	}
+	if _, err := ChannelByUsername(db, &ChannelByUsernameParams{Username: "unknown"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSetChannelUpdated(t *testing.T) {
+	before := time.Now().Add(-time.Minute)
+	if err := SetChannelUpdated(db, "58753574"); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := ChannelByUsername(db, &ChannelByUsernameParams{Username: "zeling"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.LastUpdatedAt == nil || ch.LastUpdatedAt.Before(before) {
+		t.Fatalf("expected last_updated_at to be updated, got %v", ch.LastUpdatedAt)
+	}
+	if err := SetChannelUpdated(db, "1234"); !errors.Is(err, ErrNoRowsAffected) {
+		t.Fatalf("expected ErrNoRowsAffected, got %v", err)
+	}
+}
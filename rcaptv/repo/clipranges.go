@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	tbl "pedro.to/rcaptv/gen/tracker/public/table"
+)
+
+// AddIncompleteClipRanges stores the ranges of clips that were not fetched
+// completely, to retry them later. BcID, StartedAt, EndedAt, Depth and Retries
+// are used. Ranges already stored are updated with the new depth and retries.
+func AddIncompleteClipRanges(db *sql.DB, ctx context.Context, ranges []*model.IncompleteClipRanges) error {
+	if len(ranges) == 0 {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	stmt := tbl.IncompleteClipRanges.INSERT(
+		tbl.IncompleteClipRanges.BcID, tbl.IncompleteClipRanges.StartedAt,
+		tbl.IncompleteClipRanges.EndedAt, tbl.IncompleteClipRanges.Depth,
+		tbl.IncompleteClipRanges.Retries,
+	)
+	for _, r := range ranges {
+		stmt = stmt.VALUES(r.BcID, r.StartedAt.UTC(), r.EndedAt.UTC(), r.Depth, r.Retries)
+	}
+	stmt = stmt.ON_CONFLICT(
+		tbl.IncompleteClipRanges.BcID, tbl.IncompleteClipRanges.StartedAt, tbl.IncompleteClipRanges.EndedAt,
+	).DO_UPDATE(
+		SET(
+			tbl.IncompleteClipRanges.Depth.SET(tbl.IncompleteClipRanges.EXCLUDED.Depth),
+			tbl.IncompleteClipRanges.Retries.SET(tbl.IncompleteClipRanges.EXCLUDED.Retries),
+		))
+	_, err := stmt.ExecContext(ctx, db)
+	return err
+}
+
+type IncompleteClipRangesParams struct {
+	BroadcasterID string
+	// StartedAt and EndedAt return only the ranges overlapping them if not
+	// zero
+	StartedAt time.Time
+	EndedAt   time.Time
+
+	Context context.Context
+}
+
+// IncompleteClipRanges returns the stored incomplete ranges of a channel
+// sorted by StartedAt
+func IncompleteClipRanges(db *sql.DB, p *IncompleteClipRangesParams) ([]*model.IncompleteClipRanges, error) {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	if p.BroadcasterID == "" {
+		return nil, errors.New("empty broadcaster id")
+	}
+	where := tbl.IncompleteClipRanges.BcID.EQ(String(p.BroadcasterID))
+	if !p.StartedAt.IsZero() {
+		where = where.AND(tbl.IncompleteClipRanges.EndedAt.GT(TimestampT(p.StartedAt.UTC())))
+	}
+	if !p.EndedAt.IsZero() {
+		where = where.AND(tbl.IncompleteClipRanges.StartedAt.LT(TimestampT(p.EndedAt.UTC())))
+	}
+	stmt := SELECT(
+		tbl.IncompleteClipRanges.AllColumns,
+	).FROM(tbl.IncompleteClipRanges).
+		WHERE(where).
+		ORDER_BY(tbl.IncompleteClipRanges.StartedAt.ASC())
+
+	var r []*model.IncompleteClipRanges
+	if err := stmt.QueryContext(p.Context, db, &r); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// DeleteIncompleteClipRanges deletes the given ranges by ID, e.g. once they
+// are retried
+func DeleteIncompleteClipRanges(db *sql.DB, ctx context.Context, rangeIDs ...int64) error {
+	if len(rangeIDs) == 0 {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ids := make([]Expression, 0, len(rangeIDs))
+	for _, id := range rangeIDs {
+		ids = append(ids, Int(id))
+	}
+	stmt := tbl.IncompleteClipRanges.DELETE().WHERE(tbl.IncompleteClipRanges.RangeID.IN(ids...))
+	_, err := stmt.ExecContext(ctx, db)
+	return err
+}
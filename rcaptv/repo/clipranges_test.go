@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+)
+
+func TestIncompleteClipRanges(t *testing.T) {
+	bid := "58753574"
+	start := time.Date(2023, 6, 4, 0, 0, 0, 0, time.UTC)
+	ranges := []*model.IncompleteClipRanges{
+		{BcID: bid, StartedAt: start.Add(84 * time.Hour), EndedAt: start.Add(168 * time.Hour), Depth: 2},
+		{BcID: bid, StartedAt: start, EndedAt: start.Add(42 * time.Hour), Depth: 3},
+	}
+	if err := AddIncompleteClipRanges(db, context.Background(), ranges); err != nil {
+		t.Fatal(err)
+	}
+	// the same range again updates it
+	ranges[1].Retries = 1
+	if err := AddIncompleteClipRanges(db, context.Background(), ranges[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := IncompleteClipRanges(db, &IncompleteClipRangesParams{BroadcasterID: bid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("expected 2 ranges, got %d", len(stored))
+	}
+	if !stored[0].StartedAt.Equal(start) || stored[0].Retries != 1 || stored[0].Depth != 3 {
+		t.Fatalf("unexpected first range %+v", stored[0])
+	}
+
+	overlapping, err := IncompleteClipRanges(db, &IncompleteClipRangesParams{
+		BroadcasterID: bid,
+		StartedAt:     start.Add(48 * time.Hour),
+		EndedAt:       start.Add(96 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overlapping) != 1 || overlapping[0].RangeID != stored[1].RangeID {
+		t.Fatalf("expected only the second range, got %+v", overlapping)
+	}
+
+	if err := DeleteIncompleteClipRanges(db, context.Background(), stored[0].RangeID, stored[1].RangeID); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = IncompleteClipRanges(db, &IncompleteClipRangesParams{BroadcasterID: bid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 0 {
+		t.Fatalf("expected the ranges to be deleted, got %+v", stored)
+	}
+}
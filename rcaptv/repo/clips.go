@@ -8,6 +8,7 @@ import (
 
 	. "github.com/go-jet/jet/v2/postgres"
 
+	"pedro.to/rcaptv/gen/tracker/public/model"
 	tbl "pedro.to/rcaptv/gen/tracker/public/table"
 	"pedro.to/rcaptv/helix"
 )
@@ -60,6 +61,43 @@ func Clips(db *sql.DB, p *ClipsParams) (r []*helix.Clip, err error) {
 	return r, nil
 }
 
+type LatestClipsParams struct {
+	BroadcasterID string
+	// MinViews excludes clips with less views
+	MinViews int
+	// First is the number of clips returned. Defaults to 20
+	First int
+
+	Context context.Context
+}
+
+// LatestClips returns the most recently created clips of a broadcaster with
+// at least p.MinViews views.
+func LatestClips(db *sql.DB, p *LatestClipsParams) (r []*model.Clips, err error) {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	if p.First <= 0 {
+		p.First = 20
+	}
+	stmt := SELECT(
+		tbl.Clips.AllColumns,
+	).FROM(tbl.Clips).
+		WHERE(
+			tbl.Clips.BcID.EQ(String(p.BroadcasterID)).
+				AND(tbl.Clips.ViewCount.GT_EQ(Int(int64(p.MinViews)))),
+		).
+		ORDER_BY(tbl.Clips.CreatedAt.DESC(), tbl.Clips.ClipID.ASC()).
+		LIMIT(int64(p.First))
+
+	if err = stmt.QueryContext(p.Context, db, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// UpsertClips inserts the new clips and updates the stored ones. Returns
+// ErrNoRowsAffected if every clip was already stored without changes
 func UpsertClips(db *sql.DB, clips []*helix.Clip) error {
 	stmt := tbl.Clips.INSERT(
 		tbl.Clips.ClipID, tbl.Clips.BcID, tbl.Clips.VideoID, tbl.Clips.CreatedAt, tbl.Clips.CreatorID,
@@ -73,16 +111,20 @@ func UpsertClips(db *sql.DB, clips []*helix.Clip) error {
 			c.DurationSeconds, c.ViewCount, c.VODOffsetSeconds,
 		)
 	}
+	videoID := StringExp(COALESCE(NULLIF(tbl.Clips.EXCLUDED.VideoID, String("")), tbl.Clips.VideoID))
+	vodOffset := IntExp(COALESCE(tbl.Clips.EXCLUDED.VodOffset, tbl.Clips.VodOffset))
 	stmt.ON_CONFLICT(tbl.Clips.ClipID).DO_UPDATE(
 		SET(
 			tbl.Clips.Title.SET(tbl.Clips.EXCLUDED.Title),
 			tbl.Clips.ViewCount.SET(tbl.Clips.EXCLUDED.ViewCount),
-			tbl.Clips.VideoID.SET(
-				StringExp(COALESCE(NULLIF(tbl.Clips.EXCLUDED.VideoID, String("")), tbl.Clips.VideoID)),
-			),
-			tbl.Clips.VodOffset.SET(
-				IntExp(COALESCE(tbl.Clips.EXCLUDED.VodOffset, tbl.Clips.VodOffset)),
-			),
+			tbl.Clips.VideoID.SET(videoID),
+			tbl.Clips.VodOffset.SET(vodOffset),
+		).WHERE(
+			// unchanged clips are not counted as affected
+			tbl.Clips.Title.IS_DISTINCT_FROM(tbl.Clips.EXCLUDED.Title).
+				OR(tbl.Clips.ViewCount.IS_DISTINCT_FROM(tbl.Clips.EXCLUDED.ViewCount)).
+				OR(videoID.IS_DISTINCT_FROM(tbl.Clips.VideoID)).
+				OR(vodOffset.IS_DISTINCT_FROM(tbl.Clips.VodOffset)),
 		))
 	res, err := stmt.Exec(db)
 	if err != nil {
@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -46,6 +47,9 @@ func TestClipsUpsert(t *testing.T) {
 	if err := UpsertClips(db, clips); err != nil {
 		t.Fatal(err)
 	}
+	if err := UpsertClips(db, clips); !errors.Is(err, ErrNoRowsAffected) {
+		t.Fatalf("expected no rows affected upserting the same clips, got %v", err)
+	}
 	got, err := Clips(db, nil)
 	if err != nil {
 		t.Fatal(err)
@@ -295,3 +299,44 @@ func TestClipsSelect(t *testing.T) {
 		}
 	}
 }
+
+func TestLatestClips(t *testing.T) {
+	defer cleanupClips()
+
+	clip := func(id string, views int, at string) *helix.Clip {
+		return &helix.Clip{
+			ClipID:        id,
+			BroadcasterID: "58753574",
+			CreatedAt:     at,
+			CreatorID:     "creator1",
+			CreatorName:   "John Doe",
+			Title:         id,
+			Lang:          "es",
+			ThumbnailURL:  "https://example.com/" + id + ".jpg",
+			ViewCount:     views,
+		}
+	}
+	clips := []*helix.Clip{
+		clip("clip1", 100, "2023-01-01T10:00:00Z"),
+		clip("clip2", 5, "2023-01-02T10:00:00Z"),
+		clip("clip3", 250, "2023-01-03T10:00:00Z"),
+		clip("clip4", 10, "2023-01-04T10:00:00Z"),
+	}
+	if err := UpsertClips(db, clips); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LatestClips(db, &LatestClipsParams{
+		BroadcasterID: "58753574",
+		MinViews:      10,
+		First:         2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 clips, got %d", len(got))
+	}
+	if got[0].ClipID != "clip4" || got[1].ClipID != "clip3" {
+		t.Fatalf("unexpected clips %s, %s", got[0].ClipID, got[1].ClipID)
+	}
+}
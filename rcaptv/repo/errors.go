@@ -2,4 +2,8 @@ package repo
 
 import "errors"
 
-var ErrNoRowsAffected = errors.New("no rows affected")
+var (
+	ErrNoRowsAffected = errors.New("no rows affected")
+	ErrNotFound       = errors.New("not found")
+	ErrUnknownChannel = errors.New("unknown channel")
+)
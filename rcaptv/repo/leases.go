@@ -0,0 +1,171 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	tbl "pedro.to/rcaptv/gen/tracker/public/table"
+)
+
+// ScheduleLeases is a scheduler.Coordinator of a worker of a distributed
+// schedule. Workers keep a lease in schedule_workers renewed with every
+// heartbeat and claim the runs of the minutes of the cycle in schedule_runs,
+// whose primary key guarantees that only one of them runs every slot.
+type ScheduleLeases struct {
+	db       *sql.DB
+	schedule string
+	id       string
+	ttl      time.Duration
+}
+
+// NewScheduleLeases returns the coordinator of the worker id of the
+// schedule. A worker is dead if its last heartbeat is older than ttl, which
+// must be a few times the frequency of the schedule
+func NewScheduleLeases(db *sql.DB, schedule, id string, ttl time.Duration) *ScheduleLeases {
+	return &ScheduleLeases{
+		db:       db,
+		schedule: schedule,
+		id:       id,
+		ttl:      ttl,
+	}
+}
+
+func (sl *ScheduleLeases) ID() string {
+	return sl.id
+}
+
+// liveWorkers returns the statement selecting the IDs of the live workers
+func (sl *ScheduleLeases) liveWorkers() SelectStatement {
+	return SELECT(
+		tbl.ScheduleWorkers.WorkerID,
+	).FROM(tbl.ScheduleWorkers).
+		WHERE(
+			tbl.ScheduleWorkers.ScheduleName.EQ(String(sl.schedule)).
+				AND(tbl.ScheduleWorkers.HeartbeatAt.GT(TimestampExp(NOW()).SUB(INTERVALd(sl.ttl)))),
+		)
+}
+
+func (sl *ScheduleLeases) Heartbeat(ctx context.Context) ([]string, error) {
+	stmt := tbl.ScheduleWorkers.INSERT(
+		tbl.ScheduleWorkers.ScheduleName, tbl.ScheduleWorkers.WorkerID, tbl.ScheduleWorkers.HeartbeatAt,
+	).VALUES(
+		String(sl.schedule), String(sl.id), NOW(),
+	).ON_CONFLICT(
+		tbl.ScheduleWorkers.ScheduleName, tbl.ScheduleWorkers.WorkerID,
+	).DO_UPDATE(SET(
+		tbl.ScheduleWorkers.HeartbeatAt.SET(tbl.ScheduleWorkers.EXCLUDED.HeartbeatAt),
+	))
+	if _, err := stmt.ExecContext(ctx, sl.db); err != nil {
+		return nil, err
+	}
+
+	var workers []*model.ScheduleWorkers
+	live := sl.liveWorkers().ORDER_BY(tbl.ScheduleWorkers.WorkerID.ASC())
+	if err := live.QueryContext(ctx, sl.db, &workers); err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return nil, err
+	}
+	ids := make([]string, 0, len(workers))
+	for _, w := range workers {
+		ids = append(ids, w.WorkerID)
+	}
+	return ids, nil
+}
+
+func (sl *ScheduleLeases) Claim(ctx context.Context, slot int64) (bool, error) {
+	stmt := tbl.ScheduleRuns.INSERT(
+		tbl.ScheduleRuns.ScheduleName, tbl.ScheduleRuns.Slot, tbl.ScheduleRuns.WorkerID,
+	).VALUES(
+		String(sl.schedule), Int(slot), String(sl.id),
+	).ON_CONFLICT(
+		tbl.ScheduleRuns.ScheduleName, tbl.ScheduleRuns.Slot,
+	).DO_NOTHING()
+	res, err := stmt.ExecContext(ctx, sl.db)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// run returns the condition matching the run of the slot claimed by the
+// worker
+func (sl *ScheduleLeases) run(slot int64) BoolExpression {
+	return tbl.ScheduleRuns.ScheduleName.EQ(String(sl.schedule)).
+		AND(tbl.ScheduleRuns.Slot.EQ(Int(slot))).
+		AND(tbl.ScheduleRuns.WorkerID.EQ(String(sl.id)))
+}
+
+func (sl *ScheduleLeases) Release(ctx context.Context, slot int64) error {
+	stmt := tbl.ScheduleRuns.UPDATE(tbl.ScheduleRuns.WorkerID).
+		SET(NULL).
+		WHERE(sl.run(slot))
+	_, err := stmt.ExecContext(ctx, sl.db)
+	return err
+}
+
+func (sl *ScheduleLeases) Finish(ctx context.Context, slot int64) error {
+	stmt := tbl.ScheduleRuns.UPDATE(tbl.ScheduleRuns.Finished).
+		SET(Bool(true)).
+		WHERE(sl.run(slot))
+	_, err := stmt.ExecContext(ctx, sl.db)
+	return err
+}
+
+func (sl *ScheduleLeases) TakeOver(ctx context.Context, since int64) ([]int64, error) {
+	del := tbl.ScheduleRuns.DELETE().WHERE(
+		tbl.ScheduleRuns.ScheduleName.EQ(String(sl.schedule)).
+			AND(tbl.ScheduleRuns.Slot.LT(Int(since))),
+	)
+	if _, err := del.ExecContext(ctx, sl.db); err != nil {
+		return nil, err
+	}
+
+	// concurrent updates of the same run wait for the first one and then
+	// re-check the condition, which no longer matches since the run is
+	// claimed by a live worker
+	stmt := tbl.ScheduleRuns.UPDATE(tbl.ScheduleRuns.WorkerID).
+		SET(String(sl.id)).
+		WHERE(
+			tbl.ScheduleRuns.ScheduleName.EQ(String(sl.schedule)).
+				AND(tbl.ScheduleRuns.Slot.GT_EQ(Int(since))).
+				AND(tbl.ScheduleRuns.Finished.IS_FALSE()).
+				AND(tbl.ScheduleRuns.WorkerID.IS_NULL().
+					OR(tbl.ScheduleRuns.WorkerID.NOT_IN(sl.liveWorkers()))),
+		).
+		RETURNING(tbl.ScheduleRuns.Slot)
+
+	var runs []*model.ScheduleRuns
+	if err := stmt.QueryContext(ctx, sl.db, &runs); err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return nil, err
+	}
+	slots := make([]int64, 0, len(runs))
+	for _, r := range runs {
+		slots = append(slots, r.Slot)
+	}
+	return slots, nil
+}
+
+func (sl *ScheduleLeases) Leave(ctx context.Context) error {
+	del := tbl.ScheduleWorkers.DELETE().WHERE(
+		tbl.ScheduleWorkers.ScheduleName.EQ(String(sl.schedule)).
+			AND(tbl.ScheduleWorkers.WorkerID.EQ(String(sl.id))),
+	)
+	if _, err := del.ExecContext(ctx, sl.db); err != nil {
+		return err
+	}
+	release := tbl.ScheduleRuns.UPDATE(tbl.ScheduleRuns.WorkerID).
+		SET(NULL).
+		WHERE(
+			tbl.ScheduleRuns.ScheduleName.EQ(String(sl.schedule)).
+				AND(tbl.ScheduleRuns.WorkerID.EQ(String(sl.id))).
+				AND(tbl.ScheduleRuns.Finished.IS_FALSE()),
+		)
+	_, err := release.ExecContext(ctx, sl.db)
+	return err
+}
@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/scheduler"
+)
+
+func TestScheduleLeases(t *testing.T) {
+	ctx := context.Background()
+	ttl := time.Second
+	a := NewScheduleLeases(db, "test_leases", "a", ttl)
+	b := NewScheduleLeases(db, "test_leases", "b", ttl)
+	for _, w := range []*ScheduleLeases{a, b} {
+		if _, err := w.Heartbeat(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	live, err := a.Heartbeat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(live) != "[a b]" {
+		t.Fatalf("expected workers [a b], got %v", live)
+	}
+
+	// only one worker claims a slot
+	if ok, err := a.Claim(ctx, 100); err != nil || !ok {
+		t.Fatalf("expected a to claim the slot, got %t (err:%v)", ok, err)
+	}
+	if ok, err := b.Claim(ctx, 100); err != nil || ok {
+		t.Fatalf("expected b to not claim the slot, got %t (err:%v)", ok, err)
+	}
+	if ok, _ := a.Claim(ctx, 101); !ok {
+		t.Fatal("expected a to claim the slot")
+	}
+	if err := a.Finish(ctx, 101); err != nil {
+		t.Fatal(err)
+	}
+
+	// a is alive, nothing to take over
+	slots, err := b.TakeOver(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 0 {
+		t.Fatalf("expected no slots, got %v", slots)
+	}
+
+	// a dies while running 100
+	time.Sleep(ttl + 100*time.Millisecond)
+	if live, _ := b.Heartbeat(ctx); fmt.Sprint(live) != "[b]" {
+		t.Fatalf("expected workers [b], got %v", live)
+	}
+	slots, err = b.TakeOver(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(slots) != "[100]" {
+		t.Fatalf("expected to take over [100], got %v", slots)
+	}
+
+	// released runs are taken over and old ones are forgotten
+	if err := b.Release(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	if slots, _ := b.TakeOver(ctx, 101); len(slots) != 0 {
+		t.Fatalf("expected no slots after 101, got %v", slots)
+	}
+	if ok, _ := b.Claim(ctx, 100); !ok {
+		t.Fatal("expected to claim a forgotten slot")
+	}
+	if err := b.Leave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if slots, _ := a.TakeOver(ctx, 0); fmt.Sprint(slots) != "[100]" {
+		t.Fatalf("expected to take over the run released when leaving, got %v", slots)
+	}
+}
+
+// TestDistributedSchedule runs several workers of a distributed schedule
+// against the test database. A worker dies in the middle and its minutes must
+// be run by the others.
+func TestDistributedSchedule(t *testing.T) {
+	var (
+		cycleSize    uint = 6
+		pickInterval      = 250 * time.Millisecond
+		keys              = []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	)
+	type run struct {
+		worker string
+		min    scheduler.Minute
+	}
+	var (
+		mu   sync.Mutex
+		runs = make(map[int64][]run)
+		wg   sync.WaitGroup
+	)
+	ids := []string{"w1", "w2", "w3"}
+	schedules := make([]*scheduler.BalancedSchedule, 0, len(ids))
+	stops := make([]chan struct{}, 0, len(ids))
+	for _, id := range ids {
+		bs := scheduler.New(scheduler.BalancedScheduleOpts{
+			CycleSize:        cycleSize,
+			EstimatedObjects: cycleSize,
+			BalanceStrategy:  scheduler.StrategyMurmur(uint32(cycleSize)),
+			Freq:             pickInterval,
+			Coordinator:      NewScheduleLeases(db, "test_distributed", id, 3*pickInterval),
+		})
+		for _, k := range keys {
+			bs.Add(k)
+		}
+		stop := make(chan struct{})
+		schedules, stops = append(schedules, bs), append(stops, stop)
+		bs.Start()
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				case m := <-bs.RealTime():
+					mu.Lock()
+					slot := m.At.UnixNano() / int64(pickInterval)
+					runs[slot] = append(runs[slot], run{id, m.Min})
+					mu.Unlock()
+					m.Done()
+				}
+			}
+		}(id)
+	}
+	first := time.Now().UnixNano()/int64(pickInterval) + 1
+
+	time.Sleep(time.Duration(cycleSize) * pickInterval)
+	// w2 leaves
+	close(stops[1])
+	schedules[1].Stop()
+	left := time.Now().UnixNano() / int64(pickInterval)
+	time.Sleep(2 * time.Duration(cycleSize) * pickInterval)
+
+	last := time.Now().UnixNano()/int64(pickInterval) - 2
+	for _, i := range []int{0, 2} {
+		close(stops[i])
+		schedules[i].Stop()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for slot := first; slot <= last; slot++ {
+		if len(runs[slot]) != 1 {
+			t.Fatalf("expected slot %d to run exactly once, got %+v", slot, runs[slot])
+		}
+		if runs[slot][0].min != scheduler.Minute(uint64(slot)%uint64(cycleSize)) {
+			t.Fatalf("unexpected minute of slot %d: %+v", slot, runs[slot])
+		}
+		if slot > left && runs[slot][0].worker == "w2" {
+			t.Fatalf("slot %d run by a worker that left", slot)
+		}
+	}
+}
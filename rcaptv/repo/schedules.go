@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	tbl "pedro.to/rcaptv/gen/tracker/public/table"
+	"pedro.to/rcaptv/scheduler"
+)
+
+// Names of the stored schedules
+const (
+	TrackerSchedule   = "tracker"
+	ValidatorSchedule = "token_validator"
+)
+
+// ScheduleState returns the stored cursor and keys of the schedule. The
+// cursor is nil if the schedule was never saved
+func ScheduleState(db *sql.DB, ctx context.Context, name string) (*model.ScheduleCursors, []*model.ScheduleKeys, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cursorStmt := SELECT(
+		tbl.ScheduleCursors.AllColumns,
+	).FROM(tbl.ScheduleCursors).
+		WHERE(tbl.ScheduleCursors.ScheduleName.EQ(String(name)))
+
+	var cursor model.ScheduleCursors
+	if err := cursorStmt.QueryContext(ctx, db, &cursor); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	keysStmt := SELECT(
+		tbl.ScheduleKeys.AllColumns,
+	).FROM(tbl.ScheduleKeys).
+		WHERE(tbl.ScheduleKeys.ScheduleName.EQ(String(name)))
+
+	var keys []*model.ScheduleKeys
+	if err := keysStmt.QueryContext(ctx, db, &keys); err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return nil, nil, err
+	}
+	return &cursor, keys, nil
+}
+
+type ScheduledKeyParams struct {
+	Schedule string
+	Key      string
+
+	Context context.Context
+}
+
+// ScheduledKey is the minute of the cycle of a key of a stored schedule
+type ScheduledKey struct {
+	Minute    int32
+	CycleSize int32
+}
+
+// ScheduledKeyMinute returns the stored minute of the key and the cycle size
+// of its schedule. Nil if the key or the schedule are not stored
+func ScheduledKeyMinute(db *sql.DB, p *ScheduledKeyParams) (*ScheduledKey, error) {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	stmt := SELECT(
+		tbl.ScheduleKeys.Minute.AS("scheduled_key.minute"),
+		tbl.ScheduleCursors.CycleSize.AS("scheduled_key.cycle_size"),
+	).FROM(
+		tbl.ScheduleKeys.INNER_JOIN(
+			tbl.ScheduleCursors,
+			tbl.ScheduleCursors.ScheduleName.EQ(tbl.ScheduleKeys.ScheduleName),
+		),
+	).WHERE(
+		tbl.ScheduleKeys.ScheduleName.EQ(String(p.Schedule)).
+			AND(tbl.ScheduleKeys.Key.EQ(String(p.Key))),
+	)
+
+	var k ScheduledKey
+	if err := stmt.QueryContext(p.Context, db, &k); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// SaveScheduleState upserts the cursor and the added keys of the schedule
+// and deletes the removed ones in a single transaction
+func SaveScheduleState(db *sql.DB, ctx context.Context, cursor *model.ScheduleCursors, added []*model.ScheduleKeys, removed []string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cursorStmt := tbl.ScheduleCursors.INSERT(
+		tbl.ScheduleCursors.AllColumns,
+	).MODEL(cursor).
+		ON_CONFLICT(tbl.ScheduleCursors.ScheduleName).
+		DO_UPDATE(SET(
+			tbl.ScheduleCursors.Minute.SET(tbl.ScheduleCursors.EXCLUDED.Minute),
+			tbl.ScheduleCursors.CycleSize.SET(tbl.ScheduleCursors.EXCLUDED.CycleSize),
+			tbl.ScheduleCursors.PickedAt.SET(tbl.ScheduleCursors.EXCLUDED.PickedAt),
+		))
+	if _, err := cursorStmt.ExecContext(ctx, tx); err != nil {
+		return err
+	}
+
+	if len(added) > 0 {
+		addStmt := tbl.ScheduleKeys.INSERT(
+			tbl.ScheduleKeys.AllColumns,
+		).MODELS(added).
+			ON_CONFLICT(tbl.ScheduleKeys.ScheduleName, tbl.ScheduleKeys.Key).
+			DO_UPDATE(SET(
+				tbl.ScheduleKeys.Minute.SET(tbl.ScheduleKeys.EXCLUDED.Minute),
+			))
+		if _, err := addStmt.ExecContext(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 {
+		keys := make([]Expression, 0, len(removed))
+		for _, k := range removed {
+			keys = append(keys, String(k))
+		}
+		removeStmt := tbl.ScheduleKeys.DELETE().WHERE(
+			tbl.ScheduleKeys.ScheduleName.EQ(String(cursor.ScheduleName)).
+				AND(tbl.ScheduleKeys.Key.IN(keys...)),
+		)
+		if _, err := removeStmt.ExecContext(ctx, tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ScheduleStore is a scheduler.Store that persists the state of a schedule
+// in Postgres
+type ScheduleStore struct {
+	db   *sql.DB
+	name string
+}
+
+// NewScheduleStore returns the store of the schedule name, e.g. "tracker"
+func NewScheduleStore(db *sql.DB, name string) *ScheduleStore {
+	return &ScheduleStore{db: db, name: name}
+}
+
+func (s *ScheduleStore) Load(ctx context.Context) (*scheduler.State, error) {
+	cursor, keys, err := ScheduleState(s.db, ctx, s.name)
+	if err != nil || cursor == nil {
+		return nil, err
+	}
+	state := &scheduler.State{
+		Cursor: scheduler.Cursor{
+			Min:       scheduler.Minute(cursor.Minute),
+			At:        cursor.PickedAt,
+			CycleSize: uint(cursor.CycleSize),
+		},
+		KeyToMin: make(scheduler.KeyToMinuteMap, len(keys)),
+	}
+	for _, k := range keys {
+		state.KeyToMin[k.Key] = scheduler.Minute(k.Minute)
+	}
+	return state, nil
+}
+
+func (s *ScheduleStore) Save(ctx context.Context, c scheduler.Cursor, added scheduler.KeyToMinuteMap, removed []string) error {
+	keys := make([]*model.ScheduleKeys, 0, len(added))
+	for k, m := range added {
+		keys = append(keys, &model.ScheduleKeys{
+			ScheduleName: s.name,
+			Key:          k,
+			Minute:       int32(m),
+		})
+	}
+	return SaveScheduleState(s.db, ctx, &model.ScheduleCursors{
+		ScheduleName: s.name,
+		Minute:       int32(c.Min),
+		CycleSize:    int32(c.CycleSize),
+		PickedAt:     c.At.UTC(),
+	}, keys, removed)
+}
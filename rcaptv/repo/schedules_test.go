@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/scheduler"
+)
+
+func TestScheduleStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewScheduleStore(db, "test_schedule")
+	state, err := store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != nil {
+		t.Fatalf("expected no state, got %+v", state)
+	}
+
+	at := time.Date(2023, 6, 4, 12, 30, 0, 0, time.UTC)
+	cursor := scheduler.Cursor{Min: 3, At: at, CycleSize: 720}
+	if err := store.Save(ctx, cursor, scheduler.KeyToMinuteMap{"a": 1, "b": 2, "c": 3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	cursor.Min = 4
+	if err := store.Save(ctx, cursor, scheduler.KeyToMinuteMap{"a": 5}, []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Cursor.Min != 4 || state.Cursor.CycleSize != 720 || !state.Cursor.At.Equal(at) {
+		t.Fatalf("unexpected cursor %+v", state.Cursor)
+	}
+	if len(state.KeyToMin) != 2 || state.KeyToMin["a"] != 5 || state.KeyToMin["c"] != 3 {
+		t.Fatalf("unexpected keys %+v", state.KeyToMin)
+	}
+
+	// schedules don't share keys
+	other, err := NewScheduleStore(db, "other_schedule").Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != nil {
+		t.Fatalf("expected no state for other schedule, got %+v", other)
+	}
+}
@@ -119,6 +119,8 @@ func vodsByStreamer(db *sql.DB, ctx context.Context, p *VodsParams) (r []*helix.
 	return r, nil
 }
 
+// UpsertVods inserts the new VODs and updates the stored ones. Returns
+// ErrNoRowsAffected if every VOD was already stored without changes
 func UpsertVods(db *sql.DB, vods []*helix.VOD) error {
 	stmt := tbl.Vods.INSERT(
 		tbl.Vods.VideoID, tbl.Vods.BcID, tbl.Vods.StreamID, tbl.Vods.CreatedAt,
@@ -139,6 +141,13 @@ func UpsertVods(db *sql.DB, vods []*helix.VOD) error {
 			tbl.Vods.ThumbnailURL.SET(tbl.Vods.EXCLUDED.ThumbnailURL),
 			tbl.Vods.Title.SET(tbl.Vods.EXCLUDED.Title),
 			tbl.Vods.ViewCount.SET(tbl.Vods.EXCLUDED.ViewCount),
+		).WHERE(
+			// unchanged VODs are not counted as affected
+			tbl.Vods.PublishedAt.IS_DISTINCT_FROM(tbl.Vods.EXCLUDED.PublishedAt).
+				OR(tbl.Vods.DurationSeconds.IS_DISTINCT_FROM(tbl.Vods.EXCLUDED.DurationSeconds)).
+				OR(tbl.Vods.ThumbnailURL.IS_DISTINCT_FROM(tbl.Vods.EXCLUDED.ThumbnailURL)).
+				OR(tbl.Vods.Title.IS_DISTINCT_FROM(tbl.Vods.EXCLUDED.Title)).
+				OR(tbl.Vods.ViewCount.IS_DISTINCT_FROM(tbl.Vods.EXCLUDED.ViewCount)),
 		))
 	res, err := stmt.Exec(db)
 	if err != nil {
@@ -0,0 +1,257 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	. "github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/lib/pq"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	tbl "pedro.to/rcaptv/gen/tracker/public/table"
+)
+
+// CreateWebhookEndpoint stores a new webhook endpoint. UserID, URL and Secret
+// are required, filters are optional. Returns the stored endpoint with its ID
+// and defaults. Returns ErrUnknownChannel if the bc_id filter is not a tracked
+// channel.
+func CreateWebhookEndpoint(db *sql.DB, ep *model.WebhookEndpoints) (*model.WebhookEndpoints, error) {
+	stmt := tbl.WebhookEndpoints.INSERT(
+		tbl.WebhookEndpoints.UserID, tbl.WebhookEndpoints.URL, tbl.WebhookEndpoints.Secret,
+		tbl.WebhookEndpoints.BcID, tbl.WebhookEndpoints.EventType, tbl.WebhookEndpoints.MinViews,
+	).VALUES(
+		ep.UserID, ep.URL, ep.Secret, ep.BcID, ep.EventType, ep.MinViews,
+	).RETURNING(tbl.WebhookEndpoints.AllColumns)
+
+	var r model.WebhookEndpoints
+	if err := stmt.Query(db, &r); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" && pqErr.Constraint == "webhook_endpoints_bc_id_fkey" {
+			return nil, ErrUnknownChannel
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+type WebhookEndpointsParams struct {
+	// UserID returns only the endpoints of the user if not 0
+	UserID int64
+	// EndpointID returns only the given endpoint if not 0
+	EndpointID int64
+	// BroadcasterID returns only enabled endpoints that may be interested on
+	// events of the broadcaster, that is, with the same bc_id or without one.
+	BroadcasterID string
+
+	Context context.Context
+}
+
+// WebhookEndpoints returns the endpoints matching p sorted by creation
+func WebhookEndpoints(db *sql.DB, p *WebhookEndpointsParams) ([]*model.WebhookEndpoints, error) {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	where := Bool(true)
+	if p.UserID != 0 {
+		where = where.AND(tbl.WebhookEndpoints.UserID.EQ(Int(p.UserID)))
+	}
+	if p.EndpointID != 0 {
+		where = where.AND(tbl.WebhookEndpoints.EndpointID.EQ(Int(p.EndpointID)))
+	}
+	if p.BroadcasterID != "" {
+		where = where.AND(
+			tbl.WebhookEndpoints.Enabled.IS_TRUE().AND(
+				tbl.WebhookEndpoints.BcID.IS_NULL().
+					OR(tbl.WebhookEndpoints.BcID.EQ(String(p.BroadcasterID))),
+			),
+		)
+	}
+	stmt := SELECT(
+		tbl.WebhookEndpoints.AllColumns,
+	).FROM(tbl.WebhookEndpoints).
+		WHERE(where).
+		ORDER_BY(tbl.WebhookEndpoints.EndpointID.ASC())
+
+	var r []*model.WebhookEndpoints
+	if err := stmt.QueryContext(p.Context, db, &r); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// DeleteWebhookEndpoint deletes an endpoint of a user along with its delivery
+// log. Returns ErrNoRowsAffected if the user has no such endpoint.
+func DeleteWebhookEndpoint(db *sql.DB, userID, endpointID int64) error {
+	stmt := tbl.WebhookEndpoints.DELETE().WHERE(
+		tbl.WebhookEndpoints.EndpointID.EQ(Int(endpointID)).
+			AND(tbl.WebhookEndpoints.UserID.EQ(Int(userID))),
+	)
+	return expectAffected(stmt.Exec(db))
+}
+
+// EnableWebhookEndpoint enables again an endpoint of a user that was disabled
+// after too many failures. Returns ErrNoRowsAffected if the user has no such
+// endpoint.
+func EnableWebhookEndpoint(db *sql.DB, userID, endpointID int64) error {
+	stmt := tbl.WebhookEndpoints.UPDATE(
+		tbl.WebhookEndpoints.Enabled,
+		tbl.WebhookEndpoints.ConsecutiveFailures,
+		tbl.WebhookEndpoints.DisabledAt,
+	).SET(
+		Bool(true), Int(0), NULL,
+	).WHERE(
+		tbl.WebhookEndpoints.EndpointID.EQ(Int(endpointID)).
+			AND(tbl.WebhookEndpoints.UserID.EQ(Int(userID))),
+	)
+	return expectAffected(stmt.Exec(db))
+}
+
+// WebhookEndpointSucceeded resets the consecutive failures of an endpoint
+func WebhookEndpointSucceeded(db *sql.DB, endpointID int64) error {
+	stmt := tbl.WebhookEndpoints.UPDATE(
+		tbl.WebhookEndpoints.ConsecutiveFailures,
+	).SET(
+		Int(0),
+	).WHERE(
+		tbl.WebhookEndpoints.EndpointID.EQ(Int(endpointID)).
+			AND(tbl.WebhookEndpoints.ConsecutiveFailures.GT(Int(0))),
+	)
+	_, err := stmt.Exec(db)
+	return err
+}
+
+// WebhookEndpointFailed increments the consecutive failures of an endpoint and
+// disables it once they reach maxFailures. Reports whether the endpoint was
+// disabled by this call.
+func WebhookEndpointFailed(db *sql.DB, endpointID int64, maxFailures int) (bool, error) {
+	failures := tbl.WebhookEndpoints.ConsecutiveFailures.ADD(Int(1))
+	stmt := tbl.WebhookEndpoints.UPDATE(
+		tbl.WebhookEndpoints.ConsecutiveFailures,
+	).SET(
+		failures,
+	).WHERE(
+		tbl.WebhookEndpoints.EndpointID.EQ(Int(endpointID)),
+	).RETURNING(tbl.WebhookEndpoints.ConsecutiveFailures)
+
+	var r model.WebhookEndpoints
+	if err := stmt.Query(db, &r); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return false, ErrNoRowsAffected
+		}
+		return false, err
+	}
+	if int(r.ConsecutiveFailures) < maxFailures {
+		return false, nil
+	}
+	disable := tbl.WebhookEndpoints.UPDATE(
+		tbl.WebhookEndpoints.Enabled,
+		tbl.WebhookEndpoints.DisabledAt,
+	).SET(
+		Bool(false), TimestampExp(NOW()),
+	).WHERE(
+		tbl.WebhookEndpoints.EndpointID.EQ(Int(endpointID)).
+			AND(tbl.WebhookEndpoints.Enabled.IS_TRUE()),
+	)
+	res, err := disable.Exec(db)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// InsertWebhookDelivery logs a delivery attempt
+func InsertWebhookDelivery(db *sql.DB, d *model.WebhookDeliveries) error {
+	stmt := tbl.WebhookDeliveries.INSERT(
+		tbl.WebhookDeliveries.EndpointID, tbl.WebhookDeliveries.EventID,
+		tbl.WebhookDeliveries.EventType, tbl.WebhookDeliveries.Attempt,
+		tbl.WebhookDeliveries.Success, tbl.WebhookDeliveries.StatusCode,
+		tbl.WebhookDeliveries.Error, tbl.WebhookDeliveries.DurationMs,
+	).VALUES(
+		d.EndpointID, d.EventID, d.EventType, d.Attempt, d.Success,
+		d.StatusCode, d.Error, d.DurationMs,
+	)
+	_, err := stmt.Exec(db)
+	return err
+}
+
+type WebhookDeliveriesParams struct {
+	EndpointID int64
+	// First is the number of deliveries returned. Defaults to 50
+	First int
+
+	Context context.Context
+}
+
+// WebhookDeliveries returns the most recent delivery attempts of an endpoint
+func WebhookDeliveries(db *sql.DB, p *WebhookDeliveriesParams) ([]*model.WebhookDeliveries, error) {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	if p.First <= 0 {
+		p.First = 50
+	}
+	stmt := SELECT(
+		tbl.WebhookDeliveries.AllColumns,
+	).FROM(tbl.WebhookDeliveries).
+		WHERE(tbl.WebhookDeliveries.EndpointID.EQ(Int(p.EndpointID))).
+		ORDER_BY(tbl.WebhookDeliveries.DeliveryID.DESC()).
+		LIMIT(int64(p.First))
+
+	var r []*model.WebhookDeliveries
+	if err := stmt.QueryContext(p.Context, db, &r); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// ClipViews returns the stored view count of the given clips by clip ID.
+// Clips not stored yet are not in the map.
+func ClipViews(db *sql.DB, ctx context.Context, clipIDs []string) (map[string]int, error) {
+	r := make(map[string]int, len(clipIDs))
+	if len(clipIDs) == 0 {
+		return r, nil
+	}
+	ids := make([]Expression, 0, len(clipIDs))
+	for _, id := range clipIDs {
+		ids = append(ids, String(id))
+	}
+	stmt := SELECT(
+		tbl.Clips.ClipID, tbl.Clips.ViewCount,
+	).FROM(tbl.Clips).
+		WHERE(tbl.Clips.ClipID.IN(ids...))
+
+	var rows []*model.Clips
+	if err := stmt.QueryContext(ctx, db, &rows); err != nil {
+		if errors.Is(err, qrm.ErrNoRows) {
+			return r, nil
+		}
+		return nil, err
+	}
+	for _, c := range rows {
+		r[c.ClipID] = int(c.ViewCount)
+	}
+	return r, nil
+}
+
+func expectAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRowsAffected
+	}
+	return nil
+}
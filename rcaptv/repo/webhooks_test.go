@@ -0,0 +1,177 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	"pedro.to/rcaptv/helix"
+)
+
+func createTestWebhookUser(t *testing.T) int64 {
+	t.Helper()
+	uid, err := UpsertUser(db, &helix.User{
+		Id:              "58753574",
+		Login:           "zeling",
+		DisplayName:     "Zeling",
+		BroadcasterType: "partner",
+		CreatedAt:       helix.RFC3339Timestamp(time.Date(2015, 5, 2, 0, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uid
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	defer cleanupUserAndTokens()
+	uid := createTestWebhookUser(t)
+
+	bid := "58753574"
+	evt := "clip.threshold_reached"
+	all, err := CreateWebhookEndpoint(db, &model.WebhookEndpoints{
+		UserID: int32(uid),
+		URL:    "https://example.com/all",
+		Secret: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !all.Enabled || all.EndpointID == 0 || all.BcID != nil || all.MinViews != 0 {
+		t.Fatalf("unexpected endpoint %+v", all)
+	}
+	filtered, err := CreateWebhookEndpoint(db, &model.WebhookEndpoints{
+		UserID:    int32(uid),
+		URL:       "https://example.com/filtered",
+		Secret:    "secret",
+		BcID:      &bid,
+		EventType: &evt,
+		MinViews:  100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := "1234"
+	if _, err := CreateWebhookEndpoint(db, &model.WebhookEndpoints{
+		UserID: int32(uid),
+		URL:    "https://example.com/unknown",
+		Secret: "secret",
+		BcID:   &unknown,
+	}); !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected ErrUnknownChannel, got %v", err)
+	}
+
+	eps, err := WebhookEndpoints(db, &WebhookEndpointsParams{UserID: uid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eps) != 2 || eps[0].EndpointID != all.EndpointID || eps[1].EndpointID != filtered.EndpointID {
+		t.Fatalf("expected both endpoints, got %+v", eps)
+	}
+	eps, err = WebhookEndpoints(db, &WebhookEndpointsParams{BroadcasterID: "90075649"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eps) != 1 || eps[0].EndpointID != all.EndpointID {
+		t.Fatalf("expected only the endpoint without channel filter, got %+v", eps)
+	}
+
+	// disabled after 2 failures in a row, success resets the count
+	id := int64(filtered.EndpointID)
+	if disabled, err := WebhookEndpointFailed(db, id, 2); err != nil || disabled {
+		t.Fatalf("expected not disabled, got %v %v", disabled, err)
+	}
+	if err := WebhookEndpointSucceeded(db, id); err != nil {
+		t.Fatal(err)
+	}
+	if disabled, err := WebhookEndpointFailed(db, id, 2); err != nil || disabled {
+		t.Fatalf("expected not disabled, got %v %v", disabled, err)
+	}
+	if disabled, err := WebhookEndpointFailed(db, id, 2); err != nil || !disabled {
+		t.Fatalf("expected disabled, got %v %v", disabled, err)
+	}
+	eps, err = WebhookEndpoints(db, &WebhookEndpointsParams{BroadcasterID: bid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eps) != 1 || eps[0].EndpointID != all.EndpointID {
+		t.Fatalf("expected disabled endpoint to be excluded, got %+v", eps)
+	}
+	if err := EnableWebhookEndpoint(db, uid+1, id); !errors.Is(err, ErrNoRowsAffected) {
+		t.Fatalf("expected ErrNoRowsAffected for another user, got %v", err)
+	}
+	if err := EnableWebhookEndpoint(db, uid, id); err != nil {
+		t.Fatal(err)
+	}
+	eps, err = WebhookEndpoints(db, &WebhookEndpointsParams{EndpointID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eps) != 1 || !eps[0].Enabled || eps[0].ConsecutiveFailures != 0 || eps[0].DisabledAt != nil {
+		t.Fatalf("expected endpoint enabled again, got %+v", eps)
+	}
+
+	// deliveries are listed from most recent
+	for attempt := int32(1); attempt <= 3; attempt++ {
+		status := int32(500)
+		if err := InsertWebhookDelivery(db, &model.WebhookDeliveries{
+			EndpointID: int32(id),
+			EventID:    "evt",
+			EventType:  evt,
+			Attempt:    attempt,
+			StatusCode: &status,
+			DurationMs: 10,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ds, err := WebhookDeliveries(db, &WebhookDeliveriesParams{EndpointID: id, First: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 2 || ds[0].Attempt != 3 || ds[1].Attempt != 2 {
+		t.Fatalf("unexpected deliveries %+v", ds)
+	}
+
+	if err := DeleteWebhookEndpoint(db, uid+1, id); !errors.Is(err, ErrNoRowsAffected) {
+		t.Fatalf("expected ErrNoRowsAffected for another user, got %v", err)
+	}
+	if err := DeleteWebhookEndpoint(db, uid, id); err != nil {
+		t.Fatal(err)
+	}
+	ds, err = WebhookDeliveries(db, &WebhookDeliveriesParams{EndpointID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 0 {
+		t.Fatalf("expected deliveries to be deleted with the endpoint, got %d", len(ds))
+	}
+}
+
+func TestClipViews(t *testing.T) {
+	defer cleanupClips()
+
+	if err := UpsertClips(db, []*helix.Clip{{
+		ClipID:        "clip1",
+		BroadcasterID: "58753574",
+		CreatedAt:     "2023-01-01T10:00:00Z",
+		CreatorID:     "creator1",
+		CreatorName:   "John Doe",
+		Title:         "Awesome Clip",
+		Lang:          "en",
+		ThumbnailURL:  "https://example.com/thumbnail1.jpg",
+		ViewCount:     100,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	views, err := ClipViews(db, context.Background(), []string{"clip1", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != 1 || views["clip1"] != 100 {
+		t.Fatalf("expected only clip1 with 100 views, got %v", views)
+	}
+}
@@ -0,0 +1,28 @@
+package scheduler
+
+import "time"
+
+// AlignedMinute returns the minute of the cycle of an aligned schedule at t,
+// that is the number of freq units since the Unix epoch mod cycleSize
+func AlignedMinute(t time.Time, freq time.Duration, cycleSize uint) Minute {
+	return Minute(uint64(t.UnixNano()/int64(freq)) % uint64(cycleSize))
+}
+
+// alignedNext returns the start of the freq unit after t
+func alignedNext(t time.Time, freq time.Duration) time.Time {
+	return time.Unix(0, (t.UnixNano()/int64(freq)+1)*int64(freq))
+}
+
+// AlignedNextRun returns when min is picked next after now by an aligned
+// schedule. Any process knowing the minute of a key can tell when it runs
+// without asking the scheduler, see BalancedScheduleOpts.Aligned
+func AlignedNextRun(min Minute, now time.Time, freq time.Duration, cycleSize uint) time.Time {
+	next := alignedNext(now, freq)
+	return runAfter(min, AlignedMinute(next, freq, cycleSize), next, freq, cycleSize)
+}
+
+// runAfter returns when min is picked if the minute m is picked at t
+func runAfter(min, m Minute, t time.Time, freq time.Duration, cycleSize uint) time.Time {
+	steps := (uint(min) + cycleSize - uint(m)) % cycleSize
+	return t.Add(time.Duration(steps) * freq)
+}
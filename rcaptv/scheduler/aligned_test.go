@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlignedNextRun(t *testing.T) {
+	t.Parallel()
+	// 2023-06-04T00:00:00Z is minute 1685836800/60 = 28097280 since the epoch,
+	// minute 0 of a 10 minutes cycle
+	start := time.Date(2023, 6, 4, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		min  Minute
+		now  time.Time
+		want time.Time
+	}{
+		{"later in the cycle", 5, start.Add(90 * time.Second), start.Add(5 * time.Minute)},
+		{"next minute", 2, start.Add(90 * time.Second), start.Add(2 * time.Minute)},
+		{"current minute already picked", 1, start.Add(90 * time.Second), start.Add(11 * time.Minute)},
+		{"at the start of the minute", 0, start, start.Add(10 * time.Minute)},
+		{"next cycle", 0, start.Add(9*time.Minute + time.Second), start.Add(10 * time.Minute)},
+	}
+	if m := AlignedMinute(start, time.Minute, 10); m != 0 {
+		t.Fatalf("expected min:0 at %s, got %d", start, m)
+	}
+	for _, tt := range tests {
+		if got := AlignedNextRun(tt.min, tt.now, time.Minute, 10); !got.Equal(tt.want) {
+			t.Fatalf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestBalancedScheduleAligned(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 4
+		pickInterval time.Duration = 100 * time.Millisecond
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: cycleSize,
+		BalanceStrategy:  StrategyMurmur(uint32(cycleSize)),
+		Freq:             pickInterval,
+		Aligned:          true,
+	})
+	key := usernames[0]
+	bs.Add(key)
+	bs.Start()
+	defer bs.Stop()
+
+	var next time.Time
+	for i := 0; i < 10 && next.IsZero(); i++ {
+		// wait for the add op
+		next = bs.NextRun(key)
+		time.Sleep(time.Millisecond)
+	}
+	if want := AlignedNextRun(bs.BalancedMin(key), time.Now(), pickInterval, cycleSize); !next.Equal(want) {
+		t.Fatalf("expected next run at %s, got %s", want, next)
+	}
+
+	for i := uint(0); i < cycleSize; i++ {
+		m := <-bs.RealTime()
+		now := time.Now()
+		if want := AlignedMinute(now, pickInterval, cycleSize); m.Min != want {
+			t.Fatalf("expected min:%d at %s, got %d", want, now.Format(time.RFC3339Nano), m.Min)
+		}
+		if len(m.Objects) > 0 {
+			if d := now.Sub(next); d < -pickInterval/2 || d > pickInterval/2 {
+				t.Fatalf("expected %s to be picked at %s, got %s", key, next, now)
+			}
+		}
+	}
+}
+
+func TestBalancedScheduleNextRun(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 4
+		pickInterval time.Duration = 100 * time.Millisecond
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: cycleSize,
+		Freq:             pickInterval,
+	})
+	if next := bs.NextRun("a"); !next.IsZero() {
+		t.Fatalf("expected no next run before starting, got %s", next)
+	}
+	bs.Add("a")
+	bs.Add("b")
+	bs.Start()
+	defer bs.Stop()
+
+	var next time.Time
+	for i := 0; i < 10 && next.IsZero(); i++ {
+		next = bs.NextRun("b")
+		time.Sleep(time.Millisecond)
+	}
+	if next.IsZero() {
+		t.Fatal("expected a next run")
+	}
+	if next := bs.NextRun("unknown"); !next.IsZero() {
+		t.Fatalf("expected no next run for an unknown key, got %s", next)
+	}
+	for {
+		m := <-bs.RealTime()
+		if len(m.Objects) == 1 && m.Objects[0] == "b" {
+			if d := time.Since(next); d < -pickInterval/2 || d > pickInterval/2 {
+				t.Fatalf("expected b to be picked at %s, got %s", next, time.Now())
+			}
+			return
+		}
+	}
+}
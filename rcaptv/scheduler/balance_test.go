@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/montanaflynn/stats"
+)
+
+// balanceKeys are the keys to measure the balancers with, besides the
+// usernames fixture
+func balanceKeys(n int) []string {
+	keys := make([]string, 0, n+len(usernames))
+	keys = append(keys, usernames...)
+	for i := 0; i < n; i++ {
+		keys = append(keys, fmt.Sprintf("%d", 10000000+i*7919))
+	}
+	return keys
+}
+
+// assign returns the minute of every key with a new strategy of max minutes
+func assign(strategy func(max uint) KeyBalancer, max uint, keys []string) []Minute {
+	b := strategy(max)
+	mins := make([]Minute, len(keys))
+	for i, k := range keys {
+		mins[i] = b.Key(k)
+	}
+	return mins
+}
+
+// relativeStd returns the standard deviation of the number of keys per
+// minute relative to the mean
+func relativeStd(mins []Minute, max uint) float64 {
+	counts := make([]float64, max)
+	for _, m := range mins {
+		counts[m]++
+	}
+	std, _ := stats.StandardDeviation(counts)
+	mean, _ := stats.Mean(counts)
+	return std / mean
+}
+
+// reshuffle returns the ratio of keys whose minute changed
+func reshuffle(before, after []Minute) float64 {
+	moved := 0
+	for i := range before {
+		if before[i] != after[i] {
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(before))
+}
+
+func TestBalancers(t *testing.T) {
+	t.Parallel()
+	keys := balanceKeys(20000)
+	strategies := []struct {
+		name     string
+		strategy func(max uint) KeyBalancer
+	}{
+		{"count", func(max uint) KeyBalancer { return StrategyCount(max) }},
+		{"murmur", func(max uint) KeyBalancer { return StrategyMurmur(uint32(max)) }},
+		{"jump", func(max uint) KeyBalancer { return StrategyJump(uint32(max)) }},
+	}
+	changes := []struct {
+		from, to uint
+	}{
+		{720, 721},
+		{720, 719},
+		{720, 360},
+		{60, 90},
+	}
+
+	stds := make(map[string]float64)
+	ratios := make(map[string][]float64)
+	for _, st := range strategies {
+		before := assign(st.strategy, 720, keys)
+		for _, m := range before {
+			if m >= 720 {
+				t.Fatalf("%s: min:%d out of the cycle", st.name, m)
+			}
+		}
+		stds[st.name] = relativeStd(before, 720)
+		for _, c := range changes {
+			r := reshuffle(assign(st.strategy, c.from, keys), assign(st.strategy, c.to, keys))
+			ratios[st.name] = append(ratios[st.name], r)
+			t.Logf("%s: %d -> %d minutes moved %.1f%% of the keys", st.name, c.from, c.to, r*100)
+		}
+		t.Logf("%s: relative std of keys per minute %.3f", st.name, stds[st.name])
+	}
+
+	// the hashing strategies distribute similarly, with ~28 keys per minute
+	// the expected relative std is ~1/sqrt(28)
+	if stds["jump"] > 0.25 || stds["jump"] > stds["murmur"]*1.2 {
+		t.Fatalf("unbalanced jump distribution, relative std %.3f (murmur %.3f)", stds["jump"], stds["murmur"])
+	}
+	for i, c := range changes {
+		// the minimal reshuffle is the ratio of the minutes added or removed
+		min := float64(c.to-c.from) / float64(c.to)
+		if c.to < c.from {
+			min = float64(c.from-c.to) / float64(c.from)
+		}
+		if got := ratios["jump"][i]; got > min*1.2+0.01 {
+			t.Fatalf("jump: %d -> %d moved %.3f of the keys, expected ~%.3f", c.from, c.to, got, min)
+		}
+		if ratios["murmur"][i] < 0.4 {
+			t.Fatalf("murmur: expected most keys to move when changing %d -> %d, got %.3f", c.from, c.to, ratios["murmur"][i])
+		}
+	}
+}
+
+func TestNewResizesBalancer(t *testing.T) {
+	t.Parallel()
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        720,
+		EstimatedObjects: 10,
+		BalanceStrategy:  StrategyJump(720),
+	})
+	for _, k := range usernames {
+		if m := bs.BalancedMin(k); uint(m) >= bs.CycleSize() {
+			t.Fatalf("%s assigned to min:%d out of the cycle of %d", k, m, bs.CycleSize())
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Coordinator coordinates the workers of a distributed schedule. Every
+// worker has its own BalancedSchedule with the same keys and cycle and the
+// minutes of the cycle are sharded across the live workers.
+//
+// A run is a minute of the cycle at a given time, identified by its slot: the
+// number of minutes (or Freq units) since the Unix epoch. Only the worker that
+// claims a slot runs it.
+type Coordinator interface {
+	// ID returns the ID of the worker
+	ID() string
+	// Heartbeat renews the lease of the worker and returns the sorted IDs of
+	// the live workers, including itself
+	Heartbeat(ctx context.Context) ([]string, error)
+	// Claim claims the run of the slot for the worker. It returns false if it
+	// was already claimed
+	Claim(ctx context.Context, slot int64) (bool, error)
+	// Release releases the claim of a slot the worker couldn't run, so it is
+	// taken over by a live worker
+	Release(ctx context.Context, slot int64) error
+	// Finish marks the run of a slot claimed by the worker as finished
+	Finish(ctx context.Context, slot int64) error
+	// TakeOver claims the runs not finished of the slots since the given one
+	// that were released or claimed by workers whose lease expired, and
+	// returns them. Runs before since are forgotten
+	TakeOver(ctx context.Context, since int64) ([]int64, error)
+	// Leave removes the lease of the worker and releases its runs not
+	// finished, so the others rebalance without waiting for it to expire
+	Leave(ctx context.Context) error
+}
+
+// distributedBuffer is the size of the realTime channel of a distributed
+// schedule, which may pick the runs of other workers besides its own minute
+const distributedBuffer = 8
+
+// owner returns the worker that owns the minute m
+func owner(live []string, m Minute) string {
+	return live[uint(m)%uint(len(live))]
+}
+
+// distributedPick picks the minute m scheduled at the slot if the worker owns
+// it. Every tick it also takes over the runs of the previous slot if its
+// owner didn't claim it, e.g. because it died or the workers didn't agree on
+// who owned it while rebalancing, and the runs of workers that died while
+// running them.
+func (s *Schedule) distributedPick(ctx context.Context, m Minute, slot int64, at time.Time, freq time.Duration, cycleSize uint) {
+	l := log.With().Str("ctx", "scheduler").Str("worker", s.coord.ID()).Logger()
+	live, err := s.coord.Heartbeat(ctx)
+	if err != nil {
+		// without a lease the other workers take over our minutes
+		l.Err(err).Msgf("heartbeat failed, skipping min:%d", m)
+		return
+	}
+	if len(live) > 0 && owner(live, m) == s.coord.ID() {
+		s.claimAndSend(ctx, m, slot, at)
+	}
+	prev := slot - 1
+	s.claimAndSend(ctx, AlignedMinute(time.Unix(0, prev*int64(freq)), freq, cycleSize), prev, at.Add(-freq))
+
+	slots, err := s.coord.TakeOver(ctx, slot-int64(cycleSize))
+	if err != nil {
+		l.Err(err).Msg("could not take over the runs of dead workers")
+		return
+	}
+	for _, sl := range slots {
+		slotAt := time.Unix(0, sl*int64(freq))
+		l.Info().Msgf("took over the run of min:%d scheduled at %s", AlignedMinute(slotAt, freq, cycleSize), slotAt.Format(time.RFC3339))
+		s.send(ctx, AlignedMinute(slotAt, freq, cycleSize), sl, slotAt)
+	}
+}
+
+// claimAndSend sends the minute to the realTime channel if the worker claims
+// the slot
+func (s *Schedule) claimAndSend(ctx context.Context, m Minute, slot int64, at time.Time) {
+	claimed, err := s.coord.Claim(ctx, slot)
+	if err != nil {
+		l := log.With().Str("ctx", "scheduler").Str("worker", s.coord.ID()).Logger()
+		l.Err(err).Msgf("could not claim min:%d", m)
+		return
+	}
+	if claimed {
+		s.send(ctx, m, slot, at)
+	}
+}
+
+// send sends a claimed run to the realTime channel. The run is finished when
+// the receiver calls Done. If the channel is blocked the run is released
+func (s *Schedule) send(ctx context.Context, m Minute, slot int64, at time.Time) {
+	coord := s.coord
+	rt := RealTimeMinute{
+		Min:     m,
+		At:      at,
+		Late:    s.clock.Now().Sub(at),
+		Objects: s.pick(m),
+		done: func() {
+			ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+			defer cancel()
+			if err := coord.Finish(ctx, slot); err != nil {
+				l := log.With().Str("ctx", "scheduler").Str("worker", coord.ID()).Logger()
+				l.Err(err).Msgf("could not finish min:%d", m)
+			}
+		},
+	}
+	s.addOneShots(&rt)
+	s.spreadObjects(&rt)
+	select {
+	case s.realTime <- rt:
+	default:
+		l := log.With().Str("ctx", "scheduler").Str("worker", coord.ID()).Logger()
+		l.Warn().Msgf("WARN: releasing minute (min:%d) because 'realTime' channel is blocked.", m)
+		if err := coord.Release(ctx, slot); err != nil {
+			l.Err(err).Msgf("could not release min:%d", m)
+		}
+	}
+}
+
+// MemoryLeases coordinates the workers of a distributed schedule in the same
+// process. Useful for testing
+type MemoryLeases struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	workers map[string]time.Time
+	runs    map[int64]*memoryRun
+}
+
+type memoryRun struct {
+	worker   string
+	finished bool
+}
+
+// NewMemoryLeases returns the leases of a schedule. Workers whose last
+// heartbeat is older than ttl are dead
+func NewMemoryLeases(ttl time.Duration) *MemoryLeases {
+	return &MemoryLeases{
+		ttl:     ttl,
+		workers: make(map[string]time.Time),
+		runs:    make(map[int64]*memoryRun),
+	}
+}
+
+// Worker returns the Coordinator of the worker id
+func (ml *MemoryLeases) Worker(id string) Coordinator {
+	return &memoryWorker{leases: ml, id: id}
+}
+
+func (ml *MemoryLeases) alive(id string) bool {
+	hb, found := ml.workers[id]
+	return found && time.Since(hb) < ml.ttl
+}
+
+type memoryWorker struct {
+	leases *MemoryLeases
+	id     string
+}
+
+func (w *memoryWorker) ID() string {
+	return w.id
+}
+
+func (w *memoryWorker) Heartbeat(_ context.Context) ([]string, error) {
+	w.leases.mu.Lock()
+	defer w.leases.mu.Unlock()
+	w.leases.workers[w.id] = time.Now()
+	live := make([]string, 0, len(w.leases.workers))
+	for id := range w.leases.workers {
+		if w.leases.alive(id) {
+			live = append(live, id)
+		}
+	}
+	sort.Strings(live)
+	return live, nil
+}
+
+func (w *memoryWorker) Claim(_ context.Context, slot int64) (bool, error) {
+	w.leases.mu.Lock()
+	defer w.leases.mu.Unlock()
+	if _, found := w.leases.runs[slot]; found {
+		return false, nil
+	}
+	w.leases.runs[slot] = &memoryRun{worker: w.id}
+	return true, nil
+}
+
+func (w *memoryWorker) Release(_ context.Context, slot int64) error {
+	w.leases.mu.Lock()
+	defer w.leases.mu.Unlock()
+	if r, found := w.leases.runs[slot]; found && r.worker == w.id {
+		r.worker = ""
+	}
+	return nil
+}
+
+func (w *memoryWorker) Finish(_ context.Context, slot int64) error {
+	w.leases.mu.Lock()
+	defer w.leases.mu.Unlock()
+	if r, found := w.leases.runs[slot]; found && r.worker == w.id {
+		r.finished = true
+	}
+	return nil
+}
+
+func (w *memoryWorker) TakeOver(_ context.Context, since int64) ([]int64, error) {
+	w.leases.mu.Lock()
+	defer w.leases.mu.Unlock()
+	var slots []int64
+	for slot, r := range w.leases.runs {
+		if slot < since {
+			delete(w.leases.runs, slot)
+			continue
+		}
+		if !r.finished && (r.worker == "" || !w.leases.alive(r.worker)) {
+			r.worker = w.id
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots, nil
+}
+
+func (w *memoryWorker) Leave(_ context.Context) error {
+	w.leases.mu.Lock()
+	defer w.leases.mu.Unlock()
+	delete(w.leases.workers, w.id)
+	for _, r := range w.leases.runs {
+		if r.worker == w.id && !r.finished {
+			r.worker = ""
+		}
+	}
+	return nil
+}
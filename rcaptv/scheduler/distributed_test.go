@@ -0,0 +1,255 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// crashingCoordinator doesn't leave the schedule when stopped, as if the
+// worker died
+type crashingCoordinator struct {
+	Coordinator
+}
+
+func (crashingCoordinator) Leave(_ context.Context) error {
+	return nil
+}
+
+// distributedRuns records the slots run by the workers of a distributed
+// schedule
+type distributedRuns struct {
+	mu   sync.Mutex
+	runs map[int64][]string
+	// wrong are the runs with a minute not matching the slot
+	wrong int
+}
+
+// run runs a worker until stopped
+func (r *distributedRuns) run(bs *BalancedSchedule, id string, freq time.Duration, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case m := <-bs.RealTime():
+			slot := m.At.UnixNano() / int64(freq)
+			r.mu.Lock()
+			if m.Min != AlignedMinute(m.At, freq, bs.CycleSize()) {
+				r.wrong++
+			}
+			r.runs[slot] = append(r.runs[slot], id)
+			r.mu.Unlock()
+			m.Done()
+		}
+	}
+}
+
+func TestDistributedSchedule(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 6
+		pickInterval time.Duration = 50 * time.Millisecond
+	)
+	leases := NewMemoryLeases(3 * pickInterval)
+	runs := &distributedRuns{runs: make(map[int64][]string)}
+	ids := []string{"a", "b", "c"}
+	schedules := make(map[string]*BalancedSchedule, len(ids))
+	stops := make(map[string]chan struct{}, len(ids))
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		coord := leases.Worker(id)
+		if id == "b" {
+			coord = crashingCoordinator{coord}
+		}
+		bs := New(BalancedScheduleOpts{
+			CycleSize:        cycleSize,
+			EstimatedObjects: 60,
+			BalanceStrategy:  StrategyMurmur(uint32(cycleSize)),
+			Freq:             pickInterval,
+			Coordinator:      coord,
+		})
+		for _, k := range usernames[:60] {
+			bs.Add(k)
+		}
+		schedules[id], stops[id] = bs, make(chan struct{})
+		bs.Start()
+		wg.Add(1)
+		go func(bs *BalancedSchedule, id string, stop chan struct{}) {
+			defer wg.Done()
+			runs.run(bs, id, pickInterval, stop)
+		}(bs, id, stops[id])
+	}
+	first := time.Now().UnixNano()/int64(pickInterval) + 1
+
+	time.Sleep(2 * time.Duration(cycleSize) * pickInterval)
+	// b dies and its minutes are taken over after its lease expires
+	close(stops["b"])
+	schedules["b"].Stop()
+	crashed := time.Now().UnixNano() / int64(pickInterval)
+	time.Sleep(3 * time.Duration(cycleSize) * pickInterval)
+
+	last := time.Now().UnixNano()/int64(pickInterval) - 2
+	for _, id := range []string{"a", "c"} {
+		close(stops[id])
+		schedules[id].Stop()
+	}
+	wg.Wait()
+
+	runs.mu.Lock()
+	defer runs.mu.Unlock()
+	if runs.wrong > 0 {
+		t.Fatalf("%d runs with a minute not matching their slot", runs.wrong)
+	}
+	byWorker := make(map[string]int)
+	for slot := first; slot <= last; slot++ {
+		if len(runs.runs[slot]) != 1 {
+			t.Fatalf("expected slot %d (min:%d) to run exactly once, got %v",
+				slot, uint64(slot)%uint64(cycleSize), runs.runs[slot])
+		}
+		byWorker[runs.runs[slot][0]]++
+		if slot > crashed && runs.runs[slot][0] == "b" {
+			t.Fatalf("slot %d run by a dead worker", slot)
+		}
+	}
+	for _, id := range ids {
+		if byWorker[id] == 0 {
+			t.Fatalf("expected every worker to run some minutes, got %v", byWorker)
+		}
+	}
+	t.Logf("runs by worker: %v", byWorker)
+}
+
+func TestDistributedScheduleInterrupted(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 4
+		pickInterval time.Duration = 50 * time.Millisecond
+	)
+	leases := NewMemoryLeases(3 * pickInterval)
+	newSchedule := func(id string) *BalancedSchedule {
+		bs := New(BalancedScheduleOpts{
+			CycleSize:        cycleSize,
+			EstimatedObjects: 8,
+			BalanceStrategy:  StrategyMurmur(uint32(cycleSize)),
+			Freq:             pickInterval,
+			Coordinator:      leases.Worker(id),
+		})
+		for _, k := range usernames[:8] {
+			bs.Add(k)
+		}
+		return bs
+	}
+	runs := &distributedRuns{runs: make(map[int64][]string)}
+	a, b := newSchedule("a"), newSchedule("b")
+	a.Start()
+	b.Start()
+	stopB := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runs.run(b, "b", pickInterval, stopB)
+	}()
+	defer func() {
+		close(stopB)
+		b.Stop()
+		<-done
+	}()
+
+	// a is stopped in the middle of its first minute with several objects.
+	// Like the tracker, it doesn't call Done for a minute it didn't finish
+	var slot int64
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	timeout := time.After(5 * time.Duration(cycleSize) * pickInterval)
+	for slot == 0 {
+		select {
+		case m := <-a.RealTime():
+			if len(m.Objects) < 2 {
+				m.Done()
+				continue
+			}
+			interrupted := false
+			for i := range m.Objects {
+				if i == 1 {
+					cancel()
+				}
+				if ctx.Err() != nil {
+					interrupted = true
+					break
+				}
+			}
+			if !interrupted {
+				m.Done()
+			}
+			slot = m.At.UnixNano() / int64(pickInterval)
+		case <-timeout:
+			t.Fatal("expected a minute with several objects")
+		}
+	}
+	leases.mu.Lock()
+	finished := leases.runs[slot].finished
+	leases.mu.Unlock()
+	if finished {
+		t.Fatalf("expected slot %d not to be finished", slot)
+	}
+	a.Stop()
+
+	// b takes over the run after a leaves
+	time.Sleep(2 * pickInterval)
+	for deadline := time.Now().Add(time.Duration(cycleSize) * pickInterval); ; {
+		runs.mu.Lock()
+		byB := len(runs.runs[slot]) == 1 && runs.runs[slot][0] == "b"
+		runs.mu.Unlock()
+		if byB {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected slot %d to be run again by b, got %v", slot, runs.runs[slot])
+		}
+		time.Sleep(pickInterval / 5)
+	}
+	leases.mu.Lock()
+	defer leases.mu.Unlock()
+	if r := leases.runs[slot]; r == nil || !r.finished {
+		t.Fatalf("expected slot %d to be finished by b", slot)
+	}
+}
+
+func TestDistributedScheduleBalancer(t *testing.T) {
+	t.Parallel()
+	leases := NewMemoryLeases(time.Second)
+	newSchedule := func(b KeyBalancer) (bs *BalancedSchedule, panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		return New(BalancedScheduleOpts{
+			CycleSize:        10,
+			EstimatedObjects: 5,
+			BalanceStrategy:  b,
+			Coordinator:      leases.Worker("a"),
+		}), false
+	}
+	for name, b := range map[string]KeyBalancer{
+		"nil":   nil,
+		"count": StrategyCount(10),
+		"load":  StrategyLoad(10),
+	} {
+		if _, panicked := newSchedule(b); !panicked {
+			t.Fatalf("expected a distributed schedule with the %s balancer to be rejected", name)
+		}
+	}
+	for name, b := range map[string]KeyBalancer{
+		"murmur": StrategyMurmur(10),
+		"jump":   StrategyJump(10),
+	} {
+		bs, panicked := newSchedule(b)
+		if panicked {
+			t.Fatalf("expected a distributed schedule with the %s balancer", name)
+		}
+		// workers may estimate a different number of objects
+		if bs.CycleSize() != 10 {
+			t.Fatalf("expected the cycle size not to be shrunk, got %d", bs.CycleSize())
+		}
+	}
+}
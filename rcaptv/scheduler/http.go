@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// KeySnapshot is the minute of a key of a running schedule
+type KeySnapshot struct {
+	Key     string    `json:"key"`
+	Min     Minute    `json:"min"`
+	NextRun time.Time `json:"next_run"`
+}
+
+// DebugHandler returns an internal endpoint to inspect a running schedule.
+// GET returns its Snapshot, or the KeySnapshot of a key with ?key=. schedule
+// returns the schedule, nil if it was not created yet. Do not expose it
+// publicly, keys may be user IDs.
+func DebugHandler(schedule func() *BalancedSchedule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		bs := schedule()
+		if bs == nil {
+			http.Error(w, "schedule not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		var resp any
+		if key := r.URL.Query().Get("key"); key != "" {
+			min, found := bs.Lookup(key)
+			if !found {
+				http.Error(w, "key not found", http.StatusNotFound)
+				return
+			}
+			resp = &KeySnapshot{Key: key, Min: min, NextRun: bs.NextRun(key)}
+		} else {
+			snap := bs.Snapshot()
+			if snap == nil {
+				http.Error(w, "schedule not running", http.StatusServiceUnavailable)
+				return
+			}
+			resp = snap
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
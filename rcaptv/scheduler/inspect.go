@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+type inspectReq struct {
+	// fn is run by the worker with the minute it picks next and when
+	fn   func(next Minute, nextAt time.Time)
+	done chan struct{}
+}
+
+type inspectChan chan *inspectReq
+
+// Snapshot is the state of a running schedule at a given time
+type Snapshot struct {
+	// Current is the last minute picked. Nil if none was picked yet
+	Current *Minute `json:"current"`
+	// Next is the minute picked at NextAt
+	Next      Minute    `json:"next"`
+	NextAt    time.Time `json:"next_at"`
+	CycleSize uint      `json:"cycle_size"`
+	// Counts are the number of keys of every minute
+	Counts    []int     `json:"counts"`
+	Histogram Histogram `json:"histogram"`
+}
+
+// Histogram describes the distribution of the keys across the minutes
+type Histogram struct {
+	Keys    int     `json:"keys"`
+	Minutes int     `json:"minutes"`
+	Min     int     `json:"min"`
+	Max     int     `json:"max"`
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"std_dev"`
+	// Empty is the number of minutes without keys
+	Empty int `json:"empty"`
+	// Bins are the number of minutes by their number of keys, sorted by keys
+	Bins []HistogramBin `json:"bins"`
+}
+
+type HistogramBin struct {
+	Keys    int `json:"keys"`
+	Minutes int `json:"minutes"`
+}
+
+// NewHistogram returns the histogram of the number of keys per minute
+func NewHistogram(counts []int) Histogram {
+	h := Histogram{Minutes: len(counts)}
+	if len(counts) == 0 {
+		return h
+	}
+	bins := make(map[int]int)
+	h.Min = counts[0]
+	for _, c := range counts {
+		h.Keys += c
+		if c < h.Min {
+			h.Min = c
+		}
+		if c > h.Max {
+			h.Max = c
+		}
+		if c == 0 {
+			h.Empty++
+		}
+		bins[c]++
+	}
+	h.Mean = float64(h.Keys) / float64(len(counts))
+	var variance float64
+	for _, c := range counts {
+		variance += math.Pow(float64(c)-h.Mean, 2)
+	}
+	h.StdDev = math.Sqrt(variance / float64(len(counts)))
+
+	h.Bins = make([]HistogramBin, 0, len(bins))
+	for keys, minutes := range bins {
+		h.Bins = append(h.Bins, HistogramBin{Keys: keys, Minutes: minutes})
+	}
+	sort.Slice(h.Bins, func(i, j int) bool { return h.Bins[i].Keys < h.Bins[j].Keys })
+	return h
+}
+
+// counts returns the number of keys of every minute of the cycle
+func (s *Schedule) counts(cycleSize uint) []int {
+	counts := make([]int, cycleSize)
+	for m := range counts {
+		counts[m] = len(s.schedule[Minute(m)])
+	}
+	return counts
+}
+
+// inspect runs fn in the worker goroutine, where the schedule can be read
+// safely. It returns false if the scheduler is not running
+func (bs *BalancedSchedule) inspect(fn func(next Minute, nextAt time.Time)) bool {
+	ctx := bs.context()
+	if ctx == nil {
+		return false
+	}
+	req := &inspectReq{fn: fn, done: make(chan struct{})}
+	select {
+	case bs.internal.inspects <- req:
+	case <-ctx.Done():
+		return false
+	}
+	<-req.done
+	return true
+}
+
+// CurrentMinute returns the last minute picked. False if none was picked yet
+// or the scheduler is not running. CurrentMinute is safe for concurrent
+// access
+func (bs *BalancedSchedule) CurrentMinute() (Minute, bool) {
+	var (
+		min    Minute
+		picked bool
+	)
+	running := bs.inspect(func(_ Minute, _ time.Time) {
+		min, picked = bs.internal.last, bs.internal.picked
+	})
+	return min, running && picked
+}
+
+// MinuteCounts returns the number of keys of every minute. Nil if the
+// scheduler is not running. MinuteCounts is safe for concurrent access
+func (bs *BalancedSchedule) MinuteCounts() []int {
+	var counts []int
+	bs.inspect(func(_ Minute, _ time.Time) {
+		counts = bs.internal.counts(bs.opts.CycleSize)
+	})
+	return counts
+}
+
+// Lookup returns the minute of the key. False if the key is not in the
+// schedule or the scheduler is not running. Lookup is safe for concurrent
+// access
+func (bs *BalancedSchedule) Lookup(key string) (Minute, bool) {
+	var (
+		min   Minute
+		found bool
+	)
+	bs.inspect(func(_ Minute, _ time.Time) {
+		min, found = bs.internal.keyToMin[key]
+	})
+	return min, found
+}
+
+// Histogram returns the distribution of the keys across the minutes. False
+// if the scheduler is not running. Histogram is safe for concurrent access
+func (bs *BalancedSchedule) Histogram() (Histogram, bool) {
+	counts := bs.MinuteCounts()
+	if counts == nil {
+		return Histogram{}, false
+	}
+	return NewHistogram(counts), true
+}
+
+// Snapshot returns the state of the schedule. Nil if the scheduler is not
+// running. Snapshot is safe for concurrent access
+func (bs *BalancedSchedule) Snapshot() *Snapshot {
+	var snap *Snapshot
+	bs.inspect(func(next Minute, nextAt time.Time) {
+		snap = &Snapshot{
+			Next:      next,
+			NextAt:    nextAt,
+			CycleSize: bs.opts.CycleSize,
+			Counts:    bs.internal.counts(bs.opts.CycleSize),
+		}
+		if bs.internal.picked {
+			last := bs.internal.last
+			snap.Current = &last
+		}
+	})
+	if snap != nil {
+		snap.Histogram = NewHistogram(snap.Counts)
+	}
+	return snap
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewHistogram(t *testing.T) {
+	t.Parallel()
+	h := NewHistogram([]int{2, 0, 4, 2})
+	want := Histogram{
+		Keys:    8,
+		Minutes: 4,
+		Min:     0,
+		Max:     4,
+		Mean:    2,
+		StdDev:  1.4142135623730951,
+		Empty:   1,
+		Bins:    []HistogramBin{{Keys: 0, Minutes: 1}, {Keys: 2, Minutes: 2}, {Keys: 4, Minutes: 1}},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Fatalf("expected %+v, got %+v", want, h)
+	}
+}
+
+func TestScheduleInspect(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 10
+		pickInterval time.Duration = 20 * time.Millisecond
+		keys                       = usernames[:50]
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(len(keys)),
+		Freq:             pickInterval,
+	})
+	bs.AddMany(keys...)
+	if _, ok := bs.Lookup(keys[0]); ok {
+		t.Fatal("expected no lookups while not running")
+	}
+	if bs.Snapshot() != nil || bs.MinuteCounts() != nil {
+		t.Fatal("expected no snapshot while not running")
+	}
+
+	bs.Start()
+	bs.Flush()
+	if _, ok := bs.CurrentMinute(); ok {
+		t.Fatal("expected no current minute before the first pick")
+	}
+	picked := <-bs.RealTime()
+	if m, ok := bs.CurrentMinute(); !ok || m != picked.Min {
+		t.Fatalf("expected current min:%d, got %d (ok:%t)", picked.Min, m, ok)
+	}
+	snap := bs.Snapshot()
+	if snap == nil || snap.Current == nil || *snap.Current != picked.Min || snap.Next != picked.Min+1 {
+		t.Fatalf("unexpected snapshot after picking min:%d %+v", picked.Min, snap)
+	}
+	if snap.Histogram.Keys != len(keys) || snap.Histogram.Min != 5 || snap.Histogram.Max != 5 {
+		t.Fatalf("expected 5 keys every minute, got %+v", snap.Histogram)
+	}
+	if h, ok := bs.Histogram(); !ok || !reflect.DeepEqual(h, snap.Histogram) {
+		t.Fatalf("expected the histogram of the snapshot, got %+v", h)
+	}
+	lookups := make(KeyToMinuteMap, len(keys))
+	for _, k := range keys {
+		m, ok := bs.Lookup(k)
+		if !ok {
+			t.Fatalf("expected %s to be found", k)
+		}
+		lookups[k] = m
+	}
+	if _, ok := bs.Lookup("unknown"); ok {
+		t.Fatal("expected unknown key not to be found")
+	}
+	bs.Stop()
+	if !reflect.DeepEqual(lookups, bs.UnsafeKeyToMinute()) {
+		t.Fatal("expected lookups to match the schedule")
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize uint = 10
+		keys           = usernames[:20]
+	)
+	var current atomic.Pointer[BalancedSchedule]
+	srv := httptest.NewServer(DebugHandler(current.Load))
+	defer srv.Close()
+
+	get := func(query string, want int, v any) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("GET %q: expected status %d, got %d", query, want, resp.StatusCode)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	get("", http.StatusServiceUnavailable, nil)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(len(keys)),
+		Freq:             time.Hour,
+	})
+	bs.AddMany(keys...)
+	current.Store(bs)
+	get("", http.StatusServiceUnavailable, nil)
+
+	bs.Start()
+	defer bs.Stop()
+	bs.Flush()
+	var snap Snapshot
+	get("", http.StatusOK, &snap)
+	if snap.CycleSize != cycleSize || len(snap.Counts) != int(cycleSize) || snap.Histogram.Keys != len(keys) {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+	var key KeySnapshot
+	get("?key="+keys[3], http.StatusOK, &key)
+	if min, _ := bs.Lookup(keys[3]); key.Key != keys[3] || key.Min != min || key.NextRun.IsZero() {
+		t.Fatalf("unexpected key snapshot %+v", key)
+	}
+	get("?key=unknown", http.StatusNotFound, nil)
+}
@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"sync"
+)
+
+// WeightedBalancer is a KeyBalancer that places the keys by their cost, e.g.
+// the number of requests needed to process them. It must be safe for
+// concurrent access.
+type WeightedBalancer interface {
+	KeyBalancer
+	// KeyCost returns the minute of the key, assigning it with the given cost
+	// if it is new
+	KeyCost(k string, cost float64) Minute
+	// Observe updates the cost of the key with the one observed when
+	// processing it
+	Observe(k string, cost float64)
+	// Move moves the key to min, e.g. when restored from a Store
+	Move(k string, min Minute)
+	// Remove removes the key and its cost
+	Remove(k string)
+	// Rebalance moves keys to even the cost of the minutes and returns the
+	// new minute of the keys moved
+	Rebalance() KeyToMinuteMap
+}
+
+// defaultKeyCost is the cost of the keys added without one
+const defaultKeyCost = 1
+
+// observedCostWeight is the weight of the last observed cost in the moving
+// average of the cost of a key. Costs vary between cycles, e.g. a deep clips
+// fetch after a live, so a single observation shouldn't move the key
+const observedCostWeight = 0.5
+
+type loadKey struct {
+	min  Minute
+	cost float64
+}
+
+// LoadBalance is a weighted key balancer that places every key in the minute
+// with the lowest cost, the sum of the cost of its keys. Costs are estimated
+// when the key is added and updated with the observed ones, and keys are
+// moved by Rebalance when the costs change.
+//
+// LoadBalance supports Remove(). Keys are not salted. Key assignment depends
+// on the order and the cost of the keys added, like in CountBalance
+type LoadBalance struct {
+	mu   sync.Mutex
+	load []float64
+	keys map[string]*loadKey
+}
+
+func StrategyLoad(max uint) *LoadBalance {
+	return &LoadBalance{
+		load: make([]float64, max),
+		keys: make(map[string]*loadKey),
+	}
+}
+
+func (b *LoadBalance) Key(k string) Minute {
+	return b.KeyCost(k, defaultKeyCost)
+}
+
+func (b *LoadBalance) KeyCost(k string, cost float64) Minute {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lk, found := b.keys[k]; found {
+		return lk.min
+	}
+	min := b.lightest()
+	b.keys[k] = &loadKey{min: min, cost: cost}
+	b.load[min] += cost
+	return min
+}
+
+func (b *LoadBalance) Observe(k string, cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lk, found := b.keys[k]
+	if !found {
+		return
+	}
+	next := lk.cost*(1-observedCostWeight) + cost*observedCostWeight
+	b.load[lk.min] += next - lk.cost
+	lk.cost = next
+}
+
+func (b *LoadBalance) Move(k string, min Minute) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if int(min) >= len(b.load) {
+		return
+	}
+	lk, found := b.keys[k]
+	if !found {
+		lk = &loadKey{min: min, cost: defaultKeyCost}
+		b.keys[k] = lk
+		b.load[min] += lk.cost
+		return
+	}
+	b.load[lk.min] -= lk.cost
+	b.load[min] += lk.cost
+	lk.min = min
+}
+
+func (b *LoadBalance) Remove(k string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lk, found := b.keys[k]; found {
+		b.load[lk.min] -= lk.cost
+		delete(b.keys, k)
+	}
+}
+
+// Rebalance moves keys from the most loaded minute to the least loaded one
+// while that reduces the difference between them by half the cost of the key
+// at least. Only a few keys are moved, every key moved is processed once out
+// of its interval.
+func (b *LoadBalance) Rebalance() KeyToMinuteMap {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	moved := make(KeyToMinuteMap)
+	// every key is moved at most once
+	for i := 0; i < len(b.keys); i++ {
+		heaviest, lightest := b.heaviest(), b.lightest()
+		diff := b.load[heaviest] - b.load[lightest]
+		// the biggest key that doesn't make lightest heavier than heaviest was
+		// after the move
+		var (
+			key  string
+			best *loadKey
+		)
+		for k, lk := range b.keys {
+			if lk.min != heaviest || lk.cost > diff/2 {
+				continue
+			}
+			if _, found := moved[k]; found {
+				continue
+			}
+			if best == nil || lk.cost > best.cost || (lk.cost == best.cost && k < key) {
+				key, best = k, lk
+			}
+		}
+		if best == nil || best.cost <= 0 {
+			break
+		}
+		b.load[heaviest] -= best.cost
+		b.load[lightest] += best.cost
+		best.min = lightest
+		moved[key] = lightest
+	}
+	return moved
+}
+
+// Resize changes the number of minutes. Keys in the minutes removed are
+// moved to the least loaded ones
+func (b *LoadBalance) Resize(max uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	load := make([]float64, max)
+	copy(load, b.load)
+	b.load = load
+	for _, lk := range b.keys {
+		if uint(lk.min) >= max {
+			lk.min = b.lightest()
+			b.load[lk.min] += lk.cost
+		}
+	}
+}
+
+// Loads returns the cost of every minute
+func (b *LoadBalance) Loads() []float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	load := make([]float64, len(b.load))
+	copy(load, b.load)
+	return load
+}
+
+// lightest returns the minute with the lowest cost, the first one if tied
+func (b *LoadBalance) lightest() Minute {
+	min := 0
+	for m := range b.load {
+		if b.load[m] < b.load[min] {
+			min = m
+		}
+	}
+	return Minute(min)
+}
+
+// heaviest returns the minute with the highest cost, the first one if tied
+func (b *LoadBalance) heaviest() Minute {
+	max := 0
+	for m := range b.load {
+		if b.load[m] > b.load[max] {
+			max = m
+		}
+	}
+	return Minute(max)
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// spread returns the difference between the most and the least loaded
+// minutes
+func spread(b *LoadBalance) float64 {
+	loads := b.Loads()
+	return loads[b.heaviest()] - loads[b.lightest()]
+}
+
+func TestLoadBalance(t *testing.T) {
+	t.Parallel()
+	b := StrategyLoad(3)
+
+	// keys go to the least loaded minute, the first one if tied
+	if m := b.KeyCost("a", 10); m != 0 {
+		t.Fatalf("expected a at min:0, got %d", m)
+	}
+	if m := b.KeyCost("b", 4); m != 1 {
+		t.Fatalf("expected b at min:1, got %d", m)
+	}
+	if m := b.KeyCost("c", 5); m != 2 {
+		t.Fatalf("expected c at min:2, got %d", m)
+	}
+	if m := b.KeyCost("d", 3); m != 1 {
+		t.Fatalf("expected d at min:1, got %d", m)
+	}
+	// known keys keep their minute
+	if m := b.Key("a"); m != 0 {
+		t.Fatalf("expected a to keep min:0, got %d", m)
+	}
+	if loads := b.Loads(); loads[0] != 10 || loads[1] != 7 || loads[2] != 5 {
+		t.Fatalf("unexpected loads %v", loads)
+	}
+
+	b.Remove("c")
+	if m := b.KeyCost("e", 1); m != 2 {
+		t.Fatalf("expected e at the min of the removed key, got %d", m)
+	}
+
+	// a single observation moves the cost half way
+	b.Observe("e", 21)
+	if loads := b.Loads(); loads[2] != 11 {
+		t.Fatalf("expected load 11 at min:2, got %v", loads)
+	}
+	b.Observe("unknown", 100)
+
+	b.Move("e", 0)
+	if loads := b.Loads(); loads[0] != 21 || loads[2] != 0 {
+		t.Fatalf("unexpected loads after move %v", loads)
+	}
+}
+
+func TestLoadBalanceRebalance(t *testing.T) {
+	t.Parallel()
+	keys := usernames[:120]
+	b := StrategyLoad(10)
+	for _, k := range keys {
+		b.Key(k)
+	}
+	// a few keys turn out to be much more expensive
+	for i, k := range keys {
+		if i%20 == 0 {
+			for j := 0; j < 8; j++ {
+				b.Observe(k, 20)
+			}
+		}
+	}
+	before := spread(b)
+	assigned := make(KeyToMinuteMap, len(keys))
+	for _, k := range keys {
+		assigned[k] = b.Key(k)
+	}
+
+	moved := b.Rebalance()
+	after := spread(b)
+	if after >= before || len(moved) == 0 {
+		t.Fatalf("expected rebalance to reduce the spread %.2f, got %.2f moving %d keys", before, after, len(moved))
+	}
+	if len(moved) > len(keys)/4 {
+		t.Fatalf("expected only a few keys to be moved, moved %d", len(moved))
+	}
+	for k, m := range moved {
+		if b.Key(k) != m || assigned[k] == m {
+			t.Fatalf("unexpected move of %s from min:%d to %d", k, assigned[k], m)
+		}
+	}
+	// balanced already
+	if again := b.Rebalance(); len(again) != 0 {
+		t.Fatalf("expected no moves after rebalancing, got\n%s", spew.Sdump(again))
+	}
+}
+
+func TestBalancedScheduleRebalance(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 4
+		pickInterval time.Duration = 10 * time.Millisecond
+		keys                       = usernames[:20]
+	)
+	b := StrategyLoad(cycleSize)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(len(keys)),
+		Freq:             pickInterval,
+		BalanceStrategy:  b,
+		RebalanceCycles:  1,
+	})
+	for i, k := range keys {
+		// the first keys are expensive and stacked in the same minutes
+		cost := 1.
+		if i < 4 {
+			cost = 10
+		}
+		bs.AddCost(k, cost)
+	}
+	bs.Start()
+	for m := range bs.RealTime() {
+		for _, k := range m.Objects {
+			if k == keys[0] || k == keys[1] {
+				bs.Observe(k, 40)
+			}
+		}
+		if m.Min == Minute(cycleSize-1) {
+			break
+		}
+	}
+	// the cycle after the first rebalance
+	for m := range bs.RealTime() {
+		if m.Min == Minute(cycleSize-1) {
+			break
+		}
+	}
+	bs.Remove(keys[19])
+	// let the remove op go through
+	<-bs.RealTime()
+	bs.Stop()
+
+	got := bs.UnsafeKeyToMinute()
+	if _, found := got[keys[19]]; found {
+		t.Fatalf("expected %s to be removed", keys[19])
+	}
+	if len(got) != len(keys)-1 {
+		t.Fatalf("expected %d keys, got %d", len(keys)-1, len(got))
+	}
+	for k, m := range got {
+		if b.Key(k) != m {
+			t.Fatalf("schedule and balancer disagree on %s: min:%d and min:%d", k, m, b.Key(k))
+		}
+	}
+	// the cheap keys were moved away from the expensive ones
+	for _, expensive := range keys[:2] {
+		for k, m := range got {
+			if k != expensive && m == got[expensive] {
+				t.Fatalf("expected %s alone in min:%d, found %s\n%v", expensive, m, k, b.Loads())
+			}
+		}
+	}
+}
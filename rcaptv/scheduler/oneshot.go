@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+type oneShot struct {
+	key string
+	at  time.Time
+}
+
+// enqueue adds the one-shot job of the key. A key has one job at most, the
+// earliest one
+func (s *Schedule) enqueue(key string, at time.Time) {
+	if prev, found := s.oneShots[key]; found && !at.Before(prev) {
+		return
+	}
+	s.oneShots[key] = at
+}
+
+// addOneShots adds the one-shot jobs due at rt.At to the objects of the
+// minute, oldest first and up to the cap of one-shots per minute. Jobs of
+// keys whose periodic run is close enough are dropped
+func (s *Schedule) addOneShots(rt *RealTimeMinute) {
+	if len(s.oneShots) == 0 {
+		return
+	}
+	due := make([]oneShot, 0, len(s.oneShots))
+	for k, at := range s.oneShots {
+		if !at.After(rt.At) {
+			due = append(due, oneShot{key: k, at: at})
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].at.Equal(due[j].at) {
+			return due[i].key < due[j].key
+		}
+		return due[i].at.Before(due[j].at)
+	})
+
+	periodic := make(map[string]struct{}, len(rt.Objects))
+	for _, k := range rt.Objects {
+		periodic[k] = struct{}{}
+	}
+	for _, job := range due {
+		if s.oneShotCap > 0 && len(rt.OneShots) >= s.oneShotCap {
+			// delayed to the next minutes
+			return
+		}
+		delete(s.oneShots, job.key)
+		if _, found := periodic[job.key]; found {
+			continue
+		}
+		run := s.nextRun(job.key, rt.Min, rt.At, s.freq, s.cycleSize)
+		if !run.IsZero() && run.Sub(rt.At) <= s.oneShotDedup {
+			continue
+		}
+		periodic[job.key] = struct{}{}
+		rt.Objects = append(rt.Objects, job.key)
+		rt.OneShots = append(rt.OneShots, job.key)
+	}
+}
+
+// Enqueue schedules a one-shot job of the key, delivered once with the first
+// minute picked at or after at, e.g. to refresh a key now or to retry it
+// later. The key doesn't need to be in the schedule. A key has one job
+// pending at most, the earliest one. One-shot jobs are listed in
+// RealTimeMinute.OneShots besides Objects, see
+// BalancedScheduleOpts.OneShotDedup and OneShotsPerMinute. In distributed
+// schedules jobs are delivered with the next minute run by the worker that
+// enqueued them.
+//
+// Enqueue only blocks while the ops queue is full. Enqueue is safe for
+// concurrent access
+func (bs *BalancedSchedule) Enqueue(key string, at time.Time) {
+	bs.send(&Op{
+		Typ: OpEnqueue,
+		Key: key,
+		At:  at,
+	})
+}
@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnqueue(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 10
+		pickInterval time.Duration = 20 * time.Millisecond
+		keys                       = usernames[:20]
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:         cycleSize,
+		EstimatedObjects:  uint(len(keys)),
+		Freq:              pickInterval,
+		OneShotsPerMinute: 2,
+	})
+	bs.AddMany(keys...)
+	bs.Start()
+	defer bs.Stop()
+	first := <-bs.RealTime()
+
+	jobs := make([]string, 5)
+	now := time.Now()
+	for i := range jobs {
+		jobs[i] = fmt.Sprintf("job%d", i)
+		// in reverse order of due time
+		bs.Enqueue(jobs[i], now.Add(-time.Duration(i)*time.Millisecond))
+	}
+	// later than the pending one, ignored
+	bs.Enqueue(jobs[0], now.Add(time.Hour))
+	bs.Flush()
+
+	var got []string
+	for _, want := range [][]string{{"job4", "job3"}, {"job2", "job1"}, {"job0"}, nil} {
+		m := <-bs.RealTime()
+		if !reflect.DeepEqual(m.OneShots, want) {
+			t.Fatalf("expected one-shots %v in min:%d, got %v", want, m.Min, m.OneShots)
+		}
+		for _, k := range want {
+			if !m.IsOneShot(k) || m.Objects[len(m.Objects)-len(want)] != want[0] {
+				t.Fatalf("expected %v at the end of the objects of min:%d, got %v", want, m.Min, m.Objects)
+			}
+		}
+		if len(m.Objects) != 2+len(want) {
+			t.Fatalf("expected the periodic keys of min:%d too, got %v", m.Min, m.Objects)
+		}
+		got = append(got, m.OneShots...)
+	}
+	if len(got) != len(jobs) {
+		t.Fatalf("expected every job delivered once, got %v", got)
+	}
+
+	// a job due later is delivered with the first minute at or after it
+	at := time.Now().Add(3 * pickInterval)
+	bs.Enqueue("later", at)
+	for {
+		m := <-bs.RealTime()
+		if m.IsOneShot("later") {
+			if m.At.Before(at) {
+				t.Fatalf("expected the job after %s, got it with min:%d at %s", at, m.Min, m.At)
+			}
+			break
+		}
+		if m.Min == first.Min {
+			t.Fatal("expected the job within a cycle")
+		}
+	}
+}
+
+func TestEnqueueDedup(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 10
+		pickInterval time.Duration = 20 * time.Millisecond
+		keys                       = usernames[:20]
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(len(keys)),
+		BalanceStrategy:  StrategyMurmur(uint32(cycleSize)),
+		Freq:             pickInterval,
+		OneShotDedup:     3 * pickInterval,
+	})
+	bs.AddMany(keys...)
+	bs.Start()
+	defer bs.Stop()
+	m := <-bs.RealTime()
+
+	// keys run periodically in the next minutes are dropped, the others are
+	// delivered with the next minute
+	schedule := bs.MinuteCounts()
+	var dropped, delivered []string
+	for _, k := range keys {
+		min, _ := bs.Lookup(k)
+		ahead := (uint(min) + cycleSize - uint(m.Min) - 1) % cycleSize
+		if ahead <= 3 {
+			dropped = append(dropped, k)
+		} else {
+			delivered = append(delivered, k)
+		}
+		bs.Enqueue(k, time.Now())
+	}
+	bs.Flush()
+	if len(dropped) == 0 || len(delivered) == 0 {
+		t.Fatalf("expected keys to drop and deliver, schedule:%v", schedule)
+	}
+	next := <-bs.RealTime()
+	if next.Min != m.Min+1 {
+		t.Skipf("min:%d skipped, the receiver was too slow", m.Min+1)
+	}
+	for _, k := range delivered {
+		if !next.IsOneShot(k) {
+			t.Fatalf("expected %s to be delivered with min:%d, got %v", k, next.Min, next.OneShots)
+		}
+	}
+	for _, k := range dropped {
+		if next.IsOneShot(k) {
+			t.Fatalf("expected %s to be dropped, its periodic run is close", k)
+		}
+	}
+}
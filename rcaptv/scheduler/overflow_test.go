@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/utils"
+)
+
+// busyConsumer receives a minute, stays busy for ticks minutes and returns
+// the first minute received and the next one
+func busyConsumer(t *testing.T, policy OverflowPolicy, backlog uint, ticks int) (*BalancedSchedule, RealTimeMinute, RealTimeMinute) {
+	t.Helper()
+	var (
+		cycleSize    uint          = 10
+		pickInterval time.Duration = 20 * time.Millisecond
+		keys                       = usernames[:40]
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(len(keys)),
+		Freq:             pickInterval,
+		Overflow:         policy,
+		Backlog:          backlog,
+	})
+	for _, k := range keys {
+		bs.Add(k)
+	}
+	bs.Start()
+	first := <-bs.RealTime()
+	time.Sleep(time.Duration(ticks)*pickInterval + pickInterval/2)
+	next := <-bs.RealTime()
+	bs.Stop()
+	return bs, first, next
+}
+
+func TestOverflowDrop(t *testing.T) {
+	t.Parallel()
+	bs, first, next := busyConsumer(t, OverflowDrop, 0, 3)
+	if next.Min == first.Min+1 {
+		t.Fatalf("expected the minutes after min:%d to be dropped", first.Min)
+	}
+	if got := bs.OverflowStats(); got.Dropped < 12 || got.Merged != 0 {
+		t.Fatalf("expected at least 3 minutes of keys dropped, got %+v", got)
+	}
+}
+
+func TestOverflowQueue(t *testing.T) {
+	t.Parallel()
+	bs, first, next := busyConsumer(t, OverflowQueue, 5, 3)
+	if next.Min != first.Min+1 {
+		t.Fatalf("expected min:%d after min:%d, got %d", first.Min+1, first.Min, next.Min)
+	}
+	if next.Late < 40*time.Millisecond {
+		t.Fatalf("expected min:%d to be queued for 2 ticks at least, late:%s", next.Min, next.Late)
+	}
+	if got := bs.OverflowStats(); got.Dropped != 0 {
+		t.Fatalf("expected no keys dropped, got %+v", got)
+	}
+
+	// a full backlog drops the oldest minutes
+	bs, first, next = busyConsumer(t, OverflowQueue, 2, 5)
+	if next.Min <= first.Min+1 {
+		t.Fatalf("expected the oldest minutes after min:%d to be dropped, got %d", first.Min, next.Min)
+	}
+	if got := bs.OverflowStats(); got.Dropped == 0 {
+		t.Fatalf("expected keys dropped, got %+v", got)
+	}
+}
+
+func TestOverflowMerge(t *testing.T) {
+	t.Parallel()
+	bs, first, next := busyConsumer(t, OverflowMerge, 0, 3)
+	if len(next.Merged) < 2 || next.Merged[0] != first.Min+1 {
+		t.Fatalf("expected the minutes after min:%d merged into min:%d, got %v", first.Min, next.Min, next.Merged)
+	}
+	for i, m := range next.Merged {
+		if m != first.Min+1+Minute(i) || !next.Includes(m) {
+			t.Fatalf("unexpected merged minutes %v after min:%d", next.Merged, first.Min)
+		}
+	}
+	sched := bs.UnsafeSchedule()
+	merged := 0
+	for _, m := range next.Merged {
+		merged += len(sched[m])
+		for _, k := range sched[m] {
+			if utils.Find(next.Objects, k) == -1 {
+				t.Fatalf("expected %s of min:%d in min:%d", k, m, next.Min)
+			}
+		}
+	}
+	if got := bs.OverflowStats(); got.Merged != int64(merged) || got.Dropped != 0 {
+		t.Fatalf("expected %d keys merged, got %+v", merged, got)
+	}
+	if len(next.Objects) != merged+len(sched[next.Min]) {
+		t.Fatalf("expected %d objects in min:%d, got %d", merged+len(sched[next.Min]), next.Min, len(next.Objects))
+	}
+}
@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"sync"
+)
+
+// defaultOpsQueue is the default max number of ops queued while the
+// scheduler is running
+const defaultOpsQueue = 1024
+
+// opQueue is the ordered queue of the ops applied by the worker. Ops are
+// applied in the order they were pushed. While the worker runs, push blocks
+// when the queue is full. Ops pushed while it is stopped are queued until it
+// starts again, e.g. the keys added before Start.
+//
+// opQueue is safe for concurrent access
+type opQueue struct {
+	mu sync.Mutex
+	// changed is broadcasted when ops are taken or applied and when the
+	// worker starts or stops
+	changed *sync.Cond
+	ops     []*Op
+	max     int
+	running bool
+	// ready notifies the worker that there are ops queued
+	ready chan struct{}
+	// pushed and applied count the ops, to know in Flush when the ops pushed
+	// before it were applied
+	pushed, applied uint64
+}
+
+func newOpQueue(max int) *opQueue {
+	q := &opQueue{
+		max:   max,
+		ready: make(chan struct{}, 1),
+	}
+	q.changed = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues the ops in order, waiting while the worker runs and the queue
+// is full
+func (q *opQueue) push(ops ...*Op) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, op := range ops {
+		for q.running && len(q.ops) >= q.max {
+			q.notify()
+			q.changed.Wait()
+		}
+		q.ops = append(q.ops, op)
+		q.pushed++
+	}
+	q.notify()
+}
+
+// notify notifies the worker without blocking. One notification pending is
+// enough, the worker takes every op queued
+func (q *opQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take returns every op queued, oldest first
+func (q *opQueue) take() []*Op {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ops := q.ops
+	q.ops = nil
+	q.changed.Broadcast()
+	return ops
+}
+
+// done marks n ops taken as applied
+func (q *opQueue) done(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.applied += uint64(n)
+	q.changed.Broadcast()
+}
+
+// setRunning is called by the worker when it starts and stops. Ops are
+// queued without bound while it is stopped
+func (q *opQueue) setRunning(running bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running = running
+	q.changed.Broadcast()
+}
+
+// flush waits until the ops pushed before it are applied or the worker
+// stops
+func (q *opQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	target := q.pushed
+	for q.running && q.applied < target {
+		q.changed.Wait()
+	}
+}
@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpsOrder(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize uint = 10
+		keys           = usernames[:200]
+		mu        sync.Mutex
+		applied   []Op
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(len(keys)),
+		BalanceStrategy:  StrategyMurmur(uint32(cycleSize)),
+		Freq:             time.Hour,
+		OpsQueue:         8,
+		AfterOp: func(op *Op) {
+			mu.Lock()
+			defer mu.Unlock()
+			applied = append(applied, *op)
+		},
+	})
+	// queued until the scheduler starts, beyond the size of the queue
+	bs.AddMany(keys...)
+	bs.Flush()
+	bs.Start()
+
+	var sent []Op
+	for i, k := range keys {
+		// every key is removed right after being added, and the even keys are
+		// added again
+		bs.Remove(k)
+		sent = append(sent, Op{Typ: OpRemove, Key: k})
+		if i%2 == 0 {
+			bs.Add(k)
+			sent = append(sent, Op{Typ: OpAdd, Key: k})
+		}
+	}
+	bs.Flush()
+
+	mu.Lock()
+	if len(applied) != len(keys)+len(sent) {
+		mu.Unlock()
+		t.Fatalf("expected %d ops applied after Flush, got %d", len(keys)+len(sent), len(applied))
+	}
+	for i, op := range applied[:len(keys)] {
+		if op.Typ != OpAdd || op.Key != keys[i] {
+			t.Fatalf("expected add %s as op %d, got %+v", keys[i], i, op)
+		}
+	}
+	for i, op := range applied[len(keys):] {
+		if op.Typ != sent[i].Typ || op.Key != sent[i].Key {
+			t.Fatalf("expected op %d to be %+v, got %+v", i, sent[i], op)
+		}
+	}
+	mu.Unlock()
+
+	for i, k := range keys {
+		if scheduled := !bs.NextRun(k).IsZero(); scheduled != (i%2 == 0) {
+			t.Fatalf("unexpected %s scheduled:%t", k, scheduled)
+		}
+	}
+	bs.Stop()
+	// the scheduler is stopped, ops are applied when it starts again
+	bs.RemoveMany(keys...)
+	bs.Flush()
+	if got := len(bs.UnsafeKeyToMinute()); got != len(keys)/2 {
+		t.Fatalf("expected %d keys before restarting, got %d", len(keys)/2, got)
+	}
+	bs.Start()
+	bs.Flush()
+	bs.Stop()
+	if got := bs.UnsafeKeyToMinute(); len(got) != 0 {
+		t.Fatalf("expected every key removed, got %d", len(got))
+	}
+}
+
+func TestOpsGoroutines(t *testing.T) {
+	var (
+		cycleSize uint = 60
+		n              = 20000
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(n),
+		BalanceStrategy:  StrategyMurmur(uint32(cycleSize)),
+		Freq:             time.Hour,
+		OpsQueue:         16,
+	})
+	bs.Start()
+	defer bs.Stop()
+
+	before := runtime.NumGoroutine()
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%d", i)
+		bs.Add(keys[i])
+	}
+	bs.AddMany(keys...)
+	if after := runtime.NumGoroutine(); after > before+2 {
+		t.Fatalf("expected no goroutines per op, got %d before and %d after", before, after)
+	}
+	bs.Flush()
+	if after := runtime.NumGoroutine(); after > before+2 {
+		t.Fatalf("expected no goroutines per op, got %d before and %d after", before, after)
+	}
+}
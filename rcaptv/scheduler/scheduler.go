@@ -5,6 +5,7 @@ import (
 	"math"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -17,6 +18,13 @@ type KeyBalancer interface {
 	Key(k string) Minute
 }
 
+// ResizableBalancer is a KeyBalancer whose number of minutes can be changed.
+// New resizes the strategy if it shrinks the cycle size
+type ResizableBalancer interface {
+	KeyBalancer
+	Resize(max uint)
+}
+
 // CountBalance is a key balancer that simply counts the keys up to a maximum
 // value. The load and keys will be 1:1 if the load = number of keys, that is,
 // for 200 keys we will have 200 assignations where each key is assigned to a
@@ -39,6 +47,10 @@ func (b *CountBalance) Key(k string) Minute {
 	return m
 }
 
+func (b *CountBalance) Resize(max uint) {
+	b.max = max
+}
+
 func StrategyCount(max uint) *CountBalance {
 	return &CountBalance{
 		max: max,
@@ -69,12 +81,55 @@ func (b *MurmurBalance) Key(k string) Minute {
 	return Minute(murmur(k) % b.max)
 }
 
+func (b *MurmurBalance) Resize(max uint) {
+	b.max = uint32(max)
+}
+
 func StrategyMurmur(max uint32) *MurmurBalance {
 	return &MurmurBalance{
 		max: max,
 	}
 }
 
+// JumpBalance uses the jump consistent hash of Lamping and Veach over the
+// murmur3 hash of the key. Like MurmurBalance it is deterministic and supports
+// Remove(), but changing the number of minutes only moves the keys that must
+// move: growing from n to n+1 minutes moves 1/(n+1) of the keys to the new
+// minute and shrinking moves only the keys of the removed minutes, while
+// MurmurBalance reassigns almost every key.
+//
+// Load distribution is similar to MurmurBalance. Use it if the cycle size may
+// change, e.g. when it is shrunk to EstimatedObjects
+type JumpBalance struct {
+	max uint32
+}
+
+func (b *JumpBalance) Key(k string) Minute {
+	return Minute(jump(murmur3.Sum64(utils.StringToByte(k)), b.max))
+}
+
+func (b *JumpBalance) Resize(max uint) {
+	b.max = uint32(max)
+}
+
+func StrategyJump(max uint32) *JumpBalance {
+	return &JumpBalance{
+		max: max,
+	}
+}
+
+// jump returns the bucket in [0, buckets) of the key, see "A Fast, Minimal
+// Memory, Consistent Hash Algorithm" (arXiv:1406.2294)
+func jump(key uint64, buckets uint32) uint32 {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return uint32(b)
+}
+
 type Minute uint
 
 func (m Minute) String() string {
@@ -83,6 +138,37 @@ func (m Minute) String() string {
 
 const ResetMinute = Minute(0)
 
+// OverflowPolicy is what a BalancedSchedule does with a minute when the
+// receiver of RealTime is still busy with the previous one
+type OverflowPolicy int
+
+const (
+	// OverflowDrop drops the minute
+	OverflowDrop OverflowPolicy = iota
+	// OverflowQueue queues the minute in a bounded backlog, dropping the
+	// oldest minute queued when full
+	OverflowQueue
+	// OverflowMerge merges the objects of the minute into the next one
+	OverflowMerge
+)
+
+// defaultBacklog is the default max number of minutes queued by
+// OverflowQueue
+const defaultBacklog = 10
+
+// OverflowStats are the number of keys not delivered in their minute because
+// the receiver of RealTime was busy, see BalancedScheduleOpts.Overflow
+type OverflowStats struct {
+	// Dropped keys were never delivered
+	Dropped int64
+	// Merged keys were delivered with a later minute
+	Merged int64
+}
+
+// storeTimeout is the max duration of the last save of the state when the
+// scheduler stops
+const storeTimeout = 5 * time.Second
+
 type BalancedScheduleOpts struct {
 	// After a full cycle, every streamer will have been chosen by Pick()
 	CycleSize uint
@@ -95,14 +181,31 @@ type BalancedScheduleOpts struct {
 	// determined CycleSize and as more objects are added, the cycle will take
 	// longer to complete until the CycleSize is reached and the load is
 	// balanced.
+	//
+	// The CycleSize of distributed schedules is never shrunk, every worker
+	// may see a different estimation and it must be the same for all of them.
 	EstimatedObjects uint
 
 	// Freq changes scheduler real-time pick interval. Useful for testing. Not
 	// recommended for real use cases since minutes is how rate limiting is
 	// calculated. BalancedSchedule specifically mentions minutes instead of a
 	// generic duration unit for ease of use.
+	//
+	// A Minute is really a slot of Freq and CycleSize the number of slots per
+	// cycle, e.g. Freq of 10s and CycleSize of 6*720 for a 12h cycle with a
+	// finer resolution. To smooth the request rate inside every minute
+	// without changing the cycle see Spread instead
 	Freq time.Duration
 
+	// Spread spreads the objects of every minute evenly inside it instead of
+	// having all of them due at the start of the minute. The receiver of
+	// RealTime waits for every object with RealTimeMinute.Wait
+	Spread bool
+
+	// Clock is the source of time of the scheduler. Defaults to the wall
+	// clock. Useful for testing
+	Clock Clock
+
 	// The load balancer strategy.
 	//
 	// - CountBalance: excelent distribution for any length size, deterministic
@@ -112,18 +215,131 @@ type BalancedScheduleOpts struct {
 	// - MurmurBalance: good distribution especially in large numbers, stochastic
 	// load distribution, deterministic key assignment. Objects are guarantee
 	// to have the same min assigned as long as the cycle size is the same
+	//
+	// - JumpBalance: like MurmurBalance, but most objects keep their min when
+	// the cycle size changes
+	//
+	// - LoadBalance: weighted, objects are assigned to the min with the lowest
+	// cost and moved when their observed cost changes, see AddCost, Observe
+	// and RebalanceCycles. Not suitable for distributed schedules, every worker
+	// would observe different costs
 	BalanceStrategy KeyBalancer
 
+	// RebalanceCycles rebalances the keys of a WeightedBalancer strategy
+	// every RebalanceCycles cycles, after picking the last minute of the
+	// cycle. Zero disables periodic rebalancing, see Rebalance
+	RebalanceCycles uint
+
 	// Salt to be appended to keys.
 	Salt string
 
 	// Hook to be run after processing each op. Useful for testing
 	AfterOp func(op *Op)
+
+	// Store persists the cursor and the minute of every key. If set, the
+	// scheduler resumes at the minute of the cycle it would be at if it had
+	// not been stopped, and the keys added again keep their stored minute
+	// instead of the balanced one, so every key keeps its real interval
+	// across restarts. Stored keys not added again within a cycle are removed
+	// from the store. Optional
+	Store Store
+
+	// Aligned aligns the cycle to the wall clock instead of starting it when
+	// the scheduler starts: the minute m of the cycle is picked when the
+	// number of minutes (or Freq units) since the Unix epoch mod CycleSize is
+	// m. Keys keep their real interval across restarts and any process
+	// knowing the minute of a key can tell when it runs next with
+	// AlignedNextRun. The cursor of the Store is ignored
+	Aligned bool
+
+	// Coordinator distributes the schedule across several workers, e.g. the
+	// same service running in several hosts. Every worker must add the same
+	// keys. The minutes of the cycle are sharded across the live workers and
+	// rebalanced when a worker joins, leaves or dies, so every run is picked
+	// by exactly one worker. If a worker dies while running a minute, the
+	// minute is picked again by another one. The receiver of RealTime must
+	// call RealTimeMinute.Done after running each minute. Implies Aligned.
+	// Requires a deterministic BalanceStrategy, MurmurBalance or JumpBalance,
+	// so every worker assigns the same minute to a key whatever the order the
+	// keys are added in. Optional
+	Coordinator Coordinator
+
+	// Overflow is what to do with a minute when the receiver of RealTime is
+	// still busy with the previous one. Ignored by distributed schedules,
+	// which release the minute to other workers. Defaults to OverflowDrop
+	Overflow OverflowPolicy
+	// Backlog is the max number of minutes queued by OverflowQueue. Defaults
+	// to 10
+	Backlog uint
+
+	// OpsQueue is the max number of ops (Add, Remove...) queued while the
+	// scheduler is running. Ops block while the queue is full. Ops before
+	// Start are queued without bound. Defaults to 1024
+	OpsQueue uint
+
+	// OneShotDedup drops the one-shot jobs of keys whose periodic minute is
+	// picked within OneShotDedup of the minute the job is delivered with, see
+	// Enqueue. Jobs of keys already in that minute are always dropped
+	OneShotDedup time.Duration
+	// OneShotsPerMinute is the max number of one-shot jobs delivered with a
+	// minute, so bursts don't break rate limits. The rest are delayed to the
+	// next minutes, oldest first. Zero is unlimited
+	OneShotsPerMinute uint
 }
 
 type RealTimeMinute struct {
-	Min     Minute
+	Min Minute
+	// At is when the minute was scheduled
+	At time.Time
+	// Late is how late the minute was delivered, accurate to Freq. Minutes
+	// queued or merged while the receiver was busy are late
+	Late time.Duration
+	// Merged are the earlier minutes whose objects were merged into this one
+	// by OverflowMerge, in order
+	Merged  []Minute
 	Objects []string
+	// OneShots are the objects that are one-shot jobs instead of periodic
+	// keys, see BalancedSchedule.Enqueue
+	OneShots []string
+
+	done func()
+	// own is the number of objects of Min, without the merged ones
+	own int
+	// interval is the time between objects if they are spread
+	interval time.Duration
+	clock    Clock
+}
+
+// IsOneShot returns true if the object is a one-shot job
+func (m RealTimeMinute) IsOneShot(key string) bool {
+	for _, k := range m.OneShots {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Includes returns true if the objects of the minute min were delivered with
+// this one, as Min or merged
+func (m RealTimeMinute) Includes(min Minute) bool {
+	if m.Min == min {
+		return true
+	}
+	for _, merged := range m.Merged {
+		if merged == min {
+			return true
+		}
+	}
+	return false
+}
+
+// Done marks the minute as run. Only needed by distributed schedules, see
+// BalancedScheduleOpts.Coordinator
+func (m RealTimeMinute) Done() {
+	if m.done != nil {
+		m.done()
+	}
 }
 
 type schedulerOp int
@@ -131,19 +347,28 @@ type schedulerOp int
 const (
 	OpAdd schedulerOp = iota
 	OpRemove
+	OpRebalance
+	OpEnqueue
 )
 
 type Op struct {
 	Typ schedulerOp
 	Key string
 	Min Minute
+	// At is when a one-shot job of OpEnqueue is due
+	At time.Time
+}
+
+type nextRunReq struct {
+	key   string
+	reply chan time.Time
 }
 
 type (
 	ScheduleMap    map[Minute][]string
 	KeyToMinuteMap map[string]Minute
-	opsChan        chan *Op
 	pickChan       chan []string
+	nextRunChan    chan *nextRunReq
 )
 
 // Schedule is a schedule map.
@@ -154,29 +379,187 @@ type Schedule struct {
 	schedule ScheduleMap
 	// denormalize. More memory required, O(1) Add operations
 	keyToMin KeyToMinuteMap
-	ops      opsChan
+	ops      *opQueue
 	picks    pickChan
+	nextRuns nextRunChan
+	inspects inspectChan
 
 	realTime chan RealTimeMinute
 
 	readyCh chan struct{}
 	afterOp func(op *Op)
+	aligned bool
+	coord   Coordinator
+
+	// last is the last minute picked, if picked
+	last   Minute
+	picked bool
+
+	clock  Clock
+	spread bool
+
+	// oneShots are the one-shot jobs pending by key and when they are due
+	oneShots     map[string]time.Time
+	oneShotDedup time.Duration
+	oneShotCap   int
+	freq         time.Duration
+	cycleSize    uint
+
+	overflow    OverflowPolicy
+	backlogSize int
+	// backlog are the minutes not delivered yet by OverflowQueue and
+	// OverflowMerge
+	backlog []RealTimeMinute
+	dropped atomic.Int64
+	merged  atomic.Int64
+
+	weighted        WeightedBalancer
+	rebalanceCycles uint
+	cycles          uint
+
+	store  Store
+	loaded bool
+	// cursor is the last minute picked. nil if none was picked yet
+	cursor *Cursor
+	// restored are the keys loaded from the store and not added again yet.
+	// They are removed from the store after restoredTicks reaches the cycle
+	// size
+	restored      KeyToMinuteMap
+	restoredTicks uint
+	// changes since the last save
+	added   KeyToMinuteMap
+	removed map[string]struct{}
 }
 
-func (s *Schedule) add(min Minute, key string) {
+// add adds the key to min if not already in the schedule, or to its
+// restored minute if any. Returns the minute of the key
+func (s *Schedule) add(min Minute, key string) Minute {
 	// O(1)
-	if _, found := s.keyToMin[key]; !found {
-		s.schedule[min] = append(s.schedule[min], key)
-		s.keyToMin[key] = min
+	if m, found := s.keyToMin[key]; found {
+		return m
+	}
+	if m, found := s.restored[key]; found {
+		// already stored
+		delete(s.restored, key)
+		min = m
+	} else if s.store != nil {
+		s.added[key] = min
+		delete(s.removed, key)
 	}
+	s.schedule[min] = append(s.schedule[min], key)
+	s.keyToMin[key] = min
+	return min
 }
 
-func (s *Schedule) remove(min Minute, key string) {
+func (s *Schedule) remove(key string) {
+	if s.store != nil {
+		delete(s.restored, key)
+		delete(s.added, key)
+		s.removed[key] = struct{}{}
+	}
+	min, found := s.keyToMin[key]
+	if !found {
+		return
+	}
 	// O(n); n = len(s.schedule[min])
 	s.schedule[min] = utils.RemoveKey(s.schedule[min], key)
 	delete(s.keyToMin, key)
 }
 
+// resume loads the state of the store the first time the worker runs and
+// returns the minute to pick next
+func (s *Schedule) resume(ctx context.Context, freq time.Duration, cycleSize uint) Minute {
+	l := log.With().Str("ctx", "scheduler").Logger()
+	if !s.loaded {
+		s.loaded = true
+		state, err := s.store.Load(ctx)
+		if err != nil {
+			l.Err(err).Msg("could not load the schedule state, starting a new cycle")
+		}
+		if state != nil && state.Cursor.CycleSize == cycleSize {
+			s.cursor = &state.Cursor
+			s.restored = state.KeyToMin
+		} else if state != nil {
+			l.Warn().Msgf("cycle size changed from %d to %d, starting a new cycle", state.Cursor.CycleSize, cycleSize)
+			for k := range state.KeyToMin {
+				s.removed[k] = struct{}{}
+			}
+		}
+	}
+	if s.cursor == nil || s.aligned {
+		return ResetMinute
+	}
+	m := s.cursor.Next(s.clock.Now(), freq, cycleSize)
+	l.Info().Msgf("resuming schedule at min:%d (last:%d at %s)", m, s.cursor.Min, s.cursor.At.Format(time.RFC3339))
+	return m
+}
+
+// save stores the cursor and the keys changed since the last save
+func (s *Schedule) save(ctx context.Context) {
+	if s.cursor == nil {
+		return
+	}
+	if s.restored != nil {
+		s.restoredTicks++
+		if s.restoredTicks >= s.cursor.CycleSize {
+			// a full cycle went by, the keys not added again are gone
+			for k := range s.restored {
+				s.removed[k] = struct{}{}
+			}
+			s.restored = nil
+		}
+	}
+	removed := make([]string, 0, len(s.removed))
+	for k := range s.removed {
+		removed = append(removed, k)
+	}
+	if err := s.store.Save(ctx, *s.cursor, s.added, removed); err != nil {
+		l := log.With().Str("ctx", "scheduler").Logger()
+		l.Err(err).Msg("could not save the schedule state")
+		return
+	}
+	s.added = make(KeyToMinuteMap)
+	s.removed = make(map[string]struct{})
+}
+
+// move moves the key to min if it is in the schedule
+func (s *Schedule) move(key string, min Minute) {
+	old, found := s.keyToMin[key]
+	if !found || old == min {
+		return
+	}
+	s.schedule[old] = utils.RemoveKey(s.schedule[old], key)
+	s.schedule[min] = append(s.schedule[min], key)
+	s.keyToMin[key] = min
+	if s.store != nil {
+		s.added[key] = min
+		delete(s.removed, key)
+	}
+}
+
+// rebalance moves the keys rebalanced by the weighted balancer
+func (s *Schedule) rebalance() {
+	moved := s.weighted.Rebalance()
+	for k, m := range moved {
+		s.move(k, m)
+	}
+	if len(moved) > 0 {
+		l := log.With().Str("ctx", "scheduler").Logger()
+		l.Info().Msgf("rebalanced schedule, moved %d keys", len(moved))
+	}
+}
+
+// endCycle is run after picking the last minute of the cycle
+func (s *Schedule) endCycle() {
+	if s.weighted == nil || s.rebalanceCycles == 0 {
+		return
+	}
+	s.cycles++
+	if s.cycles%s.rebalanceCycles == 0 {
+		s.rebalance()
+	}
+}
+
 func (s *Schedule) pick(min Minute) []string {
 	orig := s.schedule[min]
 	clone := make([]string, len(orig))
@@ -184,39 +567,195 @@ func (s *Schedule) pick(min Minute) []string {
 	return clone
 }
 
+// deliver delivers the minute to the realTime channel or applies the
+// overflow policy if the receiver is busy. Queued and merged minutes are
+// delivered by the worker when the receiver is ready
+func (s *Schedule) deliver(rt RealTimeMinute) {
+	l := log.With().Str("ctx", "scheduler").Logger()
+	rt.own = len(rt.Objects)
+	switch s.overflow {
+	case OverflowQueue:
+		if len(s.backlog) >= s.backlogSize {
+			oldest := s.backlog[0]
+			s.backlog = s.backlog[1:]
+			s.dropped.Add(int64(oldest.own))
+			l.Warn().Msgf("WARN: discarding minute (min:%d) because the 'realTime' backlog is full.", oldest.Min)
+		}
+		s.backlog = append(s.backlog, rt)
+	case OverflowMerge:
+		if len(s.backlog) == 0 {
+			s.backlog = append(s.backlog, rt)
+			return
+		}
+		prev := s.backlog[0]
+		rt.Merged = append(prev.Merged, prev.Min)
+		rt.Objects = mergeObjects(prev.Objects, rt.Objects)
+		rt.OneShots = mergeObjects(prev.OneShots, rt.OneShots)
+		s.spreadObjects(&rt)
+		s.merged.Add(int64(prev.own))
+		s.backlog[0] = rt
+		l.Warn().Msgf("WARN: merging minute (min:%d) into min:%d because 'realTime' channel is blocked.", prev.Min, rt.Min)
+	default:
+		rt.Late = s.clock.Now().Sub(rt.At)
+		select {
+		case s.realTime <- rt:
+		default:
+			s.dropped.Add(int64(rt.own))
+			l.Warn().Msgf("WARN: discarding minute (min:%d) because 'realTime' channel is blocked.", rt.Min)
+		}
+	}
+}
+
+// mergeObjects returns the objects of both minutes without duplicates, e.g.
+// keys moved by a rebalance
+func mergeObjects(prev, next []string) []string {
+	merged := make([]string, 0, len(prev)+len(next))
+	seen := make(map[string]struct{}, len(prev)+len(next))
+	for _, objs := range [][]string{prev, next} {
+		for _, o := range objs {
+			if _, found := seen[o]; found {
+				continue
+			}
+			seen[o] = struct{}{}
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
 func (s *Schedule) RealTime() <-chan RealTimeMinute {
 	return s.realTime
 }
 
+// nextRun returns when the key is picked if the minute m is picked at t. Zero
+// if the key is not in the schedule
+func (s *Schedule) nextRun(key string, m Minute, t time.Time, freq time.Duration, cycleSize uint) time.Time {
+	min, found := s.keyToMin[key]
+	if !found {
+		return time.Time{}
+	}
+	return runAfter(min, m, t, freq, cycleSize)
+}
+
+// apply applies the op to the schedule
+func (s *Schedule) apply(op *Op) {
+	switch op.Typ {
+	case OpAdd:
+		min := s.add(op.Min, op.Key)
+		if min != op.Min && s.weighted != nil {
+			// e.g. restored from the store, the cost goes to its minute
+			s.weighted.Move(op.Key, min)
+		}
+		op.Min = min
+	case OpRemove:
+		s.remove(op.Key)
+	case OpRebalance:
+		if s.weighted != nil {
+			s.rebalance()
+		}
+	case OpEnqueue:
+		s.enqueue(op.Key, op.At)
+	}
+}
+
 // Worker of schedule.
 //
 // IMPORTANT: only this goroutine should access contents at the same time
 func (s *Schedule) Worker(ctx context.Context, freq time.Duration, cycleSize uint) {
 	l := log.With().Str("ctx", "scheduler").Logger()
-	ticker := time.NewTicker(freq)
-	defer ticker.Stop()
+	s.ops.setRunning(true)
+	// ops sent from now on are applied on the next start
+	defer s.ops.setRunning(false)
+	s.freq, s.cycleSize = freq, cycleSize
 	m := ResetMinute
+	if s.store != nil {
+		m = s.resume(ctx, freq, cycleSize)
+	}
 	max := Minute(cycleSize - 1)
 
+	// nextAt is when m is picked. The timer is re-armed at every tick to
+	// nextAt instead of using a ticker, which would drift from the wall clock
+	// in aligned mode
+	nextAt := s.clock.Now().Add(freq)
+	if s.aligned {
+		nextAt = alignedNext(s.clock.Now(), freq)
+		m = AlignedMinute(nextAt, freq, cycleSize)
+	}
+	timer := s.clock.NewTimer(nextAt.Sub(s.clock.Now()))
+	defer timer.Stop()
+
 	once := make(chan struct{}, 1)
 	for {
+		// the oldest minute of the backlog, if any, is sent when the receiver
+		// is ready. out is nil otherwise, which never sends
+		var (
+			out  chan<- RealTimeMinute
+			head RealTimeMinute
+		)
+		if len(s.backlog) > 0 {
+			out, head = s.realTime, s.backlog[0]
+			head.Late = s.clock.Now().Sub(head.At)
+		}
 		select {
 		case <-ctx.Done():
+			if s.coord != nil {
+				leaveCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+				if err := s.coord.Leave(leaveCtx); err != nil {
+					l.Err(err).Msg("could not leave the distributed schedule")
+				}
+				cancel()
+			}
+			if s.store != nil {
+				// keys added since the last tick
+				saveCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+				s.save(saveCtx)
+				cancel()
+			}
 			return
-		case op := <-s.ops:
-			switch op.Typ {
-			case OpAdd:
-				s.add(op.Min, op.Key)
-			case OpRemove:
-				s.remove(op.Min, op.Key)
+		case <-s.ops.ready:
+			ops := s.ops.take()
+			for _, op := range ops {
+				s.apply(op)
+				s.afterOp(op)
 			}
-			s.afterOp(op)
-		case <-ticker.C:
-			select {
-			case s.realTime <- RealTimeMinute{Min: m, Objects: s.pick(m)}:
-			default:
-				l.Warn().Msgf("WARN: discarding minute (min:%d) because 'realTime' channel is blocked.", m)
+			s.ops.done(len(ops))
+		case out <- head:
+			s.backlog = s.backlog[1:]
+		case req := <-s.nextRuns:
+			req.reply <- s.nextRun(req.key, m, nextAt, freq, cycleSize)
+		case req := <-s.inspects:
+			req.fn(m, nextAt)
+			close(req.done)
+		case now := <-timer.C():
+			s.last, s.picked = m, true
+			if s.coord != nil {
+				slot := nextAt.UnixNano() / int64(freq)
+				s.distributedPick(ctx, m, slot, nextAt, freq, cycleSize)
+			} else {
+				rt := RealTimeMinute{Min: m, At: nextAt, Objects: s.pick(m)}
+				s.addOneShots(&rt)
+				s.spreadObjects(&rt)
+				s.deliver(rt)
+			}
+			if s.store != nil {
+				s.cursor = &Cursor{Min: m, At: now, CycleSize: cycleSize}
+				s.save(ctx)
+			}
+			if m == max {
+				s.endCycle()
 			}
+			if s.aligned {
+				nextAt = alignedNext(now, freq)
+				m = AlignedMinute(nextAt, freq, cycleSize)
+				timer.Reset(nextAt.Sub(s.clock.Now()))
+				continue
+			}
+			// like a ticker, ticks missed by a slow worker are skipped
+			nextAt = nextAt.Add(freq)
+			if !nextAt.After(now) {
+				nextAt = now.Add(freq)
+			}
+			timer.Reset(nextAt.Sub(s.clock.Now()))
 			if m >= max {
 				m = ResetMinute
 			} else {
@@ -285,42 +824,115 @@ type BalancedSchedule struct {
 	opts BalancedScheduleOpts
 }
 
-func (bs *BalancedSchedule) send(op *Op) {
-	// execute in a different goroutine to prevent the current goroutine from
-	// blocking. It also holds only the needed values to keep memory footprint as
-	// small as possible if blocking go-routines start to pile up. We could have
-	// the same effect with a buffered channel, but then we would have to keep an
-	// eye on the buffer size
-	go func(op *Op) {
-		bs.internal.ops <- op
-	}(op)
+// send queues the ops, which are applied by the worker in order. It blocks
+// while the scheduler is running and the queue is full, see
+// BalancedScheduleOpts.OpsQueue
+func (bs *BalancedSchedule) send(ops ...*Op) {
+	bs.internal.ops.push(ops...)
 }
 
-// Add adds the key element to the schedule if it is not already in it. Add is
-// not a blocking op. Add is safe for concurrent access
+func (bs *BalancedSchedule) addOp(key string) *Op {
+	return &Op{
+		Typ: OpAdd,
+		Key: key,
+		Min: bs.BalancedMin(key),
+	}
+}
+
+func (bs *BalancedSchedule) removeOp(key string) *Op {
+	if w, ok := bs.opts.BalanceStrategy.(WeightedBalancer); ok {
+		w.Remove(key)
+		return &Op{Typ: OpRemove, Key: key}
+	}
+	return &Op{
+		Typ: OpRemove,
+		Key: key,
+		Min: bs.BalancedMin(key),
+	}
+}
+
+// Add adds the key element to the schedule if it is not already in it. Add
+// only blocks while the ops queue is full. Ops are applied in order. Add is
+// safe for concurrent access
 func (bs *BalancedSchedule) Add(key string) {
+	bs.send(bs.addOp(key))
+}
+
+// AddMany adds the keys like Add, in order
+func (bs *BalancedSchedule) AddMany(keys ...string) {
+	ops := make([]*Op, 0, len(keys))
+	for _, k := range keys {
+		ops = append(ops, bs.addOp(k))
+	}
+	bs.send(ops...)
+}
+
+// AddCost adds the key element to the schedule like Add, with an estimation
+// of its cost if the balancer strategy is a WeightedBalancer, e.g. the number
+// of requests needed to process it. The key is assigned to the minute with
+// the lowest cost. AddCost is safe for concurrent access
+func (bs *BalancedSchedule) AddCost(key string, cost float64) {
+	w, ok := bs.opts.BalanceStrategy.(WeightedBalancer)
+	if !ok {
+		bs.Add(key)
+		return
+	}
 	bs.send(&Op{
 		Typ: OpAdd,
 		Key: key,
-		Min: bs.BalancedMin(key),
+		Min: w.KeyCost(key, cost),
 	})
 }
 
-// Remove removes the key element from the schedule. Remove is not a blocking
-// op. Remove is safe for concurrent access.
+// Observe feeds the cost observed when processing the key back to the
+// WeightedBalancer strategy. Keys are not moved until the schedule is
+// rebalanced. No-op for other strategies. Observe is safe for concurrent
+// access
+func (bs *BalancedSchedule) Observe(key string, cost float64) {
+	if w, ok := bs.opts.BalanceStrategy.(WeightedBalancer); ok {
+		w.Observe(key, cost)
+	}
+}
+
+// Rebalance moves keys to even the cost of the minutes if the balancer
+// strategy is a WeightedBalancer. Rebalance only blocks while the ops queue is
+// full. Rebalance is safe for concurrent access
+func (bs *BalancedSchedule) Rebalance() {
+	bs.send(&Op{Typ: OpRebalance})
+}
+
+// Remove removes the key element from the schedule. Remove only blocks while
+// the ops queue is full. Ops are applied in order, a key added and then
+// removed is not in the schedule. Remove is safe for concurrent access.
 //
 // IMPORTANT: For this feature to work a deterministic balancer is required.
 // Use e.g. StrategyMurmur not StrategyCount. If you only add items you can
-// use StrategyCount.
+// use StrategyCount. StrategyLoad also supports it
 func (bs *BalancedSchedule) Remove(key string) {
-	bs.send(&Op{
-		Typ: OpRemove,
-		Key: key,
-		Min: bs.BalancedMin(key),
-	})
+	bs.send(bs.removeOp(key))
+}
+
+// RemoveMany removes the keys like Remove, in order
+func (bs *BalancedSchedule) RemoveMany(keys ...string) {
+	ops := make([]*Op, 0, len(keys))
+	for _, k := range keys {
+		ops = append(ops, bs.removeOp(k))
+	}
+	bs.send(ops...)
+}
+
+// Flush waits until the ops sent before it are applied. It returns right
+// away if the scheduler is not running, ops sent meanwhile are applied when
+// it starts. Flush is safe for concurrent access
+func (bs *BalancedSchedule) Flush() {
+	bs.internal.ops.flush()
 }
 
 func (bs *BalancedSchedule) BalancedMin(key string) Minute {
+	if _, ok := bs.opts.BalanceStrategy.(WeightedBalancer); ok {
+		// weighted balancers track the keys to move them
+		return bs.opts.BalanceStrategy.Key(key)
+	}
 	if bs.salt != "" {
 		return bs.opts.BalanceStrategy.Key(key + bs.salt)
 	}
@@ -331,6 +943,33 @@ func (bs *BalancedSchedule) RealTime() <-chan RealTimeMinute {
 	return bs.internal.RealTime()
 }
 
+// NextRun returns when the key will be picked next. It is zero if the key is
+// not in the schedule or the scheduler is not running. Keys added are not
+// in the schedule until the add op is processed. NextRun is safe for
+// concurrent access
+func (bs *BalancedSchedule) NextRun(key string) time.Time {
+	ctx := bs.context()
+	if ctx == nil {
+		return time.Time{}
+	}
+	req := &nextRunReq{key: key, reply: make(chan time.Time, 1)}
+	select {
+	case bs.internal.nextRuns <- req:
+	case <-ctx.Done():
+		return time.Time{}
+	}
+	return <-req.reply
+}
+
+// OverflowStats returns the number of keys dropped and merged since the
+// schedule was created. OverflowStats is safe for concurrent access
+func (bs *BalancedSchedule) OverflowStats() OverflowStats {
+	return OverflowStats{
+		Dropped: bs.internal.dropped.Load(),
+		Merged:  bs.internal.merged.Load(),
+	}
+}
+
 func (bs *BalancedSchedule) CycleSize() uint {
 	return bs.opts.CycleSize
 }
@@ -365,6 +1004,8 @@ func (bs *BalancedSchedule) UnsafeKeyToMinute() KeyToMinuteMap {
 func (bs *BalancedSchedule) Start() {
 	bs.resetContext(false)
 	ctx := bs.context()
+	// before the worker starts, so a Flush right after Start waits for it
+	bs.internal.ops.setRunning(true)
 	go func() {
 		bs.internal.Worker(ctx, bs.opts.Freq, bs.opts.CycleSize)
 		bs.resetContext(true)
@@ -400,6 +1041,16 @@ func (bs *BalancedSchedule) Stop() {
 	<-bs.ctx.stopping
 }
 
+// deterministic reports whether b assigns a key to the same minute regardless
+// of the other keys
+func deterministic(b KeyBalancer) bool {
+	switch b.(type) {
+	case *MurmurBalance, *JumpBalance:
+		return true
+	}
+	return false
+}
+
 func New(opts BalancedScheduleOpts) *BalancedSchedule {
 	if opts.CycleSize == 0 {
 		// prevent zero division in runtime
@@ -408,8 +1059,16 @@ func New(opts BalancedScheduleOpts) *BalancedSchedule {
 	if opts.EstimatedObjects == 0 {
 		opts.EstimatedObjects = 100
 	}
-	if opts.EstimatedObjects < opts.CycleSize {
+	if opts.Coordinator != nil && !deterministic(opts.BalanceStrategy) {
+		// workers would run the keys in different minutes
+		panic("a distributed schedule needs a deterministic BalanceStrategy, e.g. StrategyJump")
+	}
+	if opts.EstimatedObjects < opts.CycleSize && opts.Coordinator == nil {
 		opts.CycleSize = opts.EstimatedObjects
+		// otherwise keys could be assigned to minutes out of the cycle
+		if b, ok := opts.BalanceStrategy.(ResizableBalancer); ok {
+			b.Resize(opts.CycleSize)
+		}
 	}
 	if opts.BalanceStrategy == nil {
 		opts.BalanceStrategy = StrategyCount(opts.CycleSize)
@@ -417,6 +1076,21 @@ func New(opts BalancedScheduleOpts) *BalancedSchedule {
 	if opts.Freq == 0 {
 		opts.Freq = time.Minute
 	}
+	if opts.Clock == nil {
+		opts.Clock = realClock{}
+	}
+	if opts.Backlog == 0 {
+		opts.Backlog = defaultBacklog
+	}
+	if opts.OpsQueue == 0 {
+		opts.OpsQueue = defaultOpsQueue
+	}
+	realTime := make(chan RealTimeMinute)
+	if opts.Coordinator != nil {
+		// the runs must be aligned to be the same across workers
+		opts.Aligned = true
+		realTime = make(chan RealTimeMinute, distributedBuffer)
+	}
 
 	pre := make(ScheduleMap, opts.CycleSize)
 	// preallocate strings slices
@@ -430,16 +1104,35 @@ func New(opts BalancedScheduleOpts) *BalancedSchedule {
 		internal: &Schedule{
 			schedule: pre,
 			keyToMin: make(KeyToMinuteMap),
-			ops:      make(opsChan),
+			ops:      newOpQueue(int(opts.OpsQueue)),
 			picks:    make(pickChan),
-			realTime: make(chan RealTimeMinute),
+			nextRuns: make(nextRunChan),
+			inspects: make(inspectChan),
+			realTime: realTime,
 			readyCh:  make(chan struct{}),
 			afterOp:  func(op *Op) {},
+			aligned:  opts.Aligned,
+			coord:    opts.Coordinator,
+			store:    opts.Store,
+			added:    make(KeyToMinuteMap),
+			removed:  make(map[string]struct{}),
+
+			rebalanceCycles: opts.RebalanceCycles,
+			oneShots:        make(map[string]time.Time),
+			oneShotDedup:    opts.OneShotDedup,
+			oneShotCap:      int(opts.OneShotsPerMinute),
+			clock:           opts.Clock,
+			spread:          opts.Spread,
+			overflow:        opts.Overflow,
+			backlogSize:     int(opts.Backlog),
 		},
 		salt: opts.Salt,
 		ctx:  new(SchedulerCtx),
 	}
 
+	if w, ok := opts.BalanceStrategy.(WeightedBalancer); ok {
+		bs.internal.weighted = w
+	}
 	if opts.AfterOp != nil {
 		bs.internal.afterOp = opts.AfterOp
 	}
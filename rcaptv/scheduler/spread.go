@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// spreadObjects spreads the objects of the minute evenly inside it
+func (s *Schedule) spreadObjects(rt *RealTimeMinute) {
+	rt.clock = s.clock
+	if !s.spread || len(rt.Objects) == 0 {
+		rt.interval = 0
+		return
+	}
+	rt.interval = s.freq / time.Duration(len(rt.Objects))
+}
+
+// Due returns when the i-th object is due. Every object is due at At unless
+// the schedule spreads them, see BalancedScheduleOpts.Spread
+func (m RealTimeMinute) Due(i int) time.Time {
+	return m.At.Add(time.Duration(i) * m.interval)
+}
+
+// Wait waits until the i-th object is due or the context is done. It returns
+// right away if the objects are not spread or the object is late
+func (m RealTimeMinute) Wait(ctx context.Context, i int) error {
+	if m.interval == 0 || m.clock == nil {
+		return nil
+	}
+	d := m.Due(i).Sub(m.clock.Now())
+	if d <= 0 {
+		return nil
+	}
+	timer := m.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves with Advance
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the time forward, firing the timers due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.fire()
+		}
+	}
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	c      chan time.Time
+	at     time.Time
+	active bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// fire must be called with the lock of the clock held
+func (t *fakeTimer) fire() {
+	t.active = false
+	select {
+	case t.c <- t.at:
+	default:
+	}
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	was := t.active
+	t.at, t.active = t.clock.now.Add(d), true
+	if d <= 0 {
+		t.fire()
+	}
+	return was
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	was := t.active
+	t.active = false
+	return was
+}
+
+var fakeStart = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestFakeClockTicks(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize uint = 10
+		freq           = time.Minute
+		keys           = usernames[:20]
+		clock          = newFakeClock(fakeStart)
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(len(keys)),
+		Freq:             freq,
+		Clock:            clock,
+		// the worker doesn't wait for the receiver
+		Overflow: OverflowQueue,
+	})
+	bs.AddMany(keys...)
+	bs.Start()
+	defer bs.Stop()
+	for i := 0; i < 12; i++ {
+		clock.Advance(freq)
+		m := <-bs.RealTime()
+		wantAt := fakeStart.Add(time.Duration(i+1) * freq)
+		if m.Min != Minute(uint(i)%cycleSize) || !m.At.Equal(wantAt) || m.Late != 0 {
+			t.Fatalf("expected min:%d at %s on time, got min:%d at %s late:%s", i%int(cycleSize), wantAt, m.Min, m.At, m.Late)
+		}
+		if m.Due(len(m.Objects)-1) != m.At {
+			t.Fatal("expected every object due at the start of the minute without Spread")
+		}
+	}
+}
+
+func TestSpread(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize uint = 10
+		freq           = time.Minute
+		keys           = usernames[:60]
+		clock          = newFakeClock(fakeStart)
+	)
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: uint(len(keys)),
+		Freq:             freq,
+		Clock:            clock,
+		Spread:           true,
+		Overflow:         OverflowQueue,
+	})
+	bs.AddMany(keys...)
+	bs.Start()
+	defer bs.Stop()
+
+	clock.Advance(freq)
+	m := <-bs.RealTime()
+	if len(m.Objects) != 6 {
+		t.Fatalf("expected 6 objects in min:%d, got %d", m.Min, len(m.Objects))
+	}
+	for i := range m.Objects {
+		if want := m.At.Add(time.Duration(i) * 10 * time.Second); !m.Due(i).Equal(want) {
+			t.Fatalf("expected object %d due at %s, got %s", i, want, m.Due(i))
+		}
+	}
+	// the first object is due right away
+	if err := m.Wait(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Wait(context.Background(), 3)
+	}()
+	clock.Advance(29 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("expected object 3 not to be due before 30s")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Wait(ctx, 5); err != context.Canceled {
+		t.Fatalf("expected the wait to be canceled, got %v", err)
+	}
+	// late objects are not waited for
+	clock.Advance(time.Minute)
+	if err := m.Wait(ctx, 5); err != nil {
+		t.Fatalf("expected no wait for a late object, got %v", err)
+	}
+}
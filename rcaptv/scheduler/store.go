@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cursor is the position of a schedule in its cycle: the last minute picked
+// and the wall-clock time it was picked at
+type Cursor struct {
+	Min       Minute
+	At        time.Time
+	CycleSize uint
+}
+
+// Next returns the minute to pick on the next tick after now, as if the
+// schedule had kept running since the cursor was saved. Every freq elapsed
+// since c.At is a minute that was skipped.
+func (c Cursor) Next(now time.Time, freq time.Duration, cycleSize uint) Minute {
+	steps := uint(1)
+	if elapsed := now.Sub(c.At); elapsed > 0 {
+		steps += uint(elapsed / freq)
+	}
+	return Minute((uint(c.Min) + steps) % cycleSize)
+}
+
+// State is the persisted state of a schedule
+type State struct {
+	Cursor   Cursor
+	KeyToMin KeyToMinuteMap
+}
+
+// Store persists the state of a BalancedSchedule so it resumes at the same
+// minute of the cycle after a restart and every key keeps its minute.
+type Store interface {
+	// Load returns the stored state or nil if there is none
+	Load(ctx context.Context) (*State, error)
+	// Save stores the cursor and the keys added and removed since the last
+	// Save
+	Save(ctx context.Context, c Cursor, added KeyToMinuteMap, removed []string) error
+}
+
+// MemoryStore is a Store that keeps the state in memory. Useful for testing
+type MemoryStore struct {
+	mu    sync.Mutex
+	state *State
+}
+
+func (s *MemoryStore) Load(_ context.Context) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == nil {
+		return nil, nil
+	}
+	clone := &State{
+		Cursor:   s.state.Cursor,
+		KeyToMin: make(KeyToMinuteMap, len(s.state.KeyToMin)),
+	}
+	for k, v := range s.state.KeyToMin {
+		clone.KeyToMin[k] = v
+	}
+	return clone, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, c Cursor, added KeyToMinuteMap, removed []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == nil {
+		s.state = &State{KeyToMin: make(KeyToMinuteMap, len(added))}
+	}
+	s.state.Cursor = c
+	for k, v := range added {
+		s.state.KeyToMin[k] = v
+	}
+	for _, k := range removed {
+		delete(s.state.KeyToMin, k)
+	}
+	return nil
+}
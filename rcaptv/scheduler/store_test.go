@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestCursorNext(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2023, 6, 4, 0, 0, 0, 0, time.UTC)
+	c := Cursor{Min: 5, At: at, CycleSize: 10}
+	tests := []struct {
+		name string
+		now  time.Time
+		want Minute
+	}{
+		{"same minute", at.Add(30 * time.Second), 6},
+		{"clock went backwards", at.Add(-time.Hour), 6},
+		{"skipped minutes", at.Add(3*time.Minute + 10*time.Second), 9},
+		{"next cycle", at.Add(7 * time.Minute), 3},
+		{"several cycles", at.Add(24*time.Minute + 30*time.Second), 0},
+	}
+	for _, tt := range tests {
+		if got := c.Next(tt.now, time.Minute, 10); got != tt.want {
+			t.Fatalf("%s: expected min:%d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestScheduleResume(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 10
+		pickInterval time.Duration = 100 * time.Millisecond
+		keys                       = usernames[:40]
+	)
+	store := new(MemoryStore)
+	newSchedule := func() *BalancedSchedule {
+		return New(BalancedScheduleOpts{
+			CycleSize:        cycleSize,
+			EstimatedObjects: uint(len(keys)),
+			Freq:             pickInterval,
+			Store:            store,
+		})
+	}
+
+	bs := newSchedule()
+	for _, k := range keys {
+		bs.Add(k)
+	}
+	bs.Start()
+	var last RealTimeMinute
+	for i := 0; i < 3; i++ {
+		last = <-bs.RealTime()
+	}
+	bs.Stop()
+	want := bs.UnsafeKeyToMinute()
+
+	state, err := store.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Cursor.Min != last.Min || len(state.KeyToMin) != len(keys) {
+		t.Fatalf("unexpected stored state, last min:%d\n%s", last.Min, spew.Sdump(state))
+	}
+
+	// a restarted schedule with the keys added in a different order keeps
+	// their minutes with the count balancer and resumes after the last minute
+	bs = newSchedule()
+	for i := len(keys) - 1; i >= 0; i-- {
+		bs.Add(keys[i])
+	}
+	bs.Start()
+	first := <-bs.RealTime()
+	bs.Stop()
+	if first.Min != last.Min+1 {
+		t.Fatalf("expected to resume at min:%d, got %d", last.Min+1, first.Min)
+	}
+	got := bs.UnsafeKeyToMinute()
+	for k, m := range want {
+		if got[k] != m {
+			t.Fatalf("expected %s at min:%d, got %d", k, m, got[k])
+		}
+	}
+}
+
+func TestScheduleResumePrune(t *testing.T) {
+	t.Parallel()
+	var (
+		cycleSize    uint          = 2
+		pickInterval time.Duration = 25 * time.Millisecond
+	)
+	store := new(MemoryStore)
+	store.Save(context.Background(), Cursor{Min: 0, At: time.Now(), CycleSize: cycleSize},
+		KeyToMinuteMap{"kept": 1, "gone": 0}, nil)
+
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        cycleSize,
+		EstimatedObjects: cycleSize,
+		Freq:             pickInterval,
+		Store:            store,
+	})
+	bs.Add("kept")
+	bs.Start()
+	// the keys not added again are removed after a full cycle
+	for i := uint(0); i < cycleSize; i++ {
+		<-bs.RealTime()
+	}
+	bs.Stop()
+
+	state, _ := store.Load(context.Background())
+	if len(state.KeyToMin) != 1 || state.KeyToMin["kept"] != 1 {
+		t.Fatalf("expected only the kept key at min:1, got %s", spew.Sdump(state.KeyToMin))
+	}
+	if _, found := bs.UnsafeKeyToMinute()["gone"]; found {
+		t.Fatal("expected the key not added again to not be scheduled")
+	}
+}
+
+func TestScheduleCycleSizeChanged(t *testing.T) {
+	t.Parallel()
+	store := new(MemoryStore)
+	store.Save(context.Background(), Cursor{Min: 7, At: time.Now(), CycleSize: 20},
+		KeyToMinuteMap{"a": 15}, nil)
+
+	bs := New(BalancedScheduleOpts{
+		CycleSize:        10,
+		EstimatedObjects: 10,
+		Freq:             25 * time.Millisecond,
+		Store:            store,
+	})
+	bs.Add("a")
+	bs.Start()
+	first := <-bs.RealTime()
+	bs.Stop()
+	if first.Min != ResetMinute {
+		t.Fatalf("expected a new cycle, got min:%d", first.Min)
+	}
+	if m := bs.UnsafeKeyToMinute()["a"]; m >= 10 {
+		t.Fatalf("expected the key to be balanced again, got min:%d", m)
+	}
+}
@@ -0,0 +1,370 @@
+// Package stats computes per channel analytics from the VODs and clips we
+// track.
+//
+// Aggregations are precomputed weekly per broadcaster in materialized views
+// (see migration 0003_stats) that must be refreshed with Refresh. The tracker
+// refreshes them after every tracking cycle, so stats may lag behind the
+// tracked data by up to one cycle.
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnknownChannel = errors.New("channel is not tracked")
+	ErrInvalidPeriod  = errors.New("invalid period")
+)
+
+// views are the materialized views refreshed by Refresh
+var views = []string{
+	"stats_weekly_vods",
+	"stats_weekly_clips",
+	"stats_weekly_clippers",
+	"stats_weekly_games",
+	"stats_weekly_clip_views",
+}
+
+// Refresh recomputes the materialized views used for stats. Views are
+// refreshed concurrently so reads are not blocked while refreshing.
+func Refresh(ctx context.Context, db *sql.DB) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for _, v := range views {
+		if _, err := db.ExecContext(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY "+v); err != nil {
+			return fmt.Errorf("refresh %s: %w", v, err)
+		}
+	}
+	return nil
+}
+
+type Period string
+
+const (
+	PeriodMonth   Period = "month"
+	PeriodQuarter Period = "quarter"
+	PeriodYear    Period = "year"
+	PeriodAll     Period = "all"
+
+	DefaultPeriod = PeriodQuarter
+)
+
+// Since returns the start of the first week included in the period p relative
+// to now. For PeriodAll the zero time is returned.
+func (p Period) Since(now time.Time) (time.Time, error) {
+	var since time.Time
+	switch p {
+	case PeriodMonth:
+		since = now.AddDate(0, -1, 0)
+	case PeriodQuarter:
+		since = now.AddDate(0, -3, 0)
+	case PeriodYear:
+		since = now.AddDate(-1, 0, 0)
+	case PeriodAll:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, ErrInvalidPeriod
+	}
+	return startOfWeek(since), nil
+}
+
+// startOfWeek truncates t to monday 00:00 UTC, same as postgres
+// date_trunc('week', t)
+func startOfWeek(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+type ChannelStats struct {
+	BroadcasterID string `json:"bc_id"`
+	Period        Period `json:"period"`
+	// Since is the start of the first week included in the stats. Nil if
+	// Period is PeriodAll
+	Since *time.Time `json:"since"`
+
+	// Weeks is the weekly series for the period, only weeks with at least one
+	// VOD or clip are included.
+	Weeks []*WeekStats `json:"weeks"`
+
+	HoursStreamed      float64 `json:"hours_streamed"`
+	VodCount           int     `json:"vod_count"`
+	AvgVodSeconds      float64 `json:"avg_vod_seconds"`
+	ClipCount          int     `json:"clip_count"`
+	ClipsPerStreamHour float64 `json:"clips_per_stream_hour"`
+
+	TopClippers      []*Clipper    `json:"top_clippers"`
+	TopGames         []*Game       `json:"top_games"`
+	ViewDistribution []*ViewBucket `json:"view_distribution"`
+}
+
+type WeekStats struct {
+	// Week is the monday the week starts at
+	Week               time.Time `json:"week"`
+	HoursStreamed      float64   `json:"hours_streamed"`
+	VodCount           int       `json:"vod_count"`
+	AvgVodSeconds      float64   `json:"avg_vod_seconds"`
+	ClipCount          int       `json:"clip_count"`
+	ClipsPerStreamHour float64   `json:"clips_per_stream_hour"`
+}
+
+type Clipper struct {
+	CreatorID   string `json:"creator_id"`
+	CreatorName string `json:"creator_name"`
+	ClipCount   int    `json:"clip_count"`
+	ViewCount   int64  `json:"view_count"`
+}
+
+type Game struct {
+	// GameID is empty for clips without game
+	GameID    string `json:"game_id"`
+	ClipCount int    `json:"clip_count"`
+	ViewCount int64  `json:"view_count"`
+}
+
+// ViewBucket counts the clips with views in [Min, Max). Max is nil for the
+// last bucket
+type ViewBucket struct {
+	Min       int64  `json:"min"`
+	Max       *int64 `json:"max"`
+	ClipCount int    `json:"clip_count"`
+}
+
+// viewBuckets must match the buckets of stats_weekly_clip_views
+const viewBuckets = 6
+
+const DefaultTopLimit = 10
+
+type ChannelParams struct {
+	BroadcasterID string
+	Period        Period
+	// TopLimit limits the number of top clippers and games. Defaults to
+	// DefaultTopLimit
+	TopLimit int
+	// Now is the time the period is relative to. Defaults to time.Now()
+	Now time.Time
+
+	Context context.Context
+}
+
+// Channel returns the stats of a tracked channel for the given period.
+//
+// Returns ErrUnknownChannel if the broadcaster is not tracked and
+// ErrInvalidPeriod for unknown periods.
+func Channel(db *sql.DB, p *ChannelParams) (*ChannelStats, error) {
+	if p.Context == nil {
+		p.Context = context.Background()
+	}
+	if p.Period == "" {
+		p.Period = DefaultPeriod
+	}
+	if p.TopLimit <= 0 {
+		p.TopLimit = DefaultTopLimit
+	}
+	if p.Now.IsZero() {
+		p.Now = time.Now()
+	}
+	since, err := p.Period.Since(p.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracked bool
+	err = db.QueryRowContext(p.Context,
+		`SELECT EXISTS(SELECT 1 FROM tracked_channels WHERE bc_id = $1)`,
+		p.BroadcasterID,
+	).Scan(&tracked)
+	if err != nil {
+		return nil, err
+	}
+	if !tracked {
+		return nil, ErrUnknownChannel
+	}
+
+	st := &ChannelStats{
+		BroadcasterID: p.BroadcasterID,
+		Period:        p.Period,
+	}
+	if p.Period != PeriodAll {
+		st.Since = &since
+	}
+	if err := weeks(db, p, since, st); err != nil {
+		return nil, fmt.Errorf("weekly stats: %w", err)
+	}
+	if st.TopClippers, err = topClippers(db, p, since); err != nil {
+		return nil, fmt.Errorf("top clippers: %w", err)
+	}
+	if st.TopGames, err = topGames(db, p, since); err != nil {
+		return nil, fmt.Errorf("top games: %w", err)
+	}
+	if st.ViewDistribution, err = viewDistribution(db, p, since); err != nil {
+		return nil, fmt.Errorf("view distribution: %w", err)
+	}
+	return st, nil
+}
+
+// weeks fills the weekly series and the totals of st
+func weeks(db *sql.DB, p *ChannelParams, since time.Time, st *ChannelStats) error {
+	rows, err := db.QueryContext(p.Context, `
+	SELECT
+		coalesce(v.week, c.week),
+		coalesce(v.vod_count, 0),
+		coalesce(v.stream_seconds, 0),
+		coalesce(c.clip_count, 0)
+	FROM (
+		SELECT week, vod_count, stream_seconds FROM stats_weekly_vods
+		WHERE bc_id = $1 AND week >= $2
+	) v
+	FULL OUTER JOIN (
+		SELECT week, clip_count FROM stats_weekly_clips
+		WHERE bc_id = $1 AND week >= $2
+	) c ON c.week = v.week
+	ORDER BY 1 ASC`,
+		p.BroadcasterID, since,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var totalSeconds int64
+	st.Weeks = make([]*WeekStats, 0, 16)
+	for rows.Next() {
+		var (
+			w       WeekStats
+			seconds int64
+		)
+		if err := rows.Scan(&w.Week, &w.VodCount, &seconds, &w.ClipCount); err != nil {
+			return err
+		}
+		w.Week = w.Week.UTC()
+		w.HoursStreamed = float64(seconds) / 3600
+		w.AvgVodSeconds = ratio(float64(seconds), float64(w.VodCount))
+		w.ClipsPerStreamHour = ratio(float64(w.ClipCount), w.HoursStreamed)
+		st.Weeks = append(st.Weeks, &w)
+
+		totalSeconds += seconds
+		st.VodCount += w.VodCount
+		st.ClipCount += w.ClipCount
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	st.HoursStreamed = float64(totalSeconds) / 3600
+	st.AvgVodSeconds = ratio(float64(totalSeconds), float64(st.VodCount))
+	st.ClipsPerStreamHour = ratio(float64(st.ClipCount), st.HoursStreamed)
+	return nil
+}
+
+func topClippers(db *sql.DB, p *ChannelParams, since time.Time) ([]*Clipper, error) {
+	rows, err := db.QueryContext(p.Context, `
+	SELECT creator_id, max(creator_name), sum(clip_count), sum(view_count)
+	FROM stats_weekly_clippers
+	WHERE bc_id = $1 AND week >= $2
+	GROUP BY creator_id
+	ORDER BY 3 DESC, 4 DESC, creator_id ASC
+	LIMIT $3`,
+		p.BroadcasterID, since, p.TopLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r := make([]*Clipper, 0, p.TopLimit)
+	for rows.Next() {
+		var c Clipper
+		if err := rows.Scan(&c.CreatorID, &c.CreatorName, &c.ClipCount, &c.ViewCount); err != nil {
+			return nil, err
+		}
+		r = append(r, &c)
+	}
+	return r, rows.Err()
+}
+
+func topGames(db *sql.DB, p *ChannelParams, since time.Time) ([]*Game, error) {
+	rows, err := db.QueryContext(p.Context, `
+	SELECT game_id, sum(clip_count), sum(view_count)
+	FROM stats_weekly_games
+	WHERE bc_id = $1 AND week >= $2
+	GROUP BY game_id
+	ORDER BY 2 DESC, 3 DESC, game_id ASC
+	LIMIT $3`,
+		p.BroadcasterID, since, p.TopLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	r := make([]*Game, 0, p.TopLimit)
+	for rows.Next() {
+		var g Game
+		if err := rows.Scan(&g.GameID, &g.ClipCount, &g.ViewCount); err != nil {
+			return nil, err
+		}
+		r = append(r, &g)
+	}
+	return r, rows.Err()
+}
+
+// viewDistribution always returns every bucket, including empty ones
+func viewDistribution(db *sql.DB, p *ChannelParams, since time.Time) ([]*ViewBucket, error) {
+	r := make([]*ViewBucket, viewBuckets)
+	min := int64(0)
+	for i := range r {
+		r[i] = &ViewBucket{Min: min}
+		if i < viewBuckets-1 {
+			max := pow10(i + 1)
+			r[i].Max = &max
+			min = max
+		}
+	}
+
+	rows, err := db.QueryContext(p.Context, `
+	SELECT bucket, sum(clip_count)
+	FROM stats_weekly_clip_views
+	WHERE bc_id = $1 AND week >= $2
+	GROUP BY bucket`,
+		p.BroadcasterID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			bucket int
+			count  int
+		)
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		if bucket < 0 || bucket >= viewBuckets {
+			continue
+		}
+		r[bucket].ClipCount = count
+	}
+	return r, rows.Err()
+}
+
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+func pow10(n int) int64 {
+	r := int64(1)
+	for i := 0; i < n; i++ {
+		r *= 10
+	}
+	return r
+}
@@ -0,0 +1,169 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/helix"
+	"pedro.to/rcaptv/repo"
+	"pedro.to/rcaptv/test"
+)
+
+var db *sql.DB
+
+func TestMain(m *testing.M) {
+	conn, pool, res := test.SetupPostgres()
+	db = conn
+
+	// Run tests
+	code := m.Run()
+
+	if err := test.CancelPostgres(pool, res); err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(code)
+}
+
+func TestPeriodSince(t *testing.T) {
+	t.Parallel()
+	// sunday
+	now := time.Date(2023, 6, 18, 15, 31, 56, 0, time.UTC)
+	tests := []struct {
+		period Period
+		want   time.Time
+		err    error
+	}{
+		{PeriodMonth, time.Date(2023, 5, 15, 0, 0, 0, 0, time.UTC), nil},
+		{PeriodQuarter, time.Date(2023, 3, 13, 0, 0, 0, 0, time.UTC), nil},
+		{PeriodYear, time.Date(2022, 6, 13, 0, 0, 0, 0, time.UTC), nil},
+		{PeriodAll, time.Time{}, nil},
+		{Period("decade"), time.Time{}, ErrInvalidPeriod},
+	}
+	for _, tt := range tests {
+		got, err := tt.period.Since(now)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: expected err %v, got %v", tt.period, tt.err, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Fatalf("%s: expected %s, got %s", tt.period, tt.want, got)
+		}
+	}
+}
+
+func TestChannel(t *testing.T) {
+	clip := func(id, creator string, game string, views int, at string) *helix.Clip {
+		return &helix.Clip{
+			ClipID:        id,
+			BroadcasterID: "58753574",
+			CreatedAt:     at,
+			CreatorID:     creator,
+			CreatorName:   creator,
+			Title:         id,
+			GameID:        game,
+			Lang:          "es",
+			ThumbnailURL:  "https://example.com/" + id + ".jpg",
+			ViewCount:     views,
+		}
+	}
+	err := repo.UpsertClips(db, []*helix.Clip{
+		clip("c1", "alice", "g1", 5, "2023-06-14T08:00:00Z"),
+		clip("c2", "alice", "g1", 50, "2023-06-14T09:00:00Z"),
+		clip("c3", "alice", "g2", 50, "2023-06-18T09:00:00Z"),
+		clip("c4", "bob", "g1", 2000, "2023-06-18T10:00:00Z"),
+		clip("c5", "bob", "g2", 150000, "2023-06-18T11:00:00Z"),
+		// outside of the period
+		clip("c6", "carol", "g3", 10, "2022-01-01T10:00:00Z"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Refresh(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := Channel(db, &ChannelParams{
+		BroadcasterID: "58753574",
+		Period:        PeriodMonth,
+		Now:           time.Date(2023, 6, 20, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Weeks) != 1 {
+		t.Fatalf("expected 1 week, got %d", len(st.Weeks))
+	}
+	w := st.Weeks[0]
+	if want := time.Date(2023, 6, 12, 0, 0, 0, 0, time.UTC); !w.Week.Equal(want) {
+		t.Fatalf("expected week %s, got %s", want, w.Week)
+	}
+	// 1870+26420+4000+21350 seconds
+	if w.VodCount != 4 || w.ClipCount != 5 {
+		t.Fatalf("expected 4 vods and 5 clips, got %d and %d", w.VodCount, w.ClipCount)
+	}
+	if !almostEqual(st.HoursStreamed, 53640.0/3600) {
+		t.Fatalf("unexpected hours streamed %f", st.HoursStreamed)
+	}
+	if !almostEqual(st.AvgVodSeconds, 53640.0/4) {
+		t.Fatalf("unexpected avg vod seconds %f", st.AvgVodSeconds)
+	}
+	if !almostEqual(st.ClipsPerStreamHour, 5/(53640.0/3600)) {
+		t.Fatalf("unexpected clips per stream hour %f", st.ClipsPerStreamHour)
+	}
+
+	if len(st.TopClippers) != 2 {
+		t.Fatalf("expected 2 clippers, got %d", len(st.TopClippers))
+	}
+	if c := st.TopClippers[0]; c.CreatorID != "alice" || c.ClipCount != 3 || c.ViewCount != 105 {
+		t.Fatalf("unexpected top clipper %+v", c)
+	}
+	if len(st.TopGames) != 2 {
+		t.Fatalf("expected 2 games, got %d", len(st.TopGames))
+	}
+	if g := st.TopGames[0]; g.GameID != "g1" || g.ClipCount != 3 {
+		t.Fatalf("unexpected top game %+v", g)
+	}
+
+	wantViews := []int{1, 2, 0, 1, 0, 1}
+	if len(st.ViewDistribution) != len(wantViews) {
+		t.Fatalf("expected %d view buckets, got %d", len(wantViews), len(st.ViewDistribution))
+	}
+	for i, b := range st.ViewDistribution {
+		if b.ClipCount != wantViews[i] {
+			t.Fatalf("bucket %d: expected %d clips, got %d", i, wantViews[i], b.ClipCount)
+		}
+	}
+	if last := st.ViewDistribution[len(wantViews)-1]; last.Min != 100000 || last.Max != nil {
+		t.Fatalf("unexpected last bucket %+v", last)
+	}
+
+	all, err := Channel(db, &ChannelParams{
+		BroadcasterID: "58753574",
+		Period:        PeriodAll,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.ClipCount != 6 || all.Since != nil {
+		t.Fatalf("expected 6 clips and no since, got %d and %v", all.ClipCount, all.Since)
+	}
+}
+
+func TestChannelUnknown(t *testing.T) {
+	_, err := Channel(db, &ChannelParams{
+		BroadcasterID: "1234",
+	})
+	if !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected ErrUnknownChannel, got %v", err)
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
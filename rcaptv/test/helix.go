@@ -0,0 +1,121 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/helix"
+	"pedro.to/rcaptv/helix/cassette"
+	"pedro.to/rcaptv/helix/fakehelix"
+)
+
+// ReplayNow is the time the cassettes are recorded at. The dataset of the
+// fake server is relative to it, so the requests of the tests must be too.
+var ReplayNow = time.Date(2023, 6, 20, 12, 0, 0, 0, time.UTC)
+
+// FakeChannelID is a channel of the fake server without data in the test
+// database
+const FakeChannelID = "100000002"
+
+const (
+	fakeClientID     = "fake_client_id"
+	fakeClientSecret = "fake_client_secret"
+)
+
+// ReplayHelix returns a helix client replaying the cassette at path. With
+// HELIX_CASSETTE_MODE=record the cassette is recorded again from a fake
+// server seeded at ReplayNow, which is returned too. It is nil when
+// replaying.
+func ReplayHelix(t testing.TB, path string) (*helix.Helix, *cassette.Cassette, *fakehelix.Server) {
+	t.Helper()
+	c := cassette.OpenForTest(t, path)
+	if c.Mode() == cassette.ModeReplay {
+		hx := helix.NewWithoutExchange(&helix.HelixOpts{
+			APIUrl:      "https://api.twitch.tv" + fakehelix.HelixPath,
+			Middlewares: []helix.Middleware{c.Middleware()},
+		})
+		return hx, c, nil
+	}
+	hx, fake := FakeHelix(t, fakehelix.Opts{
+		Seed:     1,
+		Channels: 3,
+		Now:      func() time.Time { return ReplayNow },
+	}, c.Middleware())
+	return hx, c, fake
+}
+
+// FakeHelix starts a fake server and returns a helix client authenticated
+// with an app token of it
+func FakeHelix(t testing.TB, opts fakehelix.Opts, middlewares ...helix.Middleware) (*helix.Helix, *fakehelix.Server) {
+	t.Helper()
+	opts.ClientID, opts.ClientSecret = fakeClientID, fakeClientSecret
+	fake := fakehelix.New(opts)
+	sv := httptest.NewServer(fake)
+	t.Cleanup(sv.Close)
+	hx := helix.New(&helix.HelixOpts{
+		Creds:            helix.ClientCreds{ClientID: fakeClientID, ClientSecret: fakeClientSecret},
+		APIUrl:           sv.URL + fakehelix.HelixPath,
+		TokenURL:         sv.URL + fakehelix.OAuthPath + "/token",
+		ValidateEndpoint: sv.URL + fakehelix.OAuthPath + "/validate",
+		Middlewares:      middlewares,
+	})
+	return hx, fake
+}
+
+// Recorded returns the data of the responses recorded in c for the helix
+// endpoint, in the order they were recorded. If query is not empty, only the
+// requests with that normalized query are returned.
+func Recorded[T any](t testing.TB, c *cassette.Cassette, endpoint, query string) [][]T {
+	t.Helper()
+	var pages [][]T
+	for _, it := range c.Interactions {
+		if it.Request.Path != fakehelix.HelixPath+endpoint || (query != "" && it.Request.Query != query) {
+			continue
+		}
+		var page struct {
+			Data []T `json:"data"`
+		}
+		if err := json.Unmarshal(it.Response.Body, &page); err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, page.Data)
+	}
+	return pages
+}
+
+// CheckReplayedClips fails the test unless clips are the clips of bid
+// recorded in c, without duplicates. The views threshold is checked after
+// adding a clip, so the most viewed clip of every recorded page must be there.
+func CheckReplayedClips(t testing.TB, c *cassette.Cassette, bid string, clips []*helix.Clip) {
+	t.Helper()
+	pages := Recorded[*helix.Clip](t, c, "/clips", "")
+	recorded := make(map[string]bool)
+	for _, page := range pages {
+		for _, c := range page {
+			recorded[c.ClipID] = true
+		}
+	}
+	if len(clips) == 0 {
+		t.Fatalf("expected clips, %d recorded", len(recorded))
+	}
+	replayed := make(map[string]bool, len(clips))
+	for _, c := range clips {
+		if c.BroadcasterID != bid {
+			t.Fatalf("expected clips of %s, got %s", bid, c.BroadcasterID)
+		}
+		if !recorded[c.ClipID] {
+			t.Fatalf("clip %s was not recorded", c.ClipID)
+		}
+		if replayed[c.ClipID] {
+			t.Fatalf("duplicated clip %s", c.ClipID)
+		}
+		replayed[c.ClipID] = true
+	}
+	for _, page := range pages {
+		if len(page) > 0 && !replayed[page[0].ClipID] {
+			t.Fatalf("expected clip %s", page[0].ClipID)
+		}
+	}
+}
@@ -69,7 +69,7 @@ func SetupPostgres() (*sql.DB, *dockertest.Pool, *dockertest.Resource) {
 			StorageConnTimeout:     20 * time.Second,
 			DebugMode:              true,
 
-			MigrationVersion: 2,
+			MigrationVersion: 8,
 			MigrationPath:    "../database/postgres/migrations",
 		}))
 	db := sto.Conn()
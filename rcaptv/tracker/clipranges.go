@@ -0,0 +1,184 @@
+package tracker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	"pedro.to/rcaptv/helix"
+	"pedro.to/rcaptv/repo"
+)
+
+const (
+	// maxClipRangeRetries is the number of times the incomplete ranges of a
+	// window are retried deeper before giving up on them
+	maxClipRangeRetries = 3
+	// clipRangesPerTrack is the max number of ranges retried every time a
+	// broadcaster is tracked, the rest wait for the next cycle
+	clipRangesPerTrack = 2
+	// minClipRange is the shortest range stored. Shorter remainders of the
+	// ranges already stored are left out
+	minClipRange = time.Minute
+)
+
+// saveIncompleteClipRanges stores the ranges of res that reached the max deep
+// level, to be retried in the next cycle. retries is the number of times the
+// range of res was already retried.
+//
+// The tracking window moves every cycle, so its incomplete ranges never have
+// the same bounds twice. Only the parts of the ranges of the window, with no
+// retries, after the previous window of the broadcaster and not stored yet
+// are stored. The rest was already found incomplete and retried deeper, or
+// complete, so the same clips are not retried from scratch every cycle.
+func (t *Tracker) saveIncompleteClipRanges(bid string, res *helix.DeepClipsResult, retries int) {
+	if t.db == nil {
+		return
+	}
+	l := log.With().Str("ctx", "tracker").Logger()
+	incomplete := res.Incomplete()
+	if len(incomplete) == 0 {
+		return
+	}
+	if retries == 0 {
+		stored, err := repo.IncompleteClipRanges(t.db, &repo.IncompleteClipRangesParams{
+			BroadcasterID: bid,
+			StartedAt:     incomplete[0].From,
+			EndedAt:       incomplete[len(incomplete)-1].To,
+			Context:       t.ctx,
+		})
+		if err != nil {
+			l.Err(err).Msgf("failed to retrieve incomplete clip ranges (bid:%s)", bid)
+			return
+		}
+		if until, ok := t.clipsWindowEnd[bid]; ok {
+			// the previous window goes first, it starts before any range
+			stored = append([]*model.IncompleteClipRanges{{EndedAt: until}}, stored...)
+		}
+		incomplete = subtractClipRanges(incomplete, stored)
+		if len(incomplete) == 0 {
+			return
+		}
+	}
+	ranges := make([]*model.IncompleteClipRanges, 0, len(incomplete))
+	for _, r := range incomplete {
+		ranges = append(ranges, &model.IncompleteClipRanges{
+			BcID:      bid,
+			StartedAt: r.From,
+			EndedAt:   r.To,
+			Depth:     int32(r.Depth),
+			Retries:   int32(retries),
+		})
+	}
+	if err := repo.AddIncompleteClipRanges(t.db, t.ctx, ranges); err != nil {
+		l.Err(err).Msgf("failed to store incomplete clip ranges (bid:%s)", bid)
+		return
+	}
+	l.Warn().Msgf("incomplete clip results in %d ranges, retrying them in the next cycle "+
+		"(bid:%s, requests:%d, depth:%d, retries:%d)",
+		len(ranges), bid, res.Requests, res.Depth, retries,
+	)
+}
+
+// subtractClipRanges returns the parts of ranges not overlapping the stored
+// ones, at least minClipRange long. Both are sorted by start.
+func subtractClipRanges(ranges []helix.RangeResult, stored []*model.IncompleteClipRanges) []helix.RangeResult {
+	var r []helix.RangeResult
+	for _, rr := range ranges {
+		from := rr.From
+		for _, s := range stored {
+			if !s.EndedAt.After(from) || !s.StartedAt.Before(rr.To) {
+				continue
+			}
+			if s.StartedAt.Sub(from) >= minClipRange {
+				part := rr
+				part.From, part.To = from, s.StartedAt
+				r = append(r, part)
+			}
+			from = s.EndedAt
+		}
+		if rr.To.Sub(from) >= minClipRange {
+			part := rr
+			part.From = from
+			r = append(r, part)
+		}
+	}
+	return r
+}
+
+// RetryClipRanges fetches again the incomplete clip ranges of a broadcaster
+// stored in previous cycles and returns their clips, up to clipRangesPerTrack
+// ranges. Every range is the whole window of a new DeepClips, so it goes
+// deeper than the first time. The ranges still incomplete are stored again,
+// up to maxClipRangeRetries times. Ranges out of the tracking window are
+// dropped.
+//
+// Ranges retried maxClipRangeRetries times are kept until they leave the
+// tracking window, so the same clips are not found incomplete and retried
+// from scratch again, and the API reports them as incomplete.
+func (t *Tracker) RetryClipRanges(bid string) ([]*helix.Clip, error) {
+	if t.db == nil {
+		return nil, nil
+	}
+	l := log.With().Str("ctx", "tracker").Logger()
+	ranges, err := repo.IncompleteClipRanges(t.db, &repo.IncompleteClipRangesParams{
+		BroadcasterID: bid,
+		Context:       t.ctx,
+	})
+	if err != nil || len(ranges) == 0 {
+		return nil, err
+	}
+
+	windowStart := t.now().Add(-time.Duration(t.ClipTrackingWindowHours) * time.Hour)
+	var clips []*helix.Clip
+	done := make([]int64, 0, len(ranges))
+	retried := 0
+	for _, r := range ranges {
+		if r.EndedAt.Before(windowStart) {
+			done = append(done, r.RangeID)
+			continue
+		}
+		if r.Retries >= maxClipRangeRetries || retried == clipRangesPerTrack {
+			continue
+		}
+		retried++
+		res, err := t.hx.DeepClips(&helix.DeepClipsParams{
+			ClipsParams: &helix.ClipsParams{
+				BroadcasterID:            bid,
+				StartedAt:                r.StartedAt,
+				EndedAt:                  r.EndedAt,
+				StopViewsThreshold:       t.ClipViewThreshold,
+				ViewsThresholdWindowSize: t.ClipViewWindowSize,
+				Context:                  t.ctx,
+			},
+			// the first level is the range itself, which is known to be
+			// incomplete
+			MaxDeepLvl:  t.ClipTrackingMaxDeepLevel + 1,
+			Concurrency: t.ClipTrackingConcurrency,
+		})
+		if errors.Is(err, helix.ErrItemsEmpty) {
+			done = append(done, r.RangeID)
+			continue
+		}
+		if errors.Is(err, helix.ErrCircuitOpen) {
+			return clips, err
+		}
+		if err != nil {
+			// kept to be retried again
+			if res != nil {
+				clips = append(clips, res.Clips...)
+			}
+			l.Err(err).Msgf("failed to retry incomplete clip range from=%s to=%s (bid:%s)",
+				r.StartedAt.Format(time.RFC3339), r.EndedAt.Format(time.RFC3339), bid)
+			continue
+		}
+		clips = append(clips, res.Clips...)
+		done = append(done, r.RangeID)
+		t.saveIncompleteClipRanges(bid, res, int(r.Retries)+1)
+	}
+	if err := repo.DeleteIncompleteClipRanges(t.db, t.ctx, done...); err != nil {
+		return clips, err
+	}
+	return clips, nil
+}
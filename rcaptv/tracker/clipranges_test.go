@@ -0,0 +1,129 @@
+package tracker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	"pedro.to/rcaptv/helix"
+	"pedro.to/rcaptv/helix/fakehelix"
+	"pedro.to/rcaptv/repo"
+	"pedro.to/rcaptv/test"
+)
+
+func TestRetryClipRanges(t *testing.T) {
+	t.Parallel()
+	bid := "90075649"
+	now := time.Date(2023, 6, 20, 12, 0, 0, 0, time.UTC)
+	// a clip above the views threshold every 10 minutes of the last two days,
+	// so every range is incomplete at any deep level
+	ds := &fakehelix.Dataset{}
+	for i := 1; i <= 2*24*6; i++ {
+		ds.Clips = append(ds.Clips, &helix.Clip{
+			ClipID:        fmt.Sprintf("clip%d", i),
+			BroadcasterID: bid,
+			CreatedAt:     now.Add(-time.Duration(i) * 10 * time.Minute).Format(time.RFC3339),
+			ViewCount:     1000,
+		})
+	}
+	hx, _ := test.FakeHelix(t, fakehelix.Opts{Dataset: ds})
+	clock := now
+	tracker := New(&TrackerOpts{
+		Helix:                    hx,
+		ClipTrackingMaxDeepLevel: 1,
+		ClipTrackingWindowHours:  24,
+		ClipViewThreshold:        10,
+		ClipViewWindowSize:       4,
+		Now:                      func() time.Time { return clock },
+	})
+	tracker.db = db
+
+	stored := func() []*model.IncompleteClipRanges {
+		t.Helper()
+		ranges, err := repo.IncompleteClipRanges(db, &repo.IncompleteClipRangesParams{
+			BroadcasterID: bid,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i < len(ranges); i++ {
+			if ranges[i].StartedAt.Before(ranges[i-1].EndedAt) {
+				t.Fatalf("overlapping ranges %+v and %+v", ranges[i-1], ranges[i])
+			}
+		}
+		return ranges
+	}
+
+	if _, err := tracker.FetchClips(bid); err != nil {
+		t.Fatal(err)
+	}
+	ranges := stored()
+	if len(ranges) != 1 || !ranges[0].StartedAt.Equal(now.Add(-24*time.Hour)) || !ranges[0].EndedAt.Equal(now) {
+		t.Fatalf("expected the window to be incomplete, got %+v", ranges)
+	}
+
+	// the window moved, only the part not stored yet is added
+	clock = now.Add(time.Hour)
+	if _, err := tracker.FetchClips(bid); err != nil {
+		t.Fatal(err)
+	}
+	ranges = stored()
+	if len(ranges) != 2 || !ranges[1].StartedAt.Equal(now) || !ranges[1].EndedAt.Equal(clock) || ranges[1].Retries != 0 {
+		t.Fatalf("expected the last hour to be added, got %+v", ranges)
+	}
+
+	// the last hour has no clips and is dropped, the rest is split deeper
+	clips, err := tracker.RetryClipRanges(bid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clips) == 0 {
+		t.Fatal("expected the clips of the retried ranges")
+	}
+	ranges = stored()
+	if len(ranges) != 2 || !ranges[0].StartedAt.Equal(now.Add(-24*time.Hour)) || !ranges[1].EndedAt.Equal(now) {
+		t.Fatalf("expected the halves of the window, got %+v", ranges)
+	}
+	for _, r := range ranges {
+		if r.Retries != 1 || r.Depth != 2 {
+			t.Fatalf("expected ranges retried once at depth 2, got %+v", r)
+		}
+	}
+
+	// retried until they are given up
+	for i := 0; i < 20; i++ {
+		if _, err := tracker.RetryClipRanges(bid); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tracker.FetchClips(bid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranges = stored()
+	for _, r := range ranges {
+		if r.Retries != maxClipRangeRetries {
+			t.Fatalf("expected ranges retried %d times, got %+v", maxClipRangeRetries, r)
+		}
+	}
+	n := len(ranges)
+	clips, err = tracker.RetryClipRanges(bid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.FetchClips(bid); err != nil {
+		t.Fatal(err)
+	}
+	if len(clips) > 0 || len(stored()) != n {
+		t.Fatalf("expected the ranges given up to be kept without retrying them, got %d clips", len(clips))
+	}
+
+	// dropped once they are out of the window
+	clock = now.Add(48 * time.Hour)
+	if _, err := tracker.RetryClipRanges(bid); err != nil {
+		t.Fatal(err)
+	}
+	if ranges := stored(); len(ranges) != 0 {
+		t.Fatalf("expected the ranges out of the window to be dropped, got %+v", ranges)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -14,13 +15,24 @@ import (
 	"pedro.to/rcaptv/helix"
 	"pedro.to/rcaptv/repo"
 	"pedro.to/rcaptv/scheduler"
+	"pedro.to/rcaptv/stats"
+	"pedro.to/rcaptv/webhook"
 )
 
 var (
 	ErrEmptyVODs  = errors.New("no VODs found")
 	ErrEmptyClips = errors.New("no clips found")
+	// ErrTrackIncomplete is returned by track when the clips or VODs of a
+	// broadcaster could not be fetched or stored
+	ErrTrackIncomplete = errors.New("tracking incomplete")
 )
 
+// trackerLeaseTTL is the lease of a tracker in a distributed schedule
+const trackerLeaseTTL = 3 * time.Minute
+
+// statsRefreshTimeout is the max duration of a refresh of the stats
+const statsRefreshTimeout = 10 * time.Minute
+
 type lastVODTable map[string]string
 
 func (t lastVODTable) FromDB(db *sql.DB) error {
@@ -42,13 +54,43 @@ type Tracker struct {
 	db                *sql.DB
 	ctx               context.Context
 	hx                *helix.Helix
+	webhooks          *webhook.Dispatcher
 	lastVIDByStreamer lastVODTable
+	// requeued are the broadcasters skipped while the Twitch API circuit
+	// breaker was open
+	requeued requeue
+	// retries are the retries in a row of the broadcasters whose tracking
+	// failed
+	retries map[string]int
+	// schedule is the running schedule, nil until Run creates it
+	schedule atomic.Pointer[scheduler.BalancedSchedule]
+	// refreshing is true while the stats are being refreshed
+	refreshing atomic.Bool
+	// now is the end of the clips tracking window
+	now func() time.Time
+	// clipsWindowEnd is the end of the last clips tracking window of every
+	// broadcaster
+	clipsWindowEnd map[string]time.Time
 
 	TrackingCycleMinutes     int
 	ClipTrackingMaxDeepLevel int
 	ClipTrackingWindowHours  int
 	ClipViewThreshold        int
 	ClipViewWindowSize       int
+	// ClipTrackingConcurrency is the max number of clip requests in flight
+	// when fetching the clips of a broadcaster
+	ClipTrackingConcurrency int
+	// MinRateLimitBudget is the number of points of the Twitch rate limit
+	// budget needed to track a broadcaster. If there are less, the tracker
+	// waits for the bucket to refill
+	MinRateLimitBudget int
+	// TrackingRetryMinutes is the delay of the retry of a broadcaster whose
+	// tracking failed, instead of waiting for the next cycle
+	TrackingRetryMinutes int
+	// TrackingMaxRetries is the max number of retries in a row of a
+	// broadcaster. 0 disables them, but New replaces a 0 of TrackerOpts with
+	// cfg.TrackingMaxRetries
+	TrackingMaxRetries int
 
 	// Useful for testing. Run won't FetchVods/Clips if true. Not available in
 	// production mode
@@ -72,14 +114,46 @@ func (t *Tracker) Run() error {
 	t.lastVIDByStreamer.FromDB(t.db)
 
 	l.Info().Msg("initializing scheduler")
-	bs := scheduler.New(scheduler.BalancedScheduleOpts{
+	opts := scheduler.BalancedScheduleOpts{
 		CycleSize:        uint(t.TrackingCycleMinutes),
 		EstimatedObjects: uint(lenbc),
 		Salt:             cfg.BalancerSalt,
-	})
+		Aligned:          cfg.AlignedSchedules,
+		// broadcasters of a minute that starts while the previous one is
+		// still being tracked are tracked right after it
+		Overflow: scheduler.OverflowMerge,
+		// retries are pointless if the broadcaster is tracked soon anyway
+		OneShotDedup:      time.Duration(t.TrackingRetryMinutes) * time.Minute,
+		OneShotsPerMinute: uint(cfg.TrackingRetriesPerMinute),
+		Spread:            cfg.SpreadSchedules,
+	}
+	if cfg.PersistSchedules {
+		// resume at the same minute of the cycle after a restart so every
+		// streamer keeps its refresh interval
+		opts.Store = repo.NewScheduleStore(t.db, repo.TrackerSchedule)
+	}
+	if cfg.DistributedTracking {
+		// minutes are sharded across the trackers. A tracker is dead after
+		// missing a few heartbeats, one per minute
+		opts.Coordinator = repo.NewScheduleLeases(t.db, repo.TrackerSchedule, cfg.WorkerID, trackerLeaseTTL)
+		// every tracker must run a broadcaster in the same minute whatever the
+		// order they are added in or the number of broadcasters it sees
+		opts.BalanceStrategy = scheduler.StrategyJump(uint32(t.TrackingCycleMinutes))
+		l.Info().Msgf("distributed tracking enabled (worker:%s)", cfg.WorkerID)
+	} else {
+		// broadcasters are weighted by the requests needed to track them, so
+		// the requests are spread evenly across the cycle. Every tracker of a
+		// distributed schedule would observe different weights
+		opts.BalanceStrategy = scheduler.StrategyLoad(uint(t.TrackingCycleMinutes))
+		opts.RebalanceCycles = uint(cfg.TrackingRebalanceCycles)
+	}
+	bs := scheduler.New(opts)
+	t.schedule.Store(bs)
+	tracked := make([]string, 0, lenbc)
 	for _, streamer := range streamers {
-		bs.Add(streamer.BcID)
+		tracked = append(tracked, streamer.BcID)
 	}
+	bs.AddMany(tracked...)
 	cs := bs.CycleSize()
 	l.Info().
 		Msgf("starting scheduler real-time tracking (cycle_size=%d, estimated_streamers=%d)",
@@ -92,58 +166,76 @@ func (t *Tracker) Run() error {
 		// For every scheduler tick we get the minute (or unit we're using) and the
 		// list of streamers to be invoked within that minute
 		case m := <-bs.RealTime():
-			for _, bid := range m.Objects {
-				bid := bid
+			if len(m.Merged) > 0 {
+				overflow := bs.OverflowStats()
+				l.Warn().Msgf("tracking is falling behind, min:%v merged into min:%d (late:%s, merged_total:%d)",
+					m.Merged, m.Min, m.Late.Round(time.Second), overflow.Merged)
+			}
+			// broadcasters requeued while the Twitch API was unavailable go first
+			requeued := t.requeued.len()
+			bids := t.requeued.drain(m.Objects)
+			interrupted := false
+			for i, bid := range bids {
 				if !cfg.IsProd && t.FakeRun {
 					l.Warn().Msg("skipping run in FakeRun mode")
 					continue
 				}
+				// broadcasters are spread across the minute, requeued ones go
+				// right away
+				if i >= requeued {
+					if err := m.Wait(t.ctx, i-requeued); err != nil {
+						interrupted = true
+						break
+					}
+				}
+				// Pause while the Twitch API circuit breaker is open instead of
+				// blocking the scheduler with requests that would fail. The
+				// remaining broadcasters are tracked in the next minutes.
+				if t.hx.BreakerState() == helix.BreakerOpen {
+					t.requeued.add(bids[i:]...)
+					l.Warn().Msgf("twitch api unavailable, pausing tracking (requeued:%d)", t.requeued.len())
+					break
+				}
 
 				// Execute sequantially streamer requests. Streamer by streamer. We are
 				// in no hurry and we don't want to be rate-limited.
 				//
 				// As we are adding more and more streamers and we get closer to the
-				// limit and if rate-limiting is limiting by seconds too, we may want
-				// to change the unit of minutes to seconds to make it easier to crunch
-				// the numbers to find out rate limits and the right cycle size
-				clips, err := t.FetchClips(bid)
-				if err != nil {
-					if errors.Is(err, ErrEmptyClips) {
-						// TODO - track maybe track this too. Add no clips count to db
-						l.Warn().Msgf("no clips found (bid:%s)", bid)
-					} else {
-						l.Err(err).Msgf("failed to fetch clips (bid:%s)", bid)
-					}
-				}
-				vods, err := t.FetchVods(bid)
-				if err != nil {
-					if errors.Is(err, ErrEmptyVODs) {
-						// TODO - update tracked_channels.seen_inactive_count
-						l.Warn().Msgf("no VODs found (bid:%s)", bid)
-					} else {
-						l.Err(err).Msg("failed to fetch VODs")
-					}
+				// limit and if rate-limiting is limiting by seconds too, the requests
+				// of a minute are spread inside it with SPREAD_SCHEDULES. The unit of
+				// minutes can also be changed to seconds with the scheduler Freq to
+				// make it easier to crunch the numbers to find out rate limits and
+				// the right cycle size
+				if err := t.pace(); err != nil {
+					// stopping, the minute is not run
+					interrupted = true
+					break
 				}
-
-				lenc, lenv := len(clips), len(vods)
-				if lenc > 0 {
-					if err := repo.UpsertClips(t.db, clips); err != nil {
-						l.Err(err).Msgf("failed to upsert clips (clips:%d)",
-							lenc,
-						)
-					}
+				// tracking is sequential, every request sent meanwhile is of bid
+				before := t.hx.RequestStats().Attempts
+				err := t.track(bid, m.Min, cs)
+				if errors.Is(err, helix.ErrCircuitOpen) {
+					t.requeued.add(bids[i:]...)
+					l.Warn().Msgf("twitch api unavailable, pausing tracking (requeued:%d)", t.requeued.len())
+					break
 				}
-				if lenv > 0 {
-					if err := repo.UpsertVods(t.db, vods); err != nil {
-						l.Err(err).Msgf("failed to upsert VODs (VODs:%d)",
-							lenv,
-						)
-					}
+				if err != nil {
+					t.retry(bs, bid)
+				} else {
+					delete(t.retries, bid)
 				}
-				l.Info().Msgf(
-					"[balanced_key:%d/%d] updated clips:%d and VODs:%d (bid:%s)",
-					m.Min, cs-1, lenc, lenv, bid,
-				)
+				bs.Observe(bid, float64(t.hx.RequestStats().Attempts-before))
+			}
+			// with a distributed schedule no other tracker runs the minute
+			// from now on. If stopping it is run by another one
+			if !interrupted {
+				m.Done()
+			}
+			// Stats are aggregated once per cycle, when every streamer has been
+			// updated
+			if m.Includes(scheduler.Minute(cs-1)) && !(!cfg.IsProd && t.FakeRun) {
+				l.Info().Msg("tracking cycle completed, refreshing stats")
+				t.refreshStats()
 			}
 		case <-t.ctx.Done():
 			l.Info().Msg("stopping scheduler real-time tracking")
@@ -154,6 +246,221 @@ func (t *Tracker) Run() error {
 	}
 }
 
+// refreshStats refreshes the stats in the background so a slow refresh
+// doesn't delay the next minutes. It is skipped if the previous refresh is
+// still running
+func (t *Tracker) refreshStats() {
+	l := log.With().Str("ctx", "tracker").Logger()
+	if !t.refreshing.CompareAndSwap(false, true) {
+		l.Warn().Msg("previous stats refresh still running, skipping")
+		return
+	}
+	go func() {
+		defer t.refreshing.Store(false)
+		ctx, cancel := context.WithTimeout(t.ctx, statsRefreshTimeout)
+		defer cancel()
+		start := time.Now()
+		if err := stats.Refresh(ctx, t.db); err != nil {
+			l.Err(err).Msg("failed to refresh stats")
+			return
+		}
+		l.Info().Msgf("stats refreshed in %s", time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+// Schedule returns the tracking schedule, nil until Run creates it. Useful
+// to inspect it, see scheduler.DebugHandler
+func (t *Tracker) Schedule() *scheduler.BalancedSchedule {
+	return t.schedule.Load()
+}
+
+// retry tracks the broadcaster again in TrackingRetryMinutes, up to
+// TrackingMaxRetries times in a row
+func (t *Tracker) retry(bs *scheduler.BalancedSchedule, bid string) {
+	l := log.With().Str("ctx", "tracker").Logger()
+	if t.retries == nil {
+		t.retries = make(map[string]int)
+	}
+	n := t.retries[bid]
+	if n >= t.TrackingMaxRetries {
+		if n > 0 {
+			l.Warn().Msgf("giving up after %d retries, tracking in the next cycle (bid:%s)", n, bid)
+		}
+		delete(t.retries, bid)
+		return
+	}
+	t.retries[bid] = n + 1
+	delay := time.Duration(t.TrackingRetryMinutes) * time.Minute
+	bs.Enqueue(bid, time.Now().Add(delay))
+	l.Info().Msgf("retrying in %s (bid:%s retry:%d)", delay, bid, n+1)
+}
+
+// track fetches the clips and VODs of a broadcaster and stores them. If the
+// Twitch API circuit breaker opens meanwhile nothing is stored and it returns
+// helix.ErrCircuitOpen so the broadcaster can be tracked later. If the clips
+// or VODs could not be fetched or stored it returns ErrTrackIncomplete after
+// storing the rest.
+func (t *Tracker) track(bid string, min scheduler.Minute, cs uint) error {
+	l := log.With().Str("ctx", "tracker").Logger()
+	failed := false
+	// ranges stored in previous cycles go first, so the ones found incomplete
+	// now are retried in the next one
+	retried, err := t.RetryClipRanges(bid)
+	if err != nil {
+		if errors.Is(err, helix.ErrCircuitOpen) {
+			return err
+		}
+		l.Err(err).Msgf("failed to retry incomplete clip ranges (bid:%s)", bid)
+	}
+	clips, err := t.FetchClips(bid)
+	if err != nil {
+		if errors.Is(err, helix.ErrCircuitOpen) {
+			return err
+		}
+		if errors.Is(err, ErrEmptyClips) {
+			// TODO - track maybe track this too. Add no clips count to db
+			l.Warn().Msgf("no clips found (bid:%s)", bid)
+		} else {
+			l.Err(err).Msgf("failed to fetch clips (bid:%s)", bid)
+			failed = true
+		}
+	}
+	if len(retried) > 0 {
+		// a clip can't be upserted twice in the same statement
+		clips = helix.Deduplicate(append(clips, retried...), func(c *helix.Clip) string {
+			return c.ClipID
+		})
+	}
+	vods, err := t.FetchVods(bid)
+	if err != nil {
+		if errors.Is(err, helix.ErrCircuitOpen) {
+			return err
+		}
+		if errors.Is(err, ErrEmptyVODs) {
+			// TODO - update tracked_channels.seen_inactive_count
+			l.Warn().Msgf("no VODs found (bid:%s)", bid)
+		} else {
+			l.Err(err).Msg("failed to fetch VODs")
+			failed = true
+		}
+	}
+
+	lenc, lenv := len(clips), len(vods)
+	// events are built before upserting to compare with the stored rows
+	var events []*webhook.Event
+	if t.webhooks != nil {
+		events, err = t.webhookEvents(vods, clips)
+		if err != nil {
+			l.Err(err).Msgf("failed to build webhook events (bid:%s)", bid)
+		}
+	}
+	// only set when a clip or VOD is new or changed
+	updated := false
+	if lenc > 0 {
+		err := repo.UpsertClips(t.db, clips)
+		if err == nil {
+			updated = true
+		} else if !errors.Is(err, repo.ErrNoRowsAffected) {
+			l.Err(err).Msgf("failed to upsert clips (clips:%d)",
+				lenc,
+			)
+			failed = true
+		}
+	}
+	if lenv > 0 {
+		err := repo.UpsertVods(t.db, vods)
+		if err == nil {
+			updated = true
+		} else if !errors.Is(err, repo.ErrNoRowsAffected) {
+			l.Err(err).Msgf("failed to upsert VODs (VODs:%d)",
+				lenv,
+			)
+			failed = true
+		}
+	}
+	// invalidates the cached feeds of the channel
+	if updated {
+		if err := repo.SetChannelUpdated(t.db, bid); err != nil {
+			l.Err(err).Msgf("failed to set channel as updated (bid:%s)", bid)
+		}
+		if len(events) > 0 {
+			if err := t.webhooks.Emit(t.ctx, events...); err != nil {
+				l.Err(err).Msgf("failed to emit webhook events (bid:%s)", bid)
+			}
+		}
+	}
+	l.Info().Msgf(
+		"[balanced_key:%d/%d] updated clips:%d and VODs:%d (bid:%s)",
+		min, cs-1, lenc, lenv, bid,
+	)
+	if failed {
+		return ErrTrackIncomplete
+	}
+	return nil
+}
+
+// requeue is an ordered set of broadcaster IDs. The zero value is ready to
+// use.
+type requeue struct {
+	bids []string
+	set  map[string]struct{}
+}
+
+func (q *requeue) add(bids ...string) {
+	if q.set == nil {
+		q.set = make(map[string]struct{}, len(bids))
+	}
+	for _, bid := range bids {
+		if _, ok := q.set[bid]; ok {
+			continue
+		}
+		q.set[bid] = struct{}{}
+		q.bids = append(q.bids, bid)
+	}
+}
+
+// drain empties the queue, returning its broadcasters followed by the ones in
+// next that were not queued
+func (q *requeue) drain(next []string) []string {
+	if len(q.bids) == 0 {
+		return next
+	}
+	q.add(next...)
+	bids := q.bids
+	q.bids, q.set = nil, nil
+	return bids
+}
+
+func (q *requeue) len() int {
+	return len(q.bids)
+}
+
+// pace waits until the bucket is refilled if the rate limit budget is below
+// MinRateLimitBudget. Requests would be delayed by the helix rate limit
+// governor anyway, but this way a broadcaster is never left half tracked.
+//
+// Returns an error only if the tracker is stopped while waiting.
+func (t *Tracker) pace() error {
+	b := t.hx.RateLimitBudget()
+	if !b.Known || b.Remaining >= t.MinRateLimitBudget {
+		return nil
+	}
+	d := time.Until(b.ResetAt)
+	if d <= 0 {
+		return nil
+	}
+	log.Warn().Str("ctx", "tracker").
+		Msgf("rate limit budget running low (remaining:%d/%d), waiting %s", b.Remaining, b.Limit, d)
+	tm := time.NewTimer(d)
+	defer tm.Stop()
+	select {
+	case <-tm.C:
+		return nil
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	}
+}
+
 // FetchVods retrieves VODS for a given broadcaster ID up to the last vod ID,
 // including the last VOD ID in the result. Then it updates the lastVODs table
 // with the new most recent VOD. The last VOD ID is included and fetched again
@@ -188,15 +495,17 @@ func (t *Tracker) FetchVods(bid string) ([]*helix.VOD, error) {
 // ClipTrackingWindowHours. Clips obtained will stop if they don't meet a view
 // threshold in a rolling window average. Both specified correspondingly by
 // ClipViewThreshold and ClipViewWindowSize. See hx.DeepClips for more details.
+// The ranges that reached ClipTrackingMaxDeepLevel are stored to be retried
+// in the next cycle, see RetryClipRanges.
 //
 // TODO - Test this. This was moved to helix.DeepClips and the unit test is
 // very similar to this function so if it passes this should pass too, but it
 // may be interesting to test it even if it is duplicated just in case this
 // changes in the future
 func (t *Tracker) FetchClips(bid string) ([]*helix.Clip, error) {
-	now := time.Now()
+	now := t.now()
 	from := now.Add(-time.Duration(t.ClipTrackingWindowHours) * time.Hour)
-	clips, err := t.hx.DeepClips(&helix.DeepClipsParams{
+	res, err := t.hx.DeepClips(&helix.DeepClipsParams{
 		ClipsParams: &helix.ClipsParams{
 			BroadcasterID:            bid,
 			StartedAt:                from,
@@ -204,7 +513,8 @@ func (t *Tracker) FetchClips(bid string) ([]*helix.Clip, error) {
 			StopViewsThreshold:       t.ClipViewThreshold,
 			ViewsThresholdWindowSize: t.ClipViewWindowSize,
 		},
-		MaxDeepLvl: t.ClipTrackingMaxDeepLevel,
+		MaxDeepLvl:  t.ClipTrackingMaxDeepLevel,
+		Concurrency: t.ClipTrackingConcurrency,
 	})
 	if err != nil {
 		if errors.Is(err, helix.ErrItemsEmpty) {
@@ -212,7 +522,72 @@ func (t *Tracker) FetchClips(bid string) ([]*helix.Clip, error) {
 		}
 		return nil, err
 	}
-	return clips, nil
+	t.saveIncompleteClipRanges(bid, res, 0)
+	if t.clipsWindowEnd == nil {
+		t.clipsWindowEnd = make(map[string]time.Time)
+	}
+	t.clipsWindowEnd[bid] = now
+	return res.Clips, nil
+}
+
+// webhookEvents compares the fetched VODs and clips with the stored ones and
+// returns the webhook events they trigger. Must be called before upserting
+// them.
+//
+// New VODs trigger vod.created and VODs whose duration, title or thumbnail
+// changed trigger vod.updated. Every clip triggers clip.threshold_reached
+// with its previous views, endpoints decide if their threshold was reached.
+func (t *Tracker) webhookEvents(vods []*helix.VOD, clips []*helix.Clip) ([]*webhook.Event, error) {
+	events := make([]*webhook.Event, 0, len(vods))
+	if len(vods) > 0 {
+		ids := make([]string, 0, len(vods))
+		for _, v := range vods {
+			ids = append(ids, v.VideoID)
+		}
+		stored, err := repo.Vods(t.db, &repo.VodsParams{
+			VideoIDs: ids,
+			First:    len(ids),
+			Context:  t.ctx,
+		})
+		if err != nil {
+			return nil, err
+		}
+		prev := make(map[string]*helix.VOD, len(stored))
+		for _, v := range stored {
+			prev[v.VideoID] = v
+		}
+		for _, v := range vods {
+			p, ok := prev[v.VideoID]
+			if !ok {
+				events = append(events, webhook.NewVodEvent(webhook.EventVodCreated, v))
+				continue
+			}
+			d, _ := v.DurationSeconds()
+			if d != p.Duration || v.Title != p.Title || v.ThumbnailURL != p.ThumbnailURL {
+				events = append(events, webhook.NewVodEvent(webhook.EventVodUpdated, v))
+			}
+		}
+	}
+	if len(clips) > 0 {
+		ids := make([]string, 0, len(clips))
+		for _, c := range clips {
+			ids = append(ids, c.ClipID)
+		}
+		views, err := repo.ClipViews(t.db, t.ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range clips {
+			prev, ok := views[c.ClipID]
+			if !ok {
+				prev = -1
+			}
+			if prev < c.ViewCount {
+				events = append(events, webhook.NewClipEvent(c, prev))
+			}
+		}
+	}
+	return events, nil
 }
 
 // Deprecated: use hx.DeepClips instead
@@ -272,12 +647,26 @@ type TrackerOpts struct {
 	Context context.Context
 	Storage database.Storage
 	Helix   *helix.Helix
+	// Webhooks delivers events about new VODs and clips. Optional
+	Webhooks *webhook.Dispatcher
 
 	TrackingCycleMinutes     int
 	ClipTrackingMaxDeepLevel int
 	ClipTrackingWindowHours  int
 	ClipViewThreshold        int
 	ClipViewWindowSize       int
+	ClipTrackingConcurrency  int
+	// Defaults to an estimate of the requests of a DeepClips down to
+	// ClipTrackingMaxDeepLevel, the retries of the incomplete ranges one level
+	// deeper and the VODs one
+	MinRateLimitBudget   int
+	TrackingRetryMinutes int
+	// Defaults to cfg.TrackingMaxRetries when 0, so retries are disabled
+	// with TRACKING_MAX_RETRIES=0
+	TrackingMaxRetries int
+	// Now returns the current time, the end of the clips tracking window.
+	// Defaults to time.Now
+	Now func() time.Time
 }
 
 func New(opts *TrackerOpts) *Tracker {
@@ -299,15 +688,39 @@ func New(opts *TrackerOpts) *Tracker {
 	if opts.ClipViewWindowSize == 0 {
 		opts.ClipViewWindowSize = cfg.ClipViewWindowSize
 	}
+	if opts.ClipTrackingConcurrency == 0 {
+		opts.ClipTrackingConcurrency = cfg.ClipTrackingConcurrency
+	}
+
+	if opts.TrackingRetryMinutes == 0 {
+		opts.TrackingRetryMinutes = cfg.TrackingRetryMinutes
+	}
+	if opts.TrackingMaxRetries == 0 {
+		opts.TrackingMaxRetries = cfg.TrackingMaxRetries
+	}
+
+	if opts.MinRateLimitBudget == 0 {
+		opts.MinRateLimitBudget = 2<<opts.ClipTrackingMaxDeepLevel +
+			clipRangesPerTrack*(2<<(opts.ClipTrackingMaxDeepLevel+1)) + 1
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
 
 	tk := &Tracker{
 		ctx:                      opts.Context,
 		hx:                       opts.Helix,
+		webhooks:                 opts.Webhooks,
+		now:                      opts.Now,
 		TrackingCycleMinutes:     opts.TrackingCycleMinutes,
 		ClipTrackingMaxDeepLevel: opts.ClipTrackingMaxDeepLevel,
 		ClipTrackingWindowHours:  opts.ClipTrackingWindowHours,
 		ClipViewThreshold:        opts.ClipViewThreshold,
 		ClipViewWindowSize:       opts.ClipViewWindowSize,
+		ClipTrackingConcurrency:  opts.ClipTrackingConcurrency,
+		MinRateLimitBudget:       opts.MinRateLimitBudget,
+		TrackingRetryMinutes:     opts.TrackingRetryMinutes,
+		TrackingMaxRetries:       opts.TrackingMaxRetries,
 	}
 	if opts.Storage != nil {
 		tk.db = opts.Storage.Conn()
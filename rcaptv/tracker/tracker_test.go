@@ -84,6 +84,77 @@ func TestFetchVods(t *testing.T) {
 	}
 }
 
+// TestFetchReplay fetches VODs and clips from the responses recorded in a
+// cassette. Record it again with HELIX_CASSETTE_MODE=record.
+func TestFetchReplay(t *testing.T) {
+	t.Parallel()
+	bid := "58753574"
+	mostRecentQuery := "first=1&period=week&type=archive&user_id=" + bid
+	stopAtQuery := "first=100&period=week&type=archive&user_id=" + bid
+	hx, c, fake := test.ReplayHelix(t, "testdata/cassettes/track.json")
+	tracker := New(&TrackerOpts{
+		Helix:                    hx,
+		ClipTrackingMaxDeepLevel: 3,
+		ClipTrackingWindowHours:  7 * 24,
+		ClipViewThreshold:        300,
+		ClipViewWindowSize:       4,
+		Now:                      func() time.Time { return test.ReplayNow },
+	})
+	tracker.lastVIDByStreamer = make(lastVODTable, 1)
+
+	clips, err := tracker.FetchClips(bid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.FetchVods(bid); err != nil {
+		t.Fatal(err)
+	}
+	latest := tracker.lastVIDByStreamer[bid]
+	// the VOD last seen by the tracker is the third most recent one
+	var lastVID string
+	if fake != nil {
+		var vids []string
+		for _, v := range fake.Dataset().Videos {
+			if v.BroadcasterID == bid {
+				vids = append(vids, v.VideoID)
+			}
+		}
+		lastVID = vids[2]
+	} else {
+		lastVID = test.Recorded[*helix.VOD](t, c, "/videos", stopAtQuery)[0][2].VideoID
+	}
+	tracker.lastVIDByStreamer[bid] = lastVID
+	vods, err := tracker.FetchVods(bid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake != nil {
+		// recording, the responses are checked when replaying them
+		return
+	}
+
+	test.CheckReplayedClips(t, c, bid, clips)
+	mostRecent := test.Recorded[*helix.VOD](t, c, "/videos", mostRecentQuery)[0]
+	if want := mostRecent[0].VideoID; latest != want {
+		t.Fatalf("expected lastVOD to be %s, got %s", want, latest)
+	}
+	var want []string
+	for _, v := range test.Recorded[*helix.VOD](t, c, "/videos", stopAtQuery)[0] {
+		want = append(want, v.VideoID)
+		if v.VideoID == lastVID {
+			break
+		}
+	}
+	if len(vods) != len(want) {
+		t.Fatalf("expected %d vods down to %s, got %d", len(want), lastVID, len(vods))
+	}
+	for i, v := range vods {
+		if v.VideoID != want[i] {
+			t.Fatalf("expected vod %d to be %s, got %s", i, want[i], v.VideoID)
+		}
+	}
+}
+
 func TestTrackerStop(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -121,3 +192,29 @@ func TestTrackerStop(t *testing.T) {
 		}
 	}
 }
+
+func TestRequeue(t *testing.T) {
+	t.Parallel()
+	var q requeue
+	next := []string{"1", "2"}
+	if got := q.drain(next); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Fatalf("expected empty queue to return next, got %v", got)
+	}
+	q.add("3", "1", "3")
+	if q.len() != 2 {
+		t.Fatalf("expected duplicates to be queued once, got %d", q.len())
+	}
+	want := []string{"3", "1", "2"}
+	got := q.drain(next)
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	if q.len() != 0 {
+		t.Fatalf("expected drained queue, got %d", q.len())
+	}
+}
@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenDestination is returned for endpoints in the internal network of
+// the tracker, like loopback, private or link-local addresses, so users can't
+// reach internal services or the cloud metadata through the webhooks.
+var ErrForbiddenDestination = errors.New("forbidden webhook destination")
+
+// forbiddenPrefixes are the special purpose ranges not covered by the
+// netip.Addr methods used by AllowedAddr
+var forbiddenPrefixes = []netip.Prefix{
+	// "this network"
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// shared address space, used by some cloud metadata services too
+	netip.MustParsePrefix("100.64.0.0/10"),
+	// benchmarking
+	netip.MustParsePrefix("198.18.0.0/15"),
+	// reserved, including the broadcast address
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// AllowedAddr reports whether events can be delivered to the address. Only
+// public unicast addresses are allowed.
+func AllowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, p := range forbiddenPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckDestination returns ErrForbiddenDestination if the host of u is an
+// address not allowed or a name resolving to one. Names may resolve to other
+// addresses later, so the clients returned by NewClient check the addresses
+// again when connecting.
+func CheckDestination(ctx context.Context, u *url.URL) error {
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !AllowedAddr(addr) {
+			return fmt.Errorf("%w: %s", ErrForbiddenDestination, addr)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !AllowedAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenDestination, host, addr)
+		}
+	}
+	return nil
+}
+
+// NewClient returns the client used to deliver events. It refuses to connect
+// to the addresses not allowed by AllowedAddr, checked after resolving the
+// host of every connection, redirects included, so DNS rebinding can't get
+// around CheckDestination.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   controlDestination,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// no proxy from the environment, it would be checked instead of the
+			// endpoint
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// controlDestination is called with the resolved address right before
+// connecting
+func controlDestination(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !AllowedAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenDestination, addr)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAllowedAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"100.100.100.200", false},
+		{"0.0.0.0", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		// IPv4-mapped IPv6
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+	}
+	for _, tt := range tests {
+		if got := AllowedAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("%s: expected allowed %t, got %t", tt.addr, tt.want, got)
+		}
+	}
+}
+
+func TestCheckDestination(t *testing.T) {
+	for _, raw := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"https://192.168.1.10/hook",
+		"http://localhost:4000/hook",
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckDestination(context.Background(), u); !errors.Is(err, ErrForbiddenDestination) {
+			t.Errorf("%s: expected ErrForbiddenDestination, got %v", raw, err)
+		}
+	}
+	u, _ := url.Parse("https://8.8.8.8/hook")
+	if err := CheckDestination(context.Background(), u); err != nil {
+		t.Fatalf("expected a public address to be allowed, got %v", err)
+	}
+}
+
+func TestNewClientRefusesInternalAddresses(t *testing.T) {
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer sv.Close()
+
+	// the check happens when connecting, whatever the URL looked like when
+	// the endpoint was created
+	_, err := NewClient(time.Second).Get(sv.URL)
+	if !errors.Is(err, ErrForbiddenDestination) {
+		t.Fatalf("expected ErrForbiddenDestination, got %v", err)
+	}
+}
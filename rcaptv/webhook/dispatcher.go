@@ -0,0 +1,322 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	cfg "pedro.to/rcaptv/config"
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	"pedro.to/rcaptv/repo"
+)
+
+var ErrDispatcherStopped = errors.New("dispatcher stopped")
+
+type DispatcherOpts struct {
+	DB *sql.DB
+	// Client used to deliver events. Defaults to NewClient with a 10s timeout,
+	// refusing to connect to internal addresses
+	Client *http.Client
+	// Workers is the number of concurrent deliveries. Defaults to 4
+	Workers int
+	// QueueSize is the number of pending deliveries. Events emitted while the
+	// queue is full are dropped. Defaults to 1024
+	QueueSize int
+	// MaxAttempts of a delivery before giving up. Defaults to
+	// cfg.WebhookDeliveryMaxAttempts
+	MaxAttempts int
+	// BaseBackoff is the wait after the first failed attempt, doubled on every
+	// attempt up to MaxBackoff. Defaults to 1s and 5m
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// MaxConsecutiveFailures is the number of failed deliveries in a row after
+	// which an endpoint is disabled. Defaults to
+	// cfg.WebhookMaxConsecutiveFailures
+	MaxConsecutiveFailures int
+}
+
+type delivery struct {
+	ev *Event
+	ep *model.WebhookEndpoints
+	// attempt is the number of the next attempt, starting at 1
+	attempt int
+	// body is encoded on the first attempt and reused by the retries
+	body []byte
+}
+
+// Dispatcher delivers events to the matching endpoints in the background,
+// retrying failed deliveries with exponential backoff. Every attempt is logged
+// in webhook_deliveries.
+//
+// Workers make a single attempt of a delivery at a time. Failed deliveries
+// wait for their retry in a timer, out of the workers, so failing endpoints
+// don't delay the deliveries to the others.
+type Dispatcher struct {
+	db     *sql.DB
+	client *http.Client
+	opts   *DispatcherOpts
+
+	queue  chan *delivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	mu sync.Mutex
+	// retries are the timers of the deliveries waiting for their next attempt
+	retries map[*delivery]*time.Timer
+}
+
+func NewDispatcher(opts *DispatcherOpts) *Dispatcher {
+	if opts.Client == nil {
+		opts.Client = NewClient(10 * time.Second)
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = cfg.WebhookDeliveryMaxAttempts
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	if opts.MaxConsecutiveFailures <= 0 {
+		opts.MaxConsecutiveFailures = cfg.WebhookMaxConsecutiveFailures
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		db:      opts.DB,
+		client:  opts.Client,
+		opts:    opts,
+		queue:   make(chan *delivery, opts.QueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		retries: make(map[*delivery]*time.Timer),
+	}
+}
+
+// Start the delivery workers
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for {
+				select {
+				case dl := <-d.queue:
+					d.deliver(dl)
+				case <-d.ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop the workers, aborting the deliveries waiting for a retry. Pending
+// deliveries are discarded.
+func (d *Dispatcher) Stop() {
+	d.once.Do(func() {
+		d.cancel()
+		d.mu.Lock()
+		for dl, t := range d.retries {
+			t.Stop()
+			delete(d.retries, dl)
+		}
+		d.mu.Unlock()
+		d.wg.Wait()
+		if n := len(d.queue); n > 0 {
+			log.Warn().Str("ctx", "webhook").Msgf("%d pending deliveries discarded", n)
+		}
+	})
+}
+
+// Emit queues the delivery of events to every enabled endpoint matching them.
+// It doesn't wait for the deliveries. Returns an error if the endpoints can't
+// be loaded.
+func (d *Dispatcher) Emit(ctx context.Context, events ...*Event) error {
+	if d.ctx.Err() != nil {
+		return ErrDispatcherStopped
+	}
+	l := log.With().Str("ctx", "webhook").Logger()
+	// endpoints by broadcaster, events usually come in batches of the same one
+	endpoints := make(map[string][]*model.WebhookEndpoints, 1)
+	for _, ev := range events {
+		eps, ok := endpoints[ev.BroadcasterID]
+		if !ok {
+			var err error
+			eps, err = repo.WebhookEndpoints(d.db, &repo.WebhookEndpointsParams{
+				BroadcasterID: ev.BroadcasterID,
+				Context:       ctx,
+			})
+			if err != nil {
+				return err
+			}
+			endpoints[ev.BroadcasterID] = eps
+		}
+		for _, ep := range eps {
+			if !ev.Matches(ep) {
+				continue
+			}
+			select {
+			case d.queue <- &delivery{ev: ev, ep: ep, attempt: 1}:
+			default:
+				l.Warn().Msgf("delivery queue full, dropping event %s (%s) for endpoint %d",
+					ev.ID, ev.Type, ep.EndpointID)
+			}
+		}
+	}
+	return nil
+}
+
+// deliver makes the next attempt of the delivery. If it fails, the delivery is
+// retried later or the failure is counted against the endpoint after the last
+// attempt.
+func (d *Dispatcher) deliver(dl *delivery) {
+	l := log.With().Str("ctx", "webhook").Logger()
+	if dl.body == nil {
+		body, err := dl.ev.Body(dl.ep)
+		if err != nil {
+			l.Err(err).Msgf("failed to encode event %s", dl.ev.ID)
+			return
+		}
+		dl.body = body
+	}
+
+	start := time.Now()
+	status, err := d.send(dl)
+	if d.ctx.Err() != nil {
+		// stopping, the attempt was aborted and it's not the endpoint fault
+		return
+	}
+	d.logDelivery(dl, status, err, time.Since(start))
+	if err == nil {
+		if err := repo.WebhookEndpointSucceeded(d.db, int64(dl.ep.EndpointID)); err != nil {
+			l.Err(err).Msgf("failed to reset endpoint failures (endpoint:%d)", dl.ep.EndpointID)
+		}
+		return
+	}
+	l.Debug().Err(err).Msgf("delivery attempt %d/%d of event %s failed (endpoint:%d)",
+		dl.attempt, d.opts.MaxAttempts, dl.ev.ID, dl.ep.EndpointID)
+	if dl.attempt < d.opts.MaxAttempts {
+		d.retry(dl)
+		return
+	}
+
+	disabled, err := repo.WebhookEndpointFailed(d.db, int64(dl.ep.EndpointID), d.opts.MaxConsecutiveFailures)
+	if err != nil {
+		l.Err(err).Msgf("failed to count endpoint failure (endpoint:%d)", dl.ep.EndpointID)
+		return
+	}
+	if disabled {
+		l.Warn().Msgf("endpoint disabled after %d consecutive failed deliveries (endpoint:%d)",
+			d.opts.MaxConsecutiveFailures, dl.ep.EndpointID)
+	}
+}
+
+// retry queues the next attempt of a failed delivery after its backoff.
+// Retries wait for room in the queue instead of being dropped like the events
+// emitted while it's full.
+func (d *Dispatcher) retry(dl *delivery) {
+	wait := d.backoff(dl.attempt)
+	dl.attempt++
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ctx.Err() != nil {
+		return
+	}
+	d.retries[dl] = time.AfterFunc(wait, func() {
+		d.mu.Lock()
+		_, waiting := d.retries[dl]
+		delete(d.retries, dl)
+		d.mu.Unlock()
+		if !waiting {
+			// stopped
+			return
+		}
+		select {
+		case d.queue <- dl:
+		case <-d.ctx.Done():
+		}
+	})
+}
+
+// send makes a single delivery attempt. Any response other than 2xx is an
+// error. Returns the response status code or 0 if there was no response.
+func (d *Dispatcher) send(dl *delivery) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, dl.ep.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return 0, err
+	}
+	// the message ID doesn't change between attempts so receivers can discard
+	// duplicates
+	ts := time.Now().UTC().Format(time.RFC3339)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, dl.ev.ID)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderType, string(dl.ev.Type))
+	req.Header.Set(HeaderSignature, Sign(dl.ep.Secret, dl.ev.ID, ts, dl.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) logDelivery(dl *delivery, status int, err error, dur time.Duration) {
+	row := &model.WebhookDeliveries{
+		EndpointID: dl.ep.EndpointID,
+		EventID:    dl.ev.ID,
+		EventType:  string(dl.ev.Type),
+		Attempt:    int32(dl.attempt),
+		Success:    err == nil,
+		DurationMs: int32(dur.Milliseconds()),
+	}
+	if status != 0 {
+		s := int32(status)
+		row.StatusCode = &s
+	}
+	if err != nil {
+		e := err.Error()
+		row.Error = &e
+	}
+	if err := repo.InsertWebhookDelivery(d.db, row); err != nil {
+		log.Err(err).Str("ctx", "webhook").Msgf("failed to log delivery (endpoint:%d)", dl.ep.EndpointID)
+	}
+}
+
+// backoff returns the wait after the given failed attempt. It doubles with
+// every attempt up to MaxBackoff, with a random jitter of up to half of it so
+// retries of many deliveries don't happen at once.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	b := d.opts.BaseBackoff
+	for i := 1; i < attempt && b < d.opts.MaxBackoff; i++ {
+		b *= 2
+	}
+	if b > d.opts.MaxBackoff {
+		b = d.opts.MaxBackoff
+	}
+	half := b / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
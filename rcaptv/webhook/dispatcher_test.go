@@ -0,0 +1,212 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	"pedro.to/rcaptv/helix"
+	"pedro.to/rcaptv/repo"
+	"pedro.to/rcaptv/test"
+)
+
+var db *sql.DB
+
+func TestMain(m *testing.M) {
+	conn, pool, res := test.SetupPostgres()
+	db = conn
+
+	// Run tests
+	code := m.Run()
+
+	if err := test.CancelPostgres(pool, res); err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(code)
+}
+
+func createEndpoint(t *testing.T, url string) *model.WebhookEndpoints {
+	t.Helper()
+	uid, err := repo.UpsertUser(db, &helix.User{
+		Id:              "90075649",
+		Login:           "illojuan",
+		DisplayName:     "IlloJuan",
+		BroadcasterType: "partner",
+		CreatedAt:       helix.RFC3339Timestamp(time.Date(2015, 5, 2, 0, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep, err := repo.CreateWebhookEndpoint(db, &model.WebhookEndpoints{
+		UserID: int32(uid),
+		URL:    url,
+		Secret: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		repo.DeleteWebhookEndpoint(db, uid, int64(ep.EndpointID))
+	})
+	return ep
+}
+
+// waitDeliveries waits until the endpoint has n logged deliveries
+func waitDeliveries(t *testing.T, id int32, n int) []*model.WebhookDeliveries {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		ds, err := repo.WebhookDeliveries(db, &repo.WebhookDeliveriesParams{EndpointID: int64(id)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ds) >= n {
+			return ds
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %d deliveries", n)
+	return nil
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	var calls atomic.Int32
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first two attempts
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer sv.Close()
+	ep := createEndpoint(t, sv.URL)
+
+	d := NewDispatcher(&DispatcherOpts{
+		DB:          db,
+		Client:      sv.Client(),
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+	})
+	d.Start()
+	defer d.Stop()
+
+	ev := NewVodEvent(EventVodCreated, &helix.VOD{VideoID: "1", BroadcasterID: "58753574"})
+	if err := d.Emit(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	ds := waitDeliveries(t, ep.EndpointID, 3)
+	if ds[0].Attempt != 3 || !ds[0].Success || ds[1].Success || ds[2].Success {
+		t.Fatalf("expected 2 failed attempts and a successful one, got %+v", ds)
+	}
+	if ds[1].StatusCode == nil || *ds[1].StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected failed attempt with status 503, got %+v", ds[1])
+	}
+	for _, dl := range ds {
+		if dl.EventID != ev.ID || dl.EventType != string(EventVodCreated) {
+			t.Fatalf("unexpected delivery %+v", dl)
+		}
+	}
+}
+
+func TestDispatcherDisablesFailingEndpoint(t *testing.T) {
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer sv.Close()
+	ep := createEndpoint(t, sv.URL)
+
+	d := NewDispatcher(&DispatcherOpts{
+		DB:                     db,
+		Client:                 sv.Client(),
+		MaxAttempts:            2,
+		BaseBackoff:            time.Millisecond,
+		MaxConsecutiveFailures: 2,
+	})
+	d.Start()
+	defer d.Stop()
+
+	for i := 0; i < 2; i++ {
+		ev := NewVodEvent(EventVodUpdated, &helix.VOD{VideoID: "1", BroadcasterID: "58753574"})
+		if err := d.Emit(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitDeliveries(t, ep.EndpointID, 4)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		eps, err := repo.WebhookEndpoints(db, &repo.WebhookEndpointsParams{EndpointID: int64(ep.EndpointID)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(eps) == 1 && !eps[0].Enabled {
+			if eps[0].DisabledAt == nil || eps[0].ConsecutiveFailures != 2 {
+				t.Fatalf("unexpected disabled endpoint %+v", eps[0])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected endpoint to be disabled")
+}
+
+func TestDispatcherRetryDoesNotBlockWorkers(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	failingEp := createEndpoint(t, failing.URL)
+	healthyEp := createEndpoint(t, healthy.URL)
+
+	// a single worker and a retry far in the future, the healthy endpoint
+	// would never get the event if the worker waited for it
+	d := NewDispatcher(&DispatcherOpts{
+		DB:          db,
+		Client:      healthy.Client(),
+		Workers:     1,
+		MaxAttempts: 2,
+		BaseBackoff: time.Hour,
+		MaxBackoff:  time.Hour,
+	})
+	d.Start()
+
+	ev := NewVodEvent(EventVodCreated, &helix.VOD{VideoID: "1", BroadcasterID: "58753574"})
+	if err := d.Emit(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	waitDeliveries(t, failingEp.EndpointID, 1)
+	if ds := waitDeliveries(t, healthyEp.EndpointID, 1); !ds[0].Success {
+		t.Fatalf("expected a successful delivery, got %+v", ds[0])
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		d.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Stop not to wait for the pending retry")
+	}
+	ds, err := repo.WebhookDeliveries(db, &repo.WebhookDeliveriesParams{EndpointID: int64(failingEp.EndpointID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 1 {
+		t.Fatalf("expected the retry to be discarded on Stop, got %d attempts", len(ds))
+	}
+}
@@ -0,0 +1,161 @@
+// Package webhook delivers events about tracked channels to the endpoints
+// registered by users.
+//
+// Requests are signed the same way Twitch signs EventSub notifications: the
+// signature header is "sha256=" followed by the hex encoded HMAC-SHA256 of the
+// message ID, the timestamp and the body, using the endpoint secret as key. So
+// receivers can verify them with the same code they use for Twitch.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	"pedro.to/rcaptv/helix"
+)
+
+const (
+	HeaderID        = "Rcaptv-Webhook-Message-Id"
+	HeaderTimestamp = "Rcaptv-Webhook-Message-Timestamp"
+	HeaderSignature = "Rcaptv-Webhook-Message-Signature"
+	HeaderType      = "Rcaptv-Webhook-Message-Type"
+)
+
+type EventType string
+
+const (
+	EventVodCreated           EventType = "vod.created"
+	EventVodUpdated           EventType = "vod.updated"
+	EventClipThresholdReached EventType = "clip.threshold_reached"
+)
+
+// ValidEventType reports whether t is an event type endpoints can filter by
+func ValidEventType(t EventType) bool {
+	switch t {
+	case EventVodCreated, EventVodUpdated, EventClipThresholdReached:
+		return true
+	}
+	return false
+}
+
+// Event emitted by the tracker. Use NewVodEvent or NewClipEvent to create
+// one.
+type Event struct {
+	ID            string
+	Type          EventType
+	BroadcasterID string
+	CreatedAt     time.Time
+
+	vod  *helix.VOD
+	clip *helix.Clip
+	// prevViews is the view count of the clip before this update, -1 for new
+	// clips
+	prevViews int
+}
+
+// NewVodEvent returns a vod.created or vod.updated event
+func NewVodEvent(t EventType, v *helix.VOD) *Event {
+	return &Event{
+		ID:            newID(),
+		Type:          t,
+		BroadcasterID: v.BroadcasterID,
+		CreatedAt:     time.Now().UTC(),
+		vod:           v,
+	}
+}
+
+// NewClipEvent returns a clip.threshold_reached event for a clip that had
+// prevViews before the last update, or -1 if the clip is new. Whether the
+// threshold was reached depends on each endpoint, see Matches.
+func NewClipEvent(c *helix.Clip, prevViews int) *Event {
+	return &Event{
+		ID:            newID(),
+		Type:          EventClipThresholdReached,
+		BroadcasterID: c.BroadcasterID,
+		CreatedAt:     time.Now().UTC(),
+		clip:          c,
+		prevViews:     prevViews,
+	}
+}
+
+// Matches reports whether the event passes the filters of the endpoint.
+//
+// Clip events only match when the clip crossed the endpoint min_views, so an
+// endpoint gets a clip once: when it is first seen with enough views or when
+// it gets enough views on a later update. VOD events ignore min_views.
+func (e *Event) Matches(ep *model.WebhookEndpoints) bool {
+	if !ep.Enabled {
+		return false
+	}
+	if ep.BcID != nil && *ep.BcID != e.BroadcasterID {
+		return false
+	}
+	if ep.EventType != nil && EventType(*ep.EventType) != e.Type {
+		return false
+	}
+	if e.Type == EventClipThresholdReached {
+		min := int(ep.MinViews)
+		return e.prevViews < min && e.clip.ViewCount >= min
+	}
+	return true
+}
+
+// Payload is the body sent to endpoints
+type Payload struct {
+	ID            string      `json:"id"`
+	Type          EventType   `json:"type"`
+	BroadcasterID string      `json:"broadcaster_id"`
+	CreatedAt     time.Time   `json:"created_at"`
+	VOD           *helix.VOD  `json:"vod,omitempty"`
+	Clip          *helix.Clip `json:"clip,omitempty"`
+	// MinViews is the threshold of the endpoint for clip events
+	MinViews *int `json:"min_views,omitempty"`
+}
+
+// Body returns the JSON payload of the event for the endpoint
+func (e *Event) Body(ep *model.WebhookEndpoints) ([]byte, error) {
+	p := &Payload{
+		ID:            e.ID,
+		Type:          e.Type,
+		BroadcasterID: e.BroadcasterID,
+		CreatedAt:     e.CreatedAt,
+		VOD:           e.vod,
+		Clip:          e.clip,
+	}
+	if e.clip != nil {
+		min := int(ep.MinViews)
+		p.MinViews = &min
+	}
+	return json.Marshal(p)
+}
+
+// Sign returns the signature header value of a message, which is the same
+// helix.WebhookHeaders.Valid expects.
+func Sign(secret, id, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+	return string(helix.WebhookEventHMACPrefix) + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random secret for a new endpoint
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pedro.to/rcaptv/gen/tracker/public/model"
+	"pedro.to/rcaptv/helix"
+)
+
+func TestSignIsValidTwitchSignature(t *testing.T) {
+	t.Parallel()
+	body := []byte(`{"id":"1"}`)
+	ts := "2023-06-18T15:31:56Z"
+	h := &helix.WebhookHeaders{
+		ID:        "abc",
+		Timestamp: ts,
+		Signature: Sign("secret", "abc", ts, body),
+		Body:      body,
+	}
+	if !h.Valid([]byte("secret")) {
+		t.Fatal("expected signature to be valid")
+	}
+	if h.Valid([]byte("other_secret")) {
+		t.Fatal("expected signature to be invalid with another secret")
+	}
+	h.Body = []byte(`{"id":"2"}`)
+	if h.Valid([]byte("secret")) {
+		t.Fatal("expected signature to be invalid with another body")
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestEventMatches(t *testing.T) {
+	t.Parallel()
+	vod := NewVodEvent(EventVodCreated, &helix.VOD{VideoID: "1", BroadcasterID: "58753574"})
+	newClip := NewClipEvent(&helix.Clip{ClipID: "a", BroadcasterID: "58753574", ViewCount: 120}, -1)
+	updatedClip := NewClipEvent(&helix.Clip{ClipID: "a", BroadcasterID: "58753574", ViewCount: 120}, 90)
+
+	tests := []struct {
+		name string
+		ev   *Event
+		ep   *model.WebhookEndpoints
+		want bool
+	}{
+		{"no filters", vod, &model.WebhookEndpoints{Enabled: true}, true},
+		{"disabled", vod, &model.WebhookEndpoints{}, false},
+		{"same channel", vod, &model.WebhookEndpoints{Enabled: true, BcID: strPtr("58753574")}, true},
+		{"other channel", vod, &model.WebhookEndpoints{Enabled: true, BcID: strPtr("90075649")}, false},
+		{"same type", vod, &model.WebhookEndpoints{Enabled: true, EventType: strPtr("vod.created")}, true},
+		{"other type", vod, &model.WebhookEndpoints{Enabled: true, EventType: strPtr("vod.updated")}, false},
+		{"vod ignores min views", vod, &model.WebhookEndpoints{Enabled: true, MinViews: 1000}, true},
+		{"new clip without threshold", newClip, &model.WebhookEndpoints{Enabled: true}, true},
+		{"new clip over threshold", newClip, &model.WebhookEndpoints{Enabled: true, MinViews: 100}, true},
+		{"new clip under threshold", newClip, &model.WebhookEndpoints{Enabled: true, MinViews: 200}, false},
+		{"clip crossed threshold", updatedClip, &model.WebhookEndpoints{Enabled: true, MinViews: 100}, true},
+		{"clip already over threshold", updatedClip, &model.WebhookEndpoints{Enabled: true, MinViews: 50}, false},
+		{"clip reached exactly", updatedClip, &model.WebhookEndpoints{Enabled: true, MinViews: 120}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.ev.Matches(tt.ep); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	d := NewDispatcher(&DispatcherOpts{
+		BaseBackoff: time.Second,
+		MaxBackoff:  10 * time.Second,
+	})
+	for attempt, max := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 10 * time.Second,
+		9: 10 * time.Second,
+	} {
+		for i := 0; i < 100; i++ {
+			b := d.backoff(attempt)
+			if b < max/2 || b > max {
+				t.Fatalf("attempt %d: expected backoff in [%s, %s], got %s", attempt, max/2, max, b)
+			}
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+	var got *Payload
+	status := http.StatusNoContent
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		h := &helix.WebhookHeaders{
+			ID:        r.Header.Get(HeaderID),
+			Timestamp: r.Header.Get(HeaderTimestamp),
+			Signature: r.Header.Get(HeaderSignature),
+			Type:      r.Header.Get(HeaderType),
+			Body:      body,
+		}
+		if !h.Valid([]byte("secret")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		got = &Payload{}
+		if err := json.Unmarshal(body, got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer sv.Close()
+
+	d := NewDispatcher(&DispatcherOpts{Client: sv.Client()})
+	ev := NewClipEvent(&helix.Clip{ClipID: "a", BroadcasterID: "58753574", ViewCount: 120}, 90)
+	ep := &model.WebhookEndpoints{URL: sv.URL, Secret: "secret", Enabled: true, MinViews: 100}
+	body, err := ev.Body(ep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl := &delivery{ev: ev, ep: ep, attempt: 1, body: body}
+
+	code, err := d.send(dl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, code)
+	}
+	if got == nil || got.ID != ev.ID || got.Type != EventClipThresholdReached ||
+		got.Clip == nil || got.Clip.ClipID != "a" || got.MinViews == nil || *got.MinViews != 100 {
+		t.Fatalf("unexpected payload %+v", got)
+	}
+
+	ep.Secret = "wrong"
+	if code, err := d.send(dl); err == nil || code != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized error, got %d %v", code, err)
+	}
+}